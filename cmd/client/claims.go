@@ -0,0 +1,112 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"text/tabwriter"
+
+	"github.com/seb-schulz/onegate/internal/auth"
+	"github.com/seb-schulz/onegate/internal/config"
+	"github.com/seb-schulz/onegate/internal/database"
+	"github.com/spf13/cobra"
+	"golang.org/x/exp/maps"
+)
+
+var (
+	clearClaims bool
+	scope       string
+)
+
+func init() {
+	clientCmd.AddCommand(claimsCmd)
+	addClaimMappingFlags(claimsCmd)
+	claimsCmd.Flags().BoolVar(&clearClaims, "clear", false, "Remove all claim mappings")
+
+	clientCmd.AddCommand(previewClaimsCmd)
+	previewClaimsCmd.Flags().StringVar(&scope, "scope", "openid profile groups roles", "Scope requested by the client")
+}
+
+var claimsCmd = &cobra.Command{
+	Use:   "claims <client-id>",
+	Short: "Show or replace claim mappings of client",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		db, err := database.Open(database.WithDebug(debug))
+		if err != nil {
+			return err
+		}
+
+		client := auth.Client{}
+		if r := db.Where("id = ?", args[0]).First(&client); r.Error != nil {
+			return fmt.Errorf(errRetrieveClientFormat, r.Error)
+		}
+
+		if clearClaims || cmd.Flags().Changed("claim") {
+			mappings, err := claimMappings()
+			if err != nil {
+				return fmt.Errorf("invalid claim mapping: %v", err)
+			}
+			if clearClaims {
+				mappings = auth.ClaimMappings{}
+			}
+
+			client.InternalClaimMappings = mappings
+			if r := db.Model(&client).Select("InternalClaimMappings").Updates(&client); r.Error != nil {
+				return fmt.Errorf("cannot update claim mappings: %v", r.Error)
+			}
+		}
+
+		names := maps.Keys(client.ClaimMappings())
+		slices.Sort(names)
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+		fmt.Fprintln(w, "Claim\tTemplate")
+		for _, name := range names {
+			fmt.Fprintf(w, "%s\t%s\n", name, client.ClaimMappings()[name])
+		}
+		w.Flush()
+		return nil
+	},
+}
+
+var previewClaimsCmd = &cobra.Command{
+	Use:   "preview-claims <client-id> <user-id>",
+	Short: "Preview userinfo claims of user for client",
+	Args:  cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		userID, err := strconv.ParseUint(args[1], 10, 0)
+		if err != nil {
+			return fmt.Errorf("invalid user ID: %v", err)
+		}
+
+		db, err := database.Open(database.WithDebug(debug))
+		if err != nil {
+			return err
+		}
+
+		client := auth.Client{}
+		if r := db.Where("id = ?", args[0]).First(&client); r.Error != nil {
+			return fmt.Errorf(errRetrieveClientFormat, r.Error)
+		}
+
+		claims, err := auth.PreviewClaims(database.WithContext(context.Background(), db), &auth.Config{
+			SubjectSalt: config.Config.Auth.SubjectSalt,
+			GroupsClaim: string(config.Config.Auth.GroupsClaim),
+			RolesClaim:  string(config.Config.Auth.RolesClaim),
+		}, &client, uint(userID), scope)
+		if err != nil {
+			return fmt.Errorf("cannot preview claims: %v", err)
+		}
+
+		b, err := json.MarshalIndent(claims, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(b))
+		return nil
+	},
+}
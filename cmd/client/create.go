@@ -3,21 +3,79 @@ package client
 import (
 	"context"
 	"fmt"
+	"strings"
 
 	"github.com/seb-schulz/onegate/internal/auth"
+	"github.com/seb-schulz/onegate/internal/config"
 	"github.com/seb-schulz/onegate/internal/database"
+	"github.com/seb-schulz/onegate/internal/model"
 	"github.com/spf13/cobra"
 )
 
 var (
 	description string
 	redirectURI string
+	minAcr      string
+	subjectType string
+	sectorID    string
+	audiences   []string
+	scopes      []string
+	policy      auth.AccessPolicy
+	timeWindows []string
+	claims      []string
+	logoutURI   string
 )
 
+func addClaimMappingFlags(cmd *cobra.Command) {
+	cmd.Flags().StringArrayVar(&claims, "claim", nil, "Claim rendered from template like \"username={{.User.Name}}\"")
+}
+
+// claimMappings returns the claim mappings given by flags.
+func claimMappings() (auth.ClaimMappings, error) {
+	cm := auth.ClaimMappings{}
+	for _, s := range claims {
+		name, text, ok := strings.Cut(s, "=")
+		if !ok {
+			return nil, fmt.Errorf("missing template of claim %#v", s)
+		}
+		cm[name] = text
+	}
+	return cm, cm.Validate()
+}
+
+func addAccessPolicyFlags(cmd *cobra.Command) {
+	cmd.Flags().UintSliceVar(&policy.UserIDs, "allowed-user", nil, "ID of user who may log in (defaults to all users)")
+	cmd.Flags().StringSliceVar(&policy.Groups, "allowed-group", nil, "Group whose members may log in (defaults to all users)")
+	cmd.Flags().StringSliceVar(&policy.Amr, "required-amr", nil, "Authentication method required to log in, e.g. hwk for hardware keys")
+	cmd.Flags().StringArrayVar(&timeWindows, "time-window", nil, "Time window to log in like \"mon-fri 08:00-18:00 Europe/Berlin\"")
+}
+
+// accessPolicy returns the access policy given by flags.
+func accessPolicy() (auth.AccessPolicy, error) {
+	p := policy
+	p.TimeWindows = nil
+	for _, s := range timeWindows {
+		tw, err := auth.ParseTimeWindow(s)
+		if err != nil {
+			return p, err
+		}
+		p.TimeWindows = append(p.TimeWindows, tw)
+	}
+	return p, p.Validate()
+}
+
 func init() {
 	clientCmd.AddCommand(createCmd)
 	createCmd.Flags().StringVar(&description, "desc", "", "Summery about purpose of this client")
 	createCmd.Flags().StringVarP(&redirectURI, "redirect-url", "u", "", "URI callback used by oAuth2/OIDC")
+	createCmd.Flags().StringVar(&minAcr, "min-acr", "", "Minimum authentication context class required to log in")
+	createCmd.Flags().StringVar(&subjectType, "subject-type", auth.SubjectTypePublic, "Subject identifier type (public or pairwise)")
+	createCmd.Flags().StringVar(&sectorID, "sector-identifier", "", "Sector of pairwise subjects (defaults to host of redirect URI)")
+	createCmd.Flags().StringSliceVar(&audiences, "exchange-audience", nil, "Audience the client may request by token exchange")
+	createCmd.Flags().StringSliceVar(&scopes, "exchange-scope", nil, "Scope the client may request by token exchange")
+	createCmd.Flags().StringVar(&logoutURI, "backchannel-logout-uri", "", "URI receiving logout tokens when users are deleted")
+	addAccessPolicyFlags(createCmd)
+	addClaimMappingFlags(createCmd)
 }
 
 var createCmd = &cobra.Command{
@@ -30,13 +88,35 @@ var createCmd = &cobra.Command{
 		if redirectURI == "" {
 			return fmt.Errorf("client must contain a redirect URI")
 		}
+		if minAcr != "" && model.AcrLevel(minAcr) < 0 {
+			return fmt.Errorf("unknown authentication context class: %v", minAcr)
+		}
+		if subjectType != auth.SubjectTypePublic && subjectType != auth.SubjectTypePairwise {
+			return fmt.Errorf("unknown subject type: %v", subjectType)
+		}
+		if subjectType == auth.SubjectTypePairwise && len(config.Config.Auth.SubjectSalt) == 0 {
+			return fmt.Errorf("pairwise subjects require auth.subjectSalt to be configured")
+		}
+		if logoutURI != "" && !strings.HasPrefix(logoutURI, "https://") && !strings.HasPrefix(logoutURI, "http://") {
+			return fmt.Errorf("back-channel logout URI must be an absolute URL")
+		}
+
+		policy, err := accessPolicy()
+		if err != nil {
+			return fmt.Errorf("invalid access policy: %v", err)
+		}
+
+		mappings, err := claimMappings()
+		if err != nil {
+			return fmt.Errorf("invalid claim mapping: %v", err)
+		}
 
 		db, err := database.Open(database.WithDebug(debug))
 		if err != nil {
 			return err
 		}
 
-		clientID, clientSecret, err := auth.CreateClient(database.WithContext(context.Background(), db), auth.NewClientSecretHasher(), description, redirectURI)
+		clientID, clientSecret, err := auth.CreateClient(database.WithContext(context.Background(), db), auth.NewClientSecretHasher(), description, redirectURI, auth.WithMinAcr(minAcr), auth.WithSubjectType(subjectType, sectorID), auth.WithTokenExchange(audiences, scopes), auth.WithAccessPolicy(policy), auth.WithClaimMappings(mappings), auth.WithBackchannelLogoutURI(logoutURI))
 		if err != nil {
 			return fmt.Errorf("cannot create client: %v", err)
 		}
@@ -31,7 +31,7 @@ var listCmd = &cobra.Command{
 		}
 
 		w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
-		fmt.Fprintln(w, "ClientID\tDescription\tRedirectURI\tUpdated at\tCreated at\tDeleted at")
+		fmt.Fprintln(w, "ClientID\tDescription\tRedirectURI\tMin ACR\tUpdated at\tCreated at\tDeleted at")
 		for _, client := range clients {
 			deletedAt, _ := client.DeletedAt.Value()
 			deletedAtStr := ""
@@ -39,7 +39,7 @@ var listCmd = &cobra.Command{
 				deletedAtStr = deletedAt.(time.Time).Format(time.DateOnly)
 			}
 
-			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", client.ClientID(), client.Description, client.RedirectURI(), client.CreatedAt.Format(time.DateOnly), client.UpdatedAt.Format(time.DateOnly), deletedAtStr)
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n", client.ClientID(), client.Description, client.RedirectURI(), client.MinAcr(), client.CreatedAt.Format(time.DateOnly), client.UpdatedAt.Format(time.DateOnly), deletedAtStr)
 		}
 		w.Flush()
 		return nil
@@ -0,0 +1,72 @@
+package client
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/seb-schulz/onegate/internal/auth"
+	"github.com/seb-schulz/onegate/internal/database"
+	"github.com/spf13/cobra"
+)
+
+var clearPolicy bool
+
+func init() {
+	clientCmd.AddCommand(policyCmd)
+	addAccessPolicyFlags(policyCmd)
+	policyCmd.Flags().BoolVar(&clearPolicy, "clear", false, "Allow all users to log in")
+}
+
+func printAccessPolicy(p auth.AccessPolicy) {
+	if p.IsEmpty() {
+		fmt.Println("All users may log in.")
+		return
+	}
+
+	fmt.Printf("User IDs: %s\n", strings.Trim(fmt.Sprint(p.UserIDs), "[]"))
+	fmt.Printf("Groups: %s\n", strings.Join(p.Groups, ", "))
+	fmt.Printf("Required AMR: %s\n", strings.Join(p.Amr, ", "))
+	for _, tw := range p.TimeWindows {
+		fmt.Printf("Time window: %s\n", tw)
+	}
+}
+
+var policyCmd = &cobra.Command{
+	Use:   "policy <client-id>",
+	Short: "Show or replace access policy of client",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		db, err := database.Open(database.WithDebug(debug))
+		if err != nil {
+			return err
+		}
+
+		client := auth.Client{}
+		if r := db.Where("id = ?", args[0]).First(&client); r.Error != nil {
+			return fmt.Errorf(errRetrieveClientFormat, r.Error)
+		}
+
+		changed := clearPolicy
+		for _, name := range []string{"allowed-user", "allowed-group", "required-amr", "time-window"} {
+			changed = changed || cmd.Flags().Changed(name)
+		}
+
+		if changed {
+			policy, err := accessPolicy()
+			if err != nil {
+				return fmt.Errorf("invalid access policy: %v", err)
+			}
+			if clearPolicy {
+				policy = auth.AccessPolicy{}
+			}
+
+			client.InternalAccessPolicy = policy
+			if r := db.Model(&client).Select("InternalAccessPolicy").Updates(&client); r.Error != nil {
+				return fmt.Errorf("cannot update access policy: %v", r.Error)
+			}
+		}
+
+		printAccessPolicy(client.AccessPolicy())
+		return nil
+	},
+}
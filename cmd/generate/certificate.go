@@ -0,0 +1,49 @@
+package generate
+
+import (
+	"encoding/pem"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/seb-schulz/onegate/internal/auth"
+	"github.com/spf13/cobra"
+)
+
+var (
+	commonName string
+	validFor   time.Duration
+)
+
+func init() {
+	generateCmd.AddCommand(certificateCmd)
+	certificateCmd.Flags().StringVar(&commonName, "cn", "onegate", "Common name of the certificate")
+	certificateCmd.Flags().DurationVar(&validFor, "valid-for", 3*365*24*time.Hour, "Validity period of the certificate")
+}
+
+var certificateCmd = &cobra.Command{
+	Use:     "certificate",
+	Aliases: []string{"cert"},
+	Short:   "Self-sign private key from stdin to sign SAML assertions",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		rawPrivKey, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			return fmt.Errorf("failed to read key: %w", err)
+		}
+
+		privateKey, err := jwt.ParseECPrivateKeyFromPEM(rawPrivKey)
+		if err != nil {
+			return fmt.Errorf("failed to parse private key: %w", err)
+		}
+
+		cert, err := auth.NewSAMLCertificate(privateKey, commonName, validFor)
+		if err != nil {
+			return fmt.Errorf("cannot create certificate: %w", err)
+		}
+		pem.Encode(os.Stdout, &pem.Block{Type: "CERTIFICATE", Bytes: cert})
+
+		return nil
+	},
+}
@@ -0,0 +1,36 @@
+package group
+
+import (
+	"fmt"
+
+	"github.com/seb-schulz/onegate/internal/database"
+	"github.com/seb-schulz/onegate/internal/model"
+	"github.com/spf13/cobra"
+)
+
+var description string
+
+func init() {
+	groupCmd.AddCommand(createCmd)
+	createCmd.Flags().StringVar(&description, "desc", "", "Summery about purpose of this group")
+}
+
+var createCmd = &cobra.Command{
+	Use:   "create <name>",
+	Short: "Create group",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		db, err := database.Open(database.WithDebug(debug))
+		if err != nil {
+			return err
+		}
+
+		group, err := model.CreateGroup(db, args[0], description)
+		if err != nil {
+			return fmt.Errorf("cannot create group: %v", err)
+		}
+
+		fmt.Printf("Group: %s\n", group.Name)
+		return nil
+	},
+}
@@ -0,0 +1,36 @@
+package group
+
+import (
+	"fmt"
+
+	"github.com/seb-schulz/onegate/internal/database"
+	"github.com/seb-schulz/onegate/internal/model"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	groupCmd.AddCommand(deleteCmd)
+}
+
+var deleteCmd = &cobra.Command{
+	Use:     "delete <name>",
+	Short:   "Delete group and its memberships",
+	Aliases: []string{"del", "rm"},
+	Args:    cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		db, err := database.Open(database.WithDebug(debug))
+		if err != nil {
+			return err
+		}
+
+		group, err := model.GroupByName(db, args[0])
+		if err != nil {
+			return fmt.Errorf(errRetrieveGroupFormat, err)
+		}
+
+		if err := model.DeleteGroup(db, group); err != nil {
+			return fmt.Errorf("cannot delete group: %v", err)
+		}
+		return nil
+	},
+}
@@ -0,0 +1,25 @@
+package group
+
+import (
+	"github.com/seb-schulz/onegate/cmd"
+	"github.com/spf13/cobra"
+)
+
+const (
+	errRetrieveGroupFormat = "cannot retrieve group: %v"
+	errRetrieveUserFormat  = "cannot retrieve user: %v"
+)
+
+var (
+	debug bool
+)
+
+func init() {
+	cmd.RootCmd.AddCommand(groupCmd)
+	groupCmd.PersistentFlags().BoolVarP(&debug, "debug", "d", false, "Verbose output")
+}
+
+var groupCmd = &cobra.Command{
+	Use:   "group",
+	Short: "Operate with groups of users",
+}
@@ -0,0 +1,41 @@
+package group
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/seb-schulz/onegate/internal/database"
+	"github.com/seb-schulz/onegate/internal/model"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	groupCmd.AddCommand(listCmd)
+}
+
+var listCmd = &cobra.Command{
+	Use:     "list",
+	Aliases: []string{"ls"},
+	Short:   "List all groups with their members",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		db, err := database.Open(database.WithDebug(debug))
+		if err != nil {
+			return err
+		}
+
+		groups := []model.Group{}
+		if r := db.Preload("Members").Find(&groups); r.Error != nil {
+			return fmt.Errorf(errRetrieveGroupFormat, r.Error)
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+		fmt.Fprintln(w, "Name\tDescription\tMembers\tUpdated at\tCreated at")
+		for _, group := range groups {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", group.Name, group.Description, memberList(group.Members), group.UpdatedAt.Format(time.DateOnly), group.CreatedAt.Format(time.DateOnly))
+		}
+		w.Flush()
+		return nil
+	},
+}
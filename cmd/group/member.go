@@ -0,0 +1,83 @@
+package group
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/seb-schulz/onegate/internal/database"
+	"github.com/seb-schulz/onegate/internal/model"
+	"github.com/spf13/cobra"
+	"gorm.io/gorm"
+)
+
+func init() {
+	groupCmd.AddCommand(addMemberCmd)
+	groupCmd.AddCommand(removeMemberCmd)
+}
+
+func memberList(users []model.User) string {
+	names := []string{}
+	for _, u := range users {
+		names = append(names, fmt.Sprintf("%s (%d)", u.Name, u.ID))
+	}
+	return strings.Join(names, ", ")
+}
+
+// groupAndUser returns the group by name and the user by ID.
+func groupAndUser(db *gorm.DB, args []string) (*model.Group, *model.User, error) {
+	group, err := model.GroupByName(db, args[0])
+	if err != nil {
+		return nil, nil, fmt.Errorf(errRetrieveGroupFormat, err)
+	}
+
+	user := model.User{}
+	if r := db.Where("id = ?", args[1]).First(&user); r.Error != nil {
+		return nil, nil, fmt.Errorf(errRetrieveUserFormat, r.Error)
+	}
+	return group, &user, nil
+}
+
+var addMemberCmd = &cobra.Command{
+	Use:   "add-member <name> <user-id>",
+	Short: "Add user to group",
+	Args:  cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		db, err := database.Open(database.WithDebug(debug))
+		if err != nil {
+			return err
+		}
+
+		group, user, err := groupAndUser(db, args)
+		if err != nil {
+			return err
+		}
+
+		if err := group.AddMember(db, user); err != nil {
+			return fmt.Errorf("cannot add member: %v", err)
+		}
+		return nil
+	},
+}
+
+var removeMemberCmd = &cobra.Command{
+	Use:     "remove-member <name> <user-id>",
+	Short:   "Remove user from group",
+	Aliases: []string{"rm-member"},
+	Args:    cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		db, err := database.Open(database.WithDebug(debug))
+		if err != nil {
+			return err
+		}
+
+		group, user, err := groupAndUser(db, args)
+		if err != nil {
+			return err
+		}
+
+		if err := group.RemoveMember(db, user); err != nil {
+			return fmt.Errorf("cannot remove member: %v", err)
+		}
+		return nil
+	},
+}
@@ -6,7 +6,10 @@ import (
 	"github.com/seb-schulz/onegate/internal/auth"
 	"github.com/seb-schulz/onegate/internal/config"
 	"github.com/seb-schulz/onegate/internal/database"
+	"github.com/seb-schulz/onegate/internal/invitation"
 	"github.com/seb-schulz/onegate/internal/model"
+	"github.com/seb-schulz/onegate/internal/pow"
+	"github.com/seb-schulz/onegate/internal/recovery"
 	"github.com/spf13/cobra"
 )
 
@@ -23,7 +26,19 @@ var migrateCmd = &cobra.Command{
 			return err
 		}
 
-		if err := db.AutoMigrate(model.User{}, model.Credential{}, model.Session{}, model.AuthSession{}, auth.Client{}, auth.Authorization{}); err != nil {
+		if err := auth.MigrateTransactionIDs(db); err != nil {
+			return fmt.Errorf("migration failed: %v", err)
+		}
+
+		if err := db.AutoMigrate(model.User{}, model.Credential{}, model.Session{}, model.AuthSession{}, model.Group{}, model.Role{}, model.EmailAddress{}, model.UsedLoginToken{}, auth.Client{}, auth.Authorization{}, auth.IssuedToken{}, auth.Resource{}, auth.ServiceProvider{}, auth.SAMLRequest{}, invitation.Redemption{}, pow.SolvedChallenge{}, recovery.Code{}); err != nil {
+			return fmt.Errorf("migration failed: %v", err)
+		}
+
+		if err := auth.MigrateAccessPolicies(db); err != nil {
+			return fmt.Errorf("migration failed: %v", err)
+		}
+
+		if err := model.MigrateUniqueNames(db); err != nil {
 			return fmt.Errorf("migration failed: %v", err)
 		}
 
@@ -0,0 +1,44 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/seb-schulz/onegate/internal/config"
+	"github.com/seb-schulz/onegate/internal/proxy"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	RootCmd.AddCommand(proxyCmd)
+}
+
+func runProxyCmd(cmd *cobra.Command, args []string) error {
+	if config.Config.PrivateAuthKey == nil {
+		return fmt.Errorf("private auth key not defined")
+	}
+
+	c := proxy.Config{
+		HttpPort:        config.Config.Proxy.HttpPort,
+		IssuerUrl:       config.Config.BaseUrl,
+		PublicKey:       &config.Config.PrivateAuthKey.PublicKey,
+		ClientID:        config.Config.Proxy.ClientID,
+		ClientSecret:    string(config.Config.Proxy.ClientSecret),
+		RedirectUrl:     config.Config.Proxy.RedirectUrl,
+		CookieName:      config.Config.Proxy.CookieName,
+		CookieKey:       config.Config.Proxy.CookieKey,
+		CookieDomain:    config.Config.Proxy.CookieDomain,
+		SessionLifetime: config.Config.Proxy.SessionLifetime,
+	}
+
+	for _, u := range config.Config.Proxy.Upstreams {
+		c.Upstreams = append(c.Upstreams, proxy.Upstream{Host: string(u.Host), URL: u.URL})
+	}
+
+	return proxy.Serve(&c)
+}
+
+var proxyCmd = &cobra.Command{
+	Use:   "proxy",
+	Short: "Run reverse proxy authenticating users with onegate",
+	RunE:  runProxyCmd,
+}
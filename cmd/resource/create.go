@@ -0,0 +1,48 @@
+package resource
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/seb-schulz/onegate/internal/auth"
+	"github.com/seb-schulz/onegate/internal/database"
+	"github.com/spf13/cobra"
+)
+
+var (
+	description string
+	scopes      []string
+	expiresIn   time.Duration
+)
+
+func init() {
+	resourceCmd.AddCommand(createCmd)
+	createCmd.Flags().StringVar(&description, "desc", "", "Summery about purpose of this resource")
+	createCmd.Flags().StringSliceVar(&scopes, "scope", nil, "Scope defined by the resource")
+	createCmd.Flags().DurationVar(&expiresIn, "expires-in", 0, "Lifetime of access tokens (defaults to configured lifetime)")
+}
+
+var createCmd = &cobra.Command{
+	Use:   "create <identifier>",
+	Short: "Register resource server by its identifier URI",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if description == "" {
+			return fmt.Errorf("resource must contain a description")
+		}
+
+		db, err := database.Open(database.WithDebug(debug))
+		if err != nil {
+			return err
+		}
+
+		resource, err := auth.CreateResource(database.WithContext(context.Background(), db), args[0], description, scopes, expiresIn)
+		if err != nil {
+			return fmt.Errorf("cannot create resource: %v", err)
+		}
+
+		fmt.Printf("Resource: %s\n", resource.Identifier)
+		return nil
+	},
+}
@@ -0,0 +1,42 @@
+package resource
+
+import (
+	"fmt"
+
+	"github.com/seb-schulz/onegate/internal/auth"
+	"github.com/seb-schulz/onegate/internal/database"
+	"github.com/spf13/cobra"
+)
+
+var force bool
+
+func init() {
+	resourceCmd.AddCommand(deleteCmd)
+	deleteCmd.Flags().BoolVarP(&force, "force", "f", false, "Remove entry without consideration of soft-deletion flag")
+}
+
+var deleteCmd = &cobra.Command{
+	Use:     "delete <identifier>",
+	Short:   "Soft-delete resource server",
+	Aliases: []string{"del", "rm"},
+	Args:    cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		db, err := database.Open(database.WithDebug(debug))
+		if err != nil {
+			return err
+		}
+
+		tx := db
+		if force {
+			tx = tx.Unscoped()
+		}
+
+		resource := auth.Resource{}
+		if r := tx.Where("identifier = ?", args[0]).First(&resource); r.Error != nil {
+			return fmt.Errorf(errRetrieveResourceFormat, r.Error)
+		}
+		tx.Delete(&resource)
+
+		return nil
+	},
+}
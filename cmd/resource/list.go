@@ -0,0 +1,53 @@
+package resource
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/seb-schulz/onegate/internal/auth"
+	"github.com/seb-schulz/onegate/internal/database"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	resourceCmd.AddCommand(listCmd)
+}
+
+var listCmd = &cobra.Command{
+	Use:     "list",
+	Aliases: []string{"ls"},
+	Short:   "List all resource servers",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		db, err := database.Open(database.WithDebug(debug))
+		if err != nil {
+			return err
+		}
+
+		resources := []auth.Resource{}
+		if r := db.Unscoped().Find(&resources); r.Error != nil {
+			return fmt.Errorf(errRetrieveResourceFormat, r.Error)
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+		fmt.Fprintln(w, "Identifier\tDescription\tScopes\tToken lifetime\tUpdated at\tCreated at\tDeleted at")
+		for _, resource := range resources {
+			deletedAt, _ := resource.DeletedAt.Value()
+			deletedAtStr := ""
+			if deletedAt != nil {
+				deletedAtStr = deletedAt.(time.Time).Format(time.DateOnly)
+			}
+
+			expiresIn := "default"
+			if resource.AccessTokenExpiresIn > 0 {
+				expiresIn = resource.AccessTokenExpiresIn.String()
+			}
+
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n", resource.Identifier, resource.Description, strings.Join(resource.Scopes, " "), expiresIn, resource.UpdatedAt.Format(time.DateOnly), resource.CreatedAt.Format(time.DateOnly), deletedAtStr)
+		}
+		w.Flush()
+		return nil
+	},
+}
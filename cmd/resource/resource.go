@@ -0,0 +1,24 @@
+package resource
+
+import (
+	"github.com/seb-schulz/onegate/cmd"
+	"github.com/spf13/cobra"
+)
+
+const (
+	errRetrieveResourceFormat = "cannot retrieve resource: %v"
+)
+
+var (
+	debug bool
+)
+
+func init() {
+	cmd.RootCmd.AddCommand(resourceCmd)
+	resourceCmd.PersistentFlags().BoolVarP(&debug, "debug", "d", false, "Verbose output")
+}
+
+var resourceCmd = &cobra.Command{
+	Use:   "resource",
+	Short: "Operate with resource servers",
+}
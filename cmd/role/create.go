@@ -0,0 +1,36 @@
+package role
+
+import (
+	"fmt"
+
+	"github.com/seb-schulz/onegate/internal/database"
+	"github.com/seb-schulz/onegate/internal/model"
+	"github.com/spf13/cobra"
+)
+
+var description string
+
+func init() {
+	roleCmd.AddCommand(createCmd)
+	createCmd.Flags().StringVar(&description, "desc", "", "Summery about purpose of this role")
+}
+
+var createCmd = &cobra.Command{
+	Use:   "create <name>",
+	Short: "Create role",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		db, err := database.Open(database.WithDebug(debug))
+		if err != nil {
+			return err
+		}
+
+		role, err := model.CreateRole(db, args[0], description)
+		if err != nil {
+			return fmt.Errorf("cannot create role: %v", err)
+		}
+
+		fmt.Printf("Role: %s\n", role.Name)
+		return nil
+	},
+}
@@ -0,0 +1,36 @@
+package role
+
+import (
+	"fmt"
+
+	"github.com/seb-schulz/onegate/internal/database"
+	"github.com/seb-schulz/onegate/internal/model"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	roleCmd.AddCommand(deleteCmd)
+}
+
+var deleteCmd = &cobra.Command{
+	Use:     "delete <name>",
+	Short:   "Delete role and its memberships",
+	Aliases: []string{"del", "rm"},
+	Args:    cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		db, err := database.Open(database.WithDebug(debug))
+		if err != nil {
+			return err
+		}
+
+		role, err := model.RoleByName(db, args[0])
+		if err != nil {
+			return fmt.Errorf(errRetrieveRoleFormat, err)
+		}
+
+		if err := model.DeleteRole(db, role); err != nil {
+			return fmt.Errorf("cannot delete role: %v", err)
+		}
+		return nil
+	},
+}
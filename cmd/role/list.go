@@ -0,0 +1,41 @@
+package role
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/seb-schulz/onegate/internal/database"
+	"github.com/seb-schulz/onegate/internal/model"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	roleCmd.AddCommand(listCmd)
+}
+
+var listCmd = &cobra.Command{
+	Use:     "list",
+	Aliases: []string{"ls"},
+	Short:   "List all roles with their members",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		db, err := database.Open(database.WithDebug(debug))
+		if err != nil {
+			return err
+		}
+
+		roles := []model.Role{}
+		if r := db.Preload("Members").Find(&roles); r.Error != nil {
+			return fmt.Errorf(errRetrieveRoleFormat, r.Error)
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+		fmt.Fprintln(w, "Name\tDescription\tMembers\tUpdated at\tCreated at")
+		for _, role := range roles {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", role.Name, role.Description, memberList(role.Members), role.UpdatedAt.Format(time.DateOnly), role.CreatedAt.Format(time.DateOnly))
+		}
+		w.Flush()
+		return nil
+	},
+}
@@ -0,0 +1,83 @@
+package role
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/seb-schulz/onegate/internal/database"
+	"github.com/seb-schulz/onegate/internal/model"
+	"github.com/spf13/cobra"
+	"gorm.io/gorm"
+)
+
+func init() {
+	roleCmd.AddCommand(addMemberCmd)
+	roleCmd.AddCommand(removeMemberCmd)
+}
+
+func memberList(users []model.User) string {
+	names := []string{}
+	for _, u := range users {
+		names = append(names, fmt.Sprintf("%s (%d)", u.Name, u.ID))
+	}
+	return strings.Join(names, ", ")
+}
+
+// roleAndUser returns the role by name and the user by ID.
+func roleAndUser(db *gorm.DB, args []string) (*model.Role, *model.User, error) {
+	role, err := model.RoleByName(db, args[0])
+	if err != nil {
+		return nil, nil, fmt.Errorf(errRetrieveRoleFormat, err)
+	}
+
+	user := model.User{}
+	if r := db.Where("id = ?", args[1]).First(&user); r.Error != nil {
+		return nil, nil, fmt.Errorf(errRetrieveUserFormat, r.Error)
+	}
+	return role, &user, nil
+}
+
+var addMemberCmd = &cobra.Command{
+	Use:   "add-member <name> <user-id>",
+	Short: "Add user to role",
+	Args:  cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		db, err := database.Open(database.WithDebug(debug))
+		if err != nil {
+			return err
+		}
+
+		role, user, err := roleAndUser(db, args)
+		if err != nil {
+			return err
+		}
+
+		if err := role.AddMember(db, user); err != nil {
+			return fmt.Errorf("cannot add member: %v", err)
+		}
+		return nil
+	},
+}
+
+var removeMemberCmd = &cobra.Command{
+	Use:     "remove-member <name> <user-id>",
+	Short:   "Remove user from role",
+	Aliases: []string{"rm-member"},
+	Args:    cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		db, err := database.Open(database.WithDebug(debug))
+		if err != nil {
+			return err
+		}
+
+		role, user, err := roleAndUser(db, args)
+		if err != nil {
+			return err
+		}
+
+		if err := role.RemoveMember(db, user); err != nil {
+			return fmt.Errorf("cannot remove member: %v", err)
+		}
+		return nil
+	},
+}
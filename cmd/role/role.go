@@ -0,0 +1,25 @@
+package role
+
+import (
+	"github.com/seb-schulz/onegate/cmd"
+	"github.com/spf13/cobra"
+)
+
+const (
+	errRetrieveRoleFormat = "cannot retrieve role: %v"
+	errRetrieveUserFormat = "cannot retrieve user: %v"
+)
+
+var (
+	debug bool
+)
+
+func init() {
+	cmd.RootCmd.AddCommand(roleCmd)
+	roleCmd.PersistentFlags().BoolVarP(&debug, "debug", "d", false, "Verbose output")
+}
+
+var roleCmd = &cobra.Command{
+	Use:   "role",
+	Short: "Operate with roles of users",
+}
@@ -2,8 +2,14 @@ package cmd
 
 import (
 	"github.com/go-webauthn/webauthn/webauthn"
+	"github.com/seb-schulz/onegate/graph"
 	"github.com/seb-schulz/onegate/internal/auth"
 	"github.com/seb-schulz/onegate/internal/config"
+	"github.com/seb-schulz/onegate/internal/invitation"
+	"github.com/seb-schulz/onegate/internal/mail"
+	"github.com/seb-schulz/onegate/internal/model"
+	"github.com/seb-schulz/onegate/internal/pow"
+	"github.com/seb-schulz/onegate/internal/scim"
 	"github.com/seb-schulz/onegate/internal/server"
 	"github.com/spf13/cobra"
 )
@@ -13,6 +19,11 @@ func init() {
 }
 
 func runServeCmd(cmd *cobra.Command, args []string) error {
+	loginMode, err := graph.ParseLoginMode(string(config.Config.RelyingParty.LoginMode))
+	if err != nil {
+		return err
+	}
+
 	c := server.ServerConfig{
 		Router: server.RouterConfig{
 			DbDebug: config.Config.DB.Debug,
@@ -25,15 +36,62 @@ func runServeCmd(cmd *cobra.Command, args []string) error {
 				RequestLimit: config.Config.Server.Limit.RequestLimit, WindowLength: config.Config.Server.Limit.WindowLength,
 			},
 			SessionKey:              []byte(config.Config.Session.Key),
+			SessionCookieDomain:     config.Config.Session.CookieDomain,
+			ReauthenticateWithin:    config.Config.Session.ReauthenticateWithin,
 			UserRegistrationEnabled: config.Config.Features.UserRegistration,
-			Login: server.LoginConfig{
+			ProofOfWork: pow.Config{
+				Key:        []byte(config.Config.Session.Key),
+				Difficulty: config.Config.ProofOfWork.Difficulty,
+				ExpiresIn:  config.Config.ProofOfWork.ExpiresIn,
+			},
+			RegistrationApproval: config.Config.Features.RegistrationApproval,
+			ApprovalWebhook:      config.Config.Features.ApprovalWebhook,
+			Invitation: invitation.Config{
 				Key:          config.Config.UrlLogin.Key,
 				ValidMethods: config.Config.UrlLogin.ValidMethods,
-				BaseUrl:      *config.Config.BaseUrl.JoinPath("login"),
+				BaseUrl:      config.Config.BaseUrl,
+			},
+			AdminGroup:        string(config.Config.Features.AdminGroup),
+			ProfileAttributes: profileAttributes(),
+			Mail: mail.Config{
+				Transport: string(config.Config.Mail.Transport),
+				From:      config.Config.Mail.From,
+				Dir:       config.Config.Mail.Dir,
+				SMTP: mail.SMTPConfig{
+					Host:     config.Config.Mail.SMTP.Host,
+					Port:     config.Config.Mail.SMTP.Port,
+					Username: config.Config.Mail.SMTP.Username,
+					Password: string(config.Config.Mail.SMTP.Password),
+				},
+			},
+			EmailVerificationUrl: *config.Config.BaseUrl.JoinPath("email", "verify"),
+			Login: server.LoginConfig{
+				Key:                  config.Config.UrlLogin.Key,
+				ValidMethods:         config.Config.UrlLogin.ValidMethods,
+				BaseUrl:              *config.Config.BaseUrl.JoinPath("login"),
+				Mode:                 loginMode,
+				ConditionalExpiresIn: config.Config.RelyingParty.ConditionalLoginExpiresIn,
 			},
 			Auth: auth.Config{
-				IssuerUrl:  config.Config.BaseUrl.String(),
-				PrivateKey: config.Config.PrivateAuthKey,
+				IssuerUrl:            config.Config.BaseUrl.String(),
+				PrivateKey:           config.Config.PrivateAuthKey,
+				SubjectSalt:          config.Config.Auth.SubjectSalt,
+				CodeExpiresIn:        config.Config.Auth.CodeExpiresIn,
+				RequestExpiresIn:     config.Config.Auth.RequestExpiresIn,
+				AccessTokenExpiresIn: config.Config.Auth.AccessTokenExpiresIn,
+				InitialAccessToken:   []byte(config.Config.Auth.InitialAccessToken),
+				RegistrationUrl:      *config.Config.BaseUrl.JoinPath("auth", "register"),
+				SAMLCertificate:      config.Config.Auth.SAMLCertificate,
+				SAMLMetadataUrl:      *config.Config.BaseUrl.JoinPath("auth", "saml", "metadata"),
+				SAMLSSOUrl:           *config.Config.BaseUrl.JoinPath("auth", "saml", "sso"),
+				LoginUrl:             *config.Config.BaseUrl.JoinPath("login"),
+				ForwardAuthRules:     forwardAuthRules(),
+				GroupsClaim:          string(config.Config.Auth.GroupsClaim),
+				RolesClaim:           string(config.Config.Auth.RolesClaim),
+			},
+			SCIM: scim.Config{
+				BaseUrl:     *config.Config.BaseUrl.JoinPath("scim", "v2"),
+				BearerToken: config.Config.SCIM.BearerToken,
 			},
 		},
 		HttpPort:  config.Config.Server.HttpPort,
@@ -43,6 +101,22 @@ func runServeCmd(cmd *cobra.Command, args []string) error {
 	return server.Serve(&c)
 }
 
+func forwardAuthRules() []auth.ForwardAuthRule {
+	rules := []auth.ForwardAuthRule{}
+	for _, rule := range config.Config.ForwardAuth.Rules {
+		rules = append(rules, auth.ForwardAuthRule{Host: string(rule.Host), UserIDs: rule.UserIDs})
+	}
+	return rules
+}
+
+func profileAttributes() []model.AttributeDefinition {
+	defs := []model.AttributeDefinition{}
+	for _, def := range config.Config.Profile.Attributes {
+		defs = append(defs, model.AttributeDefinition{Name: string(def.Name), Description: def.Description, Pattern: def.Pattern, MaxLength: def.MaxLength})
+	}
+	return defs
+}
+
 var serveCmd = &cobra.Command{
 	Use:   "serve",
 	Short: "Run server",
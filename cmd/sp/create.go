@@ -0,0 +1,55 @@
+package sp
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/seb-schulz/onegate/internal/auth"
+	"github.com/seb-schulz/onegate/internal/database"
+	"github.com/spf13/cobra"
+)
+
+var (
+	description  string
+	acsURLs      []string
+	nameIDFormat string
+	attributes   map[string]string
+)
+
+func init() {
+	spCmd.AddCommand(createCmd)
+	createCmd.Flags().StringVar(&description, "desc", "", "Summery about purpose of this service provider")
+	createCmd.Flags().StringSliceVar(&acsURLs, "acs-url", nil, "Assertion consumer service URL (first one is the default)")
+	createCmd.Flags().StringVar(&nameIDFormat, "name-id-format", "persistent", "Name ID format (persistent, transient or unspecified)")
+	createCmd.Flags().StringToStringVar(&attributes, "attribute", nil, fmt.Sprintf("Attribute name mapped to user data (%v)", strings.Join(auth.AttributeSources, ", ")))
+}
+
+var createCmd = &cobra.Command{
+	Use:   "create <entity ID>",
+	Short: "Register SAML service provider by its entity ID",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if description == "" {
+			return fmt.Errorf("service provider must contain a description")
+		}
+
+		format, ok := auth.NameIDFormats[nameIDFormat]
+		if !ok {
+			return fmt.Errorf("unknown name ID format: %v", nameIDFormat)
+		}
+
+		db, err := database.Open(database.WithDebug(debug))
+		if err != nil {
+			return err
+		}
+
+		sp, err := auth.CreateServiceProvider(database.WithContext(context.Background(), db), args[0], description, acsURLs, auth.WithNameIDFormat(format), auth.WithAttributes(attributes))
+		if err != nil {
+			return fmt.Errorf("cannot create service provider: %v", err)
+		}
+
+		fmt.Printf("Service provider: %s\n", sp.EntityID)
+		return nil
+	},
+}
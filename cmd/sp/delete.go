@@ -0,0 +1,42 @@
+package sp
+
+import (
+	"fmt"
+
+	"github.com/seb-schulz/onegate/internal/auth"
+	"github.com/seb-schulz/onegate/internal/database"
+	"github.com/spf13/cobra"
+)
+
+var force bool
+
+func init() {
+	spCmd.AddCommand(deleteCmd)
+	deleteCmd.Flags().BoolVarP(&force, "force", "f", false, "Remove entry without consideration of soft-deletion flag")
+}
+
+var deleteCmd = &cobra.Command{
+	Use:     "delete <entity ID>",
+	Short:   "Soft-delete SAML service provider",
+	Aliases: []string{"del", "rm"},
+	Args:    cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		db, err := database.Open(database.WithDebug(debug))
+		if err != nil {
+			return err
+		}
+
+		tx := db
+		if force {
+			tx = tx.Unscoped()
+		}
+
+		sp := auth.ServiceProvider{}
+		if r := tx.Where("entity_id = ?", args[0]).First(&sp); r.Error != nil {
+			return fmt.Errorf(errRetrieveServiceProviderFormat, r.Error)
+		}
+		tx.Delete(&sp)
+
+		return nil
+	},
+}
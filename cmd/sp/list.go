@@ -0,0 +1,55 @@
+package sp
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/seb-schulz/onegate/internal/auth"
+	"github.com/seb-schulz/onegate/internal/database"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	spCmd.AddCommand(listCmd)
+}
+
+var listCmd = &cobra.Command{
+	Use:     "list",
+	Aliases: []string{"ls"},
+	Short:   "List all SAML service providers",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		db, err := database.Open(database.WithDebug(debug))
+		if err != nil {
+			return err
+		}
+
+		sps := []auth.ServiceProvider{}
+		if r := db.Unscoped().Find(&sps); r.Error != nil {
+			return fmt.Errorf(errRetrieveServiceProviderFormat, r.Error)
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+		fmt.Fprintln(w, "Entity ID\tDescription\tACS URLs\tName ID format\tAttributes\tUpdated at\tCreated at\tDeleted at")
+		for _, sp := range sps {
+			deletedAt, _ := sp.DeletedAt.Value()
+			deletedAtStr := ""
+			if deletedAt != nil {
+				deletedAtStr = deletedAt.(time.Time).Format(time.DateOnly)
+			}
+
+			attributes := []string{}
+			for name, source := range sp.Attributes {
+				attributes = append(attributes, fmt.Sprintf("%s=%s", name, source))
+			}
+			slices.Sort(attributes)
+
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n", sp.EntityID, sp.Description, strings.Join(sp.ACSURLs, " "), sp.NameIDFormat, strings.Join(attributes, ","), sp.UpdatedAt.Format(time.DateOnly), sp.CreatedAt.Format(time.DateOnly), deletedAtStr)
+		}
+		w.Flush()
+		return nil
+	},
+}
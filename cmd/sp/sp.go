@@ -0,0 +1,25 @@
+package sp
+
+import (
+	"github.com/seb-schulz/onegate/cmd"
+	"github.com/spf13/cobra"
+)
+
+const (
+	errRetrieveServiceProviderFormat = "cannot retrieve service provider: %v"
+)
+
+var (
+	debug bool
+)
+
+func init() {
+	cmd.RootCmd.AddCommand(spCmd)
+	spCmd.PersistentFlags().BoolVarP(&debug, "debug", "d", false, "Verbose output")
+}
+
+var spCmd = &cobra.Command{
+	Use:     "sp",
+	Aliases: []string{"service-provider"},
+	Short:   "Operate with SAML service providers",
+}
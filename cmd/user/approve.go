@@ -0,0 +1,57 @@
+package user
+
+import (
+	"fmt"
+
+	"github.com/seb-schulz/onegate/internal/database"
+	"github.com/seb-schulz/onegate/internal/model"
+	"github.com/spf13/cobra"
+	"gorm.io/gorm"
+)
+
+func init() {
+	userCmd.AddCommand(approveCmd)
+	userCmd.AddCommand(rejectCmd)
+}
+
+// updateApproval applies fn to the user with the ID.
+func updateApproval(userID string, fn func(*model.User, *gorm.DB) error) error {
+	db, err := database.Open(database.WithDebug(debug))
+	if err != nil {
+		return err
+	}
+
+	user := model.User{}
+	if r := db.Where("id = ?", userID).First(&user); r.Error != nil {
+		return fmt.Errorf(errRetrieveUserFormat, r.Error)
+	}
+	return fn(&user, db)
+}
+
+var approveCmd = &cobra.Command{
+	Use:   "approve <user-id>",
+	Short: "Approve registration of user",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return updateApproval(args[0], func(user *model.User, db *gorm.DB) error {
+			if err := user.Approve(db); err != nil {
+				return fmt.Errorf("cannot approve user: %v", err)
+			}
+			return nil
+		})
+	},
+}
+
+var rejectCmd = &cobra.Command{
+	Use:   "reject <user-id>",
+	Short: "Reject registration of user and deactivate it",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return updateApproval(args[0], func(user *model.User, db *gorm.DB) error {
+			if err := user.Reject(db); err != nil {
+				return fmt.Errorf("cannot reject user: %v", err)
+			}
+			return nil
+		})
+	},
+}
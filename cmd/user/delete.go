@@ -14,7 +14,7 @@ func init() {
 
 var deleteCmd = &cobra.Command{
 	Use:   "delete",
-	Short: "Soft-delete user",
+	Short: "Soft-delete user and end all sessions",
 	Args:  cobra.ExactArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
 		db, err := database.Open(database.WithDebug(debug))
@@ -26,7 +26,9 @@ var deleteCmd = &cobra.Command{
 		if r := db.Where("id = ?", args[0]).First(&user); r.Error != nil {
 			return fmt.Errorf(errRetrieveUserFormat, r.Error)
 		}
-		db.Delete(&user)
+		if err := model.DeleteUser(db, &user); err != nil {
+			return fmt.Errorf("cannot delete user: %v", err)
+		}
 		return nil
 	},
 }
@@ -0,0 +1,62 @@
+package user
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/mdp/qrterminal/v3"
+	"github.com/seb-schulz/onegate/internal/config"
+	"github.com/seb-schulz/onegate/internal/database"
+	"github.com/seb-schulz/onegate/internal/invitation"
+	"github.com/spf13/cobra"
+)
+
+var (
+	inviteExpiresIn time.Duration
+	inviteName      string
+	inviteGroups    []string
+	inviteClients   []string
+)
+
+func init() {
+	userCmd.AddCommand(inviteCmd)
+	inviteCmd.Flags().DurationVarP(&inviteExpiresIn, "expires", "e", 7*24*time.Hour, "Duration when invite link will expire")
+	inviteCmd.Flags().StringVar(&inviteName, "name", "", "Preset name of the new user")
+	inviteCmd.Flags().StringArrayVar(&inviteGroups, "group", nil, "Add the new user to group (repeatable)")
+	inviteCmd.Flags().StringArrayVar(&inviteClients, "client", nil, "Allow the new user to access client by its access policy (repeatable)")
+	inviteCmd.Flags().BoolVar(&qrCode, "qr", false, "Output link as QR code")
+}
+
+var inviteCmd = &cobra.Command{
+	Use:   "invite",
+	Short: "Provide single-use registration link",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		db, err := database.Open(database.WithDebug(debug))
+		if err != nil {
+			return err
+		}
+
+		inv := invitation.Invitation{Name: inviteName, Groups: inviteGroups, Clients: inviteClients}
+		if err := inv.Validate(db); err != nil {
+			return fmt.Errorf("invalid invitation: %v", err)
+		}
+
+		url, err := invitation.Url(invitation.Config{
+			Key:          config.Config.UrlLogin.Key,
+			ValidMethods: config.Config.UrlLogin.ValidMethods,
+			BaseUrl:      config.Config.BaseUrl,
+		}, inv, inviteExpiresIn)
+		if err != nil {
+			return fmt.Errorf("cannot generate URL: %v", err)
+		}
+
+		if qrCode {
+			qrterminal.GenerateHalfBlock(url.String(), qrterminal.L, os.Stdout)
+		} else {
+			fmt.Printf("%v\n", url)
+		}
+		return nil
+	},
+}
@@ -13,8 +13,11 @@ import (
 
 func init() {
 	userCmd.AddCommand(listCmd)
+	listCmd.Flags().BoolVar(&pendingOnly, "pending", false, "List users awaiting approval only")
 }
 
+var pendingOnly bool
+
 var listCmd = &cobra.Command{
 	Use:   "list",
 	Short: "List all users",
@@ -25,12 +28,17 @@ var listCmd = &cobra.Command{
 		}
 
 		users := []model.User{}
-		if r := db.Unscoped().Find(&users); r.Error != nil {
+		if pendingOnly {
+			users, err = model.PendingUsers(db)
+			if err != nil {
+				return fmt.Errorf(errRetrieveUserFormat, err)
+			}
+		} else if r := db.Unscoped().Find(&users); r.Error != nil {
 			return fmt.Errorf(errRetrieveUserFormat, r.Error)
 		}
 
 		w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
-		fmt.Fprintln(w, "ID\tName\tDisplay Name\tUpdated at\tCreated at\tDeleted at")
+		fmt.Fprintln(w, "ID\tName\tDisplay Name\tApproval\tUpdated at\tCreated at\tDeleted at")
 		for _, user := range users {
 			deletedAt, _ := user.DeletedAt.Value()
 			deletedAtStr := ""
@@ -38,7 +46,7 @@ var listCmd = &cobra.Command{
 				deletedAtStr = deletedAt.(time.Time).Format(time.DateOnly)
 			}
 
-			fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\t%s\n", user.ID, user.Name, user.DisplayName, user.CreatedAt.Format(time.DateOnly), user.UpdatedAt.Format(time.DateOnly), deletedAtStr)
+			fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\t%s\t%s\n", user.ID, user.Name, user.DisplayName, user.ApprovalStatus, user.CreatedAt.Format(time.DateOnly), user.UpdatedAt.Format(time.DateOnly), deletedAtStr)
 		}
 		w.Flush()
 		return nil
@@ -14,19 +14,21 @@ import (
 )
 
 var (
-	expiresIn time.Duration
-	qrCode    bool
+	expiresIn          time.Duration
+	qrCode             bool
+	registerCredential bool
 )
 
 func init() {
 	userCmd.AddCommand(loginCmd)
 	loginCmd.Flags().DurationVarP(&expiresIn, "expires", "e", config.Config.UrlLogin.ExpiresIn, "Duration when URL will expire")
 	loginCmd.Flags().BoolVar(&qrCode, "qr", false, "Output link as QR code")
+	loginCmd.Flags().BoolVar(&registerCredential, "register-credential", false, "Restrict the session to register a new passkey")
 }
 
 var loginCmd = &cobra.Command{
 	Use:   "login",
-	Short: "Provide login URL for user recovery",
+	Short: "Provide single-use login URL for user recovery",
 	Args:  cobra.ExactArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
 		db, err := database.Open(database.WithDebug(debug))
@@ -39,11 +41,16 @@ var loginCmd = &cobra.Command{
 			return fmt.Errorf(errRetrieveUserFormat, r.Error)
 		}
 
+		purpose := server.PurposeLogin
+		if registerCredential {
+			purpose = server.PurposeRegisterCredential
+		}
+
 		url, err := server.TokenBasedLoginUrl(server.LoginConfig{
 			Key:          config.Config.UrlLogin.Key,
 			ValidMethods: config.Config.UrlLogin.ValidMethods,
 			BaseUrl:      *config.Config.BaseUrl.JoinPath("login"),
-		}, user.ID, expiresIn)
+		}, user.ID, expiresIn, purpose)
 		if err != nil {
 			return fmt.Errorf("cannot generate URL: %v", err)
 		}
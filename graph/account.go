@@ -0,0 +1,218 @@
+package graph
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/seb-schulz/onegate/internal/auth"
+	"github.com/seb-schulz/onegate/internal/database"
+	"github.com/seb-schulz/onegate/internal/model"
+	"github.com/seb-schulz/onegate/internal/recovery"
+	"gorm.io/gorm"
+)
+
+type (
+	exportedProfile struct {
+		ID             uint              `json:"id"`
+		Name           string            `json:"name"`
+		DisplayName    string            `json:"displayName"`
+		Picture        string            `json:"picture,omitempty"`
+		Locale         string            `json:"locale,omitempty"`
+		Zoneinfo       string            `json:"zoneinfo,omitempty"`
+		Attributes     map[string]string `json:"attributes,omitempty"`
+		ApprovalStatus string            `json:"approvalStatus"`
+		Groups         []string          `json:"groups"`
+		Roles          []string          `json:"roles"`
+		CreatedAt      time.Time         `json:"createdAt"`
+		UpdatedAt      time.Time         `json:"updatedAt"`
+	}
+
+	exportedEmailAddress struct {
+		Address    string     `json:"address"`
+		Primary    bool       `json:"primary"`
+		VerifiedAt *time.Time `json:"verifiedAt"`
+		CreatedAt  time.Time  `json:"createdAt"`
+	}
+
+	// exportedCredential contains metadata only but no key material
+	exportedCredential struct {
+		ID              uint       `json:"id"`
+		CredentialID    string     `json:"credentialId"`
+		Description     string     `json:"description"`
+		AttestationType string     `json:"attestationType"`
+		Transport       []string   `json:"transport"`
+		AAGUID          string     `json:"aaguid"`
+		CreatedAt       time.Time  `json:"createdAt"`
+		LastLogin       *time.Time `json:"lastLogin"`
+	}
+
+	exportedSession struct {
+		ID        uuid.UUID  `json:"id"`
+		Acr       string     `json:"acr,omitempty"`
+		Amr       []string   `json:"amr,omitempty"`
+		AuthTime  *time.Time `json:"authTime"`
+		CreatedAt time.Time  `json:"createdAt"`
+		UpdatedAt time.Time  `json:"updatedAt"`
+	}
+
+	// exportedAuthorization is the consent of the user to log in to a client
+	exportedAuthorization struct {
+		ClientID   uuid.UUID  `json:"clientId"`
+		ClientName string     `json:"clientName"`
+		Scope      string     `json:"scope"`
+		Resources  []string   `json:"resources,omitempty"`
+		CreatedAt  time.Time  `json:"createdAt"`
+		RedeemedAt *time.Time `json:"redeemedAt"`
+	}
+
+	exportedToken struct {
+		ClientID  uuid.UUID  `json:"clientId"`
+		CreatedAt time.Time  `json:"createdAt"`
+		ExpiresAt time.Time  `json:"expiresAt"`
+		RevokedAt *time.Time `json:"revokedAt"`
+	}
+
+	exportedData struct {
+		ExportedAt     time.Time               `json:"exportedAt"`
+		Profile        exportedProfile         `json:"profile"`
+		EmailAddresses []exportedEmailAddress  `json:"emailAddresses"`
+		Credentials    []exportedCredential    `json:"credentials"`
+		RecoveryCodes  int64                   `json:"recoveryCodes"`
+		Sessions       []exportedSession       `json:"sessions"`
+		Authorizations []exportedAuthorization `json:"authorizations"`
+		Tokens         []exportedToken         `json:"tokens"`
+	}
+)
+
+// exportData collects everything stored about the user as JSON.
+func (r *Resolver) exportData(ctx context.Context, user *model.User) (string, error) {
+	u := model.User{}
+	if result := r.DB.Preload("Credentials").First(&u, user.ID); result.Error != nil {
+		return "", fmt.Errorf("user not found")
+	}
+
+	data := exportedData{
+		ExportedAt: time.Now(),
+		Profile: exportedProfile{
+			ID:             u.ID,
+			Name:           u.Name,
+			DisplayName:    u.DisplayName,
+			Picture:        u.Picture,
+			Locale:         u.Locale,
+			Zoneinfo:       u.Zoneinfo,
+			Attributes:     u.Attributes,
+			ApprovalStatus: u.ApprovalStatus,
+			CreatedAt:      u.CreatedAt,
+			UpdatedAt:      u.UpdatedAt,
+		},
+		EmailAddresses: []exportedEmailAddress{},
+		Credentials:    []exportedCredential{},
+		Sessions:       []exportedSession{},
+		Authorizations: []exportedAuthorization{},
+		Tokens:         []exportedToken{},
+	}
+
+	var err error
+	if data.Profile.Groups, err = model.GroupNamesByUserID(r.DB, u.ID); err != nil {
+		return "", err
+	}
+	if data.Profile.Roles, err = model.RoleNamesByUserID(r.DB, u.ID); err != nil {
+		return "", err
+	}
+
+	emails, err := model.EmailAddressesByUserID(r.DB, u.ID)
+	if err != nil {
+		return "", err
+	}
+	for _, e := range emails {
+		data.EmailAddresses = append(data.EmailAddresses, exportedEmailAddress{e.Address, e.Primary, e.VerifiedAt, e.CreatedAt})
+	}
+
+	for _, c := range u.Credentials {
+		transport := []string{}
+		for _, t := range c.Data.Transport {
+			transport = append(transport, string(t))
+		}
+		data.Credentials = append(data.Credentials, exportedCredential{
+			ID:              c.ID,
+			CredentialID:    base64.RawURLEncoding.EncodeToString(c.Data.ID),
+			Description:     c.Description,
+			AttestationType: c.Data.AttestationType,
+			Transport:       transport,
+			AAGUID:          fmt.Sprintf("%x", c.Data.Authenticator.AAGUID),
+			CreatedAt:       c.CreatedAt,
+			LastLogin:       c.LastLogin,
+		})
+	}
+
+	if data.RecoveryCodes, err = recovery.Count(r.DB, u.ID); err != nil {
+		return "", err
+	}
+
+	sessions, err := model.AllSessionByUserID(r.DB, u.ID)
+	if err != nil {
+		return "", err
+	}
+	for _, s := range sessions {
+		data.Sessions = append(data.Sessions, exportedSession{s.ID, s.Acr, s.Amr, s.AuthTime, s.CreatedAt, s.UpdatedAt})
+	}
+
+	ctx = database.WithContext(ctx, r.DB)
+	authorizations, err := auth.AuthorizationsByUserID(ctx, u.ID)
+	if err != nil {
+		return "", err
+	}
+	for _, a := range authorizations {
+		data.Authorizations = append(data.Authorizations, exportedAuthorization{a.ClientID(), a.Client.Description, a.Scope(), a.Resources(), a.CreatedAt, a.RedeemedAt})
+	}
+
+	tokens, err := auth.IssuedTokensByUserID(ctx, u.ID)
+	if err != nil {
+		return "", err
+	}
+	for _, t := range tokens {
+		data.Tokens = append(data.Tokens, exportedToken{t.ClientID, t.CreatedAt, t.ExpiresAt, t.RevokedAt})
+	}
+
+	b, err := json.MarshalIndent(data, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("cannot export data: %v", err)
+	}
+	return string(b), nil
+}
+
+// deleteAccount removes the user with credentials, recovery codes, sessions
+// and authorizations and erases its personal data. Clients registered for
+// back-channel logout are notified in the background afterwards.
+func (r *Resolver) deleteAccount(ctx context.Context, user *model.User) error {
+	ctx = database.WithContext(ctx, r.DB)
+
+	notifications, err := auth.BackchannelLogouts(ctx, &r.Auth, user.ID)
+	if err != nil {
+		slog.Warn(fmt.Sprintf("cannot prepare back-channel logouts: %v", err))
+	}
+
+	if err := r.DB.Transaction(func(tx *gorm.DB) error {
+		if err := auth.RevokeAuthorizationsByUserID(database.WithContext(ctx, tx), user.ID); err != nil {
+			return err
+		}
+		if err := recovery.Delete(tx, user.ID); err != nil {
+			return err
+		}
+		if err := model.DeleteCredentialsByUserID(tx, user.ID); err != nil {
+			return err
+		}
+		return model.EraseUser(tx, user)
+	}); err != nil {
+		return fmt.Errorf("cannot delete account: %v", err)
+	}
+
+	slog.Info(fmt.Sprintf("user %d deleted own account", user.ID))
+	go auth.SendLogoutNotifications(context.WithoutCancel(ctx), notifications)
+	return nil
+}
@@ -0,0 +1,93 @@
+package graph
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/seb-schulz/onegate/internal/mail"
+	"github.com/seb-schulz/onegate/internal/model"
+)
+
+const approvalWebhookTimeout = 5 * time.Second
+
+// notifyApprovers informs members of the admin group with a verified email
+// address and the approval webhook about a user awaiting approval. Failures
+// are logged only so that the registration succeeds anyway.
+func (r *Resolver) notifyApprovers(ctx context.Context, user *model.User) {
+	if r.Mailer != nil && r.AdminGroup != "" {
+		if err := r.mailApprovers(ctx, user); err != nil {
+			slog.Warn(fmt.Sprintf("cannot notify approvers by email: %v", err))
+		}
+	}
+
+	if r.ApprovalWebhook != "" {
+		if err := r.callApprovalWebhook(ctx, user); err != nil {
+			slog.Warn(fmt.Sprintf("cannot call approval webhook: %v", err))
+		}
+	}
+}
+
+func (r *Resolver) mailApprovers(ctx context.Context, user *model.User) error {
+	group, err := model.GroupByName(r.DB, r.AdminGroup)
+	if err != nil {
+		return fmt.Errorf("admin group not found: %v", err)
+	}
+
+	for _, admin := range group.Members {
+		email, err := model.VerifiedPrimaryEmail(r.DB, admin.ID)
+		if err != nil {
+			return err
+		}
+		if email == "" {
+			continue
+		}
+
+		if err := r.Mailer.Send(ctx, mail.Message{
+			To:      email,
+			Subject: "Registration awaiting approval",
+			Body: fmt.Sprintf("Hello %s,\n\n%s registered and awaits your approval. Approve or reject the registration with\n\n  onegate user approve %d\n  onegate user reject %d\n",
+				admin.Name, user.Name, user.ID, user.ID),
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *Resolver) callApprovalWebhook(ctx context.Context, user *model.User) error {
+	b, err := json.Marshal(map[string]any{
+		"event": "user.pending",
+		"user": map[string]any{
+			"id":   fmt.Sprint(user.ID),
+			"name": user.Name,
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, approvalWebhookTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, r.ApprovalWebhook, bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status %v", resp.Status)
+	}
+	return nil
+}
@@ -3,11 +3,18 @@ package graph
 import (
 	"context"
 	"fmt"
+	"log/slog"
+	"slices"
 
 	"github.com/go-webauthn/webauthn/protocol"
 	"github.com/go-webauthn/webauthn/protocol/webauthncose"
 	"github.com/go-webauthn/webauthn/webauthn"
+	graphmodel "github.com/seb-schulz/onegate/graph/model"
+	"github.com/seb-schulz/onegate/internal/invitation"
+	"github.com/seb-schulz/onegate/internal/mail"
 	"github.com/seb-schulz/onegate/internal/model"
+	"github.com/seb-schulz/onegate/internal/usermgr"
+	"gorm.io/gorm"
 )
 
 func (r *mutationResolver) beginRegistration(ctx context.Context, user webauthn.User) (*protocol.CredentialCreation, error) {
@@ -25,8 +32,171 @@ func (r *mutationResolver) beginRegistration(ctx context.Context, user webauthn.
 		return nil, err
 	}
 
-	if err := model.CreateAuthSession(ctx, webauthn_session); err != nil {
+	if err := model.CreateAuthSession(ctx, webauthn_session, r.WebAuthn.Config.Timeouts.Registration.Timeout); err != nil {
 		return nil, fmt.Errorf("cannot start registration: %v", err)
 	}
 	return options, nil
 }
+
+// txID returns the transaction ID of an optional argument.
+func txID(transaction *string) string {
+	if transaction == nil {
+		return ""
+	}
+	return *transaction
+}
+
+// optional returns the value of an optional argument.
+func optional(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
+
+// registrationName returns the name of a new user which may be preset by the
+// invitation.
+func registrationName(name string, inv *invitation.Invitation) (string, error) {
+	if inv != nil && inv.Name != "" {
+		if name != "" && name != inv.Name {
+			return "", fmt.Errorf("name is preset by invitation")
+		}
+		return inv.Name, nil
+	}
+
+	if name == "" {
+		return "", fmt.Errorf("name must not be empty")
+	}
+	return name, nil
+}
+
+// updatedProfile applies optional arguments to a copy of the profile. Empty
+// values of custom attributes remove them.
+func updatedProfile(p model.Profile, picture, locale, zoneinfo *string, attributes []*graphmodel.AttributeInput) model.Profile {
+	for _, attr := range []struct {
+		value  *string
+		target *string
+	}{
+		{picture, &p.Picture},
+		{locale, &p.Locale},
+		{zoneinfo, &p.Zoneinfo},
+	} {
+		if attr.value != nil {
+			*attr.target = *attr.value
+		}
+	}
+
+	if attributes == nil {
+		return p
+	}
+
+	attrs := map[string]string{}
+	for name, value := range p.Attributes {
+		attrs[name] = value
+	}
+	for _, attr := range attributes {
+		if attr.Value == "" {
+			delete(attrs, attr.Name)
+		} else {
+			attrs[attr.Name] = attr.Value
+		}
+	}
+	p.Attributes = attrs
+	return p
+}
+
+// sendEmailVerification sends a new verification link to the address.
+func (r *Resolver) sendEmailVerification(ctx context.Context, tx *gorm.DB, user *model.User, addr *model.EmailAddress) error {
+	if r.Mailer == nil {
+		return fmt.Errorf("feature disabled")
+	}
+
+	token, err := addr.NewVerificationToken(tx)
+	if err != nil {
+		return err
+	}
+
+	if err := r.Mailer.Send(ctx, mail.Message{
+		To:      addr.Address,
+		Subject: "Verify your email address",
+		Body: fmt.Sprintf("Hello %s,\n\nplease open the following link within %v to verify your email address:\n\n%s\n\nYou can ignore this email if you did not add this address.\n",
+			user.Name, model.EmailVerificationExpiresIn, r.EmailVerificationUrl.JoinPath(token)),
+	}); err != nil {
+		slog.Warn(fmt.Sprintf("cannot send verification email: %v", err))
+		return fmt.Errorf("cannot send verification email")
+	}
+	return nil
+}
+
+// requireAdmin checks whether the current user is member of the admin group.
+func (r *Resolver) requireAdmin(ctx context.Context) error {
+	user := usermgr.FromContext(ctx)
+	if user == nil {
+		return fmt.Errorf("user not logged in")
+	}
+
+	if r.AdminGroup == "" {
+		return fmt.Errorf("feature disabled")
+	}
+
+	groups, err := model.GroupNamesByUserID(r.DB, user.ID)
+	if err != nil {
+		return fmt.Errorf("cannot get groups: %v", err)
+	}
+
+	if !slices.Contains(groups, r.AdminGroup) {
+		return fmt.Errorf("permission denied")
+	}
+	return nil
+}
+
+// verifyProof checks the solved proof-of-work challenge when proofs are
+// required.
+func (r *Resolver) verifyProof(proof *graphmodel.ProofOfWorkInput) error {
+	if !r.ProofOfWork.Enabled() {
+		return nil
+	}
+
+	if proof == nil {
+		return fmt.Errorf("proof of work required")
+	}
+
+	if err := r.ProofOfWork.Verify(r.DB, proof.Challenge, proof.Solution); err != nil {
+		return fmt.Errorf("invalid proof of work: %v", err)
+	}
+	return nil
+}
+
+func (r *Resolver) userByID(userID string) (*model.User, error) {
+	user := model.User{}
+	if result := r.DB.Where("id = ?", userID).First(&user); result.Error != nil {
+		return nil, fmt.Errorf("user not found")
+	}
+	return &user, nil
+}
+
+func (r *Resolver) groupAndUser(name, userID string) (*model.Group, *model.User, error) {
+	group, err := model.GroupByName(r.DB, name)
+	if err != nil {
+		return nil, nil, fmt.Errorf("group not found")
+	}
+
+	user, err := r.userByID(userID)
+	if err != nil {
+		return nil, nil, err
+	}
+	return group, user, nil
+}
+
+func (r *Resolver) roleAndUser(name, userID string) (*model.Role, *model.User, error) {
+	role, err := model.RoleByName(r.DB, name)
+	if err != nil {
+		return nil, nil, fmt.Errorf("role not found")
+	}
+
+	user, err := r.userByID(userID)
+	if err != nil {
+		return nil, nil, err
+	}
+	return role, user, nil
+}
@@ -0,0 +1,144 @@
+package graph
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"fmt"
+	"time"
+
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/webauthn"
+	"github.com/seb-schulz/onegate/internal/model"
+)
+
+// LoginMode selects the WebAuthn ceremonies offered to log in.
+type LoginMode string
+
+const (
+	// LoginModeDiscoverable lets users pick a passkey without entering a
+	// username
+	LoginModeDiscoverable LoginMode = "discoverable"
+	// LoginModeUsername asks for the username first so that security keys
+	// without resident credentials can be used
+	LoginModeUsername LoginMode = "username"
+	// LoginModeBoth offers both ceremonies
+	LoginModeBoth LoginMode = "both"
+)
+
+func ParseLoginMode(s string) (LoginMode, error) {
+	switch m := LoginMode(s); m {
+	case LoginModeDiscoverable, LoginModeUsername, LoginModeBoth:
+		return m, nil
+	case "":
+		return LoginModeDiscoverable, nil
+	default:
+		return "", fmt.Errorf("unknown login mode: %v", s)
+	}
+}
+
+func (m LoginMode) discoverable() bool {
+	return m != LoginModeUsername
+}
+
+func (m LoginMode) usernameFirst() bool {
+	return m == LoginModeUsername || m == LoginModeBoth
+}
+
+// withTimeout keeps conditional logins open while the login page is shown
+// because the browser waits until the user picks a passkey from autofill.
+func withTimeout(timeout time.Duration) webauthn.LoginOption {
+	return func(o *protocol.PublicKeyCredentialRequestOptions) {
+		o.Timeout = int(timeout.Milliseconds())
+	}
+}
+
+// withoutTransports omits the transports of allowed credentials because
+// decoys cannot know them which would reveal unknown usernames.
+func withoutTransports() webauthn.LoginOption {
+	return func(o *protocol.PublicKeyCredentialRequestOptions) {
+		for i := range o.AllowedCredentials {
+			o.AllowedCredentials[i].Transport = nil
+		}
+	}
+}
+
+// decoyUser stands in for unknown usernames and users without credentials.
+// Its credentials are derived from the username so that repeated requests
+// cannot tell it apart from a real user.
+type decoyUser struct {
+	id          []byte
+	name        string
+	credentials []webauthn.Credential
+}
+
+func newDecoyUser(key []byte, name string) *decoyUser {
+	derive := func(purpose string) []byte {
+		h := hmac.New(sha256.New, key)
+		h.Write([]byte(purpose + ":" + name))
+		return h.Sum(nil)
+	}
+
+	// Users register one to three credentials mostly
+	credentials := make([]webauthn.Credential, 1+derive("decoy-count")[0]%3)
+	for i := range credentials {
+		credentials[i] = webauthn.Credential{ID: derive(fmt.Sprintf("decoy-credential-%d", i))}
+	}
+
+	return &decoyUser{
+		id:          derive("decoy-user")[:16],
+		name:        name,
+		credentials: credentials,
+	}
+}
+
+func (u *decoyUser) WebAuthnID() []byte {
+	return u.id
+}
+
+func (u *decoyUser) WebAuthnName() string {
+	return u.name
+}
+
+func (u *decoyUser) WebAuthnDisplayName() string {
+	return u.name
+}
+
+func (u *decoyUser) WebAuthnCredentials() []webauthn.Credential {
+	return u.credentials
+}
+
+func (u *decoyUser) WebAuthnIcon() string {
+	return ""
+}
+
+// loginCandidate returns the user with the name and its credentials or a
+// decoy if there is no such user with credentials. Names are unique among
+// users which are not deleted.
+func (r *Resolver) loginCandidate(name string) webauthn.User {
+	user := model.User{}
+	if result := r.DB.Preload("Credentials").Where("name = ?", name).First(&user); result.Error != nil || len(user.Credentials) == 0 {
+		return newDecoyUser(r.DecoyKey, name)
+	}
+	return &user
+}
+
+// validateUsernameLogin verifies the assertion of a username-first login
+// against the user the ceremony was started for.
+func (r *Resolver) validateUsernameLogin(session webauthn.SessionData, parsedResponse *protocol.ParsedCredentialAssertionData) (*model.User, *model.Credential, error) {
+	user := model.User{}
+	if result := r.DB.Preload("Credentials").First(&user, "authn_id = ?", session.UserID); result.Error != nil {
+		return nil, nil, fmt.Errorf("login failed")
+	}
+
+	cred, err := r.WebAuthn.ValidateLogin(&user, session, parsedResponse)
+	if err != nil {
+		return nil, nil, fmt.Errorf("login failed")
+	}
+
+	dbCred, err := assertedCredential(&user, cred.ID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("login failed")
+	}
+	dbCred.Data = *cred
+	return &user, dbCred, nil
+}
@@ -0,0 +1,44 @@
+package graph
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/go-webauthn/webauthn/protocol"
+)
+
+func TestNewDecoyUser(t *testing.T) {
+	key := []byte("abcd")
+
+	decoy := newDecoyUser(key, "jdoe")
+	if !reflect.DeepEqual(decoy, newDecoyUser(key, "jdoe")) {
+		t.Errorf("expected same decoy for same username")
+	}
+
+	if n := len(decoy.WebAuthnCredentials()); n < 1 || n > 3 {
+		t.Errorf("expected one to three credentials but got %d", n)
+	}
+
+	for _, other := range []*decoyUser{newDecoyUser(key, "other"), newDecoyUser([]byte("efgh"), "jdoe")} {
+		if bytes.Equal(decoy.WebAuthnID(), other.WebAuthnID()) || bytes.Equal(decoy.WebAuthnCredentials()[0].ID, other.WebAuthnCredentials()[0].ID) {
+			t.Errorf("expected different decoys for different usernames or keys")
+		}
+	}
+}
+
+func TestWithoutTransports(t *testing.T) {
+	o := protocol.PublicKeyCredentialRequestOptions{
+		AllowedCredentials: []protocol.CredentialDescriptor{
+			{Type: protocol.PublicKeyCredentialType, CredentialID: []byte("a"), Transport: []protocol.AuthenticatorTransport{protocol.USB}},
+			{Type: protocol.PublicKeyCredentialType, CredentialID: []byte("b")},
+		},
+	}
+	withoutTransports()(&o)
+
+	for _, c := range o.AllowedCredentials {
+		if c.Transport != nil {
+			t.Errorf("expected no transports but got %v", c.Transport)
+		}
+	}
+}
@@ -2,9 +2,24 @@
 
 package model
 
+type Attribute struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type AttributeInput struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
 type Mutation struct {
 }
 
+type ProofOfWorkInput struct {
+	Challenge string `json:"challenge"`
+	Solution  string `json:"solution"`
+}
+
 type PubKeyCredParam struct {
 	Type string `json:"type"`
 	Alg  int    `json:"alg"`
@@ -0,0 +1,63 @@
+package graph
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/seb-schulz/onegate/internal/model"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+)
+
+// ReauthenticationRequiredCode is the error code of sensitive mutations which
+// tells the UI to run the re-authentication ceremony and to retry.
+const ReauthenticationRequiredCode = "REAUTHENTICATION_REQUIRED"
+
+// requireRecentAuthentication fails unless the session of the user was
+// authenticated within ReauthenticateWithin. Users without credentials, e.g.
+// during registration, cannot prove possession of a passkey and are exempt.
+func (r *Resolver) requireRecentAuthentication(ctx context.Context, user *model.User) error {
+	if r.ReauthenticateWithin <= 0 {
+		return nil
+	}
+
+	if model.CountCredentialByUserID(r.DB, user.ID) == 0 {
+		return nil
+	}
+
+	ac, err := model.CurrentAuthnContext(ctx)
+	if err == nil && ac.AuthTime != nil && time.Since(*ac.AuthTime) <= r.ReauthenticateWithin {
+		return nil
+	}
+
+	return &gqlerror.Error{
+		Message:    "recent authentication required",
+		Extensions: map[string]interface{}{"code": ReauthenticationRequiredCode},
+	}
+}
+
+// userWithCredentials loads the credentials of the user which may be asserted
+// during re-authentication.
+func (r *Resolver) userWithCredentials(user *model.User) (*model.User, error) {
+	u := model.User{}
+	if result := r.DB.Preload("Credentials").First(&u, user.ID); result.Error != nil {
+		return nil, fmt.Errorf("user not found")
+	}
+
+	if len(u.Credentials) == 0 {
+		return nil, fmt.Errorf("no credential registered")
+	}
+	return &u, nil
+}
+
+// assertedCredential returns the stored credential matching the ID of the
+// asserted credential.
+func assertedCredential(user *model.User, id []byte) (*model.Credential, error) {
+	for i := range user.Credentials {
+		if bytes.Equal(user.Credentials[i].Data.ID, id) {
+			return &user.Credentials[i], nil
+		}
+	}
+	return nil, fmt.Errorf("credential not found")
+}
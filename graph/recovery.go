@@ -0,0 +1,49 @@
+package graph
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"github.com/seb-schulz/onegate/internal/mail"
+	"github.com/seb-schulz/onegate/internal/model"
+	"github.com/seb-schulz/onegate/internal/usermgr"
+)
+
+// credentialOwner returns the logged-in user or the user of a restricted
+// session which may register a new credential.
+func credentialOwner(ctx context.Context) (user *model.User, restricted bool) {
+	if user := usermgr.FromContext(ctx); user != nil {
+		return user, false
+	}
+	if user := usermgr.RecoveringFromContext(ctx); user != nil {
+		return user, true
+	}
+	return nil, false
+}
+
+// notifyRecovery informs the user about the usage of recovery codes by
+// email if the user has a verified email address. Failures are logged only.
+func (r *Resolver) notifyRecovery(ctx context.Context, user *model.User, subject, body string) {
+	slog.Info(fmt.Sprintf("%s for user %d", subject, user.ID))
+	if r.Mailer == nil {
+		return
+	}
+
+	email, err := model.VerifiedPrimaryEmail(r.DB, user.ID)
+	if err != nil {
+		slog.Warn(fmt.Sprintf("cannot get email address: %v", err))
+		return
+	}
+	if email == "" {
+		return
+	}
+
+	if err := r.Mailer.Send(ctx, mail.Message{
+		To:      email,
+		Subject: subject,
+		Body:    fmt.Sprintf("Hello %s,\n\n%s\n\nIf this was not you, log in and generate new recovery codes immediately.\n", user.Name, body),
+	}); err != nil {
+		slog.Warn(fmt.Sprintf("cannot send recovery notification: %v", err))
+	}
+}
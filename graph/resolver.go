@@ -1,7 +1,15 @@
 package graph
 
 import (
+	"net/url"
+	"time"
+
 	"github.com/go-webauthn/webauthn/webauthn"
+	"github.com/seb-schulz/onegate/internal/auth"
+	"github.com/seb-schulz/onegate/internal/invitation"
+	"github.com/seb-schulz/onegate/internal/mail"
+	"github.com/seb-schulz/onegate/internal/model"
+	"github.com/seb-schulz/onegate/internal/pow"
 	"gorm.io/gorm"
 )
 
@@ -13,4 +21,37 @@ type Resolver struct {
 	DB                      *gorm.DB
 	WebAuthn                *webauthn.WebAuthn
 	UserRegistrationEnabled bool
+	// LoginMode selects between discoverable and username-first logins.
+	// DecoyKey derives credentials of unknown usernames so that
+	// username-first logins do not reveal which users exist.
+	LoginMode LoginMode
+	DecoyKey  []byte
+	// ConditionalLoginExpiresIn keeps ceremonies of passkey autofill open
+	// while the login page is shown
+	ConditionalLoginExpiresIn time.Duration
+	// ReauthenticateWithin is the time after a login within which sensitive
+	// mutations are allowed without re-authentication
+	ReauthenticateWithin time.Duration
+	// ProofOfWork issues challenges which must be solved before
+	// registrations and logins begin
+	ProofOfWork pow.Config
+	// RegistrationApproval keeps self-registered users pending until an
+	// admin approves them. Admins are notified by email and the optional
+	// ApprovalWebhook.
+	RegistrationApproval bool
+	ApprovalWebhook      string
+	// Invitation verifies invite tokens which allow registrations even if
+	// self-registration is disabled
+	Invitation invitation.Config
+	// AdminGroup grants its members access to the management of groups and
+	// roles. The management is disabled when empty.
+	AdminGroup string
+	// ProfileAttributes define custom attributes users may set
+	ProfileAttributes []model.AttributeDefinition
+	// Mailer sends verification links to EmailVerificationUrl joined with
+	// the token. Email addresses cannot be added without mailer.
+	Mailer               mail.Mailer
+	EmailVerificationUrl url.URL
+	// Auth signs back-channel logout tokens for clients of deleted accounts
+	Auth auth.Config
 }
@@ -7,7 +7,11 @@ package graph
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
+	"log/slog"
+	"net/url"
+	"slices"
 	"strings"
 	"time"
 
@@ -16,7 +20,11 @@ import (
 	"github.com/google/uuid"
 	"github.com/seb-schulz/onegate/graph/model"
 	"github.com/seb-schulz/onegate/internal/auth"
+	"github.com/seb-schulz/onegate/internal/database"
+	"github.com/seb-schulz/onegate/internal/invitation"
 	dbmodel "github.com/seb-schulz/onegate/internal/model"
+	"github.com/seb-schulz/onegate/internal/pow"
+	"github.com/seb-schulz/onegate/internal/recovery"
 	"github.com/seb-schulz/onegate/internal/usermgr"
 	"gorm.io/gorm"
 )
@@ -26,11 +34,33 @@ func (r *credentialResolver) ID(ctx context.Context, obj *dbmodel.Credential) (s
 	return fmt.Sprintf("%d", obj.ID), nil
 }
 
-// CreateUser is the resolver for the createUser field.
-func (r *mutationResolver) CreateUser(ctx context.Context, name string) (*protocol.CredentialCreation, error) {
-	defer time.Sleep(2 * time.Second)
+// ID is the resolver for the id field.
+func (r *emailAddressResolver) ID(ctx context.Context, obj *dbmodel.EmailAddress) (string, error) {
+	return fmt.Sprintf("%d", obj.ID), nil
+}
 
-	if !r.UserRegistrationEnabled {
+// Verified is the resolver for the verified field.
+func (r *emailAddressResolver) Verified(ctx context.Context, obj *dbmodel.EmailAddress) (bool, error) {
+	return obj.IsVerified(), nil
+}
+
+// ID is the resolver for the id field.
+func (r *groupResolver) ID(ctx context.Context, obj *dbmodel.Group) (string, error) {
+	return fmt.Sprintf("%d", obj.ID), nil
+}
+
+// CreateUser is the resolver for the createUser field.
+func (r *mutationResolver) CreateUser(ctx context.Context, name *string, invite *string, proof *model.ProofOfWorkInput) (*protocol.CredentialCreation, error) {
+	var (
+		invID string
+		inv   *invitation.Invitation
+	)
+	if invite != nil {
+		var err error
+		if invID, inv, err = invitation.Parse(r.Invitation, *invite); err != nil {
+			return nil, fmt.Errorf("invalid invitation")
+		}
+	} else if !r.UserRegistrationEnabled {
 		return nil, fmt.Errorf("feature disabled")
 	}
 
@@ -38,28 +68,66 @@ func (r *mutationResolver) CreateUser(ctx context.Context, name string) (*protoc
 		return nil, fmt.Errorf("currently logged in with an user")
 	}
 
-	user, err := dbmodel.CreateUser(ctx, name)
+	if err := r.verifyProof(proof); err != nil {
+		return nil, err
+	}
+
+	userName, err := registrationName(optional(name), inv)
 	if err != nil {
 		return nil, err
 	}
+
+	user, err := database.Transaction(ctx, func(tx *gorm.DB) (*dbmodel.User, error) {
+		user, err := dbmodel.CreateUser(ctx, userName, database.WithNestedTransaction(tx))
+		if err != nil {
+			return nil, err
+		}
+
+		if inv != nil {
+			if err := invitation.Redeem(tx, invID, inv, user); err != nil {
+				return nil, err
+			}
+		} else if r.RegistrationApproval {
+			if err := user.RequireApproval(tx); err != nil {
+				return nil, err
+			}
+		}
+		return user, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if !user.IsApproved() {
+		r.notifyApprovers(ctx, user)
+	}
 	return r.beginRegistration(ctx, user)
 }
 
 // UpdateMe is the resolver for the updateMe field.
-func (r *mutationResolver) UpdateMe(ctx context.Context, name *string, displayName *string) (*dbmodel.User, error) {
+func (r *mutationResolver) UpdateMe(ctx context.Context, name *string, displayName *string, picture *string, locale *string, zoneinfo *string, attributes []*model.AttributeInput) (*dbmodel.User, error) {
 	user := usermgr.FromContext(ctx)
 	if user == nil {
 		return nil, fmt.Errorf("user not logged in")
 	}
 
-	if name != nil && len(*name) < 1 && len(*name) > 255 {
+	if err := r.requireRecentAuthentication(ctx, user); err != nil {
+		return nil, err
+	}
+
+	if name != nil && (len(*name) < 1 || len(*name) > 255) {
 		return nil, fmt.Errorf("length of name must be between 1 and 255 letters")
 	}
 
-	if displayName != nil && len(*displayName) < 1 && len(*displayName) > 255 {
+	if displayName != nil && (len(*displayName) < 1 || len(*displayName) > 255) {
 		return nil, fmt.Errorf("length of display name must be between 1 and 255 letters")
 	}
 
+	profile := updatedProfile(user.Profile, picture, locale, zoneinfo, attributes)
+	if err := profile.Validate(r.ProfileAttributes); err != nil {
+		return nil, err
+	}
+
 	if err := r.DB.Transaction(func(tx *gorm.DB) error {
 		if name != nil {
 			user.Name = *name
@@ -69,34 +137,194 @@ func (r *mutationResolver) UpdateMe(ctx context.Context, name *string, displayNa
 			user.DisplayName = *displayName
 		}
 
-		tx.Save(&user)
-		return nil
-	}); err != nil {
+		user.Profile = profile
+		return user.Save(tx)
+	}); errors.Is(err, dbmodel.ErrNameTaken) {
+		return nil, err
+	} else if err != nil {
 		return nil, fmt.Errorf("user cannot be saved: %v", err)
 	}
 
 	return user, nil
 }
 
-// InitCredential is the resolver for the initCredential field.
-func (r *mutationResolver) InitCredential(ctx context.Context) (*protocol.CredentialCreation, error) {
-	defer time.Sleep(2 * time.Second)
+// AddEmailAddress is the resolver for the addEmailAddress field.
+func (r *mutationResolver) AddEmailAddress(ctx context.Context, address string) (*dbmodel.EmailAddress, error) {
+	user := usermgr.FromContext(ctx)
+	if user == nil {
+		return nil, fmt.Errorf("user not logged in")
+	}
+
+	if err := r.requireRecentAuthentication(ctx, user); err != nil {
+		return nil, err
+	}
 
+	var addr *dbmodel.EmailAddress
+	if err := r.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		if addr, err = dbmodel.AddEmailAddress(tx, user.ID, address); err != nil {
+			return err
+		}
+		return r.sendEmailVerification(ctx, tx, user, addr)
+	}); err != nil {
+		return nil, err
+	}
+	return addr, nil
+}
+
+// ResendEmailVerification is the resolver for the resendEmailVerification field.
+func (r *mutationResolver) ResendEmailVerification(ctx context.Context, id string) (bool, error) {
 	user := usermgr.FromContext(ctx)
+	if user == nil {
+		return false, fmt.Errorf("user not logged in")
+	}
+
+	addr, err := dbmodel.EmailAddressByUserID(r.DB, user.ID, id)
+	if err != nil {
+		return false, err
+	}
+
+	if err := r.sendEmailVerification(ctx, r.DB, user, addr); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// MakePrimaryEmailAddress is the resolver for the makePrimaryEmailAddress field.
+func (r *mutationResolver) MakePrimaryEmailAddress(ctx context.Context, id string) (*dbmodel.EmailAddress, error) {
+	user := usermgr.FromContext(ctx)
+	if user == nil {
+		return nil, fmt.Errorf("user not logged in")
+	}
+
+	if err := r.requireRecentAuthentication(ctx, user); err != nil {
+		return nil, err
+	}
+
+	addr, err := dbmodel.EmailAddressByUserID(r.DB, user.ID, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := addr.MakePrimary(r.DB); err != nil {
+		return nil, err
+	}
+	return addr, nil
+}
+
+// RemoveEmailAddress is the resolver for the removeEmailAddress field.
+func (r *mutationResolver) RemoveEmailAddress(ctx context.Context, id string) (bool, error) {
+	user := usermgr.FromContext(ctx)
+	if user == nil {
+		return false, fmt.Errorf("user not logged in")
+	}
+
+	if err := r.requireRecentAuthentication(ctx, user); err != nil {
+		return false, err
+	}
+
+	addr, err := dbmodel.EmailAddressByUserID(r.DB, user.ID, id)
+	if err != nil {
+		return false, err
+	}
+
+	if err := dbmodel.DeleteEmailAddress(r.DB, addr); err != nil {
+		return false, fmt.Errorf("cannot remove email address: %v", err)
+	}
+	return true, nil
+}
+
+// GenerateRecoveryCodes is the resolver for the generateRecoveryCodes field.
+func (r *mutationResolver) GenerateRecoveryCodes(ctx context.Context) ([]string, error) {
+	user := usermgr.FromContext(ctx)
+	if user == nil {
+		return nil, fmt.Errorf("user not logged in")
+	}
+
+	if err := r.requireRecentAuthentication(ctx, user); err != nil {
+		return nil, err
+	}
+
+	codes, err := recovery.Generate(r.DB, auth.NewClientSecretHasher, user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	r.notifyRecovery(ctx, user, "Recovery codes generated", "New recovery codes were generated for your account. Previous codes are no longer valid.")
+	return codes, nil
+}
+
+// RecoverAccount is the resolver for the recoverAccount field.
+func (r *mutationResolver) RecoverAccount(ctx context.Context, code string, proof *model.ProofOfWorkInput) (bool, error) {
+	if user := usermgr.FromContext(ctx); user != nil {
+		return false, fmt.Errorf("user is logged-in")
+	}
+
+	if err := r.verifyProof(proof); err != nil {
+		return false, err
+	}
+
+	var userID uint
+	if err := r.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		if userID, err = recovery.Redeem(tx, code); err != nil {
+			return err
+		}
+
+		return dbmodel.LoginUser(ctx, dbmodel.LoginOpt{UserID: &userID, Tx: tx, AuthnContext: dbmodel.RecoveryCodeAuthnContext(), Restricted: true})
+	}); err != nil {
+		slog.Warn(fmt.Sprintf("account recovery failed: %v", err))
+		return false, fmt.Errorf("invalid recovery code")
+	}
+
+	user, err := r.userByID(fmt.Sprint(userID))
+	if err != nil {
+		return false, err
+	}
+
+	remaining, err := recovery.Count(r.DB, userID)
+	if err != nil {
+		return false, err
+	}
+	r.notifyRecovery(ctx, user, "Recovery code used", fmt.Sprintf("A recovery code was used to sign in to your account to register a new passkey. %d recovery codes remain.", remaining))
+	return true, nil
+}
+
+// InitCredential is the resolver for the initCredential field.
+func (r *mutationResolver) InitCredential(ctx context.Context, proof *model.ProofOfWorkInput) (*protocol.CredentialCreation, error) {
+	user, restricted := credentialOwner(ctx)
 	if user == nil {
 		return nil, fmt.Errorf("user not logged in")
 	}
 
+	// Restricted sessions cannot re-authenticate without passkey
+	if !restricted {
+		if err := r.requireRecentAuthentication(ctx, user); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := r.verifyProof(proof); err != nil {
+		return nil, err
+	}
+
 	return r.beginRegistration(ctx, user)
 }
 
 // AddPasskey is the resolver for the addPasskey field.
 func (r *mutationResolver) AddCredential(ctx context.Context, body string) (bool, error) {
-	user := usermgr.FromContext(ctx)
+	user, restricted := credentialOwner(ctx)
 	if user == nil {
 		return false, fmt.Errorf("user not logged in")
 	}
 
+	// Restricted sessions cannot re-authenticate without passkey
+	if !restricted {
+		if err := r.requireRecentAuthentication(ctx, user); err != nil {
+			return false, err
+		}
+	}
+
 	auth_session, err := dbmodel.FirstAuthSession(ctx)
 	if err != nil {
 		return false, fmt.Errorf("registration failed")
@@ -114,6 +342,11 @@ func (r *mutationResolver) AddCredential(ctx context.Context, body string) (bool
 	if err := r.DB.Transaction(func(tx *gorm.DB) error {
 		tx.Create(&dbmodel.Credential{UserID: user.ID, Data: *cred})
 		tx.Delete(&auth_session)
+
+		// Recovering users log in with the new credential afterwards
+		if restricted {
+			return dbmodel.EndRestrictedSession(ctx, tx)
+		}
 		return nil
 	}); err != nil {
 		panic(err)
@@ -153,6 +386,10 @@ func (r *mutationResolver) RemoveCredential(ctx context.Context, id string) (boo
 		return false, fmt.Errorf("user not logged in")
 	}
 
+	if err := r.requireRecentAuthentication(ctx, user); err != nil {
+		return false, err
+	}
+
 	if dbmodel.CountCredentialByUserID(r.DB, user.ID) <= 1 {
 		return false, fmt.Errorf("cannot delete last remaining credential")
 	}
@@ -167,30 +404,67 @@ func (r *mutationResolver) RemoveCredential(ctx context.Context, id string) (boo
 }
 
 // BeginLogin is the resolver for the beginLogin field.
-func (r *mutationResolver) BeginLogin(ctx context.Context) (*protocol.CredentialAssertion, error) {
-	defer time.Sleep(2 * time.Second)
+func (r *mutationResolver) BeginLogin(ctx context.Context, transaction *string, proof *model.ProofOfWorkInput, conditional *bool) (*protocol.CredentialAssertion, error) {
 	user := usermgr.FromContext(ctx)
-	if user != nil {
+	if user != nil && !auth.StepUpRequired(ctx, txID(transaction)) {
 		return nil, fmt.Errorf("user is logged-in")
 	}
 
-	cred, webauthn_session, err := r.WebAuthn.BeginDiscoverableLogin()
+	if !r.LoginMode.discoverable() {
+		return nil, fmt.Errorf("login without username is disabled")
+	}
+
+	if err := r.verifyProof(proof); err != nil {
+		return nil, err
+	}
+
+	expiresIn, opts := r.WebAuthn.Config.Timeouts.Login.Timeout, []webauthn.LoginOption{}
+	if conditional != nil && *conditional && r.ConditionalLoginExpiresIn > 0 {
+		expiresIn = r.ConditionalLoginExpiresIn
+		opts = append(opts, withTimeout(expiresIn))
+	}
+
+	cred, webauthn_session, err := r.WebAuthn.BeginDiscoverableLogin(opts...)
 	if err != nil {
 		return nil, err
 	}
 
-	if err := dbmodel.CreateAuthSession(ctx, webauthn_session); err != nil {
+	if err := dbmodel.CreateAuthSession(ctx, webauthn_session, expiresIn); err != nil {
 		return nil, fmt.Errorf("cannot start login: %v", err)
 	}
 
 	return cred, nil
 }
 
-// ValidateLogin is the resolver for the validateLogin field.
-func (r *mutationResolver) ValidateLogin(ctx context.Context, body string) (*model.SuccessfulLogin, error) {
-	defer time.Sleep(2 * time.Second)
+// BeginUsernameLogin is the resolver for the beginUsernameLogin field.
+func (r *mutationResolver) BeginUsernameLogin(ctx context.Context, name string, transaction *string, proof *model.ProofOfWorkInput) (*protocol.CredentialAssertion, error) {
+	if user := usermgr.FromContext(ctx); user != nil && !auth.StepUpRequired(ctx, txID(transaction)) {
+		return nil, fmt.Errorf("user is logged-in")
+	}
 
-	if user := usermgr.FromContext(ctx); user != nil {
+	if !r.LoginMode.usernameFirst() {
+		return nil, fmt.Errorf("login with username is disabled")
+	}
+
+	if err := r.verifyProof(proof); err != nil {
+		return nil, err
+	}
+
+	options, webauthn_session, err := r.WebAuthn.BeginLogin(r.loginCandidate(name), withoutTransports())
+	if err != nil {
+		return nil, err
+	}
+
+	if err := dbmodel.CreateAuthSession(ctx, webauthn_session, r.WebAuthn.Config.Timeouts.Login.Timeout); err != nil {
+		return nil, fmt.Errorf("cannot start login: %v", err)
+	}
+
+	return options, nil
+}
+
+// ValidateLogin is the resolver for the validateLogin field.
+func (r *mutationResolver) ValidateLogin(ctx context.Context, body string, transaction *string) (*model.SuccessfulLogin, error) {
+	if user := usermgr.FromContext(ctx); user != nil && !auth.StepUpRequired(ctx, txID(transaction)) {
 		return nil, fmt.Errorf("user is logged-in")
 	}
 
@@ -207,26 +481,46 @@ func (r *mutationResolver) ValidateLogin(ctx context.Context, body string) (*mod
 	user := dbmodel.User{}
 	db_cred := dbmodel.Credential{}
 
-	cred, err := r.WebAuthn.ValidateDiscoverableLogin(func(rawID, userHandle []byte) (webauthn.User, error) {
-		if result := r.DB.Preload("Credentials").First(&user, "authn_id = ?", userHandle); result.Error != nil {
-			return nil, fmt.Errorf("login failed")
+	// Username-first logins are bound to the user of the ceremony while
+	// discoverable logins learn the user from the user handle
+	if session := auth_session.Value(); len(session.UserID) > 0 {
+		u, c, err := r.validateUsernameLogin(session, parsedResponse)
+		if err != nil {
+			return nil, err
 		}
+		user, db_cred = *u, *c
+	} else {
+		cred, err := r.WebAuthn.ValidateDiscoverableLogin(func(rawID, userHandle []byte) (webauthn.User, error) {
+			if result := r.DB.Preload("Credentials").First(&user, "authn_id = ?", userHandle); result.Error != nil {
+				return nil, fmt.Errorf("login failed")
+			}
 
-		for _, c := range user.Credentials {
-			if bytes.Equal(c.Data.ID, rawID) {
-				db_cred = c
-				return &user, nil
+			for _, c := range user.Credentials {
+				if bytes.Equal(c.Data.ID, rawID) {
+					db_cred = c
+					return &user, nil
+				}
 			}
+
+			return nil, fmt.Errorf("login failed")
+		}, session, parsedResponse)
+		if err != nil {
+			return nil, fmt.Errorf("login failed")
 		}
+		db_cred.Data = *cred
+	}
 
-		return nil, fmt.Errorf("login failed")
-	}, auth_session.Value(), parsedResponse)
-	if err != nil {
+	// A step-up login must not switch to another user. The session keeps its
+	// context unless the passkey satisfies the authorization.
+	if loggedIn := usermgr.FromContext(ctx); loggedIn != nil && loggedIn.ID != user.ID {
 		return nil, fmt.Errorf("login failed")
 	}
 
+	if !auth.SatisfiesAuthorization(ctx, txID(transaction), dbmodel.AuthnContextFromCredential(db_cred.Data)) {
+		return nil, fmt.Errorf("passkey does not satisfy the assurance level required by the application")
+	}
+
 	now := time.Now()
-	db_cred.Data = *cred
 	db_cred.LastLogin = &now
 
 	if err := r.DB.Transaction(func(tx *gorm.DB) error {
@@ -241,14 +535,91 @@ func (r *mutationResolver) ValidateLogin(ctx context.Context, body string) (*mod
 		panic(err)
 	}
 
-	auth, _ := auth.FirstAuthorization(ctx)
-	if auth != nil {
-		return &model.SuccessfulLogin{RedirectURL: "/auth/callback"}, nil
+	if authReq, _ := auth.AuthorizationByTransaction(ctx, txID(transaction)); authReq != nil {
+		return &model.SuccessfulLogin{RedirectURL: fmt.Sprint(auth.TransactionURL(url.URL{Path: "/auth/callback"}, authReq.TransactionID()))}, nil
+	}
+
+	if samlReq, _ := auth.SAMLRequestByTransaction(ctx, txID(transaction)); samlReq != nil {
+		return &model.SuccessfulLogin{RedirectURL: fmt.Sprint(auth.TransactionURL(url.URL{Path: "/auth/saml/sso"}, samlReq.TransactionID))}, nil
 	}
 
 	return &model.SuccessfulLogin{RedirectURL: "/"}, nil
 }
 
+// BeginReauthentication is the resolver for the beginReauthentication field.
+func (r *mutationResolver) BeginReauthentication(ctx context.Context) (*protocol.CredentialAssertion, error) {
+	user := usermgr.FromContext(ctx)
+	if user == nil {
+		return nil, fmt.Errorf("user not logged in")
+	}
+
+	user, err := r.userWithCredentials(user)
+	if err != nil {
+		return nil, err
+	}
+
+	options, webauthn_session, err := r.WebAuthn.BeginLogin(user)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := dbmodel.CreateAuthSession(ctx, webauthn_session, r.WebAuthn.Config.Timeouts.Login.Timeout); err != nil {
+		return nil, fmt.Errorf("cannot start re-authentication: %v", err)
+	}
+	return options, nil
+}
+
+// CompleteReauthentication is the resolver for the completeReauthentication field.
+func (r *mutationResolver) CompleteReauthentication(ctx context.Context, body string) (bool, error) {
+	user := usermgr.FromContext(ctx)
+	if user == nil {
+		return false, fmt.Errorf("user not logged in")
+	}
+
+	user, err := r.userWithCredentials(user)
+	if err != nil {
+		return false, err
+	}
+
+	auth_session, err := dbmodel.FirstAuthSession(ctx)
+	if err != nil {
+		return false, fmt.Errorf("re-authentication failed")
+	}
+
+	parsedResponse, err := protocol.ParseCredentialRequestResponseBody(strings.NewReader(body))
+	if err != nil {
+		return false, fmt.Errorf("re-authentication failed")
+	}
+
+	cred, err := r.WebAuthn.ValidateLogin(user, auth_session.Value(), parsedResponse)
+	if err != nil {
+		return false, fmt.Errorf("re-authentication failed")
+	}
+
+	dbCred, err := assertedCredential(user, cred.ID)
+	if err != nil {
+		return false, fmt.Errorf("re-authentication failed")
+	}
+
+	now := time.Now()
+	dbCred.Data = *cred
+	dbCred.LastLogin = &now
+
+	// The login renews the authentication time of the current session
+	if err := r.DB.Transaction(func(tx *gorm.DB) error {
+		if r := tx.Delete(auth_session); r.Error != nil {
+			return r.Error
+		}
+		if r := tx.Model(dbCred).Select("Data", "LastLogin").Updates(dbCred); r.Error != nil {
+			return r.Error
+		}
+		return dbmodel.LoginUser(ctx, dbmodel.LoginOpt{Credential: dbCred, Tx: tx})
+	}); err != nil {
+		return false, fmt.Errorf("re-authentication failed: %v", err)
+	}
+	return true, nil
+}
+
 // RemoveSession is the resolver for the removeSession field.
 func (r *mutationResolver) RemoveSession(ctx context.Context, id string) (bool, error) {
 	user := usermgr.FromContext(ctx)
@@ -268,6 +639,186 @@ func (r *mutationResolver) RemoveSession(ctx context.Context, id string) (bool,
 	return true, nil
 }
 
+// DeleteAccount is the resolver for the deleteAccount field.
+func (r *mutationResolver) DeleteAccount(ctx context.Context) (bool, error) {
+	user := usermgr.FromContext(ctx)
+	if user == nil {
+		return false, fmt.Errorf("user not logged in")
+	}
+
+	if err := r.requireRecentAuthentication(ctx, user); err != nil {
+		return false, err
+	}
+
+	if err := r.deleteAccount(ctx, user); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// CreateGroup is the resolver for the createGroup field.
+func (r *mutationResolver) CreateGroup(ctx context.Context, name string, description *string) (*dbmodel.Group, error) {
+	if err := r.requireAdmin(ctx); err != nil {
+		return nil, err
+	}
+
+	group, err := dbmodel.CreateGroup(r.DB, name, optional(description))
+	if err != nil {
+		return nil, fmt.Errorf("cannot create group: %v", err)
+	}
+	return group, nil
+}
+
+// DeleteGroup is the resolver for the deleteGroup field.
+func (r *mutationResolver) DeleteGroup(ctx context.Context, name string) (bool, error) {
+	if err := r.requireAdmin(ctx); err != nil {
+		return false, err
+	}
+
+	group, err := dbmodel.GroupByName(r.DB, name)
+	if err != nil {
+		return false, fmt.Errorf("group not found")
+	}
+
+	if err := dbmodel.DeleteGroup(r.DB, group); err != nil {
+		return false, fmt.Errorf("cannot delete group: %v", err)
+	}
+	return true, nil
+}
+
+// AddGroupMember is the resolver for the addGroupMember field.
+func (r *mutationResolver) AddGroupMember(ctx context.Context, name string, userID string) (*dbmodel.Group, error) {
+	if err := r.requireAdmin(ctx); err != nil {
+		return nil, err
+	}
+
+	group, user, err := r.groupAndUser(name, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := group.AddMember(r.DB, user); err != nil {
+		return nil, fmt.Errorf("cannot add member: %v", err)
+	}
+	return group, nil
+}
+
+// RemoveGroupMember is the resolver for the removeGroupMember field.
+func (r *mutationResolver) RemoveGroupMember(ctx context.Context, name string, userID string) (*dbmodel.Group, error) {
+	if err := r.requireAdmin(ctx); err != nil {
+		return nil, err
+	}
+
+	group, user, err := r.groupAndUser(name, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := group.RemoveMember(r.DB, user); err != nil {
+		return nil, fmt.Errorf("cannot remove member: %v", err)
+	}
+	return group, nil
+}
+
+// CreateRole is the resolver for the createRole field.
+func (r *mutationResolver) CreateRole(ctx context.Context, name string, description *string) (*dbmodel.Role, error) {
+	if err := r.requireAdmin(ctx); err != nil {
+		return nil, err
+	}
+
+	role, err := dbmodel.CreateRole(r.DB, name, optional(description))
+	if err != nil {
+		return nil, fmt.Errorf("cannot create role: %v", err)
+	}
+	return role, nil
+}
+
+// DeleteRole is the resolver for the deleteRole field.
+func (r *mutationResolver) DeleteRole(ctx context.Context, name string) (bool, error) {
+	if err := r.requireAdmin(ctx); err != nil {
+		return false, err
+	}
+
+	role, err := dbmodel.RoleByName(r.DB, name)
+	if err != nil {
+		return false, fmt.Errorf("role not found")
+	}
+
+	if err := dbmodel.DeleteRole(r.DB, role); err != nil {
+		return false, fmt.Errorf("cannot delete role: %v", err)
+	}
+	return true, nil
+}
+
+// AddRoleMember is the resolver for the addRoleMember field.
+func (r *mutationResolver) AddRoleMember(ctx context.Context, name string, userID string) (*dbmodel.Role, error) {
+	if err := r.requireAdmin(ctx); err != nil {
+		return nil, err
+	}
+
+	role, user, err := r.roleAndUser(name, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := role.AddMember(r.DB, user); err != nil {
+		return nil, fmt.Errorf("cannot add member: %v", err)
+	}
+	return role, nil
+}
+
+// RemoveRoleMember is the resolver for the removeRoleMember field.
+func (r *mutationResolver) RemoveRoleMember(ctx context.Context, name string, userID string) (*dbmodel.Role, error) {
+	if err := r.requireAdmin(ctx); err != nil {
+		return nil, err
+	}
+
+	role, user, err := r.roleAndUser(name, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := role.RemoveMember(r.DB, user); err != nil {
+		return nil, fmt.Errorf("cannot remove member: %v", err)
+	}
+	return role, nil
+}
+
+// ApproveUser is the resolver for the approveUser field.
+func (r *mutationResolver) ApproveUser(ctx context.Context, id string) (*dbmodel.User, error) {
+	if err := r.requireAdmin(ctx); err != nil {
+		return nil, err
+	}
+
+	user, err := r.userByID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := user.Approve(r.DB); err != nil {
+		return nil, fmt.Errorf("cannot approve user: %v", err)
+	}
+	return user, nil
+}
+
+// RejectUser is the resolver for the rejectUser field.
+func (r *mutationResolver) RejectUser(ctx context.Context, id string) (*dbmodel.User, error) {
+	if err := r.requireAdmin(ctx); err != nil {
+		return nil, err
+	}
+
+	user, err := r.userByID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := user.Reject(r.DB); err != nil {
+		return nil, fmt.Errorf("cannot reject user: %v", err)
+	}
+	return user, nil
+}
+
 // Me is the resolver for the me field.
 func (r *queryResolver) Me(ctx context.Context) (*dbmodel.User, error) {
 	user := usermgr.FromContext(ctx)
@@ -299,24 +850,170 @@ func (r *queryResolver) Sessions(ctx context.Context) ([]*dbmodel.Session, error
 	return dbmodel.AllSessionByUserID(r.DB, user.ID)
 }
 
+// Groups is the resolver for the groups field.
+func (r *queryResolver) Groups(ctx context.Context) ([]*dbmodel.Group, error) {
+	if err := r.requireAdmin(ctx); err != nil {
+		return nil, err
+	}
+
+	groups := []*dbmodel.Group{}
+	if result := r.DB.Order("name").Find(&groups); result.Error != nil {
+		return nil, result.Error
+	}
+	return groups, nil
+}
+
+// Roles is the resolver for the roles field.
+func (r *queryResolver) Roles(ctx context.Context) ([]*dbmodel.Role, error) {
+	if err := r.requireAdmin(ctx); err != nil {
+		return nil, err
+	}
+
+	roles := []*dbmodel.Role{}
+	if result := r.DB.Order("name").Find(&roles); result.Error != nil {
+		return nil, result.Error
+	}
+	return roles, nil
+}
+
+// ProfileAttributes is the resolver for the profileAttributes field.
+func (r *queryResolver) ProfileAttributes(ctx context.Context) ([]*dbmodel.AttributeDefinition, error) {
+	defs := []*dbmodel.AttributeDefinition{}
+	for i := range r.Resolver.ProfileAttributes {
+		defs = append(defs, &r.Resolver.ProfileAttributes[i])
+	}
+	return defs, nil
+}
+
+// PendingUsers is the resolver for the pendingUsers field.
+func (r *queryResolver) PendingUsers(ctx context.Context) ([]*dbmodel.User, error) {
+	if err := r.requireAdmin(ctx); err != nil {
+		return nil, err
+	}
+
+	users, err := dbmodel.PendingUsers(r.DB)
+	if err != nil {
+		return nil, err
+	}
+
+	result := []*dbmodel.User{}
+	for i := range users {
+		result = append(result, &users[i])
+	}
+	return result, nil
+}
+
+// ProofOfWork is the resolver for the proofOfWork field.
+func (r *queryResolver) ProofOfWork(ctx context.Context) (*pow.Challenge, error) {
+	challenge, err := r.Resolver.ProofOfWork.NewChallenge()
+	if err != nil {
+		return nil, fmt.Errorf("cannot create challenge: %v", err)
+	}
+	return challenge, nil
+}
+
+// ExportMyData is the resolver for the exportMyData field.
+func (r *queryResolver) ExportMyData(ctx context.Context) (string, error) {
+	user := usermgr.FromContext(ctx)
+	if user == nil {
+		return "", fmt.Errorf("user not logged in")
+	}
+
+	return r.exportData(ctx, user)
+}
+
+// ID is the resolver for the id field.
+func (r *roleResolver) ID(ctx context.Context, obj *dbmodel.Role) (string, error) {
+	return fmt.Sprintf("%d", obj.ID), nil
+}
+
 // ID is the resolver for the id field.
 func (r *sessionResolver) ID(ctx context.Context, obj *dbmodel.Session) (string, error) {
 	return fmt.Sprint(obj.ID), nil
 }
 
+// ID is the resolver for the id field.
+func (r *userResolver) ID(ctx context.Context, obj *dbmodel.User) (string, error) {
+	return fmt.Sprintf("%d", obj.ID), nil
+}
+
+// Emails is the resolver for the emails field.
+func (r *userResolver) Emails(ctx context.Context, obj *dbmodel.User) ([]*dbmodel.EmailAddress, error) {
+	addrs, err := dbmodel.EmailAddressesByUserID(r.DB, obj.ID)
+	if err != nil {
+		return nil, fmt.Errorf("cannot get email addresses: %v", err)
+	}
+
+	result := []*dbmodel.EmailAddress{}
+	for i := range addrs {
+		result = append(result, &addrs[i])
+	}
+	return result, nil
+}
+
+// Attributes is the resolver for the attributes field.
+func (r *userResolver) Attributes(ctx context.Context, obj *dbmodel.User) ([]*model.Attribute, error) {
+	attrs := []*model.Attribute{}
+	for name, value := range obj.Attributes {
+		attrs = append(attrs, &model.Attribute{Name: name, Value: value})
+	}
+	slices.SortFunc(attrs, func(a, b *model.Attribute) int { return strings.Compare(a.Name, b.Name) })
+	return attrs, nil
+}
+
+// Groups is the resolver for the groups field.
+func (r *userResolver) Groups(ctx context.Context, obj *dbmodel.User) ([]*dbmodel.Group, error) {
+	groups := []*dbmodel.Group{}
+	if err := r.DB.Model(obj).Order("name").Association("Groups").Find(&groups); err != nil {
+		return nil, err
+	}
+	return groups, nil
+}
+
+// Roles is the resolver for the roles field.
+func (r *userResolver) Roles(ctx context.Context, obj *dbmodel.User) ([]*dbmodel.Role, error) {
+	roles := []*dbmodel.Role{}
+	if err := r.DB.Model(obj).Order("name").Association("Roles").Find(&roles); err != nil {
+		return nil, err
+	}
+	return roles, nil
+}
+
+// RecoveryCodes is the resolver for the recoveryCodes field.
+func (r *userResolver) RecoveryCodes(ctx context.Context, obj *dbmodel.User) (int, error) {
+	count, err := recovery.Count(r.DB, obj.ID)
+	return int(count), err
+}
+
 // Credential returns CredentialResolver implementation.
 func (r *Resolver) Credential() CredentialResolver { return &credentialResolver{r} }
 
+// EmailAddress returns EmailAddressResolver implementation.
+func (r *Resolver) EmailAddress() EmailAddressResolver { return &emailAddressResolver{r} }
+
+// Group returns GroupResolver implementation.
+func (r *Resolver) Group() GroupResolver { return &groupResolver{r} }
+
 // Mutation returns MutationResolver implementation.
 func (r *Resolver) Mutation() MutationResolver { return &mutationResolver{r} }
 
 // Query returns QueryResolver implementation.
 func (r *Resolver) Query() QueryResolver { return &queryResolver{r} }
 
+// Role returns RoleResolver implementation.
+func (r *Resolver) Role() RoleResolver { return &roleResolver{r} }
+
 // Session returns SessionResolver implementation.
 func (r *Resolver) Session() SessionResolver { return &sessionResolver{r} }
 
+// User returns UserResolver implementation.
+func (r *Resolver) User() UserResolver { return &userResolver{r} }
+
 type credentialResolver struct{ *Resolver }
+type emailAddressResolver struct{ *Resolver }
+type groupResolver struct{ *Resolver }
 type mutationResolver struct{ *Resolver }
 type queryResolver struct{ *Resolver }
+type roleResolver struct{ *Resolver }
 type sessionResolver struct{ *Resolver }
+type userResolver struct{ *Resolver }
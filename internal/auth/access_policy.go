@@ -0,0 +1,310 @@
+package auth
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/seb-schulz/onegate/internal/model"
+	"gorm.io/gorm"
+)
+
+// AccessPolicy restricts which users may log in to a client. Logins must
+// satisfy all restrictions while empty restrictions allow everyone.
+type AccessPolicy struct {
+	// UserIDs allows users by ID because names can be changed by users
+	UserIDs []uint `json:"userIds,omitempty"`
+	// Groups allows members of at least one of the groups
+	Groups []string `json:"groups,omitempty"`
+	// Amr requires logins with all of the authentication methods like hwk
+	// for hardware keys
+	Amr []string `json:"amr,omitempty"`
+	// TimeWindows allows logins within at least one of the windows
+	TimeWindows []TimeWindow `json:"timeWindows,omitempty"`
+}
+
+// accessRequest describes a login to a client checked by an access policy.
+type accessRequest struct {
+	user         *model.User
+	groups       []string
+	authnContext model.AuthnContext
+	time         time.Time
+}
+
+// accessDeniedError explains users why a login was denied.
+type accessDeniedError struct {
+	reason string
+}
+
+func (e *accessDeniedError) Error() string {
+	return fmt.Sprintf("access denied: %v", e.reason)
+}
+
+func (p AccessPolicy) IsEmpty() bool {
+	return len(p.UserIDs) == 0 && len(p.Groups) == 0 && len(p.Amr) == 0 && len(p.TimeWindows) == 0
+}
+
+func (p AccessPolicy) Validate() error {
+	for _, tw := range p.TimeWindows {
+		if err := tw.validate(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p AccessPolicy) check(req accessRequest) error {
+	if len(p.UserIDs) > 0 && !slices.Contains(p.UserIDs, req.user.ID) {
+		return &accessDeniedError{"Your account is not allowed to use this application"}
+	}
+
+	if len(p.Groups) > 0 && !slices.ContainsFunc(req.groups, func(g string) bool { return slices.Contains(p.Groups, g) }) {
+		return &accessDeniedError{"You are not member of a group allowed to use this application"}
+	}
+
+	for _, amr := range p.Amr {
+		if !slices.Contains(req.authnContext.Amr, amr) {
+			return &accessDeniedError{"Your passkey does not meet the requirements of this application"}
+		}
+	}
+
+	if len(p.TimeWindows) > 0 && !slices.ContainsFunc(p.TimeWindows, func(tw TimeWindow) bool { return tw.contains(req.time) }) {
+		return &accessDeniedError{"This application cannot be used at this time"}
+	}
+
+	return nil
+}
+
+// legacyAccessPolicy covers policies which allowed users by name.
+type legacyAccessPolicy struct {
+	AccessPolicy
+	Users []string `json:"users,omitempty"`
+}
+
+// migrateAccessPolicy converts the stored policy and groups of the former
+// allowed_groups column into a policy. Names of users are replaced by the IDs
+// of all users with the name. It reports whether the policy changed.
+func migrateAccessPolicy(policyJSON, groupsJSON []byte, userIDsByName func(name string) ([]uint, error)) (AccessPolicy, bool, error) {
+	p := legacyAccessPolicy{}
+	if len(policyJSON) > 0 {
+		if err := json.Unmarshal(policyJSON, &p); err != nil {
+			return p.AccessPolicy, false, fmt.Errorf("invalid access policy: %v", err)
+		}
+	}
+
+	changed := false
+	if len(groupsJSON) > 0 {
+		groups := []string{}
+		if err := json.Unmarshal(groupsJSON, &groups); err != nil {
+			return p.AccessPolicy, false, fmt.Errorf("invalid allowed groups: %v", err)
+		}
+		if len(groups) > 0 && len(p.Groups) == 0 {
+			p.Groups, changed = groups, true
+		}
+	}
+
+	for _, name := range p.Users {
+		ids, err := userIDsByName(name)
+		if err != nil {
+			return p.AccessPolicy, false, err
+		}
+		for _, id := range ids {
+			if !slices.Contains(p.UserIDs, id) {
+				p.UserIDs = append(p.UserIDs, id)
+			}
+		}
+		changed = true
+	}
+
+	// Dropping unknown names would allow everyone to log in
+	if len(p.Users) > 0 && len(p.UserIDs) == 0 {
+		return p.AccessPolicy, false, fmt.Errorf("none of the allowed users %v exists", p.Users)
+	}
+	return p.AccessPolicy, changed, nil
+}
+
+// MigrateAccessPolicies moves the former allowed_groups column and users
+// allowed by name into the access policies of clients.
+func MigrateAccessPolicies(db *gorm.DB) error {
+	hasAllowedGroups := db.Migrator().HasColumn(&Client{}, "allowed_groups")
+	columns := []string{"id", "access_policy"}
+	if hasAllowedGroups {
+		columns = append(columns, "allowed_groups")
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var rows []struct {
+			ID            string
+			AccessPolicy  []byte
+			AllowedGroups []byte
+		}
+		if r := tx.Unscoped().Model(&Client{}).Select(columns).Find(&rows); r.Error != nil {
+			return fmt.Errorf("cannot get access policies: %v", r.Error)
+		}
+
+		for _, row := range rows {
+			p, changed, err := migrateAccessPolicy(row.AccessPolicy, row.AllowedGroups, func(name string) ([]uint, error) {
+				var ids []uint
+				r := tx.Model(&model.User{}).Where("name = ?", name).Pluck("id", &ids)
+				return ids, r.Error
+			})
+			if err != nil {
+				return fmt.Errorf("cannot migrate access policy of client %v (allow users by ID with \"client policy\"): %v", row.ID, err)
+			}
+			if !changed {
+				continue
+			}
+
+			b, err := json.Marshal(p)
+			if err != nil {
+				return err
+			}
+			if r := tx.Unscoped().Model(&Client{}).Where("id = ?", row.ID).Update("access_policy", string(b)); r.Error != nil {
+				return fmt.Errorf("cannot update access policy of client %v: %v", row.ID, r.Error)
+			}
+		}
+		return nil
+	})
+	if err != nil || !hasAllowedGroups {
+		return err
+	}
+	return db.Migrator().DropColumn(&Client{}, "allowed_groups")
+}
+
+const timeOfDayLayout = "15:04"
+
+var weekdays = []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}
+
+// TimeWindow covers the time of day between Start and End on the given days
+// in the location. Windows ending before they start continue past midnight.
+// Empty days cover all days and an empty location is UTC.
+type TimeWindow struct {
+	Days     []string `json:"days,omitempty"`
+	Start    string   `json:"start"`
+	End      string   `json:"end"`
+	Location string   `json:"location,omitempty"`
+}
+
+// ParseTimeWindow parses windows like "mon-fri 08:00-18:00 Europe/Berlin"
+// where days and location are optional.
+func ParseTimeWindow(s string) (TimeWindow, error) {
+	fields := strings.Fields(s)
+	tw := TimeWindow{}
+
+	if len(fields) > 0 && !strings.Contains(fields[0], ":") {
+		days, err := parseDays(fields[0])
+		if err != nil {
+			return tw, err
+		}
+		tw.Days = days
+		fields = fields[1:]
+	}
+
+	if len(fields) == 0 || len(fields) > 2 {
+		return tw, fmt.Errorf("invalid time window %#v", s)
+	}
+
+	start, end, ok := strings.Cut(fields[0], "-")
+	if !ok {
+		return tw, fmt.Errorf("invalid time range %#v", fields[0])
+	}
+	tw.Start, tw.End = start, end
+
+	if len(fields) == 2 {
+		tw.Location = fields[1]
+	}
+	return tw, tw.validate()
+}
+
+// parseDays expands comma separated days and ranges like "mon-fri,sun".
+func parseDays(s string) ([]string, error) {
+	days := []string{}
+	for _, part := range strings.Split(strings.ToLower(s), ",") {
+		first, last, isRange := strings.Cut(part, "-")
+		i, j := slices.Index(weekdays, first), slices.Index(weekdays, last)
+		if !isRange {
+			j = i
+		}
+		if i < 0 || j < 0 {
+			return nil, fmt.Errorf("invalid days %#v", s)
+		}
+
+		for k := i; ; k = (k + 1) % len(weekdays) {
+			if !slices.Contains(days, weekdays[k]) {
+				days = append(days, weekdays[k])
+			}
+			if k == j {
+				break
+			}
+		}
+	}
+	return days, nil
+}
+
+func (tw TimeWindow) validate() error {
+	for _, day := range tw.Days {
+		if !slices.Contains(weekdays, day) {
+			return fmt.Errorf("unknown day %#v", day)
+		}
+	}
+
+	if _, err := time.Parse(timeOfDayLayout, tw.Start); err != nil {
+		return fmt.Errorf("invalid start of time window: %v", err)
+	}
+
+	if _, err := time.Parse(timeOfDayLayout, tw.End); err != nil {
+		return fmt.Errorf("invalid end of time window: %v", err)
+	}
+
+	if _, err := time.LoadLocation(tw.Location); err != nil {
+		return fmt.Errorf("invalid location of time window: %v", err)
+	}
+	return nil
+}
+
+func (tw TimeWindow) includesDay(d time.Weekday) bool {
+	return len(tw.Days) == 0 || slices.Contains(tw.Days, weekdays[d])
+}
+
+// contains returns false for invalid windows.
+func (tw TimeWindow) contains(t time.Time) bool {
+	loc, err := time.LoadLocation(tw.Location)
+	if err != nil {
+		return false
+	}
+
+	start, err := time.Parse(timeOfDayLayout, tw.Start)
+	if err != nil {
+		return false
+	}
+
+	end, err := time.Parse(timeOfDayLayout, tw.End)
+	if err != nil {
+		return false
+	}
+
+	t = t.In(loc)
+	now := t.Hour()*60 + t.Minute()
+	from := start.Hour()*60 + start.Minute()
+	to := end.Hour()*60 + end.Minute()
+
+	if from <= to {
+		return tw.includesDay(t.Weekday()) && from <= now && now < to
+	}
+
+	// The time after midnight belongs to the window of the previous day
+	return (tw.includesDay(t.Weekday()) && now >= from) || (tw.includesDay(t.AddDate(0, 0, -1).Weekday()) && now < to)
+}
+
+func (tw TimeWindow) String() string {
+	s := fmt.Sprintf("%v-%v", tw.Start, tw.End)
+	if len(tw.Days) > 0 {
+		s = fmt.Sprintf("%v %v", strings.Join(tw.Days, ","), s)
+	}
+	if tw.Location != "" {
+		s = fmt.Sprintf("%v %v", s, tw.Location)
+	}
+	return s
+}
@@ -0,0 +1,272 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/seb-schulz/onegate/internal/model"
+	"gorm.io/gorm"
+)
+
+func TestParseTimeWindow(t *testing.T) {
+	for _, tc := range []struct {
+		s        string
+		expected TimeWindow
+		valid    bool
+	}{
+		{"08:00-18:00", TimeWindow{Start: "08:00", End: "18:00"}, true},
+		{"mon-fri 08:00-18:00 UTC", TimeWindow{Days: []string{"mon", "tue", "wed", "thu", "fri"}, Start: "08:00", End: "18:00", Location: "UTC"}, true},
+		{"Fri-Mon,wed 22:00-06:00", TimeWindow{Days: []string{"fri", "sat", "sun", "mon", "wed"}, Start: "22:00", End: "06:00"}, true},
+		{"", TimeWindow{}, false},
+		{"mon", TimeWindow{}, false},
+		{"foo 08:00-18:00", TimeWindow{}, false},
+		{"08:00", TimeWindow{}, false},
+		{"08:00-25:00", TimeWindow{}, false},
+		{"08:00-18:00 Nowhere/Town", TimeWindow{}, false},
+	} {
+		tw, err := ParseTimeWindow(tc.s)
+		if (err == nil) != tc.valid {
+			t.Errorf("%#v: expected valid=%v but got: %v", tc.s, tc.valid, err)
+			continue
+		}
+
+		if tc.valid && !reflect.DeepEqual(tw, tc.expected) {
+			t.Errorf("%#v: expected %#v but got %#v", tc.s, tc.expected, tw)
+		}
+	}
+}
+
+func TestTimeWindow_contains(t *testing.T) {
+	// 2024-06-03 is a Monday
+	at := func(day int, hour, min int) time.Time {
+		return time.Date(2024, 6, day, hour, min, 0, 0, time.UTC)
+	}
+
+	workdays := TimeWindow{Days: []string{"mon", "tue", "wed", "thu", "fri"}, Start: "08:00", End: "18:00"}
+	nights := TimeWindow{Days: []string{"fri"}, Start: "22:00", End: "06:00"}
+	shifted := TimeWindow{Start: "08:00", End: "09:00", Location: "Etc/GMT-2"}
+
+	for _, tc := range []struct {
+		tw       TimeWindow
+		t        time.Time
+		expected bool
+	}{
+		{workdays, at(3, 8, 0), true},
+		{workdays, at(3, 17, 59), true},
+		{workdays, at(3, 18, 0), false},
+		{workdays, at(3, 7, 59), false},
+		{workdays, at(8, 12, 0), false},
+		{nights, at(7, 23, 0), true},
+		{nights, at(8, 5, 59), true},
+		{nights, at(8, 6, 0), false},
+		{nights, at(8, 23, 0), false},
+		{nights, at(7, 5, 0), false},
+		{shifted, at(3, 6, 30), true},
+		{shifted, at(3, 8, 30), false},
+		{TimeWindow{Start: "08:00", End: "18:00", Location: "Nowhere/Town"}, at(3, 12, 0), false},
+	} {
+		if actual := tc.tw.contains(tc.t); actual != tc.expected {
+			t.Errorf("%v at %v: expected %v but got %v", tc.tw, tc.t, tc.expected, actual)
+		}
+	}
+}
+
+func TestAccessPolicy_check(t *testing.T) {
+	req := accessRequest{
+		user:         &model.User{Model: gorm.Model{ID: 1}, Name: "jdoe"},
+		groups:       []string{"staff"},
+		authnContext: model.AuthnContext{Amr: []string{model.AmrSoftwareKey, model.AmrMultiFactor}},
+		time:         time.Date(2024, 6, 3, 12, 0, 0, 0, time.UTC),
+	}
+
+	for _, tc := range []struct {
+		policy  AccessPolicy
+		allowed bool
+	}{
+		{AccessPolicy{}, true},
+		{AccessPolicy{UserIDs: []uint{1, 2}}, true},
+		{AccessPolicy{UserIDs: []uint{2}}, false},
+		{AccessPolicy{Groups: []string{"guests", "staff"}}, true},
+		{AccessPolicy{Groups: []string{"guests"}}, false},
+		{AccessPolicy{Amr: []string{model.AmrMultiFactor}}, true},
+		{AccessPolicy{Amr: []string{model.AmrHardwareKey, model.AmrMultiFactor}}, false},
+		{AccessPolicy{TimeWindows: []TimeWindow{{Start: "00:00", End: "06:00"}, {Start: "08:00", End: "18:00"}}}, true},
+		{AccessPolicy{TimeWindows: []TimeWindow{{Start: "00:00", End: "06:00"}}}, false},
+		{AccessPolicy{UserIDs: []uint{1}, Groups: []string{"guests"}}, false},
+	} {
+		err := tc.policy.check(req)
+		if (err == nil) != tc.allowed {
+			t.Errorf("%#v: expected allowed=%v but got: %v", tc.policy, tc.allowed, err)
+		}
+
+		var denied *accessDeniedError
+		if err != nil && !errors.As(err, &denied) {
+			t.Errorf("%#v: expected access denied error but got: %v", tc.policy, err)
+		}
+	}
+}
+
+func TestMigrateAccessPolicy(t *testing.T) {
+	userIDsByName := func(name string) ([]uint, error) {
+		return map[string][]uint{"jdoe": {1}, "alice": {2, 3}}[name], nil
+	}
+
+	for _, tc := range []struct {
+		policy   string
+		groups   string
+		expected AccessPolicy
+		changed  bool
+		fails    bool
+	}{
+		{"", "", AccessPolicy{}, false, false},
+		{`{"amr":["hwk"]}`, "null", AccessPolicy{Amr: []string{"hwk"}}, false, false},
+		{"", `["staff"]`, AccessPolicy{Groups: []string{"staff"}}, true, false},
+		{`{"groups":["admins"]}`, `["staff"]`, AccessPolicy{Groups: []string{"admins"}}, false, false},
+		{`{"users":["jdoe","alice"]}`, "", AccessPolicy{UserIDs: []uint{1, 2, 3}}, true, false},
+		{`{"users":["jdoe"],"userIds":[1]}`, "", AccessPolicy{UserIDs: []uint{1}}, true, false},
+		{`{"users":["unknown"]}`, "", AccessPolicy{}, false, true},
+	} {
+		p, changed, err := migrateAccessPolicy([]byte(tc.policy), []byte(tc.groups), userIDsByName)
+		if (err != nil) != tc.fails {
+			t.Errorf("%v %v: unexpected error: %v", tc.policy, tc.groups, err)
+			continue
+		}
+		if tc.fails {
+			continue
+		}
+
+		if changed != tc.changed || !reflect.DeepEqual(p, tc.expected) {
+			t.Errorf("%v %v: expected %#v (changed=%v) but got %#v (changed=%v)", tc.policy, tc.groups, tc.expected, tc.changed, p, changed)
+		}
+	}
+}
+
+type mockRestrictedClient struct {
+	mockClient
+	policy AccessPolicy
+}
+
+func (mc *mockRestrictedClient) AccessPolicy() AccessPolicy {
+	return mc.policy
+}
+
+func TestCallbackRedirectHandler_accessPolicy(t *testing.T) {
+	c := &mockRestrictedClient{mockClient{uuid.New(), "https://example.com/cb"}, AccessPolicy{Groups: []string{"staff"}}}
+	authReq := &mockAuthorization{
+		Authorization{InternalTransactionID: "tx1", InternalState: "state", InternalClientID: c.ClientID()},
+		c.mockClient,
+		nil,
+	}
+
+	for _, tc := range []struct {
+		groups []string
+		status int
+	}{
+		{[]string{"guests"}, http.StatusForbidden},
+		{nil, http.StatusForbidden},
+		{[]string{"staff"}, http.StatusFound},
+	} {
+		cr := callbackRedirectHandler{
+			authorizationByTransaction: func(ctx context.Context, txID string) (authorization, error) {
+				return authReq, nil
+			},
+			clientByClientID: func(ctx context.Context, clientID string) (client, error) {
+				return c, nil
+			},
+			groupsByUserID: func(ctx context.Context, userID uint) ([]string, error) {
+				return tc.groups, nil
+			},
+			currentUser: func(ctx context.Context) *model.User {
+				return &model.User{Model: gorm.Model{ID: 1}}
+			},
+			currentAuthnContext: func(ctx context.Context) (*model.AuthnContext, error) {
+				return &model.AuthnContext{Acr: model.AcrPasskeyUV}, nil
+			},
+			loginUrl: url.URL{Path: "/login"},
+		}
+
+		rr := httptest.NewRecorder()
+		cr.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/callback?"+TransactionParam+"=tx1", nil))
+
+		if rr.Code != tc.status {
+			t.Errorf("%v: expected status %v but got %v", tc.groups, tc.status, rr.Code)
+			continue
+		}
+
+		switch tc.status {
+		case http.StatusForbidden:
+			if authReq.userID != nil {
+				t.Errorf("%v: expected user ID not to be set", tc.groups)
+			}
+
+			body := rr.Body.String()
+			if !strings.Contains(body, "Access denied") || !strings.Contains(body, "https://example.com/cb?error=access_denied&amp;error_description=") || !strings.Contains(body, "state=state") {
+				t.Errorf("%v: unexpected access denied page %v", tc.groups, body)
+			}
+		case http.StatusFound:
+			if location := rr.Header().Get("Location"); location != "https://example.com/cb?code=mno&state=state" {
+				t.Errorf("%v: unexpected redirect to %v", tc.groups, location)
+			}
+		}
+	}
+}
+
+func TestCallbackRedirectHandler_awaitingApproval(t *testing.T) {
+	c := &mockRestrictedClient{mockClient{uuid.New(), "https://example.com/cb"}, AccessPolicy{}}
+	authReq := &mockAuthorization{
+		Authorization{InternalTransactionID: "tx1", InternalState: "state", InternalClientID: c.ClientID()},
+		c.mockClient,
+		nil,
+	}
+
+	for _, tc := range []struct {
+		status string
+		code   int
+	}{
+		{model.ApprovalPending, http.StatusForbidden},
+		{model.ApprovalRejected, http.StatusForbidden},
+		{model.ApprovalApproved, http.StatusFound},
+	} {
+		authReq.userID = nil
+		cr := callbackRedirectHandler{
+			authorizationByTransaction: func(ctx context.Context, txID string) (authorization, error) {
+				return authReq, nil
+			},
+			clientByClientID: func(ctx context.Context, clientID string) (client, error) {
+				return c, nil
+			},
+			currentUser: func(ctx context.Context) *model.User {
+				return &model.User{Model: gorm.Model{ID: 1}, ApprovalStatus: tc.status}
+			},
+			currentAuthnContext: func(ctx context.Context) (*model.AuthnContext, error) {
+				return &model.AuthnContext{Acr: model.AcrPasskeyUV}, nil
+			},
+			loginUrl: url.URL{Path: "/login"},
+		}
+
+		rr := httptest.NewRecorder()
+		cr.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/callback?"+TransactionParam+"=tx1", nil))
+
+		if rr.Code != tc.code {
+			t.Errorf("%v: expected status %v but got %v", tc.status, tc.code, rr.Code)
+			continue
+		}
+
+		if tc.code == http.StatusForbidden {
+			if authReq.userID != nil {
+				t.Errorf("%v: expected user ID not to be set", tc.status)
+			}
+			if body := rr.Body.String(); !strings.Contains(body, "Awaiting approval") || !strings.Contains(body, "error=access_denied") {
+				t.Errorf("%v: unexpected page %v", tc.status, body)
+			}
+		}
+	}
+}
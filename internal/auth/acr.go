@@ -0,0 +1,43 @@
+package auth
+
+import (
+	"context"
+
+	"github.com/seb-schulz/onegate/internal/model"
+)
+
+// acrRequirement returns the lowest assurance level accepted for an
+// authorization. Any of the requested acr values is sufficient but none may be
+// weaker than the minimum of the client. A level of -1 accepts every session.
+func acrRequirement(minAcr string, acrValues []string) int {
+	requested := -1
+	for _, v := range acrValues {
+		if l := model.AcrLevel(v); l >= 0 && (requested < 0 || l < requested) {
+			requested = l
+		}
+	}
+	return max(model.AcrLevel(minAcr), requested)
+}
+
+// StepUpRequired reports whether the pending authorization of the given
+// transaction demands a stronger authentication than the session provides.
+func StepUpRequired(ctx context.Context, txID string) bool {
+	ac, err := model.CurrentAuthnContext(ctx)
+	if err != nil {
+		return false
+	}
+
+	return !SatisfiesAuthorization(ctx, txID, *ac)
+}
+
+// SatisfiesAuthorization reports whether an authentication context is strong
+// enough for the pending authorization of the given transaction. It allows
+// checking a login before it replaces the context of the session.
+func SatisfiesAuthorization(ctx context.Context, txID string, ac model.AuthnContext) bool {
+	authReq, err := AuthorizationByTransaction(ctx, txID)
+	if err != nil {
+		return true
+	}
+
+	return authReq.satisfiedBy(ac)
+}
@@ -8,6 +8,7 @@ import (
 	"net/http/httptest"
 	"net/url"
 	"testing"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/golang-jwt/jwt/v5"
@@ -38,6 +39,46 @@ func (mc *mockClient) RedirectURI() string {
 	return mc.r
 }
 
+func (mc *mockClient) AllowedRedirectURIs() []string {
+	return []string{mc.r}
+}
+
+func (mc *mockClient) TokenEndpointAuthMethod() string {
+	return ""
+}
+
+func (mc *mockClient) ExchangeAudiences() []string {
+	return nil
+}
+
+func (mc *mockClient) ExchangeScopes() []string {
+	return nil
+}
+
+func (mc *mockClient) MinAcr() string {
+	return ""
+}
+
+func (mc *mockClient) AccessPolicy() AccessPolicy {
+	return AccessPolicy{}
+}
+
+func (mc *mockClient) ClaimMappings() ClaimMappings {
+	return nil
+}
+
+func (mc *mockClient) SubjectType() string {
+	return SubjectTypePublic
+}
+
+func (mc *mockClient) SectorIdentifier() string {
+	return ""
+}
+
+func mockSubject(ctx context.Context, c client, userID uint) (string, error) {
+	return fmt.Sprint(userID), nil
+}
+
 type mockAuthorization struct {
 	Authorization
 	client mockClient
@@ -52,8 +93,9 @@ func (ma *mockAuthorization) RedirectURI() string {
 	return ma.client.RedirectURI()
 }
 
-func (ma *mockAuthorization) SetUserID(ctx context.Context, userID uint) error {
+func (ma *mockAuthorization) SetUserID(ctx context.Context, userID uint, ac model.AuthnContext) error {
 	ma.userID = &userID
+	ma.InternalAuthnContext = ac
 	return nil
 }
 
@@ -110,17 +152,18 @@ func TestAuthCodeFlow(t *testing.T) {
 	authorizationRequestHandler := &authorizationRequestHandler{
 		clientByClientID: clientByClientID,
 		loginUrl:         url.URL{Path: "/login"},
-		createAuthorization: func(ctx context.Context, client client, state, codeChallenge string) error {
+		createAuthorization: func(ctx context.Context, client client, params authorizationParams) (string, error) {
 			mock.currentAuthorization = &mockAuthorization{
 				Authorization{
-					InternalState:         state,
-					InternalCodeChallenge: codeChallenge,
+					InternalTransactionID: "tx1",
+					InternalState:         params.State,
+					InternalCodeChallenge: params.CodeChallenge,
 					InternalClientID:      client.ClientID(),
 				},
 				mockClient,
 				nil,
 			}
-			return nil
+			return mock.currentAuthorization.TransactionID(), nil
 		},
 	}
 	route.Get("/auth", authorizationRequestHandler.ServeHTTP)
@@ -130,17 +173,24 @@ func TestAuthCodeFlow(t *testing.T) {
 		uID := uint(1)
 		mock.currentAuthorization.InternalUserID = &uID
 		// mock.currentAuthorization.User = &model.User{Model: gorm.Model{ID: 1}}
-		http.Redirect(w, r, "/callback", http.StatusFound)
+		http.Redirect(w, r, fmt.Sprint(TransactionURL(url.URL{Path: "/callback"}, r.FormValue(TransactionParam))), http.StatusFound)
 
 	})
 
 	callbackRedirectHandler := &callbackRedirectHandler{
-		currentAuthorization: func(ctx context.Context) (authorization, error) {
+		authorizationByTransaction: func(ctx context.Context, txID string) (authorization, error) {
+			if txID != mock.currentAuthorization.TransactionID() {
+				return nil, fmt.Errorf("unknown transaction %#v", txID)
+			}
 			return mock.currentAuthorization, nil
 		},
+		clientByClientID: clientByClientID,
 		currentUser: func(ctx context.Context) *model.User {
 			return &mockUser
 		},
+		currentAuthnContext: func(ctx context.Context) (*model.AuthnContext, error) {
+			return &model.AuthnContext{Acr: model.AcrPasskeyUV}, nil
+		},
 	}
 	route.Get("/callback", callbackRedirectHandler.ServeHTTP)
 
@@ -150,9 +200,16 @@ func TestAuthCodeFlow(t *testing.T) {
 		authorizationByCode: func(ctx context.Context, code string) (authorization, error) {
 			return mock.currentAuthorization, nil
 		},
-		deleteAuthorization: func(ctx context.Context, a authorization) error {
+		redeemAuthorization: func(ctx context.Context, a authorization) error {
 			return nil
 		},
+		revokeAuthorization: func(ctx context.Context, a authorization) error {
+			return nil
+		},
+		issueToken: func(ctx context.Context, a authorization, expiresIn time.Duration) (uuid.UUID, error) {
+			return uuid.New(), nil
+		},
+		subject: mockSubject,
 	}
 	route.Post("/token", tokenHandler.ServeHTTP)
 
@@ -3,15 +3,31 @@ package auth
 import (
 	"context"
 	"encoding/base64"
+	"errors"
 	"fmt"
+	"net/url"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/seb-schulz/onegate/internal/database"
 	"github.com/seb-schulz/onegate/internal/model"
 	"github.com/seb-schulz/onegate/internal/sessionmgr"
+	"gorm.io/gorm"
 )
 
+// TransactionParam is the query parameter which carries the transaction ID of
+// an authorization request through login and callback.
+const TransactionParam = "tx"
+
+// TransactionURL returns u with the transaction ID added to its query.
+func TransactionURL(u url.URL, txID string) *url.URL {
+	q := u.Query()
+	q.Set(TransactionParam, txID)
+	u.RawQuery = q.Encode()
+	return &u
+}
+
 type authorizationCodeChallenger interface {
 	CodeChallenge() string
 }
@@ -19,26 +35,67 @@ type authorizationCodeChallenger interface {
 type authorization interface {
 	ClientID() uuid.UUID
 	UserID() uint
+	TransactionID() string
 	State() string
 	Code() string
+	AuthnContext() model.AuthnContext
+	Resources() []string
+	Scope() string
 	authorizationCodeChallenger
 	redirecter
-	SetUserID(context.Context, uint) error
+	redirectURIRequested() bool
+	satisfiedBy(model.AuthnContext) bool
+	id() uint
+	SetUserID(context.Context, uint, model.AuthnContext) error
+	Redeem(context.Context) error
+	Revoke(context.Context) error
 	Delete(context.Context) error
 }
 
+type authorizationParams struct {
+	State         string
+	CodeChallenge string
+	RedirectURI   string
+	AcrValues     []string
+	Resources     []string
+	Scope         string
+}
+
 type Authorization struct {
 	ID                    uint `gorm:"primarykey"`
 	CreatedAt             time.Time
 	UpdatedAt             time.Time
-	InternalClientID      uuid.UUID   `gorm:"column:client_id;type:VARCHAR;size:191;not null"`
-	Client                Client      `gorm:"foreignKey:InternalClientID"`
-	InternalUserID        *uint       `gorm:"column:user_id"`
-	User                  *model.User `gorm:"foreignKey:InternalUserID"`
-	InternalState         string      `gorm:"column:state"`
-	InternalCode          []byte      `gorm:"column:code;type:BLOB(16)"`
-	InternalCodeChallenge string      `gorm:"column:code_challenge;type:BLOB(16)"`
-	SessionID             uuid.UUID   `gorm:"column:session_id;type:VARCHAR(191);not null"`
+	InternalClientID      uuid.UUID          `gorm:"column:client_id;type:VARCHAR;size:191;not null"`
+	Client                Client             `gorm:"foreignKey:InternalClientID"`
+	InternalUserID        *uint              `gorm:"column:user_id"`
+	User                  *model.User        `gorm:"foreignKey:InternalUserID"`
+	InternalState         string             `gorm:"column:state"`
+	InternalCode          []byte             `gorm:"column:code;type:BLOB(16)"`
+	InternalCodeChallenge string             `gorm:"column:code_challenge;type:BLOB(16)"`
+	SessionID             uuid.UUID          `gorm:"column:session_id;type:VARCHAR(191);not null"`
+	InternalTransactionID string             `gorm:"column:transaction_id;size:191;uniqueIndex"`
+	InternalRedirectURI   string             `gorm:"column:redirect_uri;type:VARCHAR(255);not null;default:''"`
+	InternalResources     []string           `gorm:"column:resources;serializer:json"`
+	InternalScope         string             `gorm:"column:scope;type:VARCHAR(255);not null;default:''"`
+	InternalAcrValues     string             `gorm:"column:acr_values;type:VARCHAR(255);not null;default:''"`
+	InternalAuthnContext  model.AuthnContext `gorm:"embedded"`
+	CodeIssuedAt          *time.Time
+	RedeemedAt            *time.Time
+}
+
+var (
+	errCodeExpired  = errors.New("authorization code expired")
+	errCodeRedeemed = errors.New("authorization code already redeemed")
+
+	errMissingTransaction = errors.New("transaction ID must not be empty")
+)
+
+func isCodeReplay(err error) bool {
+	return errors.Is(err, errCodeRedeemed)
+}
+
+func (a *Authorization) id() uint {
+	return a.ID
 }
 
 func (a *Authorization) ClientID() uuid.UUID {
@@ -52,6 +109,12 @@ func (a *Authorization) UserID() uint {
 	return *a.InternalUserID
 }
 
+// TransactionID identifies the authorization request among the pending
+// requests of a browser session.
+func (a *Authorization) TransactionID() string {
+	return a.InternalTransactionID
+}
+
 func (a *Authorization) State() string {
 	return a.InternalState
 }
@@ -64,16 +127,56 @@ func (a *Authorization) CodeChallenge() string {
 	return a.InternalCodeChallenge
 }
 
+func (a *Authorization) AcrValues() []string {
+	return strings.Fields(a.InternalAcrValues)
+}
+
+// Resources returns the identifiers of the resources requested at the
+// authorization endpoint.
+func (a *Authorization) Resources() []string {
+	return a.InternalResources
+}
+
+func (a *Authorization) Scope() string {
+	return a.InternalScope
+}
+
+func (a *Authorization) AuthnContext() model.AuthnContext {
+	return a.InternalAuthnContext
+}
+
+func (a *Authorization) requiredAcrLevel() int {
+	return acrRequirement(a.Client.MinAcr(), a.AcrValues())
+}
+
+func (a *Authorization) satisfiedBy(ac model.AuthnContext) bool {
+	return ac.AcrLevel() >= a.requiredAcrLevel()
+}
+
 func (a *Authorization) RedirectURI() string {
+	if a.InternalRedirectURI != "" {
+		return a.InternalRedirectURI
+	}
 	return a.Client.RedirectURI()
 }
 
+// redirectURIRequested reports whether the authorization request contained a
+// redirect URI which must be repeated at the token endpoint.
+func (a *Authorization) redirectURIRequested() bool {
+	return a.InternalRedirectURI != ""
+}
+
 func (a *Authorization) IDStr() string {
 	return fmt.Sprint(a.ID)
 }
 
-func (a *Authorization) SetUserID(ctx context.Context, userID uint) error {
-	r := database.FromContext(ctx).Model(a).Update("user_id", userID)
+func (a *Authorization) SetUserID(ctx context.Context, userID uint, ac model.AuthnContext) error {
+	now := time.Now()
+	r := database.FromContext(ctx).Model(a).Updates(Authorization{
+		InternalUserID:       &userID,
+		InternalAuthnContext: ac,
+		CodeIssuedAt:         &now,
+	})
 	if r.Error != nil {
 		return fmt.Errorf("cannot update authorization: %w", r.Error)
 	}
@@ -81,6 +184,43 @@ func (a *Authorization) SetUserID(ctx context.Context, userID uint) error {
 	return nil
 }
 
+// Redeem marks the code as used. It fails with errCodeRedeemed when the code
+// has been presented before.
+func (a *Authorization) Redeem(ctx context.Context) error {
+	now := time.Now()
+	r := database.FromContext(ctx).Model(&Authorization{}).Where("id = ? AND redeemed_at IS NULL", a.ID).Update("redeemed_at", now)
+	if r.Error != nil {
+		return fmt.Errorf("cannot redeem authorization: %w", r.Error)
+	}
+
+	if r.RowsAffected != 1 {
+		return errCodeRedeemed
+	}
+
+	a.RedeemedAt = &now
+	return nil
+}
+
+// Revoke invalidates all tokens issued from the authorization and deletes it
+// (see RFC 6749, section 4.1.2).
+func (a *Authorization) Revoke(ctx context.Context) error {
+	_, err := database.Transaction(ctx, func(tx *gorm.DB) (bool, error) {
+		if r := tx.Model(&IssuedToken{}).Where("authorization_id = ? AND revoked_at IS NULL", a.ID).Update("revoked_at", time.Now()); r.Error != nil {
+			return false, r.Error
+		}
+
+		if r := tx.Delete(a); r.Error != nil {
+			return false, r.Error
+		}
+		return true, nil
+	})
+	if err != nil {
+		return fmt.Errorf("cannot revoke authorization: %v", err)
+	}
+
+	return nil
+}
+
 func (a *Authorization) Delete(ctx context.Context) error {
 	r := database.FromContext(ctx).Delete(&a)
 	if r.Error != nil {
@@ -90,14 +230,48 @@ func (a *Authorization) Delete(ctx context.Context) error {
 	return nil
 }
 
-func createAuthorization(ctx context.Context, client client, state, codeChallenge string) error {
+// MigrateTransactionIDs backfills the transaction IDs of authorizations which
+// were stored before transaction IDs were added. It must run before
+// AutoMigrate because the unique index rejects duplicated empty IDs.
+func MigrateTransactionIDs(db *gorm.DB) error {
+	if !db.Migrator().HasTable(&Authorization{}) {
+		return nil
+	}
+
+	if !db.Migrator().HasColumn(&Authorization{}, "transaction_id") {
+		if err := db.Migrator().AddColumn(&Authorization{}, "InternalTransactionID"); err != nil {
+			return fmt.Errorf("cannot add transaction ID: %v", err)
+		}
+	}
+
+	// Former authorizations were never looked up by transaction so the
+	// unique ID of a row is sufficient
+	r := db.Model(&Authorization{}).
+		Where("transaction_id IS NULL OR transaction_id = ''").
+		Update("transaction_id", gorm.Expr("CONCAT('legacy-', id)"))
+	if r.Error != nil {
+		return fmt.Errorf("cannot backfill transaction IDs: %v", r.Error)
+	}
+	return nil
+}
+
+// newTransactionID returns a random ID of a pending request.
+func newTransactionID() string {
+	txID := make([]byte, 16)
+	if err := readRand(txID); err != nil {
+		panic("cannot generate transaction ID")
+	}
+	return base64.RawURLEncoding.EncodeToString(txID)
+}
+
+func createAuthorization(ctx context.Context, client client, params authorizationParams) (string, error) {
 
-	if state == "" {
-		return fmt.Errorf("state must not be empty")
+	if params.State == "" {
+		return "", fmt.Errorf("state must not be empty")
 	}
 
-	if codeChallenge == "" {
-		return fmt.Errorf("code challenge must not be empty")
+	if params.CodeChallenge == "" {
+		return "", fmt.Errorf("code challenge must not be empty")
 	}
 
 	code := make([]byte, 16)
@@ -108,44 +282,111 @@ func createAuthorization(ctx context.Context, client client, state, codeChalleng
 
 	authReq := Authorization{
 		InternalClientID:      client.ClientID(),
-		InternalState:         state,
-		InternalCodeChallenge: codeChallenge,
+		InternalState:         params.State,
+		InternalCodeChallenge: params.CodeChallenge,
 		InternalCode:          code,
 		SessionID:             sessionmgr.FromContext(ctx).UUID,
+		InternalTransactionID: newTransactionID(),
+		InternalRedirectURI:   params.RedirectURI,
+		InternalResources:     params.Resources,
+		InternalScope:         params.Scope,
+		InternalAcrValues:     strings.Join(params.AcrValues, " "),
 	}
 
 	r := database.FromContext(ctx).Create(&authReq)
 	if r.Error != nil {
-		return fmt.Errorf("cannot create authorization: %v", r.Error)
+		return "", fmt.Errorf("cannot create authorization: %v", r.Error)
 	}
 
-	return nil
+	return authReq.InternalTransactionID, nil
 }
 
-func FirstAuthorization(ctx context.Context) (*Authorization, error) {
+// AuthorizationByTransaction returns the pending authorization of the current
+// session which was started with the given transaction ID.
+func AuthorizationByTransaction(ctx context.Context, txID string) (*Authorization, error) {
+	if txID == "" {
+		return nil, errMissingTransaction
+	}
+
 	sessionID := sessionmgr.FromContext(ctx).UUID
 	authReq := Authorization{}
-	r := database.FromContext(ctx).Preload("Client").Where("session_id = ?", sessionID).First(&authReq)
+	r := database.FromContext(ctx).Preload("Client").Where("session_id = ? AND transaction_id = ?", sessionID, txID).First(&authReq)
 
 	if r.Error != nil {
-		return nil, fmt.Errorf("cannot update authorization: %v", r.Error)
+		return nil, fmt.Errorf("cannot get authorization: %v", r.Error)
 	}
 
 	return &authReq, nil
 }
 
-func authorizationByCode(ctx context.Context, code string) (authorization, error) {
+// authorizationByCode returns the authorization of an issued code. Codes
+// which were redeemed already are returned regardless of their age so that
+// replays can be detected.
+func authorizationByCode(ctx context.Context, code string, expiresIn time.Duration) (authorization, error) {
 	decCode, err := base64.URLEncoding.DecodeString(code)
 	if err != nil {
 		return nil, err
 	}
 
 	authReq := Authorization{}
-	r := database.FromContext(ctx).Where("code = ?", decCode).First(&authReq)
+	r := database.FromContext(ctx).Preload("Client").Where("code = ? AND code_issued_at IS NOT NULL", decCode).First(&authReq)
 
 	if r.Error != nil {
 		return nil, fmt.Errorf("cannot get authorization: %w", r.Error)
 	}
 
+	if authReq.RedeemedAt == nil && time.Since(*authReq.CodeIssuedAt) > expiresIn {
+		return nil, errCodeExpired
+	}
+
 	return &authReq, nil
 }
+
+// deleteExpiredAuthorizations removes expired tokens, codes issued before
+// codeExpiresIn and requests which were abandoned before a user logged in.
+// Redeemed codes are kept while their tokens are valid to detect replays.
+func deleteExpiredAuthorizations(ctx context.Context, codeExpiresIn, requestExpiresIn time.Duration) error {
+	now := time.Now()
+	_, err := database.Transaction(ctx, func(tx *gorm.DB) (bool, error) {
+		if r := tx.Where("expires_at < ?", now).Delete(&IssuedToken{}); r.Error != nil {
+			return false, r.Error
+		}
+
+		activeTokens := tx.Model(&IssuedToken{}).Select("authorization_id")
+		if r := tx.Where("(code_issued_at < ? AND id NOT IN (?))", now.Add(-codeExpiresIn), activeTokens).Or("(code_issued_at IS NULL AND created_at < ?)", now.Add(-requestExpiresIn)).Delete(&Authorization{}); r.Error != nil {
+			return false, r.Error
+		}
+		return true, nil
+	})
+	return err
+}
+
+// AuthorizationsByUserID returns the authorizations of the user with their
+// clients.
+func AuthorizationsByUserID(ctx context.Context, userID uint) ([]Authorization, error) {
+	var authorizations []Authorization
+	if r := database.FromContext(ctx).Preload("Client").Where("user_id = ?", userID).Order("created_at").Find(&authorizations); r.Error != nil {
+		return nil, fmt.Errorf("cannot get authorizations: %v", r.Error)
+	}
+	return authorizations, nil
+}
+
+// RevokeAuthorizationsByUserID revokes all tokens issued to the user and
+// deletes the authorizations of the user including pending ones.
+func RevokeAuthorizationsByUserID(ctx context.Context, userID uint) error {
+	_, err := database.Transaction(ctx, func(tx *gorm.DB) (bool, error) {
+		if r := tx.Model(&IssuedToken{}).Where("user_id = ? AND revoked_at IS NULL", userID).Update("revoked_at", time.Now()); r.Error != nil {
+			return false, r.Error
+		}
+
+		if r := tx.Where("user_id = ?", userID).Delete(&Authorization{}); r.Error != nil {
+			return false, r.Error
+		}
+		return true, nil
+	})
+	if err != nil {
+		return fmt.Errorf("cannot revoke authorizations: %v", err)
+	}
+
+	return nil
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"net/http"
 	"net/url"
+	"strings"
 
 	"github.com/go-oauth2/oauth2/v4/errors"
 )
@@ -14,9 +15,10 @@ func httpAuthError(w http.ResponseWriter, err error) {
 }
 
 type authorizationRequestHandler struct {
-	clientByClientID    clientByClientIDFn
-	createAuthorization func(ctx context.Context, client client, state string, codeChallenge string) error
-	loginUrl            url.URL
+	clientByClientID      clientByClientIDFn
+	resourcesByIdentifier resourcesByIdentifierFn
+	createAuthorization   func(ctx context.Context, client client, params authorizationParams) (string, error)
+	loginUrl              url.URL
 }
 
 func (auth authorizationRequestHandler) checkResponseType(response_type string) error {
@@ -65,10 +67,33 @@ func (auth authorizationRequestHandler) ServeHTTP(w http.ResponseWriter, r *http
 		return
 	}
 
-	if auth.createAuthorization(r.Context(), client, r.FormValue("state"), r.FormValue("code_challenge")); err != nil {
+	if _, err := redirectURIFor(client, r.FormValue("redirect_uri")); err != nil {
 		httpAuthError(w, errors.ErrInvalidRequest)
 		return
 	}
 
-	http.Redirect(w, r, fmt.Sprint(&auth.loginUrl), http.StatusSeeOther)
+	resources := r.Form["resource"]
+	if len(resources) > 0 {
+		if _, err := auth.resourcesByIdentifier(r.Context(), resources); err != nil {
+			httpAuthError(w, errInvalidTarget)
+			return
+		}
+	}
+
+	// Only requested redirect URIs are recorded because they must be repeated
+	// at the token endpoint
+	txID, err := auth.createAuthorization(r.Context(), client, authorizationParams{
+		State:         r.FormValue("state"),
+		CodeChallenge: r.FormValue("code_challenge"),
+		RedirectURI:   r.FormValue("redirect_uri"),
+		AcrValues:     strings.Fields(r.FormValue("acr_values")),
+		Resources:     resources,
+		Scope:         r.FormValue("scope"),
+	})
+	if err != nil {
+		httpAuthError(w, errors.ErrInvalidRequest)
+		return
+	}
+
+	http.Redirect(w, r, fmt.Sprint(TransactionURL(auth.loginUrl, txID)), http.StatusSeeOther)
 }
@@ -4,6 +4,8 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"testing"
+
+	"github.com/seb-schulz/onegate/internal/model"
 )
 
 func TestAuthorizationRequestHandler_checkResponseType(t *testing.T) {
@@ -54,3 +56,22 @@ func TestAuthorizationRequestHandler_checkCodeChallengeMethod(t *testing.T) {
 		}
 	}
 }
+
+func TestAcrRequirement(t *testing.T) {
+	for _, tc := range []struct {
+		minAcr    string
+		acrValues []string
+		expected  int
+	}{
+		{"", nil, -1},
+		{"", []string{"unknown"}, -1},
+		{model.AcrPasskeyUV, nil, model.AcrLevel(model.AcrPasskeyUV)},
+		{"", []string{model.AcrHardwareKeyUV, model.AcrPasskey}, model.AcrLevel(model.AcrPasskey)},
+		{model.AcrPasskeyUV, []string{model.AcrPasskey}, model.AcrLevel(model.AcrPasskeyUV)},
+		{model.AcrPasskey, []string{model.AcrHardwareKeyUV}, model.AcrLevel(model.AcrHardwareKeyUV)},
+	} {
+		if got := acrRequirement(tc.minAcr, tc.acrValues); got != tc.expected {
+			t.Errorf("expected level %d for %#v and %#v but got %d", tc.expected, tc.minAcr, tc.acrValues, got)
+		}
+	}
+}
@@ -9,6 +9,7 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/google/uuid"
@@ -35,10 +36,20 @@ func TestCreateAuthorization(t *testing.T) {
 	}
 	tx.FirstOrCreate(&client)
 
-	if err := createAuthorization(ctx, &client, "state", "CodeChallenge"); err != nil {
+	txID, err := createAuthorization(ctx, &client, authorizationParams{State: "state", CodeChallenge: "CodeChallenge"})
+	if err != nil {
 		t.Errorf("failed to create authorization: %v", err)
 	}
 
+	otherTxID, err := createAuthorization(ctx, &client, authorizationParams{State: "other", CodeChallenge: "CodeChallenge"})
+	if err != nil {
+		t.Errorf("failed to create concurrent authorization: %v", err)
+	}
+
+	if txID == otherTxID {
+		t.Errorf("expected distinct transaction IDs but got %#v twice", txID)
+	}
+
 	// TODO: assert entries after authorization creation
 	// Extract code below into login handler test
 
@@ -46,9 +57,17 @@ func TestCreateAuthorization(t *testing.T) {
 	route.Get("/foo", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		fmt.Fprintln(w, "Ok")
 
-		_, err := FirstAuthorization(r.Context())
-		if err != nil {
-			t.Errorf("cannot find authorization: %v", err)
+		for state, id := range map[string]string{"state": txID, "other": otherTxID} {
+			authReq, err := AuthorizationByTransaction(r.Context(), id)
+			if err != nil {
+				t.Errorf("cannot find authorization: %v", err)
+			} else if authReq.State() != state {
+				t.Errorf("expected state %#v but got %#v", state, authReq.State())
+			}
+		}
+
+		if _, err := AuthorizationByTransaction(r.Context(), ""); err == nil {
+			t.Errorf("expected error for missing transaction ID")
 		}
 	}))
 
@@ -89,29 +108,48 @@ func TestAuthorizationByCode(t *testing.T) {
 	tx.FirstOrCreate(&user)
 
 	code := readRand(16)
+	issuedAt := time.Now()
 
-	r := tx.FirstOrCreate(&Authorization{Client: client, InternalCode: code})
+	r := tx.FirstOrCreate(&Authorization{Client: client, InternalCode: code, CodeIssuedAt: &issuedAt})
 	if r.Error != nil {
 		t.Errorf("cannot create authorization: %v", r.Error)
 	}
 
-	_, err = authorizationByCode(ctx, base64.URLEncoding.EncodeToString([]byte("non existing error")))
+	expiredCode := readRand(16)
+	expiredAt := issuedAt.Add(-time.Hour)
+	if r := tx.Create(&Authorization{Client: client, InternalCode: expiredCode, CodeIssuedAt: &expiredAt}); r.Error != nil {
+		t.Errorf("cannot create authorization: %v", r.Error)
+	}
+
+	_, err = authorizationByCode(ctx, base64.URLEncoding.EncodeToString([]byte("non existing error")), time.Minute)
 	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
 		t.Errorf("failed but with unexpected error msg: %v", err)
 	} else if err == nil {
 		t.Errorf("expected error but found authorization")
 	}
 
-	_, err = authorizationByCode(ctx, "expected decoding error")
+	_, err = authorizationByCode(ctx, "expected decoding error", time.Minute)
 	if err == nil {
 		t.Error("expected decoding error")
 	}
 
-	fetchedAuth, err := authorizationByCode(ctx, base64.URLEncoding.EncodeToString(code))
+	if _, err := authorizationByCode(ctx, base64.URLEncoding.EncodeToString(expiredCode), time.Minute); !errors.Is(err, errCodeExpired) {
+		t.Errorf("expected expired code but got: %v", err)
+	}
+
+	fetchedAuth, err := authorizationByCode(ctx, base64.URLEncoding.EncodeToString(code), time.Minute)
 	if err != nil {
 		t.Errorf("failed to get authorization by code: %v", err)
 	}
 
+	if err := fetchedAuth.Redeem(ctx); err != nil {
+		t.Errorf("cannot redeem authorization: %v", err)
+	}
+
+	if err := fetchedAuth.Redeem(ctx); !isCodeReplay(err) {
+		t.Errorf("expected replay but got: %v", err)
+	}
+
 	t.Logf("fetchedAuth: %v", fetchedAuth)
 }
 
@@ -155,3 +193,40 @@ func TestDeleteAuthorization(t *testing.T) {
 		t.Fatal("entry does exist")
 	}
 }
+
+func TestRevokeAuthorization(t *testing.T) {
+	db, err := database.Open()
+	if err != nil {
+		panic(err)
+	}
+
+	tx := db.Begin()
+	defer tx.Rollback()
+
+	ctx := database.WithContext(context.Background(), tx)
+
+	client := Client{
+		ID: uuid.New(),
+	}
+	tx.FirstOrCreate(&client)
+
+	authReq := Authorization{Client: client}
+	if res := tx.Create(&authReq); res.Error != nil {
+		t.Fatalf("failed to create authorization: %v", res.Error)
+	}
+
+	tokenID, err := issueToken(ctx, &authReq, time.Minute)
+	if err != nil {
+		t.Fatalf("cannot issue token: %v", err)
+	}
+
+	if err := authReq.Revoke(ctx); err != nil {
+		t.Errorf("failed to revoke authorization: %v", err)
+	}
+
+	token := IssuedToken{}
+	tx.First(&token, "id = ?", tokenID)
+	if token.RevokedAt == nil {
+		t.Errorf("token was not revoked")
+	}
+}
@@ -0,0 +1,149 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/seb-schulz/onegate/internal/database"
+)
+
+const (
+	backchannelLogoutEvent   = "http://schemas.openid.net/event/backchannel-logout"
+	backchannelLogoutTimeout = 5 * time.Second
+	logoutTokenExpiresIn     = 2 * time.Minute
+)
+
+type LogoutTokenClaims struct {
+	jwt.RegisteredClaims
+	Events map[string]struct{} `json:"events"`
+}
+
+// LogoutNotification is a signed logout token for the back-channel logout URI
+// of a client.
+type LogoutNotification struct {
+	ClientID uuid.UUID
+	URI      string
+	Token    string
+}
+
+// Send posts the logout token to the client (see OpenID Connect Back-Channel
+// Logout 1.0, section 2.5).
+func (n LogoutNotification) Send(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, backchannelLogoutTimeout)
+	defer cancel()
+
+	body := url.Values{"logout_token": {n.Token}}.Encode()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.URI, strings.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status %v", resp.Status)
+	}
+	return nil
+}
+
+// SendLogoutNotifications posts all logout tokens concurrently and waits
+// until they are sent. Failures are logged only because clients cannot be
+// notified later.
+func SendLogoutNotifications(ctx context.Context, notifications []LogoutNotification) {
+	var wg sync.WaitGroup
+	for _, n := range notifications {
+		wg.Add(1)
+		go func(n LogoutNotification) {
+			defer wg.Done()
+			if err := n.Send(ctx); err != nil {
+				warnf("cannot send back-channel logout to client %v: %v", n.ClientID, err)
+			}
+		}(n)
+	}
+	wg.Wait()
+}
+
+func newLogoutToken(c *Config, clientID uuid.UUID, subject string) (string, error) {
+	jwt.MarshalSingleStringAsArray = false
+
+	now := time.Now()
+	claims := &LogoutTokenClaims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    c.IssuerUrl,
+			Subject:   subject,
+			Audience:  jwt.ClaimStrings{clientID.String()},
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(logoutTokenExpiresIn)),
+			ID:        uuid.NewString(),
+		},
+		Events: map[string]struct{}{backchannelLogoutEvent: {}},
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodES256, claims)
+	token.Header["typ"] = "logout+jwt"
+	return token.SignedString(c.PrivateKey)
+}
+
+// BackchannelLogouts prepares logout tokens for all clients of the user which
+// registered a back-channel logout URI. Subjects derive from the user so the
+// tokens must be prepared before the user is deleted.
+func BackchannelLogouts(ctx context.Context, c *Config, userID uint) ([]LogoutNotification, error) {
+	tx := database.FromContext(ctx)
+
+	var clientIDs []uuid.UUID
+	if r := tx.Model(&Authorization{}).Where("user_id = ?", userID).Distinct().Pluck("client_id", &clientIDs); r.Error != nil {
+		return nil, fmt.Errorf("cannot get clients: %v", r.Error)
+	}
+
+	var tokenClientIDs []uuid.UUID
+	if r := tx.Model(&IssuedToken{}).Where("user_id = ?", userID).Distinct().Pluck("client_id", &tokenClientIDs); r.Error != nil {
+		return nil, fmt.Errorf("cannot get clients: %v", r.Error)
+	}
+	clientIDs = append(clientIDs, tokenClientIDs...)
+	if len(clientIDs) == 0 {
+		return nil, nil
+	}
+
+	var clients []Client
+	if r := tx.Where("id IN ? AND backchannel_logout_uri <> ''", clientIDs).Find(&clients); r.Error != nil {
+		return nil, fmt.Errorf("cannot get clients: %v", r.Error)
+	}
+	if len(clients) == 0 {
+		return nil, nil
+	}
+
+	user, err := userByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	notifications := make([]LogoutNotification, 0, len(clients))
+	for i := range clients {
+		// Other clients are still notified if one cannot be
+		sub, err := subjectIdentifier(&clients[i], user.AuthnID, c.SubjectSalt)
+		if err != nil {
+			warnf("cannot derive subject for back-channel logout of client %v: %v", clients[i].ID, err)
+			continue
+		}
+
+		token, err := newLogoutToken(c, clients[i].ID, sub)
+		if err != nil {
+			warnf("cannot sign logout token for client %v: %v", clients[i].ID, err)
+			continue
+		}
+		notifications = append(notifications, LogoutNotification{ClientID: clients[i].ID, URI: clients[i].Metadata.BackchannelLogoutURI, Token: token})
+	}
+	return notifications, nil
+}
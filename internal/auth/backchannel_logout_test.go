@@ -0,0 +1,90 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+func TestLogoutNotificationSend(t *testing.T) {
+	privKey, err := jwt.ParseECPrivateKeyFromPEM([]byte(privTestKey))
+	if err != nil {
+		t.Fatalf("cannot parse private test key: %v", err)
+	}
+	pubKey, err := jwt.ParseECPublicKeyFromPEM([]byte(pubTestKey))
+	if err != nil {
+		t.Fatalf("cannot parse public test key: %v", err)
+	}
+	clientID := uuid.MustParse("86ec11a2-3bfc-446b-835d-35b563c10c4e")
+
+	token, err := newLogoutToken(&Config{IssuerUrl: "https://example.com", PrivateKey: privKey}, clientID, "UGIJOd0Ts6h7yGH8Gu6Jjg")
+	if err != nil {
+		t.Fatalf("cannot create logout token: %v", err)
+	}
+
+	var received string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = r.PostFormValue("logout_token")
+	}))
+	defer srv.Close()
+
+	if err := (LogoutNotification{ClientID: clientID, URI: srv.URL, Token: token}).Send(context.Background()); err != nil {
+		t.Fatalf("cannot send logout token: %v", err)
+	}
+
+	claims := LogoutTokenClaims{}
+	parsed, err := jwt.ParseWithClaims(received, &claims, func(token *jwt.Token) (interface{}, error) {
+		return pubKey, nil
+	}, jwt.WithValidMethods([]string{"ES256"}), jwt.WithExpirationRequired(), jwt.WithAudience(clientID.String()), jwt.WithIssuer("https://example.com"), jwt.WithLeeway(30*time.Second))
+	if err != nil {
+		t.Fatalf("failed to parse token: %v", err)
+	}
+
+	if parsed.Header["typ"] != "logout+jwt" {
+		t.Errorf("unexpected typ header: %v", parsed.Header["typ"])
+	}
+	if _, ok := claims.Events[backchannelLogoutEvent]; !ok || claims.Subject != "UGIJOd0Ts6h7yGH8Gu6Jjg" || claims.ID == "" {
+		t.Errorf("unexpected claims: %#v", claims)
+	}
+}
+
+func TestLogoutNotificationSendFailure(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer srv.Close()
+
+	if err := (LogoutNotification{URI: srv.URL, Token: "token"}).Send(context.Background()); err == nil {
+		t.Errorf("expected error for bad request")
+	}
+}
+
+func TestSendLogoutNotifications(t *testing.T) {
+	var received atomic.Int32
+	ok := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received.Add(1)
+	}))
+	defer ok.Close()
+
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received.Add(1)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer failing.Close()
+
+	SendLogoutNotifications(context.Background(), []LogoutNotification{
+		{ClientID: uuid.New(), URI: failing.URL, Token: "a"},
+		{ClientID: uuid.New(), URI: ok.URL, Token: "b"},
+		{ClientID: uuid.New(), URI: ok.URL, Token: "c"},
+	})
+
+	if got := received.Load(); got != 3 {
+		t.Errorf("expected 3 notifications but got %d", got)
+	}
+}
@@ -2,17 +2,88 @@ package auth
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"html/template"
 	"log/slog"
 	"net/http"
 	"net/url"
+	"time"
 
 	"github.com/seb-schulz/onegate/internal/model"
 )
 
 type callbackRedirectHandler struct {
-	currentAuthorization func(context.Context) (authorization, error)
-	currentUser          func(ctx context.Context) *model.User
+	authorizationByTransaction func(ctx context.Context, txID string) (authorization, error)
+	clientByClientID           clientByClientIDFn
+	groupsByUserID             func(ctx context.Context, userID uint) ([]string, error)
+	currentUser                func(ctx context.Context) *model.User
+	currentAuthnContext        func(ctx context.Context) (*model.AuthnContext, error)
+	loginUrl                   url.URL
+}
+
+// checkAccessPolicy returns an accessDeniedError if the access policy of the
+// client does not allow the login.
+func (cr callbackRedirectHandler) checkAccessPolicy(ctx context.Context, authReq authorization, user *model.User, ac model.AuthnContext) error {
+	c, err := cr.clientByClientID(ctx, fmt.Sprint(authReq.ClientID()))
+	if err != nil {
+		return err
+	}
+
+	policy := c.AccessPolicy()
+	req := accessRequest{user: user, authnContext: ac, time: time.Now()}
+	if len(policy.Groups) > 0 {
+		if req.groups, err = cr.groupsByUserID(ctx, user.ID); err != nil {
+			return err
+		}
+	}
+	return policy.check(req)
+}
+
+var accessDeniedPage = template.Must(template.New("access_denied").Parse(`<!DOCTYPE html>
+<html lang="en">
+
+<head>
+  <meta charset="utf-8" />
+  <link rel="icon" href="/favicon.ico" />
+  <meta name="viewport" content="width=device-width, initial-scale=1" />
+  <title>{{.Title}} - One Gate</title>
+</head>
+
+<body>
+  <h1>{{.Title}}</h1>
+  <p>{{.Reason}}.</p>
+  {{if .RedirectURL}}<p><a href="{{.RedirectURL}}">Return to application</a></p>{{end}}
+</body>
+
+</html>
+`))
+
+// awaitingApprovalReason explains users why they cannot use clients yet
+const awaitingApprovalReason = "Your registration is awaiting approval by an administrator"
+
+// writeAccessDenied renders the access denied page with an optional link
+// back to the client.
+func writeAccessDenied(w http.ResponseWriter, title, reason, redirectURL string) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusForbidden)
+	accessDeniedPage.Execute(w, struct {
+		Title       string
+		Reason      string
+		RedirectURL string
+	}{title, reason, redirectURL})
+}
+
+// denyAccess explains users why the login was denied and lets them return
+// to the client with an access_denied error.
+func denyAccess(w http.ResponseWriter, authReq authorization, title, reason string) {
+	q := url.Values{}
+	q.Add("error", "access_denied")
+	q.Add("error_description", reason)
+	q.Add("state", authReq.State())
+
+	writeAccessDenied(w, title, reason, fmt.Sprintf("%v?%v", authReq.RedirectURI(), q.Encode()))
 }
 
 func (cr callbackRedirectHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
@@ -23,14 +94,44 @@ func (cr callbackRedirectHandler) ServeHTTP(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
-	authReq, err := cr.currentAuthorization(r.Context())
+	txID := r.URL.Query().Get(TransactionParam)
+	authReq, err := cr.authorizationByTransaction(r.Context(), txID)
 	if err != nil {
 		slog.Warn(fmt.Sprintf("oAuth2 callback failed with: %v", err))
 		http.NotFound(w, r)
 		return
 	}
 
-	if err := authReq.SetUserID(r.Context(), user.ID); err != nil {
+	ac, err := cr.currentAuthnContext(r.Context())
+	if err != nil {
+		slog.Warn(fmt.Sprintf("oAuth2 callback failed with: %v", err))
+		http.NotFound(w, r)
+		return
+	}
+
+	if !authReq.satisfiedBy(*ac) {
+		http.Redirect(w, r, fmt.Sprint(TransactionURL(cr.loginUrl, txID)), http.StatusSeeOther)
+		return
+	}
+
+	if !user.IsApproved() {
+		slog.Warn(fmt.Sprintf("user %v is not approved to log in to client %v", user.ID, authReq.ClientID()))
+		denyAccess(w, authReq, "Awaiting approval", awaitingApprovalReason)
+		return
+	}
+
+	var denied *accessDeniedError
+	if err := cr.checkAccessPolicy(r.Context(), authReq, user, *ac); errors.As(err, &denied) {
+		slog.Warn(fmt.Sprintf("user %v is not allowed to log in to client %v: %v", user.ID, authReq.ClientID(), err))
+		denyAccess(w, authReq, "Access denied", denied.reason)
+		return
+	} else if err != nil {
+		slog.Warn(fmt.Sprintf("oAuth2 callback failed with: %v", err))
+		http.NotFound(w, r)
+		return
+	}
+
+	if err := authReq.SetUserID(r.Context(), user.ID, *ac); err != nil {
 		slog.Error(fmt.Sprintf("Failed to assign user ID: %v", err))
 		http.NotFound(w, r)
 		return
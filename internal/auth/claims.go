@@ -0,0 +1,172 @@
+package auth
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"text/template"
+
+	"github.com/seb-schulz/onegate/internal/model"
+)
+
+const (
+	ScopeGroups = "groups"
+	ScopeRoles  = "roles"
+)
+
+// reservedClaims describe tokens instead of users and cannot be mapped.
+var reservedClaims = []string{"iss", "sub", "aud", "exp", "nbf", "iat", "jti", "auth_time", "nonce", "acr", "amr", "azp", "client_id", "scope", "act"}
+
+// ClaimMappings render additional claims of a client from templates over
+// the user, its verified primary email address and its groups and roles like
+// {{.User.Name}}, {{.Email}} or {{index .User.Attributes "department"}}. Templates rendering JSON arrays
+// like {{json .Groups}} yield lists and empty results are omitted.
+type ClaimMappings map[string]string
+
+// claimsData is available to templates of claim mappings.
+type claimsData struct {
+	User *model.User
+	// Email is the verified primary email address or empty
+	Email  string
+	Groups []string
+	Roles  []string
+}
+
+var claimFuncs = template.FuncMap{
+	"join":  strings.Join,
+	"lower": strings.ToLower,
+	"upper": strings.ToUpper,
+	"json": func(v any) (string, error) {
+		b, err := json.Marshal(v)
+		return string(b), err
+	},
+}
+
+func (cm ClaimMappings) Validate() error {
+	for name, text := range cm {
+		if name == "" || slices.Contains(reservedClaims, name) {
+			return fmt.Errorf("claim %#v cannot be mapped", name)
+		}
+
+		if _, err := template.New(name).Funcs(claimFuncs).Option("missingkey=error").Parse(text); err != nil {
+			return fmt.Errorf("invalid template of claim %#v: %v", name, err)
+		}
+	}
+	return nil
+}
+
+func (cm ClaimMappings) apply(data claimsData) (map[string]any, error) {
+	claims := map[string]any{}
+	for name, text := range cm {
+		t, err := template.New(name).Funcs(claimFuncs).Option("missingkey=error").Parse(text)
+		if err != nil {
+			return nil, fmt.Errorf("invalid template of claim %#v: %v", name, err)
+		}
+
+		buf := bytes.Buffer{}
+		if err := t.Execute(&buf, data); err != nil {
+			return nil, fmt.Errorf("cannot render claim %#v: %v", name, err)
+		}
+
+		list := []any{}
+		switch s := buf.String(); {
+		case s == "":
+		case strings.HasPrefix(s, "[") && json.Unmarshal(buf.Bytes(), &list) == nil:
+			claims[name] = list
+		default:
+			claims[name] = s
+		}
+	}
+	return claims, nil
+}
+
+type claimMapper interface {
+	ClaimMappings() ClaimMappings
+}
+
+// extraClaims adds the groups and roles of users to tokens if they are
+// requested by scope as well as the claims mapped by clients. Membership
+// claims without name are never added.
+type extraClaims struct {
+	groupsClaim           string
+	rolesClaim            string
+	userByID              func(ctx context.Context, userID uint) (*model.User, error)
+	verifiedEmailByUserID func(ctx context.Context, userID uint) (string, error)
+	groupsByUserID        func(ctx context.Context, userID uint) ([]string, error)
+	rolesByUserID         func(ctx context.Context, userID uint) ([]string, error)
+}
+
+func (ec extraClaims) claims(ctx context.Context, c claimMapper, userID uint, scope string) (map[string]any, error) {
+	scopes := strings.Fields(scope)
+	claims := map[string]any{}
+
+	if ec.groupsClaim != "" && slices.Contains(scopes, ScopeGroups) {
+		groups, err := ec.groupsByUserID(ctx, userID)
+		if err != nil {
+			return nil, err
+		}
+		claims[ec.groupsClaim] = groups
+	}
+
+	if ec.rolesClaim != "" && slices.Contains(scopes, ScopeRoles) {
+		roles, err := ec.rolesByUserID(ctx, userID)
+		if err != nil {
+			return nil, err
+		}
+		claims[ec.rolesClaim] = roles
+	}
+
+	if len(c.ClaimMappings()) == 0 {
+		return claims, nil
+	}
+
+	data := claimsData{}
+	var err error
+	if data.User, err = ec.userByID(ctx, userID); err != nil {
+		return nil, err
+	}
+	if data.Email, err = ec.verifiedEmailByUserID(ctx, userID); err != nil {
+		return nil, err
+	}
+	if data.Groups, err = ec.groupsByUserID(ctx, userID); err != nil {
+		return nil, err
+	}
+	if data.Roles, err = ec.rolesByUserID(ctx, userID); err != nil {
+		return nil, err
+	}
+
+	mapped, err := c.ClaimMappings().apply(data)
+	if err != nil {
+		return nil, err
+	}
+
+	// Mapped claims may reshape membership claims
+	for k, v := range mapped {
+		claims[k] = v
+	}
+	return claims, nil
+}
+
+// marshalWithExtraClaims adds claims with configurable names to the JSON of
+// v. Claims of v take precedence.
+func marshalWithExtraClaims(v any, extra map[string]any) ([]byte, error) {
+	b, err := json.Marshal(v)
+	if err != nil || len(extra) == 0 {
+		return b, err
+	}
+
+	m := map[string]any{}
+	if err := json.Unmarshal(b, &m); err != nil {
+		return nil, err
+	}
+
+	for k, v := range extra {
+		if _, ok := m[k]; !ok {
+			m[k] = v
+		}
+	}
+	return json.Marshal(m)
+}
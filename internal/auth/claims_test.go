@@ -0,0 +1,192 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/seb-schulz/onegate/internal/model"
+	"gorm.io/gorm"
+)
+
+type mockMappingClient struct {
+	mockClient
+	mappings ClaimMappings
+}
+
+func (mc *mockMappingClient) ClaimMappings() ClaimMappings {
+	return mc.mappings
+}
+
+func TestExtraClaims(t *testing.T) {
+	ec := extraClaims{
+		groupsClaim: "groups",
+		rolesClaim:  "roles",
+		userByID: func(ctx context.Context, userID uint) (*model.User, error) {
+			return &model.User{Name: "jdoe", DisplayName: "John Doe"}, nil
+		},
+		verifiedEmailByUserID: func(ctx context.Context, userID uint) (string, error) {
+			return "jdoe@example.com", nil
+		},
+		groupsByUserID: func(ctx context.Context, userID uint) ([]string, error) {
+			return []string{"staff"}, nil
+		},
+		rolesByUserID: func(ctx context.Context, userID uint) ([]string, error) {
+			return []string{"editor"}, nil
+		},
+	}
+
+	for _, tc := range []struct {
+		scope    string
+		rolesOff bool
+		mappings ClaimMappings
+		expected map[string]any
+	}{
+		{"openid", false, nil, map[string]any{}},
+		{"openid groups", false, nil, map[string]any{"groups": []string{"staff"}}},
+		{"openid groups roles", false, nil, map[string]any{"groups": []string{"staff"}, "roles": []string{"editor"}}},
+		{"openid groups roles", true, nil, map[string]any{"groups": []string{"staff"}}},
+		{"openid", false, ClaimMappings{"username": "{{.User.Name}}"}, map[string]any{"username": "jdoe"}},
+		{"openid", false, ClaimMappings{"mail": "{{.Email}}"}, map[string]any{"mail": "jdoe@example.com"}},
+		{"openid groups", false, ClaimMappings{"groups": "{{json .Roles}}"}, map[string]any{"groups": []any{"editor"}}},
+	} {
+		ec := ec
+		if tc.rolesOff {
+			ec.rolesClaim = ""
+		}
+
+		claims, err := ec.claims(context.Background(), &mockMappingClient{mappings: tc.mappings}, 1, tc.scope)
+		if err != nil {
+			t.Fatalf("%v: cannot get claims: %v", tc.scope, err)
+		}
+
+		if !reflect.DeepEqual(claims, tc.expected) {
+			t.Errorf("%v: expected %v but got %v", tc.scope, tc.expected, claims)
+		}
+	}
+}
+
+func TestMarshalWithExtraClaims(t *testing.T) {
+	b, err := marshalWithExtraClaims(struct {
+		Subject string `json:"sub"`
+	}{"user"}, map[string]any{"sub": "other", "groups": []string{"staff"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	claims := map[string]any{}
+	if err := json.Unmarshal(b, &claims); err != nil {
+		t.Fatal(err)
+	}
+
+	if claims["sub"] != "user" {
+		t.Errorf("expected extra claim not to replace sub but got %v", claims["sub"])
+	}
+
+	if !reflect.DeepEqual(claims["groups"], []any{"staff"}) {
+		t.Errorf("expected groups claim but got %v", claims)
+	}
+}
+
+func TestClaimMappings(t *testing.T) {
+	data := claimsData{
+		User:   &model.User{Name: "jdoe", DisplayName: "John Doe"},
+		Groups: []string{"staff", "admins"},
+		Roles:  []string{},
+	}
+
+	claims, err := ClaimMappings{
+		"username": "{{.User.Name}}",
+		"mail":     "{{lower .User.Name}}@example.com",
+		"teams":    "{{json .Groups}}",
+		"team":     "{{join .Groups \",\"}}",
+		"nickname": "{{.User.ExternalID}}",
+		"tags":     "[{{.User.Name}}",
+	}.apply(data)
+	if err != nil {
+		t.Fatalf("cannot apply claim mappings: %v", err)
+	}
+
+	expected := map[string]any{
+		"username": "jdoe",
+		"mail":     "jdoe@example.com",
+		"teams":    []any{"staff", "admins"},
+		"team":     "staff,admins",
+		"tags":     "[jdoe",
+	}
+	if !reflect.DeepEqual(claims, expected) {
+		t.Errorf("expected %v but got %v", expected, claims)
+	}
+
+	if _, err := (ClaimMappings{"mail": "{{.User.Mail}}"}).apply(data); err == nil {
+		t.Errorf("expected unknown field to fail")
+	}
+
+	for _, cm := range []ClaimMappings{
+		{"sub": "{{.User.Name}}"},
+		{"": "{{.User.Name}}"},
+		{"username": "{{.User.Name"},
+		{"username": "{{unknown .User.Name}}"},
+	} {
+		if err := cm.Validate(); err == nil {
+			t.Errorf("expected %v to be invalid", cm)
+		}
+	}
+}
+
+func TestCallbackRedirectHandler_userAllowed(t *testing.T) {
+	c := &mockRestrictedClient{mockClient{uuid.New(), "https://example.com/cb"}, AccessPolicy{Groups: []string{"staff"}}}
+	authReq := &mockAuthorization{
+		Authorization{InternalTransactionID: "tx1", InternalState: "state", InternalClientID: c.ClientID()},
+		c.mockClient,
+		nil,
+	}
+
+	for _, tc := range []struct {
+		groups   []string
+		location string
+	}{
+		{[]string{"guests"}, ""},
+		{nil, ""},
+		{[]string{"staff"}, "https://example.com/cb?code=mno&state=state"},
+	} {
+		cr := callbackRedirectHandler{
+			authorizationByTransaction: func(ctx context.Context, txID string) (authorization, error) {
+				return authReq, nil
+			},
+			clientByClientID: func(ctx context.Context, clientID string) (client, error) {
+				return c, nil
+			},
+			groupsByUserID: func(ctx context.Context, userID uint) ([]string, error) {
+				return tc.groups, nil
+			},
+			currentUser: func(ctx context.Context) *model.User {
+				return &model.User{Model: gorm.Model{ID: 1}}
+			},
+			currentAuthnContext: func(ctx context.Context) (*model.AuthnContext, error) {
+				return &model.AuthnContext{Acr: model.AcrPasskeyUV}, nil
+			},
+			loginUrl: url.URL{Path: "/login"},
+		}
+
+		rr := httptest.NewRecorder()
+		cr.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/callback?"+TransactionParam+"=tx1", nil))
+
+		if location := rr.Header().Get("Location"); location != tc.location {
+			t.Errorf("%v: expected redirect to %#v but got %v %#v", tc.groups, tc.location, rr.Code, location)
+		}
+
+		if tc.location == "" && authReq.userID != nil {
+			t.Errorf("%v: expected user ID not to be set for non-members of allowed groups", tc.groups)
+		}
+	}
+
+	if authReq.userID == nil {
+		t.Errorf("expected user ID to be set for members of allowed groups")
+	}
+}
@@ -9,7 +9,8 @@ import (
 	"errors"
 	"fmt"
 	"hash"
-	"log"
+	"net/url"
+	"slices"
 	"strconv"
 	"time"
 
@@ -35,10 +36,27 @@ type ClientSecretVerifier interface {
 	VerifyClientSecret(string) error
 }
 
+type subjecter interface {
+	SubjectType() string
+	SectorIdentifier() string
+}
+
+type tokenExchanger interface {
+	ExchangeAudiences() []string
+	ExchangeScopes() []string
+}
+
 type client interface {
 	ClientID() uuid.UUID
+	MinAcr() string
+	AccessPolicy() AccessPolicy
+	AllowedRedirectURIs() []string
+	TokenEndpointAuthMethod() string
 	ClientSecretVerifier
+	claimMapper
 	redirecter
+	subjecter
+	tokenExchanger
 }
 
 type clientByClientIDFn func(ctx context.Context, clientID string) (client, error)
@@ -51,6 +69,78 @@ type Client struct {
 	Description         string         `gorm:"type:VARCHAR(255);not null"`
 	ClientSecret        string         `gorm:"type:VARCHAR(255);not null"`
 	InternalRedirectURI string         `gorm:"column:redirect_uri;type:VARCHAR(255);not null"`
+	InternalMinAcr      string         `gorm:"column:min_acr;type:VARCHAR(255);not null;default:''"`
+	InternalSubjectType string         `gorm:"column:subject_type;type:VARCHAR(16);not null;default:'public'"`
+	// InternalSectorIdentifier groups clients sharing pairwise subjects and
+	// defaults to the host of the redirect URI
+	InternalSectorIdentifier string `gorm:"column:sector_identifier;type:VARCHAR(255);not null;default:''"`
+	// RegistrationAccessToken is the hashed token of dynamically registered
+	// clients to manage their own registration (see RFC 7592)
+	RegistrationAccessToken string         `gorm:"type:VARCHAR(255);not null;default:''"`
+	Metadata                ClientMetadata `gorm:"embedded"`
+	// Audiences and scopes the client may request by token exchange
+	InternalExchangeAudiences []string `gorm:"column:exchange_audiences;serializer:json"`
+	InternalExchangeScopes    []string `gorm:"column:exchange_scopes;serializer:json"`
+	// InternalAccessPolicy restricts which users may log in
+	InternalAccessPolicy AccessPolicy `gorm:"column:access_policy;serializer:json"`
+	// InternalClaimMappings add claims to tokens and userinfo responses
+	InternalClaimMappings ClaimMappings `gorm:"column:claim_mappings;serializer:json"`
+}
+
+const (
+	SubjectTypePublic   = "public"
+	SubjectTypePairwise = "pairwise"
+)
+
+type ClientOptFunc func(*Client)
+
+// WithMinAcr requires logins for a client to reach at least the given acr.
+// Weaker sessions are stepped up instead of being reused.
+func WithMinAcr(acr string) ClientOptFunc {
+	return func(c *Client) {
+		c.InternalMinAcr = acr
+	}
+}
+
+// WithSubjectType selects how the subject of tokens is derived for a client.
+// Pairwise subjects are shared by all clients of the same sector identifier.
+func WithSubjectType(subjectType, sectorIdentifier string) ClientOptFunc {
+	return func(c *Client) {
+		c.InternalSubjectType = subjectType
+		c.InternalSectorIdentifier = sectorIdentifier
+	}
+}
+
+// WithTokenExchange allows a client to exchange access tokens for tokens
+// targeted at the given audiences and limited to the given scopes.
+func WithTokenExchange(audiences, scopes []string) ClientOptFunc {
+	return func(c *Client) {
+		c.InternalExchangeAudiences = audiences
+		c.InternalExchangeScopes = scopes
+	}
+}
+
+// WithAccessPolicy restricts which users may log in to a client.
+func WithAccessPolicy(p AccessPolicy) ClientOptFunc {
+	return func(c *Client) {
+		c.InternalAccessPolicy = p
+	}
+}
+
+// WithClaimMappings adds the claims rendered by templates to tokens and
+// userinfo responses of a client.
+func WithClaimMappings(cm ClaimMappings) ClientOptFunc {
+	return func(c *Client) {
+		c.InternalClaimMappings = cm
+	}
+}
+
+// WithBackchannelLogoutURI notifies a client about deleted users by logout
+// tokens posted to the URI.
+func WithBackchannelLogoutURI(uri string) ClientOptFunc {
+	return func(c *Client) {
+		c.Metadata.BackchannelLogoutURI = uri
+	}
 }
 
 func (c *Client) ClientID() uuid.UUID {
@@ -61,15 +151,91 @@ func (c *Client) RedirectURI() string {
 	return fmt.Sprint(c.InternalRedirectURI)
 }
 
+// AllowedRedirectURIs returns all registered redirect URIs. Clients created
+// via command line only have a single one.
+func (c *Client) AllowedRedirectURIs() []string {
+	if len(c.Metadata.RedirectURIs) > 0 {
+		return c.Metadata.RedirectURIs
+	}
+	return []string{c.RedirectURI()}
+}
+
+// TokenEndpointAuthMethod returns how the client authenticates at the token
+// endpoint. An empty value accepts client_secret_basic and client_secret_post.
+func (c *Client) TokenEndpointAuthMethod() string {
+	return c.Metadata.TokenEndpointAuthMethod
+}
+
+func (c *Client) MinAcr() string {
+	return c.InternalMinAcr
+}
+
+func (c *Client) AccessPolicy() AccessPolicy {
+	return c.InternalAccessPolicy
+}
+
+func (c *Client) ClaimMappings() ClaimMappings {
+	return c.InternalClaimMappings
+}
+
+func (c *Client) ExchangeAudiences() []string {
+	return c.InternalExchangeAudiences
+}
+
+func (c *Client) ExchangeScopes() []string {
+	return c.InternalExchangeScopes
+}
+
+func (c *Client) SubjectType() string {
+	if c.InternalSubjectType == "" {
+		return SubjectTypePublic
+	}
+	return c.InternalSubjectType
+}
+
+func (c *Client) SectorIdentifier() string {
+	if c.InternalSectorIdentifier != "" {
+		return c.InternalSectorIdentifier
+	}
+
+	u, err := url.Parse(c.RedirectURI())
+	if err != nil {
+		return ""
+	}
+	return u.Host
+}
+
 func (c *Client) VerifyClientSecret(s string) error {
+	return verifySecret(c.ClientSecret, s)
+}
+
+// VerifyRegistrationAccessToken checks the token a dynamically registered
+// client uses to manage its registration.
+func (c *Client) VerifyRegistrationAccessToken(s string) error {
+	if c.RegistrationAccessToken == "" {
+		return fmt.Errorf("client was not registered dynamically")
+	}
+	return verifySecret(c.RegistrationAccessToken, s)
+}
+
+func verifySecret(phc, s string) error {
 	decodedSecret, err := base64.URLEncoding.DecodeString(s)
 	if err != nil {
 		return fmt.Errorf("decoding error: %v", err)
 	}
+	return VerifySecretHash(phc, decodedSecret)
+}
+
+// SecretHash returns the hash of a secret in PHC string format.
+func SecretHash(hasher ClientSecretHasher, secret []byte) string {
+	return hasher.phcString(secret)
+}
 
+// VerifySecretHash checks a secret against its hash in PHC string format.
+func VerifySecretHash(phc string, secret []byte) error {
 	var hasher ClientSecretHasher
 
-	phcHash := phcformat.MustParse(c.ClientSecret)
+	phcHash := phcformat.MustParse(phc)
 	switch phcHash.ID {
 	case "pbkdf2-sha1":
 		hasher = newPBKDF2KeyFromPHCWithSha1(phcHash)
@@ -79,8 +245,7 @@ func (c *Client) VerifyClientSecret(s string) error {
 		return fmt.Errorf("hash algorithm unknown")
 	}
 
-	if subtle.ConstantTimeCompare([]byte(option.UnwrapOrZero(phcHash.Output)), hasher.Key(decodedSecret)) != 1 {
-		log.Printf("%v != %s", option.UnwrapOrZero(phcHash.Output), s)
+	if subtle.ConstantTimeCompare([]byte(option.UnwrapOrZero(phcHash.Output)), hasher.Key(secret)) != 1 {
 		return fmt.Errorf("verification failed")
 	}
 
@@ -96,31 +261,70 @@ func clientByClientID(ctx context.Context, clientID string) (client, error) {
 	return &c, nil
 }
 
-func CreateClient(ctx context.Context, clientSecretHash ClientSecretHasher, desc, redirectURL string) (clientID string, clientSecret string, err error) {
-	id, err := uuid.NewRandom()
+func CreateClient(ctx context.Context, clientSecretHash ClientSecretHasher, desc, redirectURL string, opts ...ClientOptFunc) (clientID string, clientSecret string, err error) {
+	id, err := newClientID()
 	if err != nil {
-		panic(fmt.Errorf("cannot generate uuid: %v", err))
+		panic(err)
 	}
 
 	// TODO: Provide stable salt value
-	randSecret := make([]byte, 32)
-	if _, err := rand.Read(randSecret); err != nil {
+	secretHash, secret, err := newSecret(clientSecretHash)
+	if err != nil {
 		return "", "", err
 	}
 
 	client := Client{
 		ID:                  id,
-		ClientSecret:        clientSecretHash.phcString(randSecret[:]),
+		ClientSecret:        secretHash,
 		InternalRedirectURI: redirectURL,
 		Description:         desc,
 	}
 
+	for _, opt := range opts {
+		opt(&client)
+	}
+
 	r := database.FromContext(ctx).Create(&client)
 	if r.Error != nil {
 		return "", "", r.Error
 	}
 
-	return fmt.Sprint(client.ClientID()), base64.URLEncoding.EncodeToString(randSecret[:]), nil
+	return fmt.Sprint(client.ClientID()), secret, nil
+}
+
+func newClientID() (uuid.UUID, error) {
+	id, err := uuid.NewRandom()
+	if err != nil {
+		return id, fmt.Errorf("cannot generate uuid: %v", err)
+	}
+	return id, nil
+}
+
+// newSecret returns a random secret and its hash in PHC string format.
+func newSecret(hasher ClientSecretHasher) (hash string, secret string, err error) {
+	randSecret := make([]byte, 32)
+	if _, err := rand.Read(randSecret); err != nil {
+		return "", "", err
+	}
+
+	return hasher.phcString(randSecret), base64.URLEncoding.EncodeToString(randSecret), nil
+}
+
+// redirectURIFor returns the registered redirect URI matching the requested
+// one. The parameter may be omitted when a single URI is registered.
+func redirectURIFor(c client, requested string) (string, error) {
+	allowed := c.AllowedRedirectURIs()
+	if requested == "" {
+		if len(allowed) == 1 {
+			return allowed[0], nil
+		}
+		return "", fmt.Errorf("redirect URI must be provided")
+	}
+
+	if !slices.Contains(allowed, requested) {
+		return "", fmt.Errorf("redirect URI %#v is not registered", requested)
+	}
+	return requested, nil
 }
 
 type pbkdf2Key struct {
@@ -139,3 +139,30 @@ func TestMustParsePhcformat(t *testing.T) {
 	t.Skip()
 	phcformat.MustParse("$argon2id$v=19$m=65536,t=1,p=4,k=32$Ii1+3omeYiOsWbIJ2/plPgG9$8Gw1SSuNrdPsCzkH9O+eXBIsOomJJ0zIdq5G5EaGtIE")
 }
+
+func TestRedirectURIFor(t *testing.T) {
+	single := &Client{InternalRedirectURI: "https://example.com/cb"}
+	multi := &Client{InternalRedirectURI: "https://example.com/a", Metadata: ClientMetadata{RedirectURIs: []string{"https://example.com/a", "https://example.com/b"}}}
+
+	type testCase struct {
+		client    client
+		requested string
+		expected  string
+	}
+
+	for _, tc := range []testCase{
+		{single, "", "https://example.com/cb"},
+		{single, "https://example.com/cb", "https://example.com/cb"},
+		{single, "https://example.com/other", ""},
+		{multi, "", ""},
+		{multi, "https://example.com/b", "https://example.com/b"},
+		{multi, "https://example.com/b/", ""},
+	} {
+		uri, err := redirectURIFor(tc.client, tc.requested)
+		if tc.expected == "" && err == nil {
+			t.Errorf("expected error for %#v but got %#v", tc.requested, uri)
+		} else if uri != tc.expected {
+			t.Errorf("expected %#v for %#v but got %#v (%v)", tc.expected, tc.requested, uri, err)
+		}
+	}
+}
@@ -0,0 +1,159 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+
+	"github.com/seb-schulz/onegate/internal/model"
+	"github.com/seb-schulz/onegate/internal/usermgr"
+)
+
+// Headers of verified requests passed on by reverse proxies. The user header
+// carries the public subject because names can be changed by users.
+const (
+	ForwardAuthUserHeader = "X-Onegate-User"
+	ForwardAuthNameHeader = "X-Onegate-Name"
+)
+
+// redirectParam of the login page carries the URL of a request which failed
+// forward authentication.
+const redirectParam = "rd"
+
+// ForwardAuthRule grants logged-in users access to hosts behind a reverse
+// proxy. Hosts starting with "*." cover all subdomains. Any user is granted
+// access unless users are listed by ID.
+type ForwardAuthRule struct {
+	Host    string
+	UserIDs []uint
+}
+
+func (rule ForwardAuthRule) matchesHost(host string) bool {
+	if suffix, ok := strings.CutPrefix(rule.Host, "*"); ok && strings.HasPrefix(suffix, ".") {
+		return strings.HasSuffix(host, suffix)
+	}
+	return rule.Host == host
+}
+
+func (rule ForwardAuthRule) allows(user *model.User) bool {
+	return len(rule.UserIDs) == 0 || slices.Contains(rule.UserIDs, user.ID)
+}
+
+type forwardAuthRules []ForwardAuthRule
+
+// forHost returns the first rule matching the host of u.
+func (rules forwardAuthRules) forHost(u *url.URL) *ForwardAuthRule {
+	for i, rule := range rules {
+		if rule.matchesHost(u.Hostname()) {
+			return &rules[i]
+		}
+	}
+	return nil
+}
+
+// forwardedURL reconstructs the URL of the original request from the headers
+// set by nginx (X-Original-URL) or Traefik and Caddy (X-Forwarded-*).
+func forwardedURL(r *http.Request) (*url.URL, error) {
+	if original := r.Header.Get("X-Original-URL"); original != "" {
+		u, err := url.Parse(original)
+		if err != nil || !u.IsAbs() {
+			return nil, fmt.Errorf("invalid original URL %#v", original)
+		}
+		return u, nil
+	}
+
+	host := r.Header.Get("X-Forwarded-Host")
+	if host == "" {
+		return nil, fmt.Errorf("missing host of forwarded request")
+	}
+
+	proto := r.Header.Get("X-Forwarded-Proto")
+	if proto == "" {
+		proto = "https"
+	}
+
+	u, err := url.Parse(fmt.Sprintf("%s://%s%s", proto, host, r.Header.Get("X-Forwarded-Uri")))
+	if err != nil {
+		return nil, fmt.Errorf("invalid forwarded URL: %v", err)
+	}
+	return u, nil
+}
+
+type forwardAuthHandler struct {
+	rules       forwardAuthRules
+	loginUrl    url.URL
+	currentUser func(ctx context.Context) *model.User
+}
+
+// ServeHTTP answers subrequests of reverse proxies. Requests without login
+// fail with 401 or, if the verify URL contains the redirect parameter, are
+// redirected to the login page.
+func (fh *forwardAuthHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", "no-store")
+
+	target, err := forwardedURL(r)
+	if err != nil {
+		warnf("forward authentication failed: %v", err)
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	rule := fh.rules.forHost(target)
+	if rule == nil {
+		warnf("forward authentication failed: no rule for host %v", target.Host)
+		http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+		return
+	}
+
+	user := fh.currentUser(r.Context())
+	if user == nil {
+		if r.URL.Query().Has("redirect") {
+			loginUrl := fh.loginUrl
+			q := loginUrl.Query()
+			q.Set(redirectParam, target.String())
+			loginUrl.RawQuery = q.Encode()
+			http.Redirect(w, r, loginUrl.String(), http.StatusFound)
+			return
+		}
+
+		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return
+	}
+
+	if !user.IsApproved() || !rule.allows(user) {
+		warnf("forward authentication failed: user %v is not allowed to access %v", user.ID, target.Host)
+		http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+		return
+	}
+
+	w.Header().Set(ForwardAuthUserHeader, publicSubject(user.AuthnID))
+	w.Header().Set(ForwardAuthNameHeader, user.DisplayName)
+	w.WriteHeader(http.StatusOK)
+}
+
+// RedirectWhenLoggedInToForwardTarget continues at the redirect target of the
+// login page when the user is logged in. Targets must belong to a host of the
+// rules to prevent open redirects.
+func RedirectWhenLoggedInToForwardTarget(rules []ForwardAuthRule) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			rd := r.URL.Query().Get(redirectParam)
+			if rd == "" || usermgr.FromContext(r.Context()) == nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			target, err := url.Parse(rd)
+			if err != nil || !target.IsAbs() || (target.Scheme != "https" && target.Scheme != "http") || forwardAuthRules(rules).forHost(target) == nil {
+				warnf("ignore invalid redirect target %#v", rd)
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			http.Redirect(w, r, target.String(), http.StatusSeeOther)
+		})
+	}
+}
@@ -0,0 +1,121 @@
+package auth
+
+import (
+	"context"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/seb-schulz/onegate/internal/model"
+	"gorm.io/gorm"
+)
+
+func TestForwardAuthRule_matchesHost(t *testing.T) {
+	for _, tc := range []struct {
+		rule    string
+		host    string
+		matches bool
+	}{
+		{"grafana.example.com", "grafana.example.com", true},
+		{"grafana.example.com", "example.com", false},
+		{"*.example.com", "grafana.example.com", true},
+		{"*.example.com", "a.b.example.com", true},
+		{"*.example.com", "example.com", false},
+		{"*.example.com", "evilexample.com", false},
+		{"*example.com", "evilexample.com", false},
+	} {
+		if got := (ForwardAuthRule{Host: tc.rule}).matchesHost(tc.host); got != tc.matches {
+			t.Errorf("expected rule %v to match %v=%v", tc.rule, tc.host, tc.matches)
+		}
+	}
+}
+
+func TestForwardedURL(t *testing.T) {
+	for _, tc := range []struct {
+		headers  map[string]string
+		expected string
+	}{
+		{map[string]string{"X-Original-URL": "https://grafana.example.com/d/1?x=y"}, "https://grafana.example.com/d/1?x=y"},
+		{map[string]string{"X-Forwarded-Proto": "http", "X-Forwarded-Host": "grafana.example.com", "X-Forwarded-Uri": "/d/1"}, "http://grafana.example.com/d/1"},
+		{map[string]string{"X-Forwarded-Host": "grafana.example.com"}, "https://grafana.example.com"},
+		{map[string]string{"X-Original-URL": "/d/1"}, ""},
+		{map[string]string{}, ""},
+	} {
+		r := httptest.NewRequest(http.MethodGet, "/auth/verify", nil)
+		for k, v := range tc.headers {
+			r.Header.Set(k, v)
+		}
+
+		u, err := forwardedURL(r)
+		if tc.expected == "" {
+			if err == nil {
+				t.Errorf("expected %v to fail", tc.headers)
+			}
+			continue
+		}
+
+		if err != nil || u.String() != tc.expected {
+			t.Errorf("expected %v but got %v: %v", tc.expected, u, err)
+		}
+	}
+}
+
+func TestForwardAuthHandler(t *testing.T) {
+	alice := &model.User{Model: gorm.Model{ID: 1}, AuthnID: []byte("alice"), Name: "alice", DisplayName: "Alice"}
+	bob := &model.User{Model: gorm.Model{ID: 2}, AuthnID: []byte("bob"), Name: "bob", DisplayName: "Bob"}
+	carol := &model.User{Model: gorm.Model{ID: 3}, AuthnID: []byte("carol"), Name: "carol", DisplayName: "Carol", ApprovalStatus: model.ApprovalPending}
+	// Users may rename themselves
+	mallory := &model.User{Model: gorm.Model{ID: 4}, AuthnID: []byte("mallory"), Name: "alice", DisplayName: "Alice"}
+
+	for _, tc := range []struct {
+		name     string
+		user     *model.User
+		host     string
+		query    string
+		code     int
+		location string
+	}{
+		{"allowed user", alice, "admin.example.com", "", http.StatusOK, ""},
+		{"any user", bob, "grafana.example.com", "", http.StatusOK, ""},
+		{"not listed user", bob, "admin.example.com", "", http.StatusForbidden, ""},
+		{"renamed user", mallory, "admin.example.com", "", http.StatusForbidden, ""},
+		{"pending user", carol, "grafana.example.com", "", http.StatusForbidden, ""},
+		{"unknown host", alice, "example.org", "", http.StatusForbidden, ""},
+		{"no login", nil, "grafana.example.com", "", http.StatusUnauthorized, ""},
+		{"no login with redirect", nil, "grafana.example.com", "?redirect", http.StatusFound, "https://sso.example.com/login?rd=https%3A%2F%2Fgrafana.example.com%2Fd%2F1"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			fh := &forwardAuthHandler{
+				rules: []ForwardAuthRule{
+					{Host: "admin.example.com", UserIDs: []uint{1}},
+					{Host: "*.example.com"},
+				},
+				loginUrl: url.URL{Scheme: "https", Host: "sso.example.com", Path: "/login"},
+				currentUser: func(ctx context.Context) *model.User {
+					return tc.user
+				},
+			}
+
+			r := httptest.NewRequest(http.MethodGet, "/auth/verify"+tc.query, nil)
+			r.Header.Set("X-Forwarded-Host", tc.host)
+			r.Header.Set("X-Forwarded-Uri", "/d/1")
+
+			rr := httptest.NewRecorder()
+			fh.ServeHTTP(rr, r)
+
+			if rr.Code != tc.code {
+				t.Fatalf("expected status %v but got %v", tc.code, rr.Code)
+			}
+
+			if loc := rr.Header().Get("Location"); loc != tc.location {
+				t.Errorf("expected location %#v but got %#v", tc.location, loc)
+			}
+
+			if tc.code == http.StatusOK && (rr.Header().Get(ForwardAuthUserHeader) != base64.RawURLEncoding.EncodeToString(tc.user.AuthnID) || rr.Header().Get(ForwardAuthNameHeader) != tc.user.DisplayName) {
+				t.Errorf("unexpected headers %v", rr.Header())
+			}
+		})
+	}
+}
@@ -3,62 +3,205 @@ package auth
 import (
 	"context"
 	"crypto/ecdsa"
+	"crypto/x509"
 	"fmt"
 	"log/slog"
 	"net/http"
 	"net/url"
+	"time"
 
+	"github.com/crewjam/saml"
 	"github.com/go-chi/chi/v5"
+	"github.com/seb-schulz/onegate/internal/database"
+	"github.com/seb-schulz/onegate/internal/model"
 	"github.com/seb-schulz/onegate/internal/usermgr"
 )
 
 type Config struct {
-	IssuerUrl  string
-	PrivateKey *ecdsa.PrivateKey
+	IssuerUrl            string
+	PrivateKey           *ecdsa.PrivateKey
+	SubjectSalt          []byte
+	CodeExpiresIn        time.Duration
+	RequestExpiresIn     time.Duration
+	AccessTokenExpiresIn time.Duration
+	// InitialAccessToken enables dynamic client registration when set
+	InitialAccessToken []byte
+	RegistrationUrl    url.URL
+	// SAMLCertificate of the private key enables the SAML 2.0 IdP when set
+	SAMLCertificate *x509.Certificate
+	SAMLMetadataUrl url.URL
+	SAMLSSOUrl      url.URL
+	// LoginUrl is the absolute URL of the login page used by forward authentication
+	LoginUrl         url.URL
+	ForwardAuthRules []ForwardAuthRule
+	// GroupsClaim and RolesClaim name the claims of the groups and roles
+	// scopes. Empty names disable the claims.
+	GroupsClaim string
+	RolesClaim  string
+}
+
+func groupsByUserID(ctx context.Context, userID uint) ([]string, error) {
+	return model.GroupNamesByUserID(database.FromContext(ctx), userID)
+}
+
+func rolesByUserID(ctx context.Context, userID uint) ([]string, error) {
+	return model.RoleNamesByUserID(database.FromContext(ctx), userID)
+}
+
+func verifiedEmailByUserID(ctx context.Context, userID uint) (string, error) {
+	return model.VerifiedPrimaryEmail(database.FromContext(ctx), userID)
 }
 
 func NewHandler(c *Config) http.Handler {
 	route := chi.NewRouter()
 
+	extraClaims := extraClaims{
+		groupsClaim:           c.GroupsClaim,
+		rolesClaim:            c.RolesClaim,
+		userByID:              userByID,
+		verifiedEmailByUserID: verifiedEmailByUserID,
+		groupsByUserID:        groupsByUserID,
+		rolesByUserID:         rolesByUserID,
+	}
+
 	authorizationRequestHandler := authorizationRequestHandler{
-		clientByClientID:    clientByClientID,
-		loginUrl:            url.URL{Path: "/login"},
-		createAuthorization: createAuthorization,
+		clientByClientID:      clientByClientID,
+		resourcesByIdentifier: resourcesByIdentifier,
+		loginUrl:              url.URL{Path: "/login"},
+		createAuthorization: func(ctx context.Context, client client, params authorizationParams) (string, error) {
+			if err := deleteExpiredAuthorizations(ctx, c.CodeExpiresIn, c.RequestExpiresIn); err != nil {
+				warnf("cannot delete expired authorizations: %v", err)
+			}
+			return createAuthorization(ctx, client, params)
+		},
 	}
 	route.Get("/auth", authorizationRequestHandler.ServeHTTP)
 
 	callbackRedirectHandler := &callbackRedirectHandler{
-		currentAuthorization: func(ctx context.Context) (authorization, error) {
-			return FirstAuthorization(ctx)
+		authorizationByTransaction: func(ctx context.Context, txID string) (authorization, error) {
+			return AuthorizationByTransaction(ctx, txID)
 		},
-		currentUser: usermgr.FromContext,
+		clientByClientID:    clientByClientID,
+		groupsByUserID:      groupsByUserID,
+		currentUser:         usermgr.FromContext,
+		currentAuthnContext: model.CurrentAuthnContext,
+		loginUrl:            url.URL{Path: "/login"},
 	}
 	route.With(usermgr.Middleware).Get("/callback", callbackRedirectHandler.ServeHTTP)
 
 	tokenHandler := &tokenHandler{
-		issuerUrl:           c.IssuerUrl,
-		privateKey:          c.PrivateKey,
-		clientByClientID:    clientByClientID,
-		authorizationByCode: authorizationByCode,
-		deleteAuthorization: func(ctx context.Context, a authorization) error {
-			return a.Delete(ctx)
+		issuerUrl:             c.IssuerUrl,
+		privateKey:            c.PrivateKey,
+		accessTokenExpiresIn:  c.AccessTokenExpiresIn,
+		clientByClientID:      clientByClientID,
+		resourcesByIdentifier: resourcesByIdentifier,
+		authorizationByCode: func(ctx context.Context, code string) (authorization, error) {
+			return authorizationByCode(ctx, code, c.CodeExpiresIn)
+		},
+		redeemAuthorization: func(ctx context.Context, a authorization) error {
+			return a.Redeem(ctx)
+		},
+		revokeAuthorization: func(ctx context.Context, a authorization) error {
+			return a.Revoke(ctx)
 		},
+		issueToken:            issueToken,
+		activeIssuedToken:     activeIssuedToken,
+		issueExchangedToken:   issueExchangedToken,
+		subject:               subjectByUserID(c.SubjectSalt),
+		userByID:              userByID,
+		verifiedEmailByUserID: verifiedEmailByUserID,
+		extraClaims:           extraClaims,
 	}
 	route.Post("/token", tokenHandler.ServeHTTP)
 
+	userinfoHandler := &userinfoHandler{
+		issuerUrl:             c.IssuerUrl,
+		publicKey:             &c.PrivateKey.PublicKey,
+		activeIssuedToken:     activeIssuedToken,
+		clientByClientID:      clientByClientID,
+		userByID:              userByID,
+		verifiedEmailByUserID: verifiedEmailByUserID,
+		extraClaims:           extraClaims,
+	}
+	route.Get("/userinfo", userinfoHandler.ServeHTTP)
+	route.Post("/userinfo", userinfoHandler.ServeHTTP)
+
+	registrationHandler := &registrationHandler{
+		initialAccessToken: c.InitialAccessToken,
+		registrationUrl:    c.RegistrationUrl,
+		registerClient: func(ctx context.Context, m ClientMetadata) (*Client, clientCredentials, error) {
+			return registerClient(ctx, NewClientSecretHasher, m)
+		},
+		registeredClient: registeredClient,
+		updateClient:     updateRegisteredClient,
+		deleteClient:     deleteRegisteredClient,
+	}
+	if len(c.InitialAccessToken) > 0 {
+		route.Post("/register", registrationHandler.register)
+	}
+	route.Get("/register/{clientID}", registrationHandler.read)
+	route.Put("/register/{clientID}", registrationHandler.update)
+	route.Delete("/register/{clientID}", registrationHandler.delete)
+
+	forwardAuthHandler := &forwardAuthHandler{
+		rules:       c.ForwardAuthRules,
+		loginUrl:    c.LoginUrl,
+		currentUser: usermgr.FromContext,
+	}
+	route.With(usermgr.Middleware).Handle("/verify", forwardAuthHandler)
+
+	if c.SAMLCertificate != nil {
+		if samlCertificateMatches(c.PrivateKey, c.SAMLCertificate) {
+			samlHandler := newSAMLHandler(c.PrivateKey, c.SAMLCertificate, c.SAMLMetadataUrl, c.SAMLSSOUrl)
+			samlHandler.loginUrl = url.URL{Path: "/login"}
+			samlHandler.subjectSalt = c.SubjectSalt
+			samlHandler.serviceProviderByEntityID = serviceProviderByEntityID
+			samlHandler.createSAMLRequest = func(ctx context.Context, req *saml.IdpAuthnRequest) (string, error) {
+				if err := deleteExpiredSAMLRequests(ctx, c.RequestExpiresIn); err != nil {
+					warnf("%v", err)
+				}
+				return createSAMLRequest(ctx, req)
+			}
+			samlHandler.samlRequestByTransaction = SAMLRequestByTransaction
+			samlHandler.deleteSAMLRequest = func(ctx context.Context, sr *SAMLRequest) error {
+				return sr.Delete(ctx)
+			}
+			samlHandler.currentUser = usermgr.FromContext
+			samlHandler.currentAuthnContext = model.CurrentAuthnContext
+
+			route.Get("/saml/metadata", samlHandler.metadata)
+			route.With(usermgr.Middleware).Get("/saml/sso", samlHandler.sso)
+			route.With(usermgr.Middleware).Post("/saml/sso", samlHandler.sso)
+		} else {
+			slog.Error("SAML certificate does not belong to private key")
+		}
+	}
+
 	return route
 }
 
-func RedirectWhenLoggedInAndAssigned(callbackURL string) func(http.Handler) http.Handler {
+// RedirectWhenLoggedInAndAssigned continues pending OAuth2 authorizations at
+// the callback URL and pending SAML requests at the SSO URL.
+func RedirectWhenLoggedInAndAssigned(callbackURL, samlSSOURL url.URL) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			authReq, err := FirstAuthorization(r.Context())
-			if authReq == nil {
+			txID := r.URL.Query().Get(TransactionParam)
+			if txID == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			if _, err := SAMLRequestByTransaction(r.Context(), txID); err == nil {
+				if usermgr.FromContext(r.Context()) != nil {
+					http.Redirect(w, r, fmt.Sprint(TransactionURL(samlSSOURL, txID)), http.StatusSeeOther)
+					return
+				}
 				next.ServeHTTP(w, r)
 				return
 			}
 
+			authReq, err := AuthorizationByTransaction(r.Context(), txID)
 			if err != nil {
 				// TODO: Evaluate proper error page
 				slog.Warn(fmt.Sprintf("oAuth2 callback failed with: %v", err))
@@ -67,13 +210,13 @@ func RedirectWhenLoggedInAndAssigned(callbackURL string) func(http.Handler) http
 			}
 
 			if authReq.InternalUserID != nil {
-				http.Redirect(w, r, callbackURL, http.StatusSeeOther)
+				http.Redirect(w, r, fmt.Sprint(TransactionURL(callbackURL, txID)), http.StatusSeeOther)
 				return
 			}
 
 			user := usermgr.FromContext(r.Context())
-			if user != nil {
-				http.Redirect(w, r, callbackURL, http.StatusSeeOther)
+			if user != nil && !StepUpRequired(r.Context(), txID) {
+				http.Redirect(w, r, fmt.Sprint(TransactionURL(callbackURL, txID)), http.StatusSeeOther)
 				return
 			}
 
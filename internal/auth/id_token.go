@@ -7,19 +7,46 @@ import (
 
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
+	"github.com/seb-schulz/onegate/internal/model"
 )
 
 type IdTokenClaims struct {
 	jwt.RegisteredClaims
 	// Nonce string `json:"nonce"`
+	Acr      string           `json:"acr,omitempty"`
+	Amr      []string         `json:"amr,omitempty"`
+	AuthTime *jwt.NumericDate `json:"auth_time,omitempty"`
+	// Standard claims of the profile scope
+	Name              string `json:"name,omitempty"`
+	PreferredUsername string `json:"preferred_username,omitempty"`
+	Picture           string `json:"picture,omitempty"`
+	Locale            string `json:"locale,omitempty"`
+	Zoneinfo          string `json:"zoneinfo,omitempty"`
+	// Standard claims of the email scope
+	Email         string `json:"email,omitempty"`
+	EmailVerified bool   `json:"email_verified,omitempty"`
+	// Extra claims have configurable names like the groups claim
+	Extra map[string]any `json:"-"`
+}
+
+func (c IdTokenClaims) MarshalJSON() ([]byte, error) {
+	type claims IdTokenClaims
+	return marshalWithExtraClaims(claims(c), c.Extra)
 }
 
 type IDToken struct {
-	Key       *ecdsa.PrivateKey
-	Issuer    string
-	ExpiresIn time.Duration
-	UserID    uint
-	ClientID  uuid.UUID
+	Key          *ecdsa.PrivateKey
+	Issuer       string
+	ExpiresIn    time.Duration
+	Subject      string
+	ClientID     uuid.UUID
+	AuthnContext model.AuthnContext
+	// Profile of the user if requested by the profile scope
+	Profile *model.User
+	Claims  map[string]any
+	// Email is the verified primary address of the user if requested by the
+	// email scope
+	Email string
 }
 
 func (token IDToken) MarshalText() ([]byte, error) {
@@ -29,20 +56,41 @@ func (token IDToken) MarshalText() ([]byte, error) {
 		return []byte{}, fmt.Errorf("missing issuer")
 	}
 
-	if token.UserID == 0 {
-		return []byte{}, fmt.Errorf("missing user ID")
+	if token.Subject == "" {
+		return []byte{}, fmt.Errorf("missing subject")
+	}
+
+	var authTime *jwt.NumericDate
+	if token.AuthnContext.AuthTime != nil {
+		authTime = jwt.NewNumericDate(*token.AuthnContext.AuthTime)
 	}
 
-	s := jwt.NewWithClaims(jwt.SigningMethodES256, &IdTokenClaims{
+	claims := &IdTokenClaims{
 		RegisteredClaims: jwt.RegisteredClaims{
 			Issuer:    token.Issuer,
 			ExpiresAt: jwt.NewNumericDate(time.Now().Add(token.ExpiresIn)),
-			Subject:   fmt.Sprintf("%d", token.UserID),
+			Subject:   token.Subject,
 			Audience:  jwt.ClaimStrings{fmt.Sprint(&token.ClientID)},
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
 		},
 		// Nonce: "Nonce",
-	})
+		Acr:           token.AuthnContext.Acr,
+		Amr:           token.AuthnContext.Amr,
+		AuthTime:      authTime,
+		Extra:         token.Claims,
+		Email:         token.Email,
+		EmailVerified: token.Email != "",
+	}
+
+	if token.Profile != nil {
+		claims.Name = token.Profile.DisplayName
+		claims.PreferredUsername = token.Profile.Name
+		claims.Picture = token.Profile.Picture
+		claims.Locale = token.Profile.Locale
+		claims.Zoneinfo = token.Profile.Zoneinfo
+	}
+
+	s := jwt.NewWithClaims(jwt.SigningMethodES256, claims)
 
 	sigendToken, err := s.SignedString(token.Key)
 	if err != nil {
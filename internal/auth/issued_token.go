@@ -0,0 +1,85 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/seb-schulz/onegate/internal/database"
+)
+
+// IssuedToken keeps track of access tokens by their jti so that they can be
+// revoked without storing the token itself.
+type IssuedToken struct {
+	ID              uuid.UUID `gorm:"primarykey"`
+	CreatedAt       time.Time
+	ExpiresAt       time.Time `gorm:"index"`
+	RevokedAt       *time.Time
+	AuthorizationID uint      `gorm:"index"`
+	ClientID        uuid.UUID `gorm:"type:VARCHAR(191);not null"`
+	UserID          uint
+	// ParentID refers to the subject token of an exchanged token
+	ParentID *uuid.UUID `gorm:"type:VARCHAR(191)"`
+}
+
+func issueToken(ctx context.Context, a authorization, expiresIn time.Duration) (uuid.UUID, error) {
+	token := IssuedToken{
+		ID:              uuid.New(),
+		ExpiresAt:       time.Now().Add(expiresIn),
+		AuthorizationID: a.id(),
+		ClientID:        a.ClientID(),
+		UserID:          a.UserID(),
+	}
+
+	if r := database.FromContext(ctx).Create(&token); r.Error != nil {
+		return uuid.Nil, fmt.Errorf("cannot store issued token: %v", r.Error)
+	}
+
+	return token.ID, nil
+}
+
+// activeIssuedToken returns a token which is neither expired nor revoked.
+func activeIssuedToken(ctx context.Context, jti string) (*IssuedToken, error) {
+	id, err := uuid.Parse(jti)
+	if err != nil {
+		return nil, fmt.Errorf("invalid token ID: %v", err)
+	}
+
+	token := IssuedToken{}
+	r := database.FromContext(ctx).Where("id = ? AND revoked_at IS NULL AND expires_at > ?", id, time.Now()).First(&token)
+	if r.Error != nil {
+		return nil, fmt.Errorf("cannot get issued token: %v", r.Error)
+	}
+
+	return &token, nil
+}
+
+// issueExchangedToken records a token issued to a client in exchange for the
+// parent token. It belongs to the same authorization and is revoked with it.
+func issueExchangedToken(ctx context.Context, parent *IssuedToken, c client, expiresIn time.Duration) (uuid.UUID, error) {
+	token := IssuedToken{
+		ID:              uuid.New(),
+		ExpiresAt:       time.Now().Add(expiresIn),
+		AuthorizationID: parent.AuthorizationID,
+		ClientID:        c.ClientID(),
+		UserID:          parent.UserID,
+		ParentID:        &parent.ID,
+	}
+
+	if r := database.FromContext(ctx).Create(&token); r.Error != nil {
+		return uuid.Nil, fmt.Errorf("cannot store exchanged token: %v", r.Error)
+	}
+
+	return token.ID, nil
+}
+
+// IssuedTokensByUserID returns the tokens issued to the user which are not
+// expired yet.
+func IssuedTokensByUserID(ctx context.Context, userID uint) ([]IssuedToken, error) {
+	var tokens []IssuedToken
+	if r := database.FromContext(ctx).Where("user_id = ? AND expires_at > ?", userID, time.Now()).Order("created_at").Find(&tokens); r.Error != nil {
+		return nil, fmt.Errorf("cannot get issued tokens: %v", r.Error)
+	}
+	return tokens, nil
+}
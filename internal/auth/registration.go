@@ -0,0 +1,435 @@
+package auth
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/seb-schulz/onegate/internal/database"
+)
+
+const (
+	AuthMethodClientSecretBasic = "client_secret_basic"
+	AuthMethodClientSecretPost  = "client_secret_post"
+)
+
+// ClientMetadata describes a dynamically registered client (see RFC 7591,
+// section 2). The client name is stored as description of the client.
+type ClientMetadata struct {
+	RedirectURIs            []string        `json:"redirect_uris" gorm:"column:redirect_uris;serializer:json"`
+	TokenEndpointAuthMethod string          `json:"token_endpoint_auth_method,omitempty" gorm:"column:token_endpoint_auth_method;type:VARCHAR(32);not null;default:''"`
+	GrantTypes              []string        `json:"grant_types,omitempty" gorm:"column:grant_types;serializer:json"`
+	ResponseTypes           []string        `json:"response_types,omitempty" gorm:"column:response_types;serializer:json"`
+	ClientName              string          `json:"client_name,omitempty" gorm:"-"`
+	ClientURI               string          `json:"client_uri,omitempty" gorm:"column:client_uri;type:VARCHAR(255);not null;default:''"`
+	LogoURI                 string          `json:"logo_uri,omitempty" gorm:"column:logo_uri;type:VARCHAR(255);not null;default:''"`
+	Contacts                []string        `json:"contacts,omitempty" gorm:"column:contacts;serializer:json"`
+	JWKSURI                 string          `json:"jwks_uri,omitempty" gorm:"column:jwks_uri;type:VARCHAR(255);not null;default:''"`
+	JWKS                    json.RawMessage `json:"jwks,omitempty" gorm:"column:jwks;type:TEXT"`
+	// BackchannelLogoutURI receives logout tokens when users are deleted
+	// (see OpenID Connect Back-Channel Logout 1.0)
+	BackchannelLogoutURI string `json:"backchannel_logout_uri,omitempty" gorm:"column:backchannel_logout_uri;type:VARCHAR(255);not null;default:''"`
+}
+
+type registrationError struct {
+	Code        string `json:"error"`
+	Description string `json:"error_description,omitempty"`
+}
+
+func (e *registrationError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Code, e.Description)
+}
+
+func invalidRedirectURI(format string, a ...any) error {
+	return &registrationError{"invalid_redirect_uri", fmt.Sprintf(format, a...)}
+}
+
+func invalidClientMetadata(format string, a ...any) error {
+	return &registrationError{"invalid_client_metadata", fmt.Sprintf(format, a...)}
+}
+
+func isAbsoluteURL(s string) bool {
+	u, err := url.Parse(s)
+	return err == nil && u.IsAbs() && u.Host != ""
+}
+
+// isLoopback reports whether the host of a URL refers to the local machine
+// where native clients may receive redirects without TLS (see RFC 8252,
+// section 7.3).
+func isLoopback(u *url.URL) bool {
+	if u.Hostname() == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(u.Hostname())
+	return ip != nil && ip.IsLoopback()
+}
+
+// sectorIdentifier is the host shared by all redirect URIs. Clients of the
+// same host get the same pairwise subjects.
+func (m *ClientMetadata) sectorIdentifier() string {
+	u, err := url.Parse(m.RedirectURIs[0])
+	if err != nil {
+		return ""
+	}
+	return u.Host
+}
+
+// validate checks the metadata and fills in the defaults of RFC 7591.
+func (m *ClientMetadata) validate() error {
+	if len(m.RedirectURIs) == 0 {
+		return invalidRedirectURI("at least one redirect URI is required")
+	}
+
+	for _, uri := range m.RedirectURIs {
+		if !isAbsoluteURL(uri) || strings.Contains(uri, "#") {
+			return invalidRedirectURI("invalid redirect URI %#v", uri)
+		}
+
+		u, _ := url.Parse(uri)
+		if u.Scheme != "https" && !(u.Scheme == "http" && isLoopback(u)) {
+			return invalidRedirectURI("redirect URI %#v must use https", uri)
+		}
+
+		// Sector identifier URIs are not supported
+		if u.Host != m.sectorIdentifier() {
+			return invalidRedirectURI("redirect URIs must share one host")
+		}
+	}
+
+	if m.TokenEndpointAuthMethod == "" {
+		m.TokenEndpointAuthMethod = AuthMethodClientSecretBasic
+	}
+
+	if m.TokenEndpointAuthMethod != AuthMethodClientSecretBasic && m.TokenEndpointAuthMethod != AuthMethodClientSecretPost {
+		return invalidClientMetadata("unsupported token endpoint auth method %#v", m.TokenEndpointAuthMethod)
+	}
+
+	if len(m.GrantTypes) == 0 {
+		m.GrantTypes = []string{"authorization_code"}
+	}
+
+	if slices.ContainsFunc(m.GrantTypes, func(s string) bool { return s != "authorization_code" }) {
+		return invalidClientMetadata("only grant type authorization_code is supported")
+	}
+
+	if len(m.ResponseTypes) == 0 {
+		m.ResponseTypes = []string{"code"}
+	}
+
+	if slices.ContainsFunc(m.ResponseTypes, func(s string) bool { return s != "code" }) {
+		return invalidClientMetadata("only response type code is supported")
+	}
+
+	for name, uri := range map[string]string{"client_uri": m.ClientURI, "logo_uri": m.LogoURI, "jwks_uri": m.JWKSURI, "backchannel_logout_uri": m.BackchannelLogoutURI} {
+		if uri != "" && !isAbsoluteURL(uri) {
+			return invalidClientMetadata("%s must be an absolute URL", name)
+		}
+	}
+
+	if len(m.JWKS) > 0 {
+		if m.JWKSURI != "" {
+			return invalidClientMetadata("jwks and jwks_uri must not be used together")
+		}
+
+		var jwks struct {
+			Keys []json.RawMessage `json:"keys"`
+		}
+		if err := json.Unmarshal(m.JWKS, &jwks); err != nil || len(jwks.Keys) == 0 {
+			return invalidClientMetadata("jwks must be a JWK set with at least one key")
+		}
+	}
+
+	return nil
+}
+
+func (c *Client) metadata() ClientMetadata {
+	m := c.Metadata
+	m.ClientName = c.Description
+	return m
+}
+
+func (c *Client) applyMetadata(m ClientMetadata) {
+	c.Metadata = m
+	c.Description = m.ClientName
+	c.InternalRedirectURI = m.RedirectURIs[0]
+}
+
+// clientCredentials are only visible once after registration.
+type clientCredentials struct {
+	ClientSecret            string
+	RegistrationAccessToken string
+}
+
+// checkSectorIdentifier rejects redirect URIs on the host of another client.
+// Otherwise a registered client would receive the pairwise subjects of the
+// other client.
+func checkSectorIdentifier(ctx context.Context, clientID uuid.UUID, sector string) error {
+	var clients []Client
+	if r := database.FromContext(ctx).Select("id", "redirect_uri", "sector_identifier").Where("id <> ?", clientID).Find(&clients); r.Error != nil {
+		return fmt.Errorf("cannot get clients: %v", r.Error)
+	}
+
+	for _, c := range clients {
+		if c.SectorIdentifier() == sector {
+			return invalidRedirectURI("host %v is used by another client", sector)
+		}
+	}
+	return nil
+}
+
+func registerClient(ctx context.Context, newHasher func() ClientSecretHasher, m ClientMetadata) (*Client, clientCredentials, error) {
+	var (
+		client Client
+		cred   clientCredentials
+		err    error
+	)
+
+	client.ID, err = newClientID()
+	if err != nil {
+		return nil, cred, err
+	}
+
+	client.ClientSecret, cred.ClientSecret, err = newSecret(newHasher())
+	if err != nil {
+		return nil, cred, err
+	}
+
+	client.RegistrationAccessToken, cred.RegistrationAccessToken, err = newSecret(newHasher())
+	if err != nil {
+		return nil, cred, err
+	}
+
+	if err := checkSectorIdentifier(ctx, client.ID, m.sectorIdentifier()); err != nil {
+		return nil, cred, err
+	}
+
+	client.applyMetadata(m)
+
+	if r := database.FromContext(ctx).Create(&client); r.Error != nil {
+		return nil, cred, fmt.Errorf("cannot register client: %v", r.Error)
+	}
+
+	return &client, cred, nil
+}
+
+// registeredClient returns a dynamically registered client if the
+// registration access token matches.
+func registeredClient(ctx context.Context, clientID, token string) (*Client, error) {
+	var c Client
+	if r := database.FromContext(ctx).First(&c, "id = ?", clientID); r.Error != nil {
+		return nil, fmt.Errorf("cannot get client: %v", r.Error)
+	}
+
+	if err := c.VerifyRegistrationAccessToken(token); err != nil {
+		return nil, err
+	}
+
+	return &c, nil
+}
+
+func updateRegisteredClient(ctx context.Context, c *Client, m ClientMetadata) error {
+	if err := checkSectorIdentifier(ctx, c.ID, m.sectorIdentifier()); err != nil {
+		return err
+	}
+
+	c.applyMetadata(m)
+	if r := database.FromContext(ctx).Save(c); r.Error != nil {
+		return fmt.Errorf("cannot update client: %v", r.Error)
+	}
+	return nil
+}
+
+func deleteRegisteredClient(ctx context.Context, c *Client) error {
+	if r := database.FromContext(ctx).Delete(c); r.Error != nil {
+		return fmt.Errorf("cannot delete client: %v", r.Error)
+	}
+	return nil
+}
+
+type clientInformation struct {
+	ClientID                string `json:"client_id"`
+	ClientSecret            string `json:"client_secret,omitempty"`
+	ClientIDIssuedAt        int64  `json:"client_id_issued_at"`
+	ClientSecretExpiresAt   int64  `json:"client_secret_expires_at"`
+	RegistrationAccessToken string `json:"registration_access_token,omitempty"`
+	RegistrationClientURI   string `json:"registration_client_uri"`
+	ClientMetadata
+}
+
+// registrationHandler implements dynamic client registration (RFC 7591) and
+// the management of registered clients (RFC 7592).
+type registrationHandler struct {
+	initialAccessToken []byte
+	registrationUrl    url.URL
+	registerClient     func(context.Context, ClientMetadata) (*Client, clientCredentials, error)
+	registeredClient   func(ctx context.Context, clientID, token string) (*Client, error)
+	updateClient       func(context.Context, *Client, ClientMetadata) error
+	deleteClient       func(context.Context, *Client) error
+}
+
+func bearerToken(r *http.Request) string {
+	scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return ""
+	}
+	return strings.TrimSpace(token)
+}
+
+// verifyInitialAccessToken compares the token with the configured value as
+// it is written in the configuration.
+func (rh *registrationHandler) verifyInitialAccessToken(token string) bool {
+	if len(rh.initialAccessToken) == 0 || token == "" {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(token), rh.initialAccessToken) == 1
+}
+
+func (rh *registrationHandler) clientInformation(c *Client) clientInformation {
+	return clientInformation{
+		ClientID:              fmt.Sprint(c.ClientID()),
+		ClientIDIssuedAt:      c.CreatedAt.Unix(),
+		RegistrationClientURI: fmt.Sprint(rh.registrationUrl.JoinPath(fmt.Sprint(c.ClientID()))),
+		ClientMetadata:        c.metadata(),
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		warnf("cannot encode response: %v", err)
+		http.Error(w, "cannot encode response", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	w.Write(b)
+}
+
+func writeRegistrationError(w http.ResponseWriter, err error) {
+	var rErr *registrationError
+	if !errors.As(err, &rErr) {
+		warnf("client registration failed: %v", err)
+		http.Error(w, "client registration failed", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusBadRequest, rErr)
+}
+
+func unauthorized(w http.ResponseWriter) {
+	w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+	http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+}
+
+func decodeMetadata(r *http.Request, v any) error {
+	if err := json.NewDecoder(r.Body).Decode(v); err != nil {
+		return invalidClientMetadata("cannot decode metadata: %v", err)
+	}
+	return nil
+}
+
+func (rh *registrationHandler) register(w http.ResponseWriter, r *http.Request) {
+	if !rh.verifyInitialAccessToken(bearerToken(r)) {
+		unauthorized(w)
+		return
+	}
+
+	var m ClientMetadata
+	if err := decodeMetadata(r, &m); err != nil {
+		writeRegistrationError(w, err)
+		return
+	}
+
+	if err := m.validate(); err != nil {
+		writeRegistrationError(w, err)
+		return
+	}
+
+	c, cred, err := rh.registerClient(r.Context(), m)
+	if err != nil {
+		writeRegistrationError(w, err)
+		return
+	}
+
+	info := rh.clientInformation(c)
+	info.ClientSecret = cred.ClientSecret
+	info.RegistrationAccessToken = cred.RegistrationAccessToken
+	writeJSON(w, http.StatusCreated, info)
+}
+
+func (rh *registrationHandler) client(w http.ResponseWriter, r *http.Request) *Client {
+	c, err := rh.registeredClient(r.Context(), chi.URLParam(r, "clientID"), bearerToken(r))
+	if err != nil {
+		warnf("cannot authorize client registration: %v", err)
+		unauthorized(w)
+		return nil
+	}
+	return c
+}
+
+func (rh *registrationHandler) read(w http.ResponseWriter, r *http.Request) {
+	c := rh.client(w, r)
+	if c == nil {
+		return
+	}
+
+	writeJSON(w, http.StatusOK, rh.clientInformation(c))
+}
+
+func (rh *registrationHandler) update(w http.ResponseWriter, r *http.Request) {
+	c := rh.client(w, r)
+	if c == nil {
+		return
+	}
+
+	var req struct {
+		ClientID string `json:"client_id"`
+		ClientMetadata
+	}
+	if err := decodeMetadata(r, &req); err != nil {
+		writeRegistrationError(w, err)
+		return
+	}
+
+	if req.ClientID != fmt.Sprint(c.ClientID()) {
+		writeRegistrationError(w, invalidClientMetadata("client_id does not match"))
+		return
+	}
+
+	if err := req.ClientMetadata.validate(); err != nil {
+		writeRegistrationError(w, err)
+		return
+	}
+
+	if err := rh.updateClient(r.Context(), c, req.ClientMetadata); err != nil {
+		writeRegistrationError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, rh.clientInformation(c))
+}
+
+func (rh *registrationHandler) delete(w http.ResponseWriter, r *http.Request) {
+	c := rh.client(w, r)
+	if c == nil {
+		return
+	}
+
+	if err := rh.deleteClient(r.Context(), c); err != nil {
+		warnf("cannot delete client: %v", err)
+		http.Error(w, "cannot delete client", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
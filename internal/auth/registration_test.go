@@ -0,0 +1,242 @@
+package auth
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/seb-schulz/onegate/internal/database"
+)
+
+func TestClientMetadataValidate(t *testing.T) {
+	type testCase struct {
+		metadata     ClientMetadata
+		expectedCode string
+	}
+
+	for _, tc := range []testCase{
+		{ClientMetadata{RedirectURIs: []string{"https://example.com/cb"}}, ""},
+		{ClientMetadata{RedirectURIs: []string{"https://example.com/cb"}, TokenEndpointAuthMethod: AuthMethodClientSecretPost, LogoURI: "https://example.com/logo.png", Contacts: []string{"admin@example.com"}}, ""},
+		{ClientMetadata{RedirectURIs: []string{"https://example.com/cb"}, JWKS: json.RawMessage(`{"keys":[{"kty":"EC"}]}`)}, ""},
+		{ClientMetadata{RedirectURIs: []string{"https://example.com/cb"}, BackchannelLogoutURI: "https://example.com/logout"}, ""},
+		{ClientMetadata{RedirectURIs: []string{"http://localhost:9000/cb"}}, ""},
+		{ClientMetadata{RedirectURIs: []string{"http://127.0.0.1:9000/cb", "http://127.0.0.1:9000/other"}}, ""},
+		{ClientMetadata{RedirectURIs: []string{"http://[::1]/cb"}}, ""},
+		{ClientMetadata{}, "invalid_redirect_uri"},
+		{ClientMetadata{RedirectURIs: []string{"http://example.com/cb"}}, "invalid_redirect_uri"},
+		{ClientMetadata{RedirectURIs: []string{"custom://example.com/cb"}}, "invalid_redirect_uri"},
+		{ClientMetadata{RedirectURIs: []string{"https://example.com/cb", "https://other.example.com/cb"}}, "invalid_redirect_uri"},
+		{ClientMetadata{RedirectURIs: []string{"/cb"}}, "invalid_redirect_uri"},
+		{ClientMetadata{RedirectURIs: []string{"https://example.com/cb#foo"}}, "invalid_redirect_uri"},
+		{ClientMetadata{RedirectURIs: []string{"https://example.com/cb"}, TokenEndpointAuthMethod: "none"}, "invalid_client_metadata"},
+		{ClientMetadata{RedirectURIs: []string{"https://example.com/cb"}, GrantTypes: []string{"implicit"}}, "invalid_client_metadata"},
+		{ClientMetadata{RedirectURIs: []string{"https://example.com/cb"}, ResponseTypes: []string{"token"}}, "invalid_client_metadata"},
+		{ClientMetadata{RedirectURIs: []string{"https://example.com/cb"}, LogoURI: "logo.png"}, "invalid_client_metadata"},
+		{ClientMetadata{RedirectURIs: []string{"https://example.com/cb"}, BackchannelLogoutURI: "/logout"}, "invalid_client_metadata"},
+		{ClientMetadata{RedirectURIs: []string{"https://example.com/cb"}, JWKS: json.RawMessage(`{"keys":[]}`)}, "invalid_client_metadata"},
+		{ClientMetadata{RedirectURIs: []string{"https://example.com/cb"}, JWKS: json.RawMessage(`{"keys":[{}]}`), JWKSURI: "https://example.com/jwks"}, "invalid_client_metadata"},
+	} {
+		err := tc.metadata.validate()
+		if tc.expectedCode == "" {
+			if err != nil {
+				t.Errorf("expected valid metadata %#v but got: %v", tc.metadata, err)
+				continue
+			}
+
+			if tc.metadata.TokenEndpointAuthMethod == "" || len(tc.metadata.GrantTypes) == 0 || len(tc.metadata.ResponseTypes) == 0 {
+				t.Errorf("expected defaults to be set but got %#v", tc.metadata)
+			}
+			continue
+		}
+
+		rErr, ok := err.(*registrationError)
+		if !ok || rErr.Code != tc.expectedCode {
+			t.Errorf("expected %v for %#v but got: %v", tc.expectedCode, tc.metadata, err)
+		}
+	}
+}
+
+func TestRegistrationHandler(t *testing.T) {
+	clients := map[string]*Client{}
+	rh := &registrationHandler{
+		initialAccessToken: []byte("initial"),
+		registrationUrl:    url.URL{Scheme: "https", Host: "example.com", Path: "/auth/register"},
+		registerClient: func(ctx context.Context, m ClientMetadata) (*Client, clientCredentials, error) {
+			c := &Client{ID: uuid.New(), CreatedAt: time.Now()}
+			c.applyMetadata(m)
+			clients[fmt.Sprint(c.ID)] = c
+			return c, clientCredentials{"secret", "token"}, nil
+		},
+		registeredClient: func(ctx context.Context, clientID, token string) (*Client, error) {
+			c, ok := clients[clientID]
+			if !ok || token != "token" {
+				return nil, fmt.Errorf("not found")
+			}
+			return c, nil
+		},
+		updateClient: func(ctx context.Context, c *Client, m ClientMetadata) error {
+			c.applyMetadata(m)
+			return nil
+		},
+		deleteClient: func(ctx context.Context, c *Client) error {
+			delete(clients, fmt.Sprint(c.ID))
+			return nil
+		},
+	}
+
+	route := chi.NewRouter()
+	route.Post("/register", rh.register)
+	route.Get("/register/{clientID}", rh.read)
+	route.Put("/register/{clientID}", rh.update)
+	route.Delete("/register/{clientID}", rh.delete)
+
+	do := func(method, path, token, body string) *http.Response {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		route.ServeHTTP(w, req)
+		return w.Result()
+	}
+
+	body := `{"redirect_uris":["https://app.example.com/cb"],"client_name":"App","contacts":["admin@example.com"]}`
+	for _, token := range []string{"", "wrong", base64.StdEncoding.EncodeToString([]byte("initial"))} {
+		if resp := do("POST", "/register", token, body); resp.StatusCode != http.StatusUnauthorized {
+			t.Errorf("expected registration with token %#v to be unauthorized but got %v", token, resp.StatusCode)
+		}
+	}
+
+	if resp := do("POST", "/register", "initial", `{"redirect_uris":[]}`); resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("expected invalid metadata to be rejected but got %v", resp.StatusCode)
+	}
+
+	resp := do("POST", "/register", "initial", body)
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("expected status %v but got %v", http.StatusCreated, resp.StatusCode)
+	}
+
+	var info clientInformation
+	if err := json.NewDecoder(resp.Body).Decode(&info); err != nil {
+		t.Fatalf("cannot decode response: %v", err)
+	}
+
+	if info.ClientSecret != "secret" || info.RegistrationAccessToken != "token" {
+		t.Errorf("expected credentials in response but got %#v", info)
+	}
+
+	if info.ClientName != "App" || info.TokenEndpointAuthMethod != AuthMethodClientSecretBasic {
+		t.Errorf("expected metadata with defaults but got %#v", info.ClientMetadata)
+	}
+
+	if expected := "https://example.com/auth/register/" + info.ClientID; info.RegistrationClientURI != expected {
+		t.Errorf("expected registration client URI %v but got %v", expected, info.RegistrationClientURI)
+	}
+
+	path := "/register/" + info.ClientID
+	if resp := do("GET", path, "wrong", ""); resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("expected read with wrong token to be unauthorized but got %v", resp.StatusCode)
+	}
+
+	resp = do("GET", path, "token", "")
+	info = clientInformation{}
+	json.NewDecoder(resp.Body).Decode(&info)
+	if resp.StatusCode != http.StatusOK || info.ClientSecret != "" || info.RegistrationAccessToken != "" {
+		t.Errorf("expected client information without credentials but got %v: %#v", resp.StatusCode, info)
+	}
+
+	if resp := do("PUT", path, "token", `{"client_id":"other","redirect_uris":["https://app.example.com/cb"]}`); resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("expected update of other client to be rejected but got %v", resp.StatusCode)
+	}
+
+	resp = do("PUT", path, "token", fmt.Sprintf(`{"client_id":%q,"redirect_uris":["https://app.example.com/a","https://app.example.com/b"]}`, info.ClientID))
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("expected status %v but got %v", http.StatusOK, resp.StatusCode)
+	}
+
+	if c := clients[info.ClientID]; c.RedirectURI() != "https://app.example.com/a" || len(c.AllowedRedirectURIs()) != 2 || c.Description != "" {
+		t.Errorf("expected metadata to be replaced but got %#v", c)
+	}
+
+	if resp := do("DELETE", path, "token", ""); resp.StatusCode != http.StatusNoContent {
+		t.Errorf("expected status %v but got %v", http.StatusNoContent, resp.StatusCode)
+	}
+
+	if resp := do("GET", path, "token", ""); resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("expected deleted client to be unauthorized but got %v", resp.StatusCode)
+	}
+}
+
+func TestRegisterClient(t *testing.T) {
+	db, err := database.Open()
+	if err != nil {
+		panic(err)
+	}
+
+	tx := db.Begin()
+	defer tx.Rollback()
+
+	ctx := database.WithContext(context.Background(), tx)
+	newHasher := func() ClientSecretHasher {
+		return newPBKDF2Key([]byte("abc"), 1024, sha1.New)
+	}
+
+	m := ClientMetadata{RedirectURIs: []string{"http://localhost:9000/cb"}, ClientName: "hello world"}
+	if err := m.validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	c, cred, err := registerClient(ctx, newHasher, m)
+	if err != nil {
+		t.Fatalf("cannot register client: %v", err)
+	}
+
+	if err := c.VerifyClientSecret(cred.ClientSecret); err != nil {
+		t.Errorf("cannot verify secret: %v", err)
+	}
+
+	if _, err := registeredClient(ctx, fmt.Sprint(c.ID), cred.ClientSecret); err == nil {
+		t.Errorf("expected client secret to be rejected as registration access token")
+	}
+
+	rc, err := registeredClient(ctx, fmt.Sprint(c.ID), cred.RegistrationAccessToken)
+	if err != nil {
+		t.Fatalf("cannot get registered client: %v", err)
+	}
+
+	if rc.Description != "hello world" || rc.TokenEndpointAuthMethod() != AuthMethodClientSecretBasic {
+		t.Errorf("unexpected client %#v", rc)
+	}
+
+	other := ClientMetadata{RedirectURIs: []string{"http://localhost:9000/other"}}
+	if err := other.validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, _, err := registerClient(ctx, newHasher, other); err == nil {
+		t.Errorf("expected registration on the host of another client to fail")
+	}
+
+	m.LogoURI = "http://localhost:9000/logo.png"
+	if err := updateRegisteredClient(ctx, rc, m); err != nil {
+		t.Errorf("cannot update client: %v", err)
+	}
+
+	if err := deleteRegisteredClient(ctx, rc); err != nil {
+		t.Errorf("cannot delete client: %v", err)
+	}
+
+	if _, err := registeredClient(ctx, fmt.Sprint(c.ID), cred.RegistrationAccessToken); err == nil {
+		t.Errorf("expected deleted client to be gone")
+	}
+}
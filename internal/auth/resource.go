@@ -0,0 +1,145 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/seb-schulz/onegate/internal/database"
+	"gorm.io/gorm"
+)
+
+// Resource is a protected API which access tokens can be targeted at (see
+// RFC 8707).
+type Resource struct {
+	ID          uint `gorm:"primarykey"`
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+	DeletedAt   gorm.DeletedAt `gorm:"index"`
+	Identifier  string         `gorm:"type:VARCHAR(191);uniqueIndex;not null"`
+	Description string         `gorm:"type:VARCHAR(255);not null"`
+	Scopes      []string       `gorm:"serializer:json"`
+	// AccessTokenExpiresIn overrides the default lifetime of access tokens
+	AccessTokenExpiresIn time.Duration
+}
+
+type resourcesByIdentifierFn func(ctx context.Context, identifiers []string) ([]Resource, error)
+
+// validResourceIdentifier checks for an absolute URI without fragment.
+func validResourceIdentifier(identifier string) error {
+	u, err := url.Parse(identifier)
+	if err != nil {
+		return fmt.Errorf("cannot parse resource identifier: %v", err)
+	}
+
+	if !u.IsAbs() || u.Fragment != "" || strings.Contains(identifier, "#") {
+		return fmt.Errorf("resource identifier must be an absolute URI without fragment")
+	}
+	return nil
+}
+
+func CreateResource(ctx context.Context, identifier, desc string, scopes []string, accessTokenExpiresIn time.Duration) (*Resource, error) {
+	if err := validResourceIdentifier(identifier); err != nil {
+		return nil, err
+	}
+
+	resource := Resource{
+		Identifier:           identifier,
+		Description:          desc,
+		Scopes:               scopes,
+		AccessTokenExpiresIn: accessTokenExpiresIn,
+	}
+
+	if r := database.FromContext(ctx).Create(&resource); r.Error != nil {
+		return nil, fmt.Errorf("cannot create resource: %v", r.Error)
+	}
+
+	return &resource, nil
+}
+
+// resourcesByIdentifier fails unless all identifiers belong to resources.
+func resourcesByIdentifier(ctx context.Context, identifiers []string) ([]Resource, error) {
+	resources := []Resource{}
+	if r := database.FromContext(ctx).Where("identifier IN ?", identifiers).Find(&resources); r.Error != nil {
+		return nil, fmt.Errorf("cannot get resources: %v", r.Error)
+	}
+
+	for _, identifier := range identifiers {
+		if !slices.ContainsFunc(resources, func(r Resource) bool { return r.Identifier == identifier }) {
+			return nil, fmt.Errorf("unknown resource %#v", identifier)
+		}
+	}
+
+	return resources, nil
+}
+
+// selectResources returns the resources of a token request. They default to
+// the resources of the authorization request and must not exceed them.
+func selectResources(authorized, requested []string) ([]string, error) {
+	if len(requested) == 0 {
+		return authorized, nil
+	}
+
+	for _, r := range requested {
+		if !slices.Contains(authorized, r) {
+			return nil, errInvalidTarget
+		}
+	}
+	return requested, nil
+}
+
+// tokenTarget describes audience, scope and lifetime of an access token.
+type tokenTarget struct {
+	Audience  []string
+	Scope     string
+	ExpiresIn time.Duration
+}
+
+// oidcScopes are kept in access tokens for resources since they grant
+// access to the userinfo endpoint and claims of the issuer.
+var oidcScopes = []string{"openid", "profile", "email", ScopeGroups, ScopeRoles}
+
+// newTokenTarget restricts an access token to the given resources. The
+// authorized scopes are limited to OpenID Connect scopes and those defined by
+// the resources and the shortest lifetime of them applies. Without resources
+// the token is targeted at the issuer with all authorized scopes.
+func newTokenTarget(resources []Resource, scope string, defaultExpiresIn time.Duration) tokenTarget {
+	target := tokenTarget{}
+	if len(resources) == 0 {
+		target.Scope = scope
+		target.ExpiresIn = defaultExpiresIn
+		return target
+	}
+
+	scopes := []string{}
+	for _, s := range strings.Fields(scope) {
+		if slices.Contains(oidcScopes, s) && !slices.Contains(scopes, s) {
+			scopes = append(scopes, s)
+		}
+	}
+
+	for _, r := range resources {
+		target.Audience = append(target.Audience, r.Identifier)
+
+		expiresIn := r.AccessTokenExpiresIn
+		if expiresIn <= 0 {
+			expiresIn = defaultExpiresIn
+		}
+
+		if target.ExpiresIn == 0 || expiresIn < target.ExpiresIn {
+			target.ExpiresIn = expiresIn
+		}
+
+		for _, s := range strings.Fields(scope) {
+			if slices.Contains(r.Scopes, s) && !slices.Contains(scopes, s) {
+				scopes = append(scopes, s)
+			}
+		}
+	}
+	target.Scope = strings.Join(scopes, " ")
+
+	return target
+}
@@ -0,0 +1,104 @@
+package auth
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/seb-schulz/onegate/internal/database"
+)
+
+func TestValidResourceIdentifier(t *testing.T) {
+	for identifier, valid := range map[string]bool{
+		"https://api.example.com":          true,
+		"https://api.example.com/v1":       true,
+		"urn:example:api":                  true,
+		"/api":                             false,
+		"https://api.example.com/#section": false,
+		"":                                 false,
+	} {
+		if err := validResourceIdentifier(identifier); (err == nil) != valid {
+			t.Errorf("expected %#v to be valid=%v but got: %v", identifier, valid, err)
+		}
+	}
+}
+
+func TestSelectResources(t *testing.T) {
+	authorized := []string{"https://a.example.com", "https://b.example.com"}
+
+	for _, tc := range []struct {
+		authorized []string
+		requested  []string
+		expected   []string
+		fails      bool
+	}{
+		{nil, nil, nil, false},
+		{authorized, nil, authorized, false},
+		{authorized, []string{"https://b.example.com"}, []string{"https://b.example.com"}, false},
+		{authorized, []string{"https://c.example.com"}, nil, true},
+		{nil, []string{"https://a.example.com"}, nil, true},
+	} {
+		got, err := selectResources(tc.authorized, tc.requested)
+		if (err != nil) != tc.fails {
+			t.Errorf("expected failure=%v for %v but got: %v", tc.fails, tc.requested, err)
+		}
+
+		if !slices.Equal(got, tc.expected) {
+			t.Errorf("expected %v but got %v", tc.expected, got)
+		}
+	}
+}
+
+func TestNewTokenTarget(t *testing.T) {
+	a := Resource{Identifier: "https://a.example.com", Scopes: []string{"read", "write"}, AccessTokenExpiresIn: time.Hour}
+	b := Resource{Identifier: "https://b.example.com", Scopes: []string{"read", "admin"}}
+
+	for _, tc := range []struct {
+		resources []Resource
+		scope     string
+		expected  tokenTarget
+	}{
+		{nil, "openid read", tokenTarget{nil, "openid read", 5 * time.Minute}},
+		{[]Resource{a}, "openid read", tokenTarget{[]string{"https://a.example.com"}, "openid read", time.Hour}},
+		{[]Resource{a}, "openid profile groups read", tokenTarget{[]string{"https://a.example.com"}, "openid profile groups read", time.Hour}},
+		{[]Resource{a, b}, "openid read write admin", tokenTarget{[]string{"https://a.example.com", "https://b.example.com"}, "openid read write admin", 5 * time.Minute}},
+		{[]Resource{b}, "write", tokenTarget{[]string{"https://b.example.com"}, "", 5 * time.Minute}},
+	} {
+		got := newTokenTarget(tc.resources, tc.scope, 5*time.Minute)
+		if !slices.Equal(got.Audience, tc.expected.Audience) || got.Scope != tc.expected.Scope || got.ExpiresIn != tc.expected.ExpiresIn {
+			t.Errorf("expected %#v but got %#v", tc.expected, got)
+		}
+	}
+}
+
+func TestCreateResource(t *testing.T) {
+	db, err := database.Open()
+	if err != nil {
+		panic(err)
+	}
+
+	tx := db.Begin()
+	defer tx.Rollback()
+
+	ctx := database.WithContext(context.Background(), tx)
+
+	if _, err := CreateResource(ctx, "/api", "invalid", nil, 0); err == nil {
+		t.Errorf("expected relative identifier to be rejected")
+	}
+
+	if _, err := CreateResource(ctx, "https://api.example.com", "API", []string{"read"}, time.Hour); err != nil {
+		t.Fatalf("cannot create resource: %v", err)
+	}
+
+	resources, err := resourcesByIdentifier(ctx, []string{"https://api.example.com"})
+	if err != nil {
+		t.Errorf("cannot get resources: %v", err)
+	} else if len(resources) != 1 || resources[0].AccessTokenExpiresIn != time.Hour || !slices.Equal(resources[0].Scopes, []string{"read"}) {
+		t.Errorf("unexpected resources %#v", resources)
+	}
+
+	if _, err := resourcesByIdentifier(ctx, []string{"https://api.example.com", "https://unknown.example.com"}); err == nil {
+		t.Errorf("expected unknown resource to fail")
+	}
+}
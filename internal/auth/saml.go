@@ -0,0 +1,361 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"net/url"
+	"slices"
+	"time"
+
+	"github.com/crewjam/saml"
+	"github.com/google/uuid"
+	dsig "github.com/russellhaering/goxmldsig"
+	"github.com/seb-schulz/onegate/internal/database"
+	"github.com/seb-schulz/onegate/internal/model"
+	"github.com/seb-schulz/onegate/internal/sessionmgr"
+)
+
+// SAMLRequest is an authentication request of a service provider which waits
+// for the user of the browser session to log in.
+type SAMLRequest struct {
+	ID            uint `gorm:"primarykey"`
+	CreatedAt     time.Time
+	SessionID     uuid.UUID `gorm:"type:VARCHAR(191);not null"`
+	TransactionID string    `gorm:"size:191;uniqueIndex"`
+	Request       []byte    `gorm:"type:BLOB;not null"`
+	RelayState    string    `gorm:"type:VARCHAR(255);not null;default:''"`
+}
+
+// idpAuthnRequest restores the request as it was received so that its issue
+// instant is validated against the time of arrival.
+func (sr *SAMLRequest) idpAuthnRequest(idp *saml.IdentityProvider, r *http.Request) *saml.IdpAuthnRequest {
+	return &saml.IdpAuthnRequest{
+		IDP:           idp,
+		HTTPRequest:   r,
+		RelayState:    sr.RelayState,
+		RequestBuffer: sr.Request,
+		Now:           sr.CreatedAt,
+	}
+}
+
+func (sr *SAMLRequest) Delete(ctx context.Context) error {
+	if r := database.FromContext(ctx).Delete(sr); r.Error != nil {
+		return fmt.Errorf("cannot delete SAML request: %v", r.Error)
+	}
+	return nil
+}
+
+func createSAMLRequest(ctx context.Context, req *saml.IdpAuthnRequest) (string, error) {
+	samlReq := SAMLRequest{
+		CreatedAt:     req.Now,
+		SessionID:     sessionmgr.FromContext(ctx).UUID,
+		TransactionID: newTransactionID(),
+		Request:       req.RequestBuffer,
+		RelayState:    req.RelayState,
+	}
+
+	if r := database.FromContext(ctx).Create(&samlReq); r.Error != nil {
+		return "", fmt.Errorf("cannot create SAML request: %v", r.Error)
+	}
+
+	return samlReq.TransactionID, nil
+}
+
+// SAMLRequestByTransaction returns the pending SAML request of the current
+// session which was started with the given transaction ID.
+func SAMLRequestByTransaction(ctx context.Context, txID string) (*SAMLRequest, error) {
+	if txID == "" {
+		return nil, errMissingTransaction
+	}
+
+	sessionID := sessionmgr.FromContext(ctx).UUID
+	samlReq := SAMLRequest{}
+	if r := database.FromContext(ctx).Where("session_id = ? AND transaction_id = ?", sessionID, txID).First(&samlReq); r.Error != nil {
+		return nil, fmt.Errorf("cannot get SAML request: %v", r.Error)
+	}
+
+	return &samlReq, nil
+}
+
+func deleteExpiredSAMLRequests(ctx context.Context, requestExpiresIn time.Duration) error {
+	if r := database.FromContext(ctx).Where("created_at < ?", time.Now().Add(-requestExpiresIn)).Delete(&SAMLRequest{}); r.Error != nil {
+		return fmt.Errorf("cannot delete expired SAML requests: %v", r.Error)
+	}
+	return nil
+}
+
+// NewSAMLCertificate returns a DER encoded, self-signed certificate of the key
+// which signs assertions. Service providers pin it from the IdP metadata.
+func NewSAMLCertificate(key *ecdsa.PrivateKey, commonName string, validFor time.Duration) ([]byte, error) {
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, fmt.Errorf("cannot generate serial number: %v", err)
+	}
+
+	now := time.Now()
+	template := x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: commonName},
+		NotBefore:             now,
+		NotAfter:              now.Add(validFor),
+		KeyUsage:              x509.KeyUsageDigitalSignature,
+		BasicConstraintsValid: true,
+	}
+
+	return x509.CreateCertificate(rand.Reader, &template, &template, &key.PublicKey, key)
+}
+
+// xmldsigSigner encodes ECDSA signatures as concatenated r and s values as
+// required by XML signatures (see RFC 4051, section 3.3.1) instead of ASN.1.
+type xmldsigSigner struct {
+	*ecdsa.PrivateKey
+}
+
+func (s xmldsigSigner) Sign(rand io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	der, err := s.PrivateKey.Sign(rand, digest, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	sig := struct{ R, S *big.Int }{}
+	if _, err := asn1.Unmarshal(der, &sig); err != nil {
+		return nil, fmt.Errorf("cannot decode signature: %v", err)
+	}
+
+	size := (s.Curve.Params().BitSize + 7) / 8
+	raw := make([]byte, 2*size)
+	sig.R.FillBytes(raw[:size])
+	sig.S.FillBytes(raw[size:])
+	return raw, nil
+}
+
+type samlHandler struct {
+	idp                       *saml.IdentityProvider
+	loginUrl                  url.URL
+	subjectSalt               []byte
+	serviceProviderByEntityID func(ctx context.Context, entityID string) (*ServiceProvider, error)
+	createSAMLRequest         func(ctx context.Context, req *saml.IdpAuthnRequest) (string, error)
+	samlRequestByTransaction  func(ctx context.Context, txID string) (*SAMLRequest, error)
+	deleteSAMLRequest         func(ctx context.Context, sr *SAMLRequest) error
+	currentUser               func(ctx context.Context) *model.User
+	currentAuthnContext       func(ctx context.Context) (*model.AuthnContext, error)
+}
+
+func newSAMLHandler(key *ecdsa.PrivateKey, cert *x509.Certificate, metadataUrl, ssoUrl url.URL) *samlHandler {
+	sh := &samlHandler{}
+	sh.idp = &saml.IdentityProvider{
+		Signer:                  xmldsigSigner{key},
+		Certificate:             cert,
+		MetadataURL:             metadataUrl,
+		SSOURL:                  ssoUrl,
+		ServiceProviderProvider: sh,
+		SignatureMethod:         dsig.ECDSASHA256SignatureMethod,
+	}
+	return sh
+}
+
+// GetServiceProvider implements saml.ServiceProviderProvider.
+func (sh *samlHandler) GetServiceProvider(r *http.Request, entityID string) (*saml.EntityDescriptor, error) {
+	sp, err := sh.serviceProviderByEntityID(r.Context(), entityID)
+	if err != nil {
+		return nil, err
+	}
+	return sp.entityDescriptor(), nil
+}
+
+func (sh *samlHandler) metadata(w http.ResponseWriter, r *http.Request) {
+	ed := sh.idp.Metadata()
+
+	// Assertions are signed only. The IdP never decrypts messages.
+	idpDesc := &ed.IDPSSODescriptors[0]
+	idpDesc.KeyDescriptors = slices.DeleteFunc(idpDesc.KeyDescriptors, func(kd saml.KeyDescriptor) bool {
+		return kd.Use == "encryption"
+	})
+	idpDesc.NameIDFormats = []saml.NameIDFormat{saml.PersistentNameIDFormat, saml.TransientNameIDFormat, saml.UnspecifiedNameIDFormat}
+
+	buf, err := xml.MarshalIndent(ed, "", "  ")
+	if err != nil {
+		warnf("cannot marshal SAML metadata: %v", err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/samlmetadata+xml")
+	w.Write(buf)
+}
+
+// sso receives authentication requests with the HTTP-Redirect and HTTP-POST
+// binding. Requests of users without login are kept until the login page
+// returns with the transaction ID.
+func (sh *samlHandler) sso(w http.ResponseWriter, r *http.Request) {
+	var (
+		req     *saml.IdpAuthnRequest
+		pending *SAMLRequest
+		err     error
+	)
+
+	txID := r.URL.Query().Get(TransactionParam)
+	if r.Method == http.MethodGet && txID != "" {
+		pending, err = sh.samlRequestByTransaction(r.Context(), txID)
+		if err != nil {
+			warnf("SAML request failed with: %v", err)
+			http.NotFound(w, r)
+			return
+		}
+		req = pending.idpAuthnRequest(sh.idp, r)
+	} else {
+		req, err = saml.NewIdpAuthnRequest(sh.idp, r)
+		if err != nil {
+			warnf("cannot parse SAML request: %v", err)
+			http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+			return
+		}
+	}
+
+	if err := req.Validate(); err != nil {
+		warnf("invalid SAML request: %v", err)
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+	req.Now = saml.TimeNow()
+
+	user := sh.currentUser(r.Context())
+	if user == nil {
+		if pending == nil {
+			txID, err = sh.createSAMLRequest(r.Context(), req)
+			if err != nil {
+				warnf("cannot keep SAML request: %v", err)
+				http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+				return
+			}
+		}
+
+		http.Redirect(w, r, fmt.Sprint(TransactionURL(sh.loginUrl, txID)), http.StatusSeeOther)
+		return
+	}
+
+	if !user.IsApproved() {
+		warnf("user %v is not approved to log in to service provider %v", user.ID, req.ServiceProviderMetadata.EntityID)
+		writeAccessDenied(w, "Awaiting approval", awaitingApprovalReason, "")
+		return
+	}
+
+	sp, err := sh.serviceProviderByEntityID(r.Context(), req.ServiceProviderMetadata.EntityID)
+	if err != nil {
+		warnf("cannot get service provider: %v", err)
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	ac, err := sh.currentAuthnContext(r.Context())
+	if err != nil {
+		warnf("cannot get authentication context: %v", err)
+		http.NotFound(w, r)
+		return
+	}
+
+	session, err := sh.session(sp, user, *ac)
+	if err != nil {
+		warnf("cannot create SAML session: %v", err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	if err := (saml.DefaultAssertionMaker{}).MakeAssertion(req, session); err != nil {
+		warnf("cannot make assertion: %v", err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	if ac.Acr != "" {
+		req.Assertion.AuthnStatements[0].AuthnContext.AuthnContextClassRef.Value = ac.Acr
+	}
+
+	if pending != nil {
+		if err := sh.deleteSAMLRequest(r.Context(), pending); err != nil {
+			warnf("%v", err)
+		}
+	}
+
+	if err := req.WriteResponse(w); err != nil {
+		warnf("cannot write SAML response: %v", err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+}
+
+// session collects the name ID and the mapped attributes of the user for the
+// service provider.
+func (sh *samlHandler) session(sp *ServiceProvider, user *model.User, ac model.AuthnContext) (*saml.Session, error) {
+	subject := func() (string, error) {
+		return subjectIdentifier(sp, user.AuthnID, sh.subjectSalt)
+	}
+
+	session := &saml.Session{
+		ID:           newTransactionID(),
+		CreateTime:   saml.TimeNow(),
+		NameIDFormat: string(sp.nameIDFormat()),
+	}
+	if ac.AuthTime != nil {
+		session.CreateTime = *ac.AuthTime
+	}
+
+	// Names of users can change so that other formats carry the persistent
+	// subject as well
+	if sp.nameIDFormat() == saml.TransientNameIDFormat {
+		session.NameID = newTransactionID()
+	} else {
+		nameID, err := subject()
+		if err != nil {
+			return nil, err
+		}
+		session.NameID = nameID
+	}
+
+	names := make([]string, 0, len(sp.Attributes))
+	for name := range sp.Attributes {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	for _, name := range names {
+		var value string
+		switch sp.Attributes[name] {
+		case AttributeSourceSubject:
+			v, err := subject()
+			if err != nil {
+				return nil, err
+			}
+			value = v
+		case AttributeSourceName:
+			value = user.Name
+		case AttributeSourceDisplayName:
+			value = user.DisplayName
+		default:
+			return nil, fmt.Errorf("unknown source %#v of attribute %#v", sp.Attributes[name], name)
+		}
+
+		session.CustomAttributes = append(session.CustomAttributes, saml.Attribute{
+			Name:       name,
+			NameFormat: "urn:oasis:names:tc:SAML:2.0:attrname-format:basic",
+			Values:     []saml.AttributeValue{{Type: "xs:string", Value: value}},
+		})
+	}
+
+	return session, nil
+}
+
+// samlCertificateMatches reports whether the certificate belongs to the key.
+func samlCertificateMatches(key *ecdsa.PrivateKey, cert *x509.Certificate) bool {
+	pub, ok := cert.PublicKey.(*ecdsa.PublicKey)
+	return ok && key.PublicKey.Equal(pub)
+}
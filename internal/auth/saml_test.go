@@ -0,0 +1,302 @@
+package auth
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/xml"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/beevik/etree"
+	"github.com/crewjam/saml"
+	"github.com/seb-schulz/onegate/internal/model"
+)
+
+func newTestSAMLHandler(t *testing.T) *samlHandler {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	der, err := NewSAMLCertificate(key, "onegate", time.Hour)
+	if err != nil {
+		t.Fatalf("cannot create certificate: %v", err)
+	}
+
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("cannot parse certificate: %v", err)
+	}
+
+	if !samlCertificateMatches(key, cert) {
+		t.Fatalf("expected certificate to match key")
+	}
+
+	sh := newSAMLHandler(key, cert, url.URL{Scheme: "https", Host: "idp.example.com", Path: "/auth/saml/metadata"}, url.URL{Scheme: "https", Host: "idp.example.com", Path: "/auth/saml/sso"})
+	sh.loginUrl = url.URL{Path: "/login"}
+	sh.subjectSalt = []byte("salt")
+	sh.serviceProviderByEntityID = func(ctx context.Context, entityID string) (*ServiceProvider, error) {
+		if entityID != "https://sp.example.com" {
+			return nil, os.ErrNotExist
+		}
+		return &ServiceProvider{
+			EntityID:   entityID,
+			ACSURLs:    []string{"https://sp.example.com/saml/acs"},
+			Attributes: map[string]string{"uid": AttributeSourceSubject, "username": AttributeSourceName, "displayName": AttributeSourceDisplayName},
+		}, nil
+	}
+	sh.currentUser = func(ctx context.Context) *model.User {
+		return nil
+	}
+	sh.currentAuthnContext = func(ctx context.Context) (*model.AuthnContext, error) {
+		return &model.AuthnContext{Acr: model.AcrPasskeyUV}, nil
+	}
+	return sh
+}
+
+func newTestAuthnRequest(t *testing.T, sh *samlHandler, entityID string) *saml.AuthnRequest {
+	sp := saml.ServiceProvider{
+		EntityID:    entityID,
+		AcsURL:      url.URL{Scheme: "https", Host: "sp.example.com", Path: "/saml/acs"},
+		IDPMetadata: sh.idp.Metadata(),
+	}
+
+	req, err := sp.MakeAuthenticationRequest(sh.idp.SSOURL.String(), saml.HTTPRedirectBinding, saml.HTTPPostBinding)
+	if err != nil {
+		t.Fatalf("cannot create authentication request: %v", err)
+	}
+	return req
+}
+
+func TestXmldsigSigner(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	digest := sha256.Sum256([]byte("message"))
+	sig, err := xmldsigSigner{key}.Sign(rand.Reader, digest[:], nil)
+	if err != nil {
+		t.Fatalf("cannot sign: %v", err)
+	}
+
+	if len(sig) != 64 {
+		t.Fatalf("expected signature of 64 bytes but got %v", len(sig))
+	}
+
+	r, s := new(big.Int).SetBytes(sig[:32]), new(big.Int).SetBytes(sig[32:])
+	if !ecdsa.Verify(&key.PublicKey, digest[:], r, s) {
+		t.Errorf("cannot verify signature")
+	}
+}
+
+func TestSAMLHandler_metadata(t *testing.T) {
+	sh := newTestSAMLHandler(t)
+
+	rr := httptest.NewRecorder()
+	sh.metadata(rr, httptest.NewRequest(http.MethodGet, "/saml/metadata", nil))
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status 200 but got %v", rr.Code)
+	}
+
+	ed := saml.EntityDescriptor{}
+	if err := xml.Unmarshal(rr.Body.Bytes(), &ed); err != nil {
+		t.Fatalf("cannot parse metadata: %v", err)
+	}
+
+	if ed.EntityID != "https://idp.example.com/auth/saml/metadata" {
+		t.Errorf("unexpected entity ID %#v", ed.EntityID)
+	}
+
+	idpDesc := ed.IDPSSODescriptors[0]
+	if len(idpDesc.KeyDescriptors) != 1 || idpDesc.KeyDescriptors[0].Use != "signing" {
+		t.Errorf("expected signing key only but got %#v", idpDesc.KeyDescriptors)
+	}
+
+	if len(idpDesc.SingleSignOnServices) != 2 {
+		t.Errorf("expected HTTP-Redirect and HTTP-POST binding but got %#v", idpDesc.SingleSignOnServices)
+	}
+}
+
+func TestSAMLHandler_sso(t *testing.T) {
+	sh := newTestSAMLHandler(t)
+	user := &model.User{Name: "jdoe", DisplayName: "John Doe", AuthnID: []byte("1234")}
+
+	pending := map[string]*SAMLRequest{}
+	sh.createSAMLRequest = func(ctx context.Context, req *saml.IdpAuthnRequest) (string, error) {
+		txID := fmt.Sprintf("tx%d", len(pending)+1)
+		pending[txID] = &SAMLRequest{CreatedAt: req.Now, TransactionID: txID, Request: req.RequestBuffer, RelayState: req.RelayState}
+		return txID, nil
+	}
+	sh.samlRequestByTransaction = func(ctx context.Context, txID string) (*SAMLRequest, error) {
+		if r, ok := pending[txID]; ok {
+			return r, nil
+		}
+		return nil, fmt.Errorf("not found")
+	}
+	sh.deleteSAMLRequest = func(ctx context.Context, sr *SAMLRequest) error {
+		delete(pending, sr.TransactionID)
+		return nil
+	}
+
+	redirectRequest := func(entityID string) *http.Request {
+		u, err := newTestAuthnRequest(t, sh, entityID).Redirect("state", &saml.ServiceProvider{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return httptest.NewRequest(http.MethodGet, u.String(), nil)
+	}
+
+	postRequest := func(entityID string) *http.Request {
+		doc := etree.NewDocument()
+		doc.SetRoot(newTestAuthnRequest(t, sh, entityID).Element())
+		buf, err := doc.WriteToBytes()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		form := url.Values{"SAMLRequest": {base64.StdEncoding.EncodeToString(buf)}, "RelayState": {"state"}}
+		r := httptest.NewRequest(http.MethodPost, "/saml/sso", strings.NewReader(form.Encode()))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		return r
+	}
+
+	t.Run("unknown service provider", func(t *testing.T) {
+		rr := httptest.NewRecorder()
+		sh.sso(rr, redirectRequest("https://unknown.example.com"))
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("expected status 400 but got %v", rr.Code)
+		}
+	})
+
+	t.Run("unknown transaction", func(t *testing.T) {
+		rr := httptest.NewRecorder()
+		sh.sso(rr, httptest.NewRequest(http.MethodGet, "/saml/sso?tx=unknown", nil))
+		if rr.Code != http.StatusNotFound {
+			t.Errorf("expected status 404 but got %v", rr.Code)
+		}
+	})
+
+	for binding, newRequest := range map[string]func(string) *http.Request{"redirect": redirectRequest, "post": postRequest} {
+		t.Run(binding, func(t *testing.T) {
+			sh.currentUser = func(ctx context.Context) *model.User { return nil }
+
+			rr := httptest.NewRecorder()
+			sh.sso(rr, newRequest("https://sp.example.com"))
+			if rr.Code != http.StatusSeeOther {
+				t.Fatalf("expected redirect to login but got %v: %v", rr.Code, rr.Body.String())
+			}
+
+			loc, _ := url.Parse(rr.Header().Get("Location"))
+			txID := loc.Query().Get(TransactionParam)
+			if loc.Path != "/login" || pending[txID] == nil {
+				t.Fatalf("expected login with pending transaction but got %v", loc)
+			}
+
+			sh.currentUser = func(ctx context.Context) *model.User { return user }
+
+			rr = httptest.NewRecorder()
+			sh.sso(rr, httptest.NewRequest(http.MethodGet, fmt.Sprint(TransactionURL(url.URL{Path: "/saml/sso"}, txID)), nil))
+			if rr.Code != http.StatusOK {
+				t.Fatalf("expected status 200 but got %v: %v", rr.Code, rr.Body.String())
+			}
+
+			if pending[txID] != nil {
+				t.Errorf("expected pending request to be deleted")
+			}
+
+			assertion := samlAssertionFromForm(t, rr.Body.String())
+			nameID := assertion.FindElement("./Subject/NameID")
+			if nameID == nil || nameID.SelectAttrValue("Format", "") != string(saml.PersistentNameIDFormat) || nameID.Text() == "" || nameID.Text() == user.Name {
+				t.Errorf("expected pairwise persistent name ID but got %#v", nameID)
+			}
+
+			if m := assertion.FindElement("./Signature/SignedInfo/SignatureMethod"); m == nil || m.SelectAttrValue("Algorithm", "") != "http://www.w3.org/2001/04/xmldsig-more#ecdsa-sha256" {
+				t.Errorf("expected assertion signed with ECDSA")
+			}
+
+			sig, err := base64.StdEncoding.DecodeString(assertion.FindElement("./Signature/SignatureValue").Text())
+			if err != nil || len(sig) != 64 {
+				t.Errorf("expected raw ECDSA signature but got %v bytes: %v", len(sig), err)
+			}
+
+			if acr := assertion.FindElement("./AuthnStatement/AuthnContext/AuthnContextClassRef"); acr == nil || acr.Text() != model.AcrPasskeyUV {
+				t.Errorf("expected acr of session in authentication context")
+			}
+
+			attributes := map[string]string{}
+			for _, el := range assertion.FindElements("./AttributeStatement/Attribute") {
+				attributes[el.SelectAttrValue("Name", "")] = el.FindElement("./AttributeValue").Text()
+			}
+			if attributes["uid"] != nameID.Text() || attributes["username"] != "jdoe" || attributes["displayName"] != "John Doe" {
+				t.Errorf("unexpected attributes %v", attributes)
+			}
+		})
+	}
+}
+
+func TestSAMLHandler_session(t *testing.T) {
+	sh := newTestSAMLHandler(t)
+	user := &model.User{Name: "jdoe", AuthnID: []byte("1234")}
+
+	persistent, err := sh.session(&ServiceProvider{EntityID: "https://sp.example.com"}, user, model.AuthnContext{})
+	if err != nil {
+		t.Fatalf("cannot create session: %v", err)
+	}
+
+	for _, tc := range []struct {
+		format   saml.NameIDFormat
+		expected func(nameID string) bool
+	}{
+		{saml.PersistentNameIDFormat, func(nameID string) bool { return nameID == persistent.NameID && nameID != user.Name }},
+		{saml.UnspecifiedNameIDFormat, func(nameID string) bool { return nameID == persistent.NameID }},
+		{saml.TransientNameIDFormat, func(nameID string) bool { return nameID != persistent.NameID && nameID != user.Name }},
+	} {
+		session, err := sh.session(&ServiceProvider{EntityID: "https://sp.example.com", NameIDFormat: string(tc.format)}, user, model.AuthnContext{})
+		if err != nil {
+			t.Fatalf("cannot create session: %v", err)
+		}
+
+		if session.NameIDFormat != string(tc.format) || !tc.expected(session.NameID) {
+			t.Errorf("unexpected name ID %#v of format %v", session.NameID, tc.format)
+		}
+	}
+}
+
+// samlAssertionFromForm extracts the assertion of the auto-submitting form of
+// the HTTP-POST binding.
+func samlAssertionFromForm(t *testing.T, body string) *etree.Element {
+	m := regexp.MustCompile(`name="SAMLResponse" value="([^"]+)"`).FindStringSubmatch(body)
+	if m == nil {
+		t.Fatalf("missing SAML response in %v", body)
+	}
+
+	raw, err := base64.StdEncoding.DecodeString(m[1])
+	if err != nil {
+		t.Fatalf("cannot decode SAML response: %v", err)
+	}
+
+	doc := etree.NewDocument()
+	if err := doc.ReadFromBytes(raw); err != nil {
+		t.Fatalf("cannot parse SAML response: %v", err)
+	}
+
+	if doc.FindElement("./Response/Assertion") == nil {
+		t.Fatalf("missing assertion in %s", raw)
+	}
+	return doc.FindElement("./Response/Assertion")
+}
@@ -0,0 +1,177 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"slices"
+	"time"
+
+	"github.com/crewjam/saml"
+	"github.com/seb-schulz/onegate/internal/database"
+	"gorm.io/gorm"
+)
+
+// Sources of SAML attributes which can be mapped per service provider. Users
+// can change their name so that only the subject identifies them.
+const (
+	AttributeSourceSubject     = "subject"
+	AttributeSourceName        = "name"
+	AttributeSourceDisplayName = "displayName"
+)
+
+var (
+	AttributeSources = []string{AttributeSourceSubject, AttributeSourceName, AttributeSourceDisplayName}
+	NameIDFormats    = map[string]saml.NameIDFormat{
+		"persistent":  saml.PersistentNameIDFormat,
+		"transient":   saml.TransientNameIDFormat,
+		"unspecified": saml.UnspecifiedNameIDFormat,
+	}
+)
+
+// ServiceProvider is a SAML 2.0 relying party. Its assertions are addressed to
+// the entity ID and delivered to one of the assertion consumer service URLs.
+type ServiceProvider struct {
+	ID          uint `gorm:"primarykey"`
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+	DeletedAt   gorm.DeletedAt `gorm:"index"`
+	EntityID    string         `gorm:"type:VARCHAR(191);uniqueIndex;not null"`
+	Description string         `gorm:"type:VARCHAR(255);not null"`
+	ACSURLs     []string       `gorm:"column:acs_urls;serializer:json"`
+	// NameIDFormat defaults to persistent identifiers
+	NameIDFormat string `gorm:"type:VARCHAR(255);not null;default:''"`
+	// Attributes maps names of SAML attributes to the user data they carry
+	Attributes map[string]string `gorm:"serializer:json"`
+}
+
+// SubjectType makes persistent name IDs pairwise so that service providers
+// cannot correlate users.
+func (sp *ServiceProvider) SubjectType() string {
+	return SubjectTypePairwise
+}
+
+func (sp *ServiceProvider) SectorIdentifier() string {
+	return sp.EntityID
+}
+
+func (sp *ServiceProvider) nameIDFormat() saml.NameIDFormat {
+	if sp.NameIDFormat == "" {
+		return saml.PersistentNameIDFormat
+	}
+	return saml.NameIDFormat(sp.NameIDFormat)
+}
+
+func (sp *ServiceProvider) validate() error {
+	if sp.EntityID == "" {
+		return fmt.Errorf("entity ID must not be empty")
+	}
+
+	if len(sp.ACSURLs) == 0 {
+		return fmt.Errorf("at least one assertion consumer service URL is required")
+	}
+
+	for _, acsURL := range sp.ACSURLs {
+		u, err := url.Parse(acsURL)
+		if err != nil || !u.IsAbs() || u.Fragment != "" {
+			return fmt.Errorf("invalid assertion consumer service URL %#v", acsURL)
+		}
+	}
+
+	supported := false
+	for _, format := range NameIDFormats {
+		supported = supported || format == sp.nameIDFormat()
+	}
+	if !supported {
+		return fmt.Errorf("unsupported name ID format %#v", sp.NameIDFormat)
+	}
+
+	for name, source := range sp.Attributes {
+		if name == "" {
+			return fmt.Errorf("attribute name must not be empty")
+		}
+
+		if !slices.Contains(AttributeSources, source) {
+			return fmt.Errorf("unknown source %#v of attribute %#v", source, name)
+		}
+	}
+
+	return nil
+}
+
+// entityDescriptor returns the metadata of the service provider. Assertions
+// are delivered with the HTTP-POST binding to the first ACS URL by default.
+func (sp *ServiceProvider) entityDescriptor() *saml.EntityDescriptor {
+	acs := make([]saml.IndexedEndpoint, len(sp.ACSURLs))
+	for i, acsURL := range sp.ACSURLs {
+		isDefault := i == 0
+		acs[i] = saml.IndexedEndpoint{
+			Binding:   saml.HTTPPostBinding,
+			Location:  acsURL,
+			Index:     i,
+			IsDefault: &isDefault,
+		}
+	}
+
+	return &saml.EntityDescriptor{
+		EntityID: sp.EntityID,
+		SPSSODescriptors: []saml.SPSSODescriptor{{
+			SSODescriptor: saml.SSODescriptor{
+				NameIDFormats: []saml.NameIDFormat{sp.nameIDFormat()},
+			},
+			AssertionConsumerServices: acs,
+		}},
+	}
+}
+
+type ServiceProviderOptFunc func(*ServiceProvider)
+
+func WithNameIDFormat(format saml.NameIDFormat) ServiceProviderOptFunc {
+	return func(sp *ServiceProvider) {
+		sp.NameIDFormat = string(format)
+	}
+}
+
+// WithAttributes maps SAML attribute names to one of the AttributeSources.
+func WithAttributes(attributes map[string]string) ServiceProviderOptFunc {
+	return func(sp *ServiceProvider) {
+		sp.Attributes = attributes
+	}
+}
+
+func CreateServiceProvider(ctx context.Context, entityID, desc string, acsURLs []string, opts ...ServiceProviderOptFunc) (*ServiceProvider, error) {
+	sp := ServiceProvider{
+		EntityID:    entityID,
+		Description: desc,
+		ACSURLs:     acsURLs,
+	}
+
+	for _, o := range opts {
+		o(&sp)
+	}
+
+	if err := sp.validate(); err != nil {
+		return nil, err
+	}
+
+	if r := database.FromContext(ctx).Create(&sp); r.Error != nil {
+		return nil, fmt.Errorf("cannot create service provider: %v", r.Error)
+	}
+
+	return &sp, nil
+}
+
+// serviceProviderByEntityID returns os.ErrNotExist for unknown entities as
+// expected by saml.ServiceProviderProvider.
+func serviceProviderByEntityID(ctx context.Context, entityID string) (*ServiceProvider, error) {
+	sp := ServiceProvider{}
+	r := database.FromContext(ctx).Where("entity_id = ?", entityID).First(&sp)
+	if r.Error == gorm.ErrRecordNotFound {
+		return nil, os.ErrNotExist
+	} else if r.Error != nil {
+		return nil, fmt.Errorf("cannot get service provider: %v", r.Error)
+	}
+
+	return &sp, nil
+}
@@ -0,0 +1,84 @@
+package auth
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/crewjam/saml"
+	"github.com/seb-schulz/onegate/internal/database"
+)
+
+func TestServiceProviderValidate(t *testing.T) {
+	valid := ServiceProvider{EntityID: "https://sp.example.com", ACSURLs: []string{"https://sp.example.com/acs"}}
+
+	for _, tc := range []struct {
+		name  string
+		opt   func(*ServiceProvider)
+		valid bool
+	}{
+		{"defaults", func(sp *ServiceProvider) {}, true},
+		{"missing entity ID", func(sp *ServiceProvider) { sp.EntityID = "" }, false},
+		{"missing ACS URL", func(sp *ServiceProvider) { sp.ACSURLs = nil }, false},
+		{"relative ACS URL", func(sp *ServiceProvider) { sp.ACSURLs = []string{"/acs"} }, false},
+		{"transient name ID", WithNameIDFormat(saml.TransientNameIDFormat), true},
+		{"email name ID", WithNameIDFormat(saml.EmailAddressNameIDFormat), false},
+		{"mapped attributes", WithAttributes(map[string]string{"uid": AttributeSourceName}), true},
+		{"unknown attribute source", WithAttributes(map[string]string{"mail": "email"}), false},
+	} {
+		sp := valid
+		tc.opt(&sp)
+		if err := sp.validate(); (err == nil) != tc.valid {
+			t.Errorf("%v: expected valid=%v but got: %v", tc.name, tc.valid, err)
+		}
+	}
+}
+
+func TestServiceProviderEntityDescriptor(t *testing.T) {
+	sp := ServiceProvider{EntityID: "https://sp.example.com", ACSURLs: []string{"https://sp.example.com/acs", "https://sp.example.com/acs2"}}
+	ed := sp.entityDescriptor()
+
+	if ed.EntityID != sp.EntityID {
+		t.Errorf("unexpected entity ID %#v", ed.EntityID)
+	}
+
+	acs := ed.SPSSODescriptors[0].AssertionConsumerServices
+	if len(acs) != 2 || !*acs[0].IsDefault || *acs[1].IsDefault || acs[1].Binding != saml.HTTPPostBinding {
+		t.Errorf("unexpected assertion consumer services %#v", acs)
+	}
+
+	if formats := ed.SPSSODescriptors[0].NameIDFormats; len(formats) != 1 || formats[0] != saml.PersistentNameIDFormat {
+		t.Errorf("expected persistent name ID by default but got %v", formats)
+	}
+}
+
+func TestCreateServiceProvider(t *testing.T) {
+	db, err := database.Open()
+	if err != nil {
+		panic(err)
+	}
+
+	tx := db.Begin()
+	defer tx.Rollback()
+
+	ctx := database.WithContext(context.Background(), tx)
+
+	if _, err := CreateServiceProvider(ctx, "https://sp.example.com", "invalid", nil); err == nil {
+		t.Errorf("expected service provider without ACS URL to be rejected")
+	}
+
+	if _, err := CreateServiceProvider(ctx, "https://sp.example.com", "SP", []string{"https://sp.example.com/acs"}, WithAttributes(map[string]string{"uid": AttributeSourceName})); err != nil {
+		t.Fatalf("cannot create service provider: %v", err)
+	}
+
+	sp, err := serviceProviderByEntityID(ctx, "https://sp.example.com")
+	if err != nil {
+		t.Errorf("cannot get service provider: %v", err)
+	} else if sp.Attributes["uid"] != AttributeSourceName || sp.nameIDFormat() != saml.PersistentNameIDFormat {
+		t.Errorf("unexpected service provider %#v", sp)
+	}
+
+	if _, err := serviceProviderByEntityID(ctx, "https://unknown.example.com"); err != os.ErrNotExist {
+		t.Errorf("expected unknown service provider to fail with os.ErrNotExist but got: %v", err)
+	}
+}
@@ -0,0 +1,77 @@
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+
+	"github.com/seb-schulz/onegate/internal/database"
+	"github.com/seb-schulz/onegate/internal/model"
+)
+
+type subjectFn func(ctx context.Context, c client, userID uint) (string, error)
+
+// subjectIdentifier derives the sub claim of a user for a client. Public
+// subjects hide the database ID behind the random WebAuthn user handle while
+// pairwise subjects differ per sector so that clients cannot correlate users
+// (see OpenID Connect Core 1.0, section 8.1).
+func subjectIdentifier(c subjecter, authnID, salt []byte) (string, error) {
+	switch c.SubjectType() {
+	case SubjectTypePublic:
+		return publicSubject(authnID), nil
+	case SubjectTypePairwise:
+		if len(salt) == 0 {
+			return "", fmt.Errorf("salt for pairwise subjects is not configured")
+		}
+
+		h := sha256.New()
+		h.Write([]byte(c.SectorIdentifier()))
+		h.Write(authnID)
+		h.Write(salt)
+		return base64.RawURLEncoding.EncodeToString(h.Sum(nil)), nil
+	default:
+		return "", fmt.Errorf("unknown subject type: %v", c.SubjectType())
+	}
+}
+
+// publicSubject is the same for all clients and never changes.
+func publicSubject(authnID []byte) string {
+	return base64.RawURLEncoding.EncodeToString(authnID)
+}
+
+// CheckSubjectSalt fails if clients use pairwise subjects but no salt is
+// configured since their tokens could not be issued otherwise.
+func CheckSubjectSalt(ctx context.Context, salt []byte) error {
+	if len(salt) > 0 {
+		return nil
+	}
+
+	var count int64
+	if r := database.FromContext(ctx).Model(&Client{}).Where("subject_type = ?", SubjectTypePairwise).Count(&count); r.Error != nil {
+		return fmt.Errorf("cannot count pairwise clients: %v", r.Error)
+	}
+
+	if count > 0 {
+		return fmt.Errorf("%d clients use pairwise subjects but auth.subjectSalt is not configured", count)
+	}
+	return nil
+}
+
+func userByID(ctx context.Context, userID uint) (*model.User, error) {
+	user := model.User{}
+	if r := database.FromContext(ctx).First(&user, userID); r.Error != nil {
+		return nil, fmt.Errorf("cannot get user: %w", r.Error)
+	}
+	return &user, nil
+}
+
+func subjectByUserID(salt []byte) subjectFn {
+	return func(ctx context.Context, c client, userID uint) (string, error) {
+		user, err := userByID(ctx, userID)
+		if err != nil {
+			return "", err
+		}
+		return subjectIdentifier(c, user.AuthnID, salt)
+	}
+}
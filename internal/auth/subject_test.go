@@ -0,0 +1,76 @@
+package auth
+
+import (
+	"testing"
+)
+
+type mockSubjecter struct {
+	subjectType, sectorIdentifier string
+}
+
+func (ms mockSubjecter) SubjectType() string {
+	return ms.subjectType
+}
+
+func (ms mockSubjecter) SectorIdentifier() string {
+	return ms.sectorIdentifier
+}
+
+func TestSubjectIdentifier(t *testing.T) {
+	authnID, otherAuthnID := []byte("0123456789abcdef"), []byte("fedcba9876543210")
+	salt := []byte("salt")
+
+	subject := func(c subjecter, authnID, salt []byte) string {
+		sub, err := subjectIdentifier(c, authnID, salt)
+		if err != nil {
+			t.Fatalf("cannot derive subject: %v", err)
+		}
+		return sub
+	}
+
+	public := mockSubjecter{SubjectTypePublic, ""}
+	if got := subject(public, authnID, nil); got != "MDEyMzQ1Njc4OWFiY2RlZg" {
+		t.Errorf("got public subject %#v", got)
+	}
+
+	pairwiseA := mockSubjecter{SubjectTypePairwise, "a.example.com"}
+	pairwiseB := mockSubjecter{SubjectTypePairwise, "b.example.com"}
+
+	if subject(pairwiseA, authnID, salt) != subject(pairwiseA, authnID, salt) {
+		t.Errorf("pairwise subject must be stable")
+	}
+
+	if subject(pairwiseA, authnID, salt) == subject(pairwiseB, authnID, salt) {
+		t.Errorf("pairwise subject must differ between sectors")
+	}
+
+	if subject(pairwiseA, authnID, salt) == subject(pairwiseA, otherAuthnID, salt) {
+		t.Errorf("pairwise subject must differ between users")
+	}
+
+	if subject(pairwiseA, authnID, salt) == subject(public, authnID, salt) {
+		t.Errorf("pairwise subject must differ from public subject")
+	}
+
+	if _, err := subjectIdentifier(pairwiseA, authnID, nil); err == nil {
+		t.Errorf("expected error because of missing salt")
+	}
+
+	if _, err := subjectIdentifier(mockSubjecter{"foobar", ""}, authnID, salt); err == nil {
+		t.Errorf("expected error because of unknown subject type")
+	}
+}
+
+func TestClientSectorIdentifier(t *testing.T) {
+	for _, tc := range []struct {
+		client   Client
+		expected string
+	}{
+		{Client{InternalRedirectURI: "https://example.com:8443/cb"}, "example.com:8443"},
+		{Client{InternalRedirectURI: "https://example.com/cb", InternalSectorIdentifier: "sector.example.com"}, "sector.example.com"},
+	} {
+		if got := tc.client.SectorIdentifier(); got != tc.expected {
+			t.Errorf("expected %#v but got %#v", tc.expected, got)
+		}
+	}
+}
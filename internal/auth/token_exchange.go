@@ -0,0 +1,188 @@
+package auth
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/go-oauth2/oauth2/v4/errors"
+)
+
+const (
+	grantTypeTokenExchange = "urn:ietf:params:oauth:grant-type:token-exchange"
+	tokenTypeAccessToken   = "urn:ietf:params:oauth:token-type:access_token"
+)
+
+var errInvalidTarget = errors.New("invalid_target")
+
+func init() {
+	errors.Descriptions[errInvalidTarget] = "The requested audience or resource is unknown or not allowed for the client"
+	errors.StatusCodes[errInvalidTarget] = http.StatusBadRequest
+}
+
+type TokenExchangeResponds struct {
+	AccessToken     AccessToken `json:"access_token"`
+	IssuedTokenType string      `json:"issued_token_type"`
+	TokenType       string      `json:"token_type"`
+	ExpiresIn       int         `json:"expires_in,omitempty"`
+	Scope           string      `json:"scope,omitempty"`
+}
+
+// checkExchangeTarget verifies that the client may request the audiences and
+// scopes. Scopes must not exceed those of the subject token either.
+func checkExchangeTarget(c tokenExchanger, subjectScope string, audiences, scopes []string) error {
+	if len(audiences) == 0 {
+		return errInvalidTarget
+	}
+
+	for _, aud := range audiences {
+		if !slices.Contains(c.ExchangeAudiences(), aud) {
+			return errInvalidTarget
+		}
+	}
+
+	for _, scope := range scopes {
+		if !slices.Contains(c.ExchangeScopes(), scope) {
+			return errors.ErrInvalidScope
+		}
+
+		// Subject tokens without scope do not allow any scope
+		if !slices.Contains(strings.Fields(subjectScope), scope) {
+			return errors.ErrInvalidScope
+		}
+	}
+
+	return nil
+}
+
+// checkSubjectTokenHolder verifies that the subject token was issued to the
+// client or targeted at it. Otherwise any client allowed to exchange tokens
+// could act for users of other clients.
+func checkSubjectTokenHolder(c client, claims *AccessTokenClaims) error {
+	clientID := fmt.Sprint(c.ClientID())
+	if claims.ClientID == clientID {
+		return nil
+	}
+
+	if aud, _ := claims.GetAudience(); slices.Contains(aud, clientID) {
+		return nil
+	}
+	return errors.ErrInvalidGrant
+}
+
+// exchangeToken implements the token exchange grant (RFC 8693). The calling
+// client becomes the actor of the issued token.
+func (th *tokenHandler) exchangeToken(w http.ResponseWriter, r *http.Request, c client) {
+	if len(c.ExchangeAudiences()) == 0 {
+		httpAuthError(w, errors.ErrUnauthorizedClient)
+		return
+	}
+
+	if r.FormValue("subject_token_type") != tokenTypeAccessToken {
+		httpAuthError(w, errors.ErrInvalidRequest)
+		return
+	}
+
+	if t := r.FormValue("requested_token_type"); t != "" && t != tokenTypeAccessToken {
+		httpAuthError(w, errors.ErrInvalidRequest)
+		return
+	}
+
+	if r.FormValue("actor_token") != "" {
+		warnf("actor tokens are not supported")
+		httpAuthError(w, errors.ErrInvalidRequest)
+		return
+	}
+
+	claims, err := parseAccessToken(r.FormValue("subject_token"), &th.privateKey.PublicKey, th.issuerUrl)
+	if err != nil {
+		warnf("invalid subject token: %v", err)
+		httpAuthError(w, errors.ErrInvalidGrant)
+		return
+	}
+
+	if err := checkSubjectTokenHolder(c, claims); err != nil {
+		warnf("client %v cannot exchange subject token of client %v", c.ClientID(), claims.ClientID)
+		httpAuthError(w, err)
+		return
+	}
+
+	parent, err := th.activeIssuedToken(r.Context(), claims.ID)
+	if err != nil {
+		warnf("subject token is not active: %v", err)
+		httpAuthError(w, errors.ErrInvalidGrant)
+		return
+	}
+
+	// Resource indicators are accepted as audiences as well (RFC 8693, section 2.1)
+	audiences := append(slices.Clone(r.Form["audience"]), r.Form["resource"]...)
+	scopes := strings.Fields(r.FormValue("scope"))
+	if err := checkExchangeTarget(c, claims.Scope, audiences, scopes); err != nil {
+		warnf("client %v requested audiences %v and scopes %v: %v", c.ClientID(), audiences, scopes, err)
+		httpAuthError(w, err)
+		return
+	}
+
+	extraClaims, err := th.extraClaims.claims(r.Context(), c, parent.UserID, strings.Join(scopes, " "))
+	if err != nil {
+		warnf("cannot get claims of access token: %v", err)
+		httpAuthError(w, errors.ErrServerError)
+		return
+	}
+
+	// Exchanged tokens must not outlive the subject token
+	expiresIn := min(th.accessTokenExpiresIn, time.Until(parent.ExpiresAt).Truncate(time.Second))
+	if expiresIn <= 0 {
+		warnf("subject token %v expired", parent.ID)
+		httpAuthError(w, errors.ErrInvalidGrant)
+		return
+	}
+
+	// Pairwise subjects differ between clients so the subject of the token
+	// cannot be passed on
+	sub, err := th.subject(r.Context(), c, parent.UserID)
+	if err != nil {
+		warnf("cannot derive subject: %v", err)
+		httpAuthError(w, errors.ErrServerError)
+		return
+	}
+
+	tokenID, err := th.issueExchangedToken(r.Context(), parent, c, expiresIn)
+	if err != nil {
+		warnf("cannot issue token: %v", err)
+		httpAuthError(w, errors.ErrServerError)
+		return
+	}
+
+	b, err := json.Marshal(TokenExchangeResponds{
+		AccessToken: AccessToken{
+			Key:       th.privateKey,
+			Issuer:    th.issuerUrl,
+			ExpiresIn: expiresIn,
+			ID:        tokenID,
+			Subject:   sub,
+			ClientID:  c.ClientID(),
+			Audience:  audiences,
+			Scope:     strings.Join(scopes, " "),
+			Act:       &Actor{Subject: fmt.Sprint(c.ClientID()), Actor: claims.Act},
+			Claims:    extraClaims,
+		},
+		IssuedTokenType: tokenTypeAccessToken,
+		TokenType:       "Bearer",
+		ExpiresIn:       int(expiresIn.Seconds()),
+		Scope:           strings.Join(scopes, " "),
+	})
+	if err != nil {
+		warnf("cannot generate token: %v", err)
+		httpAuthError(w, errors.ErrServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusOK)
+	w.Write(b)
+}
@@ -0,0 +1,200 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-oauth2/oauth2/v4/errors"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+type mockExchangeClient struct {
+	mockClient
+	audiences []string
+	scopes    []string
+}
+
+func (mc *mockExchangeClient) ExchangeAudiences() []string {
+	return mc.audiences
+}
+
+func (mc *mockExchangeClient) ExchangeScopes() []string {
+	return mc.scopes
+}
+
+func TestCheckExchangeTarget(t *testing.T) {
+	c := &mockExchangeClient{audiences: []string{"https://api.example.com"}, scopes: []string{"read", "write"}}
+
+	for _, tc := range []struct {
+		subjectScope  string
+		audiences     []string
+		scopes        []string
+		expectedError error
+	}{
+		{"", []string{"https://api.example.com"}, nil, nil},
+		{"read write", []string{"https://api.example.com"}, []string{"read", "write"}, nil},
+		{"", []string{"https://api.example.com"}, []string{"read"}, errors.ErrInvalidScope},
+		{"read", []string{"https://api.example.com"}, []string{"read"}, nil},
+		{"", nil, nil, errInvalidTarget},
+		{"", []string{"https://api.example.com", "https://other.example.com"}, nil, errInvalidTarget},
+		{"", []string{"https://api.example.com"}, []string{"admin"}, errors.ErrInvalidScope},
+		{"read", []string{"https://api.example.com"}, []string{"write"}, errors.ErrInvalidScope},
+	} {
+		if err := checkExchangeTarget(c, tc.subjectScope, tc.audiences, tc.scopes); err != tc.expectedError {
+			t.Errorf("expected error %v for %#v but got %v", tc.expectedError, tc, err)
+		}
+	}
+}
+
+func TestTokenHandler_ExchangeToken(t *testing.T) {
+	privKey, err := jwt.ParseECPrivateKeyFromPEM([]byte(privTestKey))
+	if err != nil {
+		t.Fatalf("cannot parse private test key: %v", err)
+	}
+
+	issuer := "http://example.com"
+	gateway := &mockExchangeClient{
+		mockClient{uuid.MustParse("2e532bfa50a44f1c84aa5af13fa4612d"), "/"},
+		[]string{"https://api.example.com"},
+		[]string{"read"},
+	}
+
+	token := func(clientID uuid.UUID, aud []string, scope string, act *Actor) string {
+		b, err := AccessToken{
+			Key:       privKey,
+			Issuer:    issuer,
+			ExpiresIn: time.Minute,
+			ID:        uuid.New(),
+			Subject:   "user",
+			ClientID:  clientID,
+			Audience:  aud,
+			Scope:     scope,
+			Act:       act,
+		}.MarshalText()
+		if err != nil {
+			t.Fatalf("cannot create subject token: %v", err)
+		}
+		return string(b)
+	}
+
+	subjectToken := func(scope string, act *Actor) string {
+		return token(gateway.c, nil, scope, act)
+	}
+
+	for _, tc := range []struct {
+		client         client
+		params         url.Values
+		active         bool
+		expectedStatus int
+		expectedActor  *Actor
+	}{
+		{gateway, url.Values{"subject_token": {subjectToken("read", nil)}, "audience": {"https://api.example.com"}, "scope": {"read"}}, true, http.StatusOK, &Actor{Subject: "2e532bfa-50a4-4f1c-84aa-5af13fa4612d"}},
+		{gateway, url.Values{"subject_token": {subjectToken("read", &Actor{Subject: "first"})}, "audience": {"https://api.example.com"}}, true, http.StatusOK, &Actor{Subject: "2e532bfa-50a4-4f1c-84aa-5af13fa4612d", Actor: &Actor{Subject: "first"}}},
+		{gateway, url.Values{"subject_token": {subjectToken("read", nil)}, "audience": {"https://other.example.com"}}, true, http.StatusBadRequest, nil},
+		{gateway, url.Values{"subject_token": {subjectToken("read", nil)}, "audience": {"https://api.example.com"}, "scope": {"write"}}, true, errors.StatusCodes[errors.ErrInvalidScope], nil},
+		{gateway, url.Values{"subject_token": {subjectToken("", nil)}, "audience": {"https://api.example.com"}, "scope": {"read"}}, true, errors.StatusCodes[errors.ErrInvalidScope], nil},
+		{gateway, url.Values{"subject_token": {subjectToken("read", nil)}, "audience": {"https://api.example.com"}}, false, errors.StatusCodes[errors.ErrInvalidGrant], nil},
+		{gateway, url.Values{"subject_token": {"invalid"}, "audience": {"https://api.example.com"}}, true, errors.StatusCodes[errors.ErrInvalidGrant], nil},
+		{gateway, url.Values{"subject_token": {subjectToken("read", nil)}, "audience": {"https://api.example.com"}, "subject_token_type": {"urn:ietf:params:oauth:token-type:id_token"}}, true, http.StatusBadRequest, nil},
+		{&gateway.mockClient, url.Values{"subject_token": {subjectToken("read", nil)}, "audience": {"https://api.example.com"}}, true, errors.StatusCodes[errors.ErrUnauthorizedClient], nil},
+		{gateway, url.Values{"subject_token": {token(uuid.New(), nil, "read", nil)}, "audience": {"https://api.example.com"}}, true, errors.StatusCodes[errors.ErrInvalidGrant], nil},
+		{gateway, url.Values{"subject_token": {token(uuid.New(), []string{gateway.c.String()}, "read", nil)}, "audience": {"https://api.example.com"}}, true, http.StatusOK, &Actor{Subject: "2e532bfa-50a4-4f1c-84aa-5af13fa4612d"}},
+	} {
+		tc.params.Set("grant_type", grantTypeTokenExchange)
+		if !tc.params.Has("subject_token_type") {
+			tc.params.Set("subject_token_type", tokenTypeAccessToken)
+		}
+
+		issued := 0
+		handler := &tokenHandler{
+			issuerUrl:            issuer,
+			privateKey:           privKey,
+			accessTokenExpiresIn: time.Minute,
+			clientByClientID: func(ctx context.Context, clientID string) (client, error) {
+				return tc.client, nil
+			},
+			activeIssuedToken: func(ctx context.Context, jti string) (*IssuedToken, error) {
+				if !tc.active {
+					return nil, fmt.Errorf("revoked")
+				}
+				return &IssuedToken{ID: uuid.MustParse(jti), UserID: 1, ExpiresAt: time.Now().Add(30 * time.Second)}, nil
+			},
+			issueExchangedToken: func(ctx context.Context, parent *IssuedToken, c client, expiresIn time.Duration) (uuid.UUID, error) {
+				if expiresIn > 30*time.Second {
+					t.Errorf("expected exchanged token to expire with subject token but got %v", expiresIn)
+				}
+				issued++
+				return uuid.New(), nil
+			},
+			subject: mockSubject,
+		}
+
+		req := httptest.NewRequest("POST", "/", strings.NewReader(tc.params.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.SetBasicAuth("1", "secret")
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+
+		resp := w.Result()
+		if resp.StatusCode != tc.expectedStatus {
+			t.Errorf("expected status %d but got %d", tc.expectedStatus, resp.StatusCode)
+			continue
+		}
+
+		if tc.expectedStatus != http.StatusOK {
+			if issued != 0 {
+				t.Errorf("expected no token to be issued")
+			}
+			continue
+		}
+
+		var body struct {
+			AccessToken     string `json:"access_token"`
+			IssuedTokenType string `json:"issued_token_type"`
+			ExpiresIn       int    `json:"expires_in"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+			t.Fatalf("cannot decode response: %v", err)
+		}
+
+		if body.IssuedTokenType != tokenTypeAccessToken {
+			t.Errorf("unexpected issued token type %#v", body.IssuedTokenType)
+		}
+
+		if body.ExpiresIn <= 0 || body.ExpiresIn > 30 {
+			t.Errorf("expected expires_in to be capped by subject token but got %v", body.ExpiresIn)
+		}
+
+		claims, err := parseAccessToken(body.AccessToken, &privKey.PublicKey, issuer)
+		if err != nil {
+			t.Fatalf("cannot parse issued token: %v", err)
+		}
+
+		if claims.Subject != "1" || claims.Scope != tc.params.Get("scope") {
+			t.Errorf("unexpected claims %#v", claims)
+		}
+
+		if aud, _ := claims.GetAudience(); len(aud) != 1 || aud[0] != "https://api.example.com" {
+			t.Errorf("unexpected audience %v", aud)
+		}
+
+		if got, _ := json.Marshal(claims.Act); string(got) != string(must(json.Marshal(tc.expectedActor))) {
+			t.Errorf("expected act claim %s but got %s", must(json.Marshal(tc.expectedActor)), got)
+		}
+	}
+}
+
+func must(b []byte, err error) []byte {
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
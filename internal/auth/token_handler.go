@@ -8,10 +8,13 @@ import (
 	"fmt"
 	"log"
 	"net/http"
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/go-oauth2/oauth2/v4/errors"
 	"github.com/google/uuid"
+	"github.com/seb-schulz/onegate/internal/model"
 	"golang.org/x/exp/slog"
 	"golang.org/x/oauth2"
 )
@@ -21,18 +24,29 @@ func warnf(format string, opts ...any) {
 }
 
 type AccessTokenResponds struct {
-	AccessToken string  `json:"access_token,omitempty"`
-	TokenType   string  `json:"token_type,omitempty"`
-	ExpiresIn   int     `json:"expires_in,omitempty"`
-	IDToken     IDToken `json:"id_token,omitempty"`
+	AccessToken AccessToken `json:"access_token,omitempty"`
+	TokenType   string      `json:"token_type,omitempty"`
+	ExpiresIn   int         `json:"expires_in,omitempty"`
+	Scope       string      `json:"scope,omitempty"`
+	IDToken     IDToken     `json:"id_token,omitempty"`
 }
 
 type tokenHandler struct {
-	issuerUrl           string
-	privateKey          *ecdsa.PrivateKey
-	clientByClientID    clientByClientIDFn
-	authorizationByCode func(ctx context.Context, code string) (authorization, error)
-	deleteAuthorization func(context.Context, authorization) error
+	issuerUrl             string
+	privateKey            *ecdsa.PrivateKey
+	accessTokenExpiresIn  time.Duration
+	clientByClientID      clientByClientIDFn
+	resourcesByIdentifier resourcesByIdentifierFn
+	authorizationByCode   func(ctx context.Context, code string) (authorization, error)
+	redeemAuthorization   func(context.Context, authorization) error
+	revokeAuthorization   func(context.Context, authorization) error
+	issueToken            func(context.Context, authorization, time.Duration) (uuid.UUID, error)
+	activeIssuedToken     func(ctx context.Context, jti string) (*IssuedToken, error)
+	issueExchangedToken   func(context.Context, *IssuedToken, client, time.Duration) (uuid.UUID, error)
+	subject               subjectFn
+	userByID              func(ctx context.Context, userID uint) (*model.User, error)
+	verifiedEmailByUserID func(ctx context.Context, userID uint) (string, error)
+	extraClaims           extraClaims
 	ClientSecretVerifier
 }
 
@@ -44,19 +58,18 @@ func (th *tokenHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if r.FormValue("grant_type") == grantTypeTokenExchange {
+		th.exchangeToken(w, r, client)
+		return
+	}
+
 	authReq, err := th.authorizationByCode(r.Context(), r.FormValue("code"))
 	if err != nil {
 		log.Printf("authorization not found: %v", err)
-		httpAuthError(w, errors.ErrInvalidClient)
+		httpAuthError(w, errors.ErrInvalidGrant)
 		return
 	}
 
-	// Deletion might be defered in the future so that it happens
-	// when anything else is processed
-	if err := th.deleteAuthorization(r.Context(), authReq); err != nil {
-		warnf("cannot delete authorization: %v", err)
-	}
-
 	if err := th.checkGrantType(r); err != nil {
 		httpAuthError(w, err)
 		return
@@ -68,19 +81,114 @@ func (th *tokenHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if err := th.checkRedirectURI(r, authReq); err != nil {
+		warnf("missmatch of redirect URI: %v", err)
+		httpAuthError(w, errors.ErrInvalidGrant)
+		return
+	}
+
 	if err := th.checkCodeChallenge(r, authReq); err != nil {
 		warnf("missmach with code challenge: %v", err)
 		httpAuthError(w, errors.ErrInvalidRequest)
 		return
 	}
 
+	// The code is redeemed after the request is validated so that other
+	// clients cannot revoke the authorization by replaying a leaked code
+	if err := th.redeemAuthorization(r.Context(), authReq); isCodeReplay(err) {
+		warnf("authorization code was presented twice")
+		if err := th.revokeAuthorization(r.Context(), authReq); err != nil {
+			warnf("cannot revoke authorization: %v", err)
+		}
+		httpAuthError(w, errors.ErrInvalidGrant)
+		return
+	} else if err != nil {
+		warnf("cannot redeem authorization: %v", err)
+		httpAuthError(w, errors.ErrServerError)
+		return
+	}
+
+	target, err := th.tokenTarget(r, authReq)
+	if err != nil {
+		warnf("invalid resource: %v", err)
+		httpAuthError(w, errInvalidTarget)
+		return
+	}
+
+	sub, err := th.subject(r.Context(), client, authReq.UserID())
+	if err != nil {
+		warnf("cannot derive subject: %v", err)
+		http.Error(w, "failed to provde access token", http.StatusInternalServerError)
+		return
+	}
+
+	var (
+		profile *model.User
+		email   string
+		scopes  = strings.Fields(authReq.Scope())
+	)
+	if slices.Contains(scopes, "profile") {
+		if profile, err = th.userByID(r.Context(), authReq.UserID()); err != nil {
+			warnf("cannot get profile: %v", err)
+			http.Error(w, "failed to provde access token", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	if slices.Contains(scopes, "email") {
+		if email, err = th.verifiedEmailByUserID(r.Context(), authReq.UserID()); err != nil {
+			warnf("cannot get email: %v", err)
+			http.Error(w, "failed to provde access token", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	idTokenClaims, err := th.extraClaims.claims(r.Context(), client, authReq.UserID(), authReq.Scope())
+	if err != nil {
+		warnf("cannot get claims of ID token: %v", err)
+		http.Error(w, "failed to provde access token", http.StatusInternalServerError)
+		return
+	}
+
+	accessTokenClaims, err := th.extraClaims.claims(r.Context(), client, authReq.UserID(), target.Scope)
+	if err != nil {
+		warnf("cannot get claims of access token: %v", err)
+		http.Error(w, "failed to provde access token", http.StatusInternalServerError)
+		return
+	}
+
+	tokenID, err := th.issueToken(r.Context(), authReq, target.ExpiresIn)
+	if err != nil {
+		warnf("cannot issue token: %v", err)
+		http.Error(w, "failed to provde access token", http.StatusInternalServerError)
+		return
+	}
+
 	b, err := json.Marshal(AccessTokenResponds{
-		fmt.Sprint(uuid.New()), "Bearer", 5, IDToken{
+		AccessToken: AccessToken{
+			Key:       th.privateKey,
+			Issuer:    th.issuerUrl,
+			ExpiresIn: target.ExpiresIn,
+			ID:        tokenID,
+			Subject:   sub,
+			ClientID:  authReq.ClientID(),
+			Audience:  target.Audience,
+			Scope:     target.Scope,
+			Claims:    accessTokenClaims,
+		},
+		TokenType: "Bearer",
+		ExpiresIn: int(target.ExpiresIn.Seconds()),
+		Scope:     target.Scope,
+		IDToken: IDToken{
 			th.privateKey,
 			th.issuerUrl,
 			time.Hour,
-			authReq.UserID(),
+			sub,
 			authReq.ClientID(),
+			authReq.AuthnContext(),
+			profile,
+			idTokenClaims,
+			email,
 		},
 	})
 	if err != nil {
@@ -97,6 +205,25 @@ func (th *tokenHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	w.Write(b)
 }
 
+// tokenTarget restricts the access token to the resources requested at the
+// token endpoint or otherwise at the authorization endpoint.
+func (th *tokenHandler) tokenTarget(r *http.Request, authReq authorization) (tokenTarget, error) {
+	identifiers, err := selectResources(authReq.Resources(), r.Form["resource"])
+	if err != nil {
+		return tokenTarget{}, err
+	}
+
+	var resources []Resource
+	if len(identifiers) > 0 {
+		resources, err = th.resourcesByIdentifier(r.Context(), identifiers)
+		if err != nil {
+			return tokenTarget{}, err
+		}
+	}
+
+	return newTokenTarget(resources, authReq.Scope(), th.accessTokenExpiresIn), nil
+}
+
 func (th *tokenHandler) checkCodeChallenge(r *http.Request, auth authorizationCodeChallenger) error {
 	cv := r.FormValue("code_verifier")
 	if cv == "" {
@@ -109,6 +236,20 @@ func (th *tokenHandler) checkCodeChallenge(r *http.Request, auth authorizationCo
 	return nil
 }
 
+// checkRedirectURI requires the redirect URI if it was included in the
+// authorization request (RFC 6749, section 4.1.3).
+func (th *tokenHandler) checkRedirectURI(r *http.Request, authReq authorization) error {
+	uri := r.FormValue("redirect_uri")
+	if uri == "" && !authReq.redirectURIRequested() {
+		return nil
+	}
+
+	if uri != authReq.RedirectURI() {
+		return fmt.Errorf("expected %#v but got %#v", authReq.RedirectURI(), uri)
+	}
+	return nil
+}
+
 func (th *tokenHandler) checkGrantType(r *http.Request) error {
 	if r.FormValue("grant_type") != "authorization_code" {
 		return errors.ErrInvalidGrant
@@ -119,8 +260,10 @@ func (th *tokenHandler) checkGrantType(r *http.Request) error {
 func (th *tokenHandler) getAndVerifyClient(r *http.Request) (client, error) {
 	var clientID, secret string
 
+	method := AuthMethodClientSecretBasic
 	clientID, secret, ok := r.BasicAuth()
 	if !ok {
+		method = AuthMethodClientSecretPost
 		clientID = r.FormValue("client_id")
 		secret = r.FormValue("client_secret")
 	}
@@ -130,6 +273,10 @@ func (th *tokenHandler) getAndVerifyClient(r *http.Request) (client, error) {
 		return nil, fmt.Errorf("cannot fetch client: %v", err)
 	}
 
+	if m := client.TokenEndpointAuthMethod(); m != "" && m != method {
+		return nil, fmt.Errorf("client must authenticate with %v", m)
+	}
+
 	if err := client.VerifyClientSecret(secret); err != nil {
 		return nil, err
 	}
@@ -9,6 +9,7 @@ import (
 	"net/http/httptest"
 	"net/url"
 	"testing"
+	"time"
 
 	"github.com/go-oauth2/oauth2/v4/errors"
 	"github.com/golang-jwt/jwt/v5"
@@ -136,6 +137,30 @@ func TestTokenHandler_CheckGrantType(t *testing.T) {
 	}
 }
 
+func TestTokenHandler_checkRedirectURI(t *testing.T) {
+	client := Client{InternalRedirectURI: "https://example.com/cb"}
+	th := tokenHandler{}
+
+	for _, tc := range []struct {
+		recorded    string
+		requested   string
+		expectError bool
+	}{
+		{"", "", false},
+		{"", "https://example.com/cb", false},
+		{"", "https://example.com/other", true},
+		{"https://example.com/cb", "https://example.com/cb", false},
+		{"https://example.com/cb", "", true},
+		{"https://example.com/cb", "https://example.com/other", true},
+	} {
+		req := httptest.NewRequest("GET", "/?"+url.Values{"redirect_uri": {tc.requested}}.Encode(), nil)
+		err := th.checkRedirectURI(req, &Authorization{InternalRedirectURI: tc.recorded, Client: client})
+		if got := err != nil; got != tc.expectError {
+			t.Errorf("expected error %v for recorded %#v and requested %#v but got %v", tc.expectError, tc.recorded, tc.requested, err)
+		}
+	}
+}
+
 type mockAuthorizationCodeChallenger struct {
 	cc string
 }
@@ -221,57 +246,138 @@ func TestTokenHandler_ServeHTTP(t *testing.T) {
 
 	verifier := oauth2.GenerateVerifier()
 
-	req := httptest.NewRequest("GET", fmt.Sprintf("/?grant_type=authorization_code&code_verifier=%s", verifier), nil)
-	req.SetBasicAuth("1", "secret")
-	w := httptest.NewRecorder()
+	otherClientID := uuid.MustParse("7f1d3b0e8f3a4f0c9c3c2f6f4bb1f0a1")
+
+	for _, tc := range []struct {
+		authClientID   uuid.UUID
+		redeemErr      error
+		expectedStatus int
+		expectedRedeem int
+		expectedRevoke int
+		expectedIssue  int
+	}{
+		{mockClient.c, nil, http.StatusOK, 1, 0, 1},
+		{mockClient.c, errCodeRedeemed, errors.StatusCodes[errors.ErrInvalidGrant], 1, 1, 0},
+		{mockClient.c, fmt.Errorf("database down"), http.StatusInternalServerError, 1, 0, 0},
+		{otherClientID, errCodeRedeemed, errors.StatusCodes[errors.ErrInvalidClient], 0, 0, 0},
+	} {
+		req := httptest.NewRequest("GET", fmt.Sprintf("/?grant_type=authorization_code&code_verifier=%s", verifier), nil)
+		req.SetBasicAuth("1", "secret")
+		w := httptest.NewRecorder()
+
+		clientFetcherCalled := 0
+		authorizationByCodeCalled := 0
+		redeemAuthorizationCalled := 0
+		revokeAuthorizationCalled := 0
+		issueTokenCalled := 0
+
+		handler := &tokenHandler{
+			issuerUrl:            "http://example.com",
+			privateKey:           privKey,
+			accessTokenExpiresIn: time.Minute,
+			clientByClientID: func(ctx context.Context, clientID string) (client, error) {
+				clientFetcherCalled++
+				return &mockClient, nil
+			},
+			authorizationByCode: func(ctx context.Context, code string) (authorization, error) {
+				authorizationByCodeCalled++
+				return &mockAuthorization{
+					Authorization{
+						InternalCodeChallenge: oauth2.S256ChallengeFromVerifier(verifier),
+						InternalClientID:      tc.authClientID,
+					},
+					mockClient,
+					&mockedUserID,
+				}, nil
+			},
+			redeemAuthorization: func(ctx context.Context, a authorization) error {
+				redeemAuthorizationCalled++
+				return tc.redeemErr
+			},
+			revokeAuthorization: func(ctx context.Context, a authorization) error {
+				revokeAuthorizationCalled++
+				return nil
+			},
+			issueToken: func(ctx context.Context, a authorization, expiresIn time.Duration) (uuid.UUID, error) {
+				issueTokenCalled++
+				return uuid.New(), nil
+			},
+			subject: mockSubject,
+		}
+		handler.ServeHTTP(w, req)
+
+		resp := w.Result()
+
+		if clientFetcherCalled != 1 {
+			t.Errorf("called client fetcher %d times", clientFetcherCalled)
+		}
+
+		if authorizationByCodeCalled != 1 {
+			t.Errorf("called authorization fetcher %d times", authorizationByCodeCalled)
+		}
+
+		if redeemAuthorizationCalled != tc.expectedRedeem {
+			t.Errorf("called authorization redemption %d times instead of %d", redeemAuthorizationCalled, tc.expectedRedeem)
+		}
+
+		if revokeAuthorizationCalled != tc.expectedRevoke {
+			t.Errorf("called authorization revocation %d times instead of %d", revokeAuthorizationCalled, tc.expectedRevoke)
+		}
+
+		if issueTokenCalled != tc.expectedIssue {
+			t.Errorf("issued %d tokens instead of %d", issueTokenCalled, tc.expectedIssue)
+		}
+
+		if got, expected := resp.StatusCode, tc.expectedStatus; got != expected {
+			t.Errorf("expected status code %d but got %d", expected, got)
+		}
 
-	clientFetcherCalled := 0
-	authorizationByCodeCalled := 0
-	deleteAuthorizationCalled := 0
+		body, _ := io.ReadAll(resp.Body)
+		t.Logf("Result is \"%s\"", body)
+	}
+}
 
+func TestTokenHandler_tokenTarget(t *testing.T) {
 	handler := &tokenHandler{
-		issuerUrl:  "http://example.com",
-		privateKey: privKey,
-		clientByClientID: func(ctx context.Context, clientID string) (client, error) {
-			clientFetcherCalled++
-			return &mockClient, nil
-		},
-		authorizationByCode: func(ctx context.Context, code string) (authorization, error) {
-			authorizationByCodeCalled++
-			return &mockAuthorization{
-				Authorization{
-					InternalCodeChallenge: oauth2.S256ChallengeFromVerifier(verifier),
-					InternalClientID:      mockClient.c,
-				},
-				mockClient,
-				&mockedUserID,
-			}, nil
-		},
-		deleteAuthorization: func(ctx context.Context, a authorization) error {
-			deleteAuthorizationCalled++
-			return nil
+		accessTokenExpiresIn: time.Minute,
+		resourcesByIdentifier: func(ctx context.Context, identifiers []string) ([]Resource, error) {
+			resources := []Resource{}
+			for _, id := range identifiers {
+				resources = append(resources, Resource{Identifier: id, Scopes: []string{"read"}, AccessTokenExpiresIn: time.Hour})
+			}
+			return resources, nil
 		},
 	}
-	handler.ServeHTTP(w, req)
 
-	resp := w.Result()
+	authReq := &mockAuthorization{Authorization: Authorization{
+		InternalResources: []string{"https://a.example.com", "https://b.example.com"},
+		InternalScope:     "openid read",
+	}}
 
-	if clientFetcherCalled != 1 {
-		t.Errorf("called client fetcher %d times", clientFetcherCalled)
-	}
+	for _, tc := range []struct {
+		query    string
+		expected []string
+		fails    bool
+	}{
+		{"", []string{"https://a.example.com", "https://b.example.com"}, false},
+		{"?resource=https://b.example.com", []string{"https://b.example.com"}, false},
+		{"?resource=https://c.example.com", nil, true},
+	} {
+		req := httptest.NewRequest("POST", "/"+tc.query, nil)
+		req.ParseForm()
 
-	if authorizationByCodeCalled != 1 {
-		t.Errorf("called authorization fetcher %d times", authorizationByCodeCalled)
-	}
+		target, err := handler.tokenTarget(req, authReq)
+		if (err != nil) != tc.fails {
+			t.Errorf("expected failure=%v but got: %v", tc.fails, err)
+			continue
+		}
 
-	if deleteAuthorizationCalled != 1 {
-		t.Errorf("called authorization deletion %d times", deleteAuthorizationCalled)
-	}
+		if tc.fails {
+			continue
+		}
 
-	if got, expected := resp.StatusCode, http.StatusOK; got != expected {
-		t.Errorf("expected status code %d but got %d", expected, got)
+		if fmt.Sprint(target.Audience) != fmt.Sprint(tc.expected) || target.Scope != "openid read" || target.ExpiresIn != time.Hour {
+			t.Errorf("unexpected target %#v", target)
+		}
 	}
-
-	body, _ := io.ReadAll(resp.Body)
-	t.Logf("Result is \"%s\"", body)
 }
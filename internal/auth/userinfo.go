@@ -0,0 +1,129 @@
+package auth
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/seb-schulz/onegate/internal/model"
+)
+
+// userinfoHandler returns claims about the user authenticated by an access
+// token (see OpenID Connect Core 1.0, section 5.3).
+type userinfoHandler struct {
+	issuerUrl         string
+	publicKey         *ecdsa.PublicKey
+	activeIssuedToken func(ctx context.Context, jti string) (*IssuedToken, error)
+	clientByClientID  clientByClientIDFn
+	userByID          func(ctx context.Context, userID uint) (*model.User, error)
+	// verifiedEmailByUserID returns the verified primary email address
+	verifiedEmailByUserID func(ctx context.Context, userID uint) (string, error)
+	extraClaims           extraClaims
+}
+
+// userinfo returns the claims about the user which the client receives for
+// the scope.
+func (uh *userinfoHandler) userinfo(ctx context.Context, c client, userID uint, sub, scope string) (map[string]any, error) {
+	info, err := uh.extraClaims.claims(ctx, c, userID, scope)
+	if err != nil {
+		return nil, err
+	}
+	info["sub"] = sub
+
+	scopes := strings.Fields(scope)
+	if slices.Contains(scopes, "profile") {
+		user, err := uh.userByID(ctx, userID)
+		if err != nil {
+			return nil, fmt.Errorf("cannot get profile: %v", err)
+		}
+
+		info["preferred_username"] = user.Name
+		for claim, value := range map[string]string{
+			"name":     user.DisplayName,
+			"picture":  user.Picture,
+			"locale":   user.Locale,
+			"zoneinfo": user.Zoneinfo,
+		} {
+			if value != "" {
+				info[claim] = value
+			}
+		}
+	}
+
+	if slices.Contains(scopes, "email") {
+		email, err := uh.verifiedEmailByUserID(ctx, userID)
+		if err != nil {
+			return nil, fmt.Errorf("cannot get email: %v", err)
+		}
+
+		if email != "" {
+			info["email"] = email
+			info["email_verified"] = true
+		}
+	}
+	return info, nil
+}
+
+func (uh *userinfoHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	claims, err := parseAccessToken(bearerToken(r), uh.publicKey, uh.issuerUrl)
+	if err != nil {
+		warnf("invalid access token for userinfo: %v", err)
+		unauthorized(w)
+		return
+	}
+
+	token, err := uh.activeIssuedToken(r.Context(), claims.ID)
+	if err != nil {
+		warnf("inactive access token for userinfo: %v", err)
+		unauthorized(w)
+		return
+	}
+
+	if !slices.Contains(strings.Fields(claims.Scope), "openid") {
+		w.Header().Set("WWW-Authenticate", `Bearer error="insufficient_scope", scope="openid"`)
+		http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+		return
+	}
+
+	c, err := uh.clientByClientID(r.Context(), fmt.Sprint(token.ClientID))
+	if err != nil {
+		warnf("cannot get client of userinfo: %v", err)
+		unauthorized(w)
+		return
+	}
+
+	info, err := uh.userinfo(r.Context(), c, token.UserID, claims.Subject, claims.Scope)
+	if err != nil {
+		warnf("cannot get claims of userinfo: %v", err)
+		http.Error(w, "cannot get userinfo", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, info)
+}
+
+// PreviewClaims returns the userinfo claims a user would receive for a
+// client including the claims mapped by the client.
+func PreviewClaims(ctx context.Context, config *Config, c *Client, userID uint, scope string) (map[string]any, error) {
+	sub, err := subjectByUserID(config.SubjectSalt)(ctx, c, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	uh := userinfoHandler{
+		userByID:              userByID,
+		verifiedEmailByUserID: verifiedEmailByUserID,
+		extraClaims: extraClaims{
+			groupsClaim:           config.GroupsClaim,
+			rolesClaim:            config.RolesClaim,
+			userByID:              userByID,
+			verifiedEmailByUserID: verifiedEmailByUserID,
+			groupsByUserID:        groupsByUserID,
+			rolesByUserID:         rolesByUserID,
+		},
+	}
+	return uh.userinfo(ctx, c, userID, sub, scope)
+}
@@ -0,0 +1,238 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/seb-schulz/onegate/internal/model"
+	"golang.org/x/oauth2"
+)
+
+func TestUserinfoHandler(t *testing.T) {
+	privKey, err := jwt.ParseECPrivateKeyFromPEM([]byte(privTestKey))
+	if err != nil {
+		t.Fatalf("cannot parse private test key: %v", err)
+	}
+	issuer := "https://example.com"
+	revokedID := uuid.New()
+
+	uh := &userinfoHandler{
+		issuerUrl: issuer,
+		publicKey: &privKey.PublicKey,
+		activeIssuedToken: func(ctx context.Context, jti string) (*IssuedToken, error) {
+			if jti == revokedID.String() {
+				return nil, fmt.Errorf("token revoked")
+			}
+			return &IssuedToken{UserID: 1}, nil
+		},
+		userByID: func(ctx context.Context, userID uint) (*model.User, error) {
+			return &model.User{Name: "jdoe", DisplayName: "John Doe", Profile: model.Profile{Locale: "en-US"}}, nil
+		},
+		verifiedEmailByUserID: func(ctx context.Context, userID uint) (string, error) {
+			return "jdoe@example.com", nil
+		},
+		clientByClientID: func(ctx context.Context, clientID string) (client, error) {
+			return &mockMappingClient{mappings: ClaimMappings{"username": "{{.User.Name}}"}}, nil
+		},
+		extraClaims: extraClaims{
+			groupsClaim: "groups",
+			userByID: func(ctx context.Context, userID uint) (*model.User, error) {
+				return &model.User{Name: "jdoe", DisplayName: "John Doe"}, nil
+			},
+			verifiedEmailByUserID: func(ctx context.Context, userID uint) (string, error) {
+				return "jdoe@example.com", nil
+			},
+			rolesByUserID: func(ctx context.Context, userID uint) ([]string, error) {
+				return nil, nil
+			},
+			groupsByUserID: func(ctx context.Context, userID uint) ([]string, error) {
+				return []string{"staff"}, nil
+			},
+		},
+	}
+
+	accessToken := func(id uuid.UUID, scope string) string {
+		b, err := AccessToken{
+			Key:       privKey,
+			Issuer:    issuer,
+			ExpiresIn: time.Minute,
+			ID:        id,
+			Subject:   "user",
+			ClientID:  uuid.New(),
+			Scope:     scope,
+		}.MarshalText()
+		if err != nil {
+			t.Fatalf("cannot create access token: %v", err)
+		}
+		return string(b)
+	}
+
+	for _, tc := range []struct {
+		name     string
+		token    string
+		status   int
+		expected map[string]any
+	}{
+		{"missing token", "", http.StatusUnauthorized, nil},
+		{"revoked token", accessToken(revokedID, "openid"), http.StatusUnauthorized, nil},
+		{"missing openid scope", accessToken(uuid.New(), "profile"), http.StatusForbidden, nil},
+		{"openid", accessToken(uuid.New(), "openid"), http.StatusOK, map[string]any{"sub": "user", "username": "jdoe"}},
+		{"profile and groups", accessToken(uuid.New(), "openid profile groups"), http.StatusOK, map[string]any{
+			"sub":                "user",
+			"username":           "jdoe",
+			"name":               "John Doe",
+			"preferred_username": "jdoe",
+			"locale":             "en-US",
+			"groups":             []any{"staff"},
+		}},
+		{"email", accessToken(uuid.New(), "openid email"), http.StatusOK, map[string]any{
+			"sub":            "user",
+			"username":       "jdoe",
+			"email":          "jdoe@example.com",
+			"email_verified": true,
+		}},
+	} {
+		r := httptest.NewRequest(http.MethodGet, "/userinfo", nil)
+		if tc.token != "" {
+			r.Header.Set("Authorization", "Bearer "+tc.token)
+		}
+
+		rr := httptest.NewRecorder()
+		uh.ServeHTTP(rr, r)
+		if rr.Code != tc.status {
+			t.Errorf("%v: expected status %v but got %v", tc.name, tc.status, rr.Code)
+			continue
+		}
+
+		if tc.expected == nil {
+			if rr.Header().Get("WWW-Authenticate") == "" {
+				t.Errorf("%v: expected WWW-Authenticate header", tc.name)
+			}
+			continue
+		}
+
+		info := map[string]any{}
+		if err := json.Unmarshal(rr.Body.Bytes(), &info); err != nil {
+			t.Fatalf("%v: cannot decode userinfo: %v", tc.name, err)
+		}
+
+		if !reflect.DeepEqual(info, tc.expected) {
+			t.Errorf("%v: expected %v but got %v", tc.name, tc.expected, info)
+		}
+	}
+}
+
+func TestUserinfoHandler_tokenFromTokenHandler(t *testing.T) {
+	privKey, err := jwt.ParseECPrivateKeyFromPEM([]byte(privTestKey))
+	if err != nil {
+		t.Fatalf("cannot parse private test key: %v", err)
+	}
+	issuer := "https://example.com"
+	c := mockClient{uuid.MustParse("2e532bfa50a44f1c84aa5af13fa4612d"), "/"}
+	userID := uint(1)
+	userByID := func(ctx context.Context, userID uint) (*model.User, error) {
+		return &model.User{Name: "jdoe", DisplayName: "John Doe"}, nil
+	}
+
+	for _, tc := range []struct {
+		name      string
+		scope     string
+		resources []string
+		expected  map[string]any
+	}{
+		{"openid", "openid", nil, map[string]any{"sub": "1"}},
+		{"profile", "openid profile", nil, map[string]any{"sub": "1", "name": "John Doe", "preferred_username": "jdoe"}},
+		{"resource", "openid profile read", []string{"https://api.example.com"}, map[string]any{"sub": "1", "name": "John Doe", "preferred_username": "jdoe"}},
+	} {
+		verifier := oauth2.GenerateVerifier()
+		issued := map[string]*IssuedToken{}
+
+		th := &tokenHandler{
+			issuerUrl:            issuer,
+			privateKey:           privKey,
+			accessTokenExpiresIn: time.Minute,
+			clientByClientID: func(ctx context.Context, clientID string) (client, error) {
+				return &c, nil
+			},
+			resourcesByIdentifier: func(ctx context.Context, identifiers []string) ([]Resource, error) {
+				return []Resource{{Identifier: "https://api.example.com", Scopes: []string{"read"}, AccessTokenExpiresIn: time.Minute}}, nil
+			},
+			authorizationByCode: func(ctx context.Context, code string) (authorization, error) {
+				return &mockAuthorization{
+					Authorization{
+						InternalCodeChallenge: oauth2.S256ChallengeFromVerifier(verifier),
+						InternalClientID:      c.c,
+						InternalScope:         tc.scope,
+						InternalResources:     tc.resources,
+					},
+					c,
+					&userID,
+				}, nil
+			},
+			redeemAuthorization: func(ctx context.Context, a authorization) error { return nil },
+			issueToken: func(ctx context.Context, a authorization, expiresIn time.Duration) (uuid.UUID, error) {
+				id := uuid.New()
+				issued[id.String()] = &IssuedToken{UserID: a.UserID(), ClientID: a.ClientID()}
+				return id, nil
+			},
+			subject:  mockSubject,
+			userByID: userByID,
+		}
+
+		req := httptest.NewRequest(http.MethodPost, fmt.Sprintf("/?grant_type=authorization_code&code_verifier=%s", verifier), nil)
+		req.SetBasicAuth("1", "secret")
+		rr := httptest.NewRecorder()
+		th.ServeHTTP(rr, req)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("%v: cannot get token: %v", tc.name, rr.Body.String())
+		}
+
+		resp := struct {
+			AccessToken string `json:"access_token"`
+		}{}
+		if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("%v: cannot decode token response: %v", tc.name, err)
+		}
+
+		uh := &userinfoHandler{
+			issuerUrl: issuer,
+			publicKey: &privKey.PublicKey,
+			activeIssuedToken: func(ctx context.Context, jti string) (*IssuedToken, error) {
+				if token, ok := issued[jti]; ok {
+					return token, nil
+				}
+				return nil, fmt.Errorf("token not issued")
+			},
+			clientByClientID: func(ctx context.Context, clientID string) (client, error) {
+				return &c, nil
+			},
+			userByID: userByID,
+		}
+
+		r := httptest.NewRequest(http.MethodGet, "/userinfo", nil)
+		r.Header.Set("Authorization", "Bearer "+resp.AccessToken)
+		rr = httptest.NewRecorder()
+		uh.ServeHTTP(rr, r)
+		if rr.Code != http.StatusOK {
+			t.Errorf("%v: expected status %v but got %v", tc.name, http.StatusOK, rr.Code)
+			continue
+		}
+
+		info := map[string]any{}
+		if err := json.Unmarshal(rr.Body.Bytes(), &info); err != nil {
+			t.Fatalf("%v: cannot decode userinfo: %v", tc.name, err)
+		}
+
+		if !reflect.DeepEqual(info, tc.expected) {
+			t.Errorf("%v: expected %v but got %v", tc.name, tc.expected, info)
+		}
+	}
+}
@@ -3,7 +3,9 @@ package config
 import (
 	"bytes"
 	"crypto/ecdsa"
+	"crypto/x509"
 	"encoding/base64"
+	"encoding/pem"
 	"fmt"
 	"log"
 	"log/slog"
@@ -19,6 +21,11 @@ import (
 )
 
 type (
+	// verbatimString is a string value which is never base64-decoded
+	// because valid values like "username" or "smtp" are valid base64 as
+	// well
+	verbatimString string
+
 	db struct {
 		Dsn   string
 		Debug bool
@@ -31,12 +38,70 @@ type (
 	}
 
 	session struct {
-		Key       string
-		ActiveFor time.Duration
+		Key          string
+		ActiveFor    time.Duration
+		CookieDomain string
+		// ReauthenticateWithin is the time after a login within which
+		// sensitive account changes are allowed without re-authentication.
+		// Re-authentication is disabled when zero.
+		ReauthenticateWithin time.Duration
+	}
+
+	forwardAuthRule struct {
+		Host    verbatimString
+		UserIDs []uint
+	}
+
+	attributeDefinition struct {
+		Name        verbatimString
+		Description string
+		Pattern     string
+		MaxLength   int
+	}
+
+	upstream struct {
+		Host verbatimString
+		URL  url.URL
+	}
+
+	proxy struct {
+		HttpPort        string
+		ClientID        string
+		ClientSecret    verbatimString
+		RedirectUrl     url.URL
+		CookieName      string
+		CookieKey       []byte
+		CookieDomain    string
+		SessionLifetime time.Duration
+		Upstreams       []upstream
 	}
 
 	serverKind int
 
+	auth struct {
+		SubjectSalt          []byte
+		CodeExpiresIn        time.Duration
+		RequestExpiresIn     time.Duration
+		AccessTokenExpiresIn time.Duration
+		InitialAccessToken   verbatimString
+		SAMLCertificate      *x509.Certificate
+		GroupsClaim          verbatimString
+		RolesClaim           verbatimString
+	}
+
+	mail struct {
+		// Transport is one of smtp, file or log. Emails are disabled when empty.
+		Transport verbatimString
+		From      string
+		Dir       string
+		SMTP      struct {
+			Host     string
+			Port     int
+			Username string
+			Password verbatimString
+		}
+	}
+
 	logger struct {
 		Level slog.Level
 		File  string
@@ -47,13 +112,23 @@ type (
 			Name    string
 			ID      string
 			Origins []string
+			// LoginMode is discoverable, username or both. Username-first
+			// logins support security keys without resident credentials.
+			LoginMode verbatimString
+			// ConditionalLoginExpiresIn is the lifetime of login ceremonies
+			// waiting for passkey autofill on the login page
+			ConditionalLoginExpiresIn time.Duration
 		}
 		DB             db
 		Session        session
 		UrlLogin       urlLogin
 		BaseUrl        url.URL
 		PrivateAuthKey *ecdsa.PrivateKey
-		Server         struct {
+		Auth           auth
+		ForwardAuth    struct {
+			Rules []forwardAuthRule
+		}
+		Server struct {
 			Kind     serverKind
 			HttpPort string
 			Limit    struct {
@@ -61,8 +136,30 @@ type (
 				WindowLength time.Duration
 			}
 		}
+		Profile struct {
+			// Attributes define custom attributes of users
+			Attributes []attributeDefinition
+		}
 		Features struct {
 			UserRegistration bool
+			// RegistrationApproval keeps self-registered users pending until
+			// an admin approves them
+			RegistrationApproval bool
+			// ApprovalWebhook receives a POST request for each pending user
+			ApprovalWebhook string
+			// AdminGroup names the group of users managing groups and roles
+			AdminGroup verbatimString
+		}
+		// ProofOfWork sets the number of leading zero bits a solution of a
+		// challenge must have. Challenges are disabled when zero.
+		ProofOfWork struct {
+			Difficulty int
+			ExpiresIn  time.Duration
+		}
+		Mail  mail
+		Proxy proxy
+		SCIM  struct {
+			BearerToken []byte
 		}
 		Logger logger
 	}
@@ -82,12 +179,16 @@ relyingParty:
   name: ""
   id: ""
   origins: []
+  loginMode: discoverable
+  conditionalLoginExpiresIn: 30m
 db:
   dsn: ""
   debug: false
 session:
   key: ""
   activeFor: 2h
+  cookieDomain: ""
+  reauthenticateWithin: 5m
 urlLogin:
   key: ""
   expiresIn: 30s
@@ -99,12 +200,52 @@ server:
   limit:
     requestLimit: 50
     windowLength: "1m"
+profile:
+  attributes: []
 features:
   userRegistration: true
+  registrationApproval: false
+  approvalWebhook: ""
+  adminGroup: ""
+proofOfWork:
+  difficulty: 16
+  expiresIn: 5m
+mail:
+  transport: ""
+  from: ""
+  dir: ""
+  smtp:
+    host: ""
+    port: 587
+    username: ""
+    password: ""
 logger:
   level: "info"
   file: ""
 privateAuthKey: ""
+auth:
+  subjectSalt: ""
+  codeExpiresIn: 1m
+  requestExpiresIn: 30m
+  accessTokenExpiresIn: 5m
+  initialAccessToken: ""
+  samlCertificate: ""
+  groupsClaim: groups
+  rolesClaim: roles
+forwardAuth:
+  rules: []
+scim:
+  bearerToken: ""
+proxy:
+  httpPort: "9001"
+  clientID: ""
+  clientSecret: ""
+  redirectUrl: ""
+  cookieName: "_onegate_proxy"
+  cookieKey: ""
+  cookieDomain: ""
+  sessionLifetime: 12h
+  upstreams: []
 `)
 )
 
@@ -121,16 +262,7 @@ func init() {
 
 	viper.ReadInConfig()
 
-	if err := viper.Unmarshal(&Config, viper.DecodeHook(mapstructure.ComposeDecodeHookFunc(
-		stringToKindHookFunc(),
-		stringToLogLevelHookFunc(),
-		base64StringToBytesHookFunc(),
-		stringToURLHookFunc(),
-		stringToEcdsaPrivateKeyHookFunc(),
-		mapstructure.StringToTimeDurationHookFunc(),
-		mapstructure.StringToSliceHookFunc(","),
-		base64StringToStringHookFunc(),
-	))); err != nil {
+	if err := unmarshal(viper.GetViper(), &Config); err != nil {
 		log.Fatalln(err)
 	}
 
@@ -152,6 +284,20 @@ func init() {
 	}
 }
 
+func unmarshal(v *viper.Viper, c *config) error {
+	return v.Unmarshal(c, viper.DecodeHook(mapstructure.ComposeDecodeHookFunc(
+		stringToKindHookFunc(),
+		stringToLogLevelHookFunc(),
+		base64StringToBytesHookFunc(),
+		stringToURLHookFunc(),
+		stringToEcdsaPrivateKeyHookFunc(),
+		stringToCertificateHookFunc(),
+		mapstructure.StringToTimeDurationHookFunc(),
+		mapstructure.StringToSliceHookFunc(","),
+		base64StringToStringHookFunc(),
+	)))
+}
+
 func base64StringToStringHookFunc() mapstructure.DecodeHookFunc {
 	return func(
 		f reflect.Type,
@@ -160,7 +306,7 @@ func base64StringToStringHookFunc() mapstructure.DecodeHookFunc {
 		if f.Kind() != reflect.String {
 			return data, nil
 		}
-		if t.Kind() != reflect.String {
+		if t.Kind() != reflect.String || t == reflect.TypeOf(verbatimString("")) {
 			return data, nil
 		}
 
@@ -228,6 +374,34 @@ func stringToEcdsaPrivateKeyHookFunc() mapstructure.DecodeHookFunc {
 	}
 }
 
+func stringToCertificateHookFunc() mapstructure.DecodeHookFunc {
+	return func(f reflect.Type, t reflect.Type, data interface{}) (interface{}, error) {
+		if f.Kind() != reflect.String {
+			return data, nil
+		}
+		if t != reflect.TypeOf(&x509.Certificate{}) {
+			return data, nil
+		}
+		if data.(string) == "" {
+			return (*x509.Certificate)(nil), nil
+		}
+
+		block, _ := pem.Decode([]byte(data.(string)))
+		if block == nil {
+			if StrictHooks {
+				return nil, fmt.Errorf("cannot decode certificate")
+			}
+			return (*x509.Certificate)(nil), nil
+		}
+
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if StrictHooks && err != nil {
+			return nil, fmt.Errorf("cannot parse certificate: %w", err)
+		}
+		return cert, nil
+	}
+}
+
 func stringToKindHookFunc() mapstructure.DecodeHookFunc {
 	return func(
 		f reflect.Type,
@@ -0,0 +1,98 @@
+package config_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/seb-schulz/onegate/graph"
+	"github.com/seb-schulz/onegate/internal/config"
+)
+
+func TestLoginMode(t *testing.T) {
+	for _, tc := range []struct {
+		value    any
+		expected graph.LoginMode
+	}{
+		{nil, graph.LoginModeDiscoverable},
+		{"discoverable", graph.LoginModeDiscoverable},
+		{"username", graph.LoginModeUsername},
+		{"both", graph.LoginModeBoth},
+	} {
+		overrides := map[string]any{}
+		if tc.value != nil {
+			overrides["relyingParty.loginMode"] = tc.value
+		}
+
+		c, err := config.LoadDefault(overrides)
+		if err != nil {
+			t.Fatalf("cannot load config: %v", err)
+		}
+
+		mode, err := graph.ParseLoginMode(string(c.RelyingParty.LoginMode))
+		if err != nil || mode != tc.expected {
+			t.Errorf("expected %v for %v but got %#v: %v", tc.expected, tc.value, mode, err)
+		}
+	}
+}
+
+func TestMailTransport(t *testing.T) {
+	for _, transport := range []string{"", "smtp", "file", "log"} {
+		c, err := config.LoadDefault(map[string]any{"mail.transport": transport})
+		if err != nil {
+			t.Fatalf("cannot load config: %v", err)
+		}
+
+		if string(c.Mail.Transport) != transport {
+			t.Errorf("expected transport %#v but got %#v", transport, c.Mail.Transport)
+		}
+	}
+}
+
+func TestProxyClientSecret(t *testing.T) {
+	// Secrets printed by "client create" are valid base64
+	for _, secret := range []string{"c2VjcmV0c2VjcmV0c2VjcmV0c2VjcmV0c2VjcmV0MTI=", "Secr3tPassw0rd12", "not base64!"} {
+		c, err := config.LoadDefault(map[string]any{"proxy.clientSecret": secret})
+		if err != nil {
+			t.Fatalf("cannot load config: %v", err)
+		}
+
+		if string(c.Proxy.ClientSecret) != secret {
+			t.Errorf("expected client secret %#v but got %#v", secret, c.Proxy.ClientSecret)
+		}
+	}
+}
+
+func TestVerbatimStrings(t *testing.T) {
+	// Values of a length divisible by four are often valid base64
+	c, err := config.LoadDefault(map[string]any{
+		"features.adminGroup":     "devs",
+		"auth.groupsClaim":        "grps",
+		"auth.rolesClaim":         "team",
+		"mail.smtp.password":      "Secr3tPassw0rd12",
+		"auth.initialAccessToken": "Secr3tPassw0rd12",
+		"forwardAuth.rules":       []map[string]any{{"host": "dash", "userIds": []uint{1}}},
+		"profile.attributes":      []map[string]any{{"name": "team"}},
+		"proxy.upstreams":         []map[string]any{{"host": "wiki"}},
+	})
+	if err != nil {
+		t.Fatalf("cannot load config: %v", err)
+	}
+
+	for _, tc := range []struct {
+		got      any
+		expected string
+	}{
+		{c.Features.AdminGroup, "devs"},
+		{c.Auth.GroupsClaim, "grps"},
+		{c.Auth.RolesClaim, "team"},
+		{c.Mail.SMTP.Password, "Secr3tPassw0rd12"},
+		{c.Auth.InitialAccessToken, "Secr3tPassw0rd12"},
+		{c.ForwardAuth.Rules[0].Host, "dash"},
+		{c.Profile.Attributes[0].Name, "team"},
+		{c.Proxy.Upstreams[0].Host, "wiki"},
+	} {
+		if fmt.Sprint(tc.got) != tc.expected {
+			t.Errorf("expected %#v but got %#v", tc.expected, tc.got)
+		}
+	}
+}
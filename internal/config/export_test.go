@@ -0,0 +1,24 @@
+package config
+
+import (
+	"bytes"
+
+	"github.com/spf13/viper"
+)
+
+// LoadDefault decodes the default configuration with the given overrides
+// like the configuration of the application.
+func LoadDefault(overrides map[string]any) (config, error) {
+	v := viper.New()
+	v.SetConfigType("yaml")
+	if err := v.ReadConfig(bytes.NewBuffer(defaultYaml)); err != nil {
+		return config{}, err
+	}
+	for key, value := range overrides {
+		v.Set(key, value)
+	}
+
+	c := config{}
+	err := unmarshal(v, &c)
+	return c, err
+}
@@ -2,6 +2,7 @@ package database
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net/http"
 
@@ -10,6 +11,9 @@ import (
 	"gorm.io/gorm"
 )
 
+// ErrAlreadyUsed is returned by CreateOnce if the record exists already.
+var ErrAlreadyUsed = errors.New("already used")
+
 type contextDatabaseKeyType struct{ string }
 
 type optFuncs func(*gorm.DB) *gorm.DB
@@ -17,7 +21,7 @@ type optFuncs func(*gorm.DB) *gorm.DB
 var ctxDatabaseKey = contextDatabaseKeyType{"DB"}
 
 func Open(opts ...optFuncs) (*gorm.DB, error) {
-	db, err := gorm.Open(mysql.Open(config.Config.DB.Dsn), &gorm.Config{})
+	db, err := gorm.Open(mysql.Open(config.Config.DB.Dsn), &gorm.Config{TranslateError: true})
 
 	if err != nil {
 		return db, fmt.Errorf("failed to connect to database: %v", err)
@@ -86,3 +90,14 @@ func Transaction[T any](ctx context.Context, fn func(*gorm.DB) (T, error), opts
 	})
 	return result, err
 }
+
+// CreateOnce inserts a record whose primary key marks a single-use value like
+// a token ID as used. The primary key rejects concurrent use as well.
+func CreateOnce(tx *gorm.DB, value any) error {
+	if r := tx.Create(value); errors.Is(r.Error, gorm.ErrDuplicatedKey) {
+		return ErrAlreadyUsed
+	} else if r.Error != nil {
+		return r.Error
+	}
+	return nil
+}
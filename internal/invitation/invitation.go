@@ -0,0 +1,153 @@
+package invitation
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"slices"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/seb-schulz/onegate/internal/auth"
+	"github.com/seb-schulz/onegate/internal/database"
+	"github.com/seb-schulz/onegate/internal/model"
+	"gorm.io/gorm"
+)
+
+// audience distinguishes invite tokens from other tokens signed by the key
+const audience = "invitation"
+
+// Param is the query parameter of invite links
+const Param = "invite"
+
+type (
+	// Invitation lets someone register when self-registration is disabled.
+	Invitation struct {
+		// Name of the new user is preset when not empty
+		Name string `json:"name,omitempty"`
+		// Groups the new user becomes member of
+		Groups []string `json:"groups,omitempty"`
+		// Clients the new user is allowed to access by its access policy
+		Clients []string `json:"clients,omitempty"`
+	}
+
+	claims struct {
+		jwt.RegisteredClaims
+		Invitation
+	}
+
+	Config struct {
+		Key          []byte
+		ValidMethods []string
+		// BaseUrl is the page where users register
+		BaseUrl url.URL
+	}
+
+	// Redemption marks an invitation as used
+	Redemption struct {
+		ID        string `gorm:"type:VARCHAR(36);primaryKey"`
+		UserID    uint   `gorm:"not null"`
+		CreatedAt time.Time
+	}
+)
+
+// Validate checks that all groups and clients of the invitation exist.
+func (inv Invitation) Validate(tx *gorm.DB) error {
+	for _, name := range inv.Groups {
+		if _, err := model.GroupByName(tx, name); err != nil {
+			return fmt.Errorf("group %#v not found", name)
+		}
+	}
+
+	for _, clientID := range inv.Clients {
+		if r := tx.Where("id = ?", clientID).First(&auth.Client{}); r.Error != nil {
+			return fmt.Errorf("client %#v not found", clientID)
+		}
+	}
+	return nil
+}
+
+// Url returns a signed link to the registration page which can be used
+// once until it expires.
+func Url(c Config, inv Invitation, expiresIn time.Duration) (*url.URL, error) {
+	now := time.Now()
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, &claims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        uuid.NewString(),
+			Audience:  jwt.ClaimStrings{audience},
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(expiresIn)),
+		},
+		Invitation: inv,
+	})
+
+	signedToken, err := token.SignedString(c.Key)
+	if err != nil {
+		return nil, err
+	}
+
+	u := c.BaseUrl
+	q := u.Query()
+	q.Set(Param, signedToken)
+	u.RawQuery = q.Encode()
+	return &u, nil
+}
+
+// Parse verifies the signature and expiry of an invite token. It returns
+// the ID of the invitation to redeem it.
+func Parse(c Config, signedToken string) (string, *Invitation, error) {
+	token, err := jwt.ParseWithClaims(signedToken, &claims{}, func(token *jwt.Token) (interface{}, error) {
+		return c.Key, nil
+	}, jwt.WithValidMethods(c.ValidMethods), jwt.WithExpirationRequired(), jwt.WithAudience(audience), jwt.WithLeeway(30*time.Second))
+	if err != nil {
+		return "", nil, err
+	}
+
+	claims := token.Claims.(*claims)
+	if claims.ID == "" {
+		return "", nil, fmt.Errorf("missing ID of invitation")
+	}
+	return claims.ID, &claims.Invitation, nil
+}
+
+// Redeem marks the invitation as used and grants the new user the groups
+// and clients of the invitation. Clients whose access policy does not
+// restrict users are accessible anyway.
+func Redeem(tx *gorm.DB, id string, inv *Invitation, user *model.User) error {
+	return tx.Transaction(func(tx *gorm.DB) error {
+		if err := database.CreateOnce(tx, &Redemption{ID: id, UserID: user.ID}); errors.Is(err, database.ErrAlreadyUsed) {
+			return fmt.Errorf("invitation already used")
+		} else if err != nil {
+			return fmt.Errorf("cannot redeem invitation: %v", err)
+		}
+
+		for _, name := range inv.Groups {
+			group, err := model.GroupByName(tx, name)
+			if err != nil {
+				return fmt.Errorf("group %#v not found", name)
+			}
+			if err := group.AddMember(tx, user); err != nil {
+				return err
+			}
+		}
+
+		for _, clientID := range inv.Clients {
+			client := auth.Client{}
+			if r := tx.Where("id = ?", clientID).First(&client); r.Error != nil {
+				return fmt.Errorf("client %#v not found", clientID)
+			}
+
+			policy := client.AccessPolicy()
+			if len(policy.UserIDs) == 0 || slices.Contains(policy.UserIDs, user.ID) {
+				continue
+			}
+
+			client.InternalAccessPolicy.UserIDs = append(policy.UserIDs, user.ID)
+			if r := tx.Model(&client).Select("InternalAccessPolicy").Updates(&client); r.Error != nil {
+				return fmt.Errorf("cannot update access policy: %v", r.Error)
+			}
+		}
+		return nil
+	})
+}
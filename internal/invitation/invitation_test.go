@@ -0,0 +1,62 @@
+package invitation
+
+import (
+	"net/url"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func TestUrlAndParse(t *testing.T) {
+	c := Config{
+		Key:          []byte("secret"),
+		ValidMethods: []string{"HS256"},
+		BaseUrl:      url.URL{Scheme: "https", Host: "example.com", Path: "/"},
+	}
+	inv := Invitation{Name: "jdoe", Groups: []string{"staff"}, Clients: []string{"b4c8e1a0-0000-4000-8000-000000000000"}}
+
+	u, err := Url(c, inv, time.Hour)
+	if err != nil {
+		t.Fatalf("cannot create invite link: %v", err)
+	}
+
+	if u.Host != "example.com" || u.Path != "/" {
+		t.Errorf("unexpected invite link %v", u)
+	}
+
+	token := u.Query().Get(Param)
+	id, parsed, err := Parse(c, token)
+	if err != nil {
+		t.Fatalf("cannot parse invite token: %v", err)
+	}
+	if id == "" || !reflect.DeepEqual(*parsed, inv) {
+		t.Errorf("expected %#v but got %#v (ID %#v)", inv, parsed, id)
+	}
+
+	if _, _, err := Parse(Config{Key: []byte("other"), ValidMethods: c.ValidMethods}, token); err == nil {
+		t.Errorf("expected error because of other key")
+	}
+
+	expired, err := Url(c, inv, -time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := Parse(c, expired.Query().Get(Param)); err == nil {
+		t.Errorf("expected error because of expired token")
+	}
+
+	// Tokens of the same key like login links are no invitations
+	loginToken, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.RegisteredClaims{
+		ID:        "abcd",
+		Subject:   "1",
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+	}).SignedString(c.Key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := Parse(c, loginToken); err == nil {
+		t.Errorf("expected error because of missing audience")
+	}
+}
@@ -0,0 +1,159 @@
+package mail
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/smtp"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type (
+	Message struct {
+		To      string
+		Subject string
+		Body    string
+	}
+
+	// Mailer delivers messages to users
+	Mailer interface {
+		Send(ctx context.Context, msg Message) error
+	}
+
+	SMTPConfig struct {
+		Host     string
+		Port     int
+		Username string
+		Password string
+	}
+
+	Config struct {
+		// Transport is one of smtp, file or log
+		Transport string
+		From      string
+		// Dir receives the messages of the file transport
+		Dir  string
+		SMTP SMTPConfig
+	}
+
+	smtpMailer struct {
+		from     string
+		addr     string
+		auth     smtp.Auth
+		sendMail func(addr string, a smtp.Auth, from string, to []string, msg []byte) error
+	}
+
+	fileMailer struct {
+		from string
+		dir  string
+	}
+
+	logMailer struct {
+		from string
+	}
+)
+
+// New returns the mailer of the configured transport.
+func New(c Config) (Mailer, error) {
+	if c.From == "" {
+		return nil, fmt.Errorf("sender of emails not configured")
+	}
+
+	switch c.Transport {
+	case "smtp":
+		if c.SMTP.Host == "" {
+			return nil, fmt.Errorf("SMTP host not configured")
+		}
+
+		m := &smtpMailer{
+			from:     c.From,
+			addr:     net.JoinHostPort(c.SMTP.Host, strconv.Itoa(c.SMTP.Port)),
+			sendMail: smtp.SendMail,
+		}
+		if c.SMTP.Username != "" {
+			m.auth = smtp.PlainAuth("", c.SMTP.Username, c.SMTP.Password, c.SMTP.Host)
+		}
+		return m, nil
+	case "file":
+		if c.Dir == "" {
+			return nil, fmt.Errorf("directory of emails not configured")
+		}
+		return &fileMailer{c.From, c.Dir}, nil
+	case "log":
+		return &logMailer{c.From}, nil
+	default:
+		return nil, fmt.Errorf("unknown mail transport %#v", c.Transport)
+	}
+}
+
+// format renders the message in the Internet Message Format (RFC 5322).
+func (msg Message) format(from string, date time.Time) ([]byte, error) {
+	for _, v := range []string{from, msg.To, msg.Subject} {
+		if strings.ContainsAny(v, "\r\n") {
+			return nil, fmt.Errorf("header must not contain line breaks")
+		}
+	}
+
+	b := strings.Builder{}
+	fmt.Fprintf(&b, "From: %s\r\n", from)
+	fmt.Fprintf(&b, "To: %s\r\n", msg.To)
+	fmt.Fprintf(&b, "Subject: %s\r\n", msg.Subject)
+	fmt.Fprintf(&b, "Date: %s\r\n", date.Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	b.WriteString("\r\n")
+	b.WriteString(strings.ReplaceAll(strings.ReplaceAll(msg.Body, "\r\n", "\n"), "\n", "\r\n"))
+	return []byte(b.String()), nil
+}
+
+func (m *smtpMailer) Send(ctx context.Context, msg Message) error {
+	b, err := msg.format(m.from, time.Now())
+	if err != nil {
+		return err
+	}
+
+	if err := m.sendMail(m.addr, m.auth, m.from, []string{msg.To}, b); err != nil {
+		return fmt.Errorf("cannot send email: %v", err)
+	}
+	return nil
+}
+
+// Send writes the message into a file of the directory so that messages can
+// be read without any mail server.
+func (m *fileMailer) Send(ctx context.Context, msg Message) error {
+	now := time.Now()
+	b, err := msg.format(m.from, now)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(m.dir, 0o700); err != nil {
+		return fmt.Errorf("cannot create directory of emails: %v", err)
+	}
+
+	f, err := os.CreateTemp(m.dir, fmt.Sprintf("%d-*.eml", now.UnixNano()))
+	if err != nil {
+		return fmt.Errorf("cannot create email: %v", err)
+	}
+	defer f.Close()
+
+	if _, err := f.Write(b); err != nil {
+		return fmt.Errorf("cannot write email: %v", err)
+	}
+	return nil
+}
+
+// Send logs the message instead of sending it.
+func (m *logMailer) Send(ctx context.Context, msg Message) error {
+	b, err := msg.format(m.from, time.Now())
+	if err != nil {
+		return err
+	}
+
+	slog.Info(fmt.Sprintf("email to %v:\n%s", msg.To, b))
+	return nil
+}
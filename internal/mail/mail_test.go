@@ -0,0 +1,82 @@
+package mail
+
+import (
+	"context"
+	"net/smtp"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestNew(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		config Config
+		valid  bool
+	}{
+		{"log", Config{Transport: "log", From: "onegate@example.com"}, true},
+		{"file", Config{Transport: "file", From: "onegate@example.com", Dir: t.TempDir()}, true},
+		{"smtp", Config{Transport: "smtp", From: "onegate@example.com", SMTP: SMTPConfig{Host: "localhost", Port: 25}}, true},
+		{"missing sender", Config{Transport: "log"}, false},
+		{"missing directory", Config{Transport: "file", From: "onegate@example.com"}, false},
+		{"missing host", Config{Transport: "smtp", From: "onegate@example.com"}, false},
+		{"unknown transport", Config{Transport: "pigeon", From: "onegate@example.com"}, false},
+	} {
+		if _, err := New(tc.config); (err == nil) != tc.valid {
+			t.Errorf("%v: expected valid=%v but got: %v", tc.name, tc.valid, err)
+		}
+	}
+}
+
+func TestFileMailer(t *testing.T) {
+	dir := t.TempDir()
+	m, err := New(Config{Transport: "file", From: "onegate@example.com", Dir: dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := m.Send(context.Background(), Message{To: "jdoe@example.com", Subject: "Hello", Body: "line 1\nline 2"}); err != nil {
+		t.Fatalf("cannot send email: %v", err)
+	}
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.eml"))
+	if err != nil || len(files) != 1 {
+		t.Fatalf("expected one email but got %v: %v", files, err)
+	}
+
+	b, err := os.ReadFile(files[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, expected := range []string{"From: onegate@example.com\r\n", "To: jdoe@example.com\r\n", "Subject: Hello\r\n", "\r\n\r\nline 1\r\nline 2"} {
+		if !strings.Contains(string(b), expected) {
+			t.Errorf("expected %#v in email %#v", expected, string(b))
+		}
+	}
+}
+
+func TestSMTPMailer(t *testing.T) {
+	var sent []string
+	m := &smtpMailer{
+		from: "onegate@example.com",
+		addr: "localhost:25",
+		sendMail: func(addr string, a smtp.Auth, from string, to []string, msg []byte) error {
+			sent = append(sent, to...)
+			return nil
+		},
+	}
+
+	if err := m.Send(context.Background(), Message{To: "jdoe@example.com", Subject: "Hello\r\nBcc: eve@example.com"}); err == nil {
+		t.Errorf("expected error because of header injection")
+	}
+
+	if err := m.Send(context.Background(), Message{To: "jdoe@example.com", Subject: "Hello"}); err != nil {
+		t.Errorf("cannot send email: %v", err)
+	}
+
+	if len(sent) != 1 || sent[0] != "jdoe@example.com" {
+		t.Errorf("unexpected recipients %v", sent)
+	}
+}
@@ -12,10 +12,16 @@ import (
 	"gorm.io/gorm"
 )
 
+// AuthSession keeps the state of a pending WebAuthn ceremony. Each browser
+// session has at most one so that restarted ceremonies replace the previous
+// one.
 type AuthSession struct {
 	ID        uuid.UUID `gorm:"primarykey"`
 	CreatedAt time.Time
 	UpdatedAt time.Time
+	// ExpiresAt ends the ceremony. Conditional logins are kept for the
+	// lifetime of the login page.
+	ExpiresAt time.Time `gorm:"index"`
 	Data      datatypes.JSONType[webauthn.SessionData]
 }
 
@@ -23,11 +29,25 @@ func (a AuthSession) Value() webauthn.SessionData {
 	return a.Data.Data()
 }
 
-func CreateAuthSession(ctx context.Context, data *webauthn.SessionData) error {
+// CreateAuthSession replaces the pending ceremony of the browser session and
+// removes expired ceremonies of all sessions. The ceremony expires after
+// expiresIn unless the session data enforces an earlier timeout.
+func CreateAuthSession(ctx context.Context, data *webauthn.SessionData, expiresIn time.Duration) error {
+	now := time.Now()
+	expiresAt := now.Add(expiresIn)
+	if !data.Expires.IsZero() && data.Expires.Before(expiresAt) {
+		expiresAt = data.Expires
+	}
+
 	_, err := database.Transaction(ctx, func(tx *gorm.DB) (bool, error) {
+		if r := tx.Where("expires_at < ?", now).Delete(&AuthSession{}); r.Error != nil {
+			return false, r.Error
+		}
+
 		authSession := AuthSession{
-			ID:   sessionmgr.FromContext(ctx).UUID,
-			Data: datatypes.NewJSONType(*data),
+			ID:        sessionmgr.FromContext(ctx).UUID,
+			ExpiresAt: expiresAt,
+			Data:      datatypes.NewJSONType(*data),
 		}
 		if r := tx.Save(&authSession); r.Error != nil {
 			return false, r.Error
@@ -37,9 +57,11 @@ func CreateAuthSession(ctx context.Context, data *webauthn.SessionData) error {
 	return err
 }
 
+// FirstAuthSession returns the pending ceremony of the browser session unless
+// it expired.
 func FirstAuthSession(ctx context.Context) (*AuthSession, error) {
 	auth_session := AuthSession{ID: sessionmgr.FromContext(ctx).UUID}
-	if result := database.FromContext(ctx).Order("created_at DESC").First(&auth_session); result.Error != nil {
+	if result := database.FromContext(ctx).Where("expires_at >= ?", time.Now()).First(&auth_session); result.Error != nil {
 		return nil, result.Error
 	}
 	return &auth_session, nil
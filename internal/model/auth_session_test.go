@@ -0,0 +1,56 @@
+package model
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/go-webauthn/webauthn/webauthn"
+	"github.com/google/uuid"
+	"github.com/seb-schulz/onegate/internal/database"
+	"github.com/seb-schulz/onegate/internal/sessionmgr"
+)
+
+func TestAuthSessionExpiry(t *testing.T) {
+	tx := openDb().Begin()
+	defer tx.Rollback()
+
+	ctx := sessionmgr.ToContext(database.WithContext(context.Background(), tx), &sessionmgr.Token{UUID: uuid.New()})
+	otherCtx := sessionmgr.ToContext(database.WithContext(context.Background(), tx), &sessionmgr.Token{UUID: uuid.New()})
+
+	if err := CreateAuthSession(otherCtx, &webauthn.SessionData{Challenge: "stale"}, -time.Minute); err != nil {
+		t.Fatalf("cannot create auth session: %v", err)
+	}
+	if _, err := FirstAuthSession(otherCtx); err == nil {
+		t.Errorf("expected expired auth session to be ignored")
+	}
+
+	// Restarted ceremonies replace the previous one
+	for _, challenge := range []string{"first", "second"} {
+		if err := CreateAuthSession(ctx, &webauthn.SessionData{Challenge: challenge}, time.Minute); err != nil {
+			t.Fatalf("cannot create auth session: %v", err)
+		}
+	}
+
+	a, err := FirstAuthSession(ctx)
+	if err != nil {
+		t.Fatalf("cannot get auth session: %v", err)
+	}
+	if a.Value().Challenge != "second" {
+		t.Errorf("expected latest challenge but got %#v", a.Value().Challenge)
+	}
+
+	var count int64
+	tx.Model(&AuthSession{}).Where("id IN ?", []uuid.UUID{sessionmgr.FromContext(ctx).UUID, sessionmgr.FromContext(otherCtx).UUID}).Count(&count)
+	if count != 1 {
+		t.Errorf("expected expired auth session to be removed but got %v sessions", count)
+	}
+
+	// Enforced timeouts of the session data take precedence
+	if err := CreateAuthSession(ctx, &webauthn.SessionData{Challenge: "enforced", Expires: time.Now().Add(-time.Second)}, time.Hour); err != nil {
+		t.Fatalf("cannot create auth session: %v", err)
+	}
+	if _, err := FirstAuthSession(ctx); err == nil {
+		t.Errorf("expected enforced timeout to expire auth session")
+	}
+}
@@ -0,0 +1,90 @@
+package model
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-webauthn/webauthn/webauthn"
+)
+
+// Authentication context class references ordered by increasing assurance
+const (
+	AcrRecoveryCode  = "urn:onegate:acr:code"
+	AcrRecoveryURL   = "urn:onegate:acr:url"
+	AcrPasskey       = "urn:onegate:acr:passkey"
+	AcrPasskeyUV     = "urn:onegate:acr:passkey:uv"
+	AcrHardwareKeyUV = "urn:onegate:acr:hwk:uv"
+)
+
+// Authentication method references (see RFC 8176)
+const (
+	AmrHardwareKey     = "hwk"
+	AmrSoftwareKey     = "swk"
+	AmrUserPresence    = "user"
+	AmrMultiFactor     = "mfa"
+	AmrOneTimePassword = "otp"
+	// AmrRecoveryURL is not registered and marks logins via recovery URL
+	AmrRecoveryURL = "url"
+)
+
+var acrLevels = []string{AcrRecoveryCode, AcrRecoveryURL, AcrPasskey, AcrPasskeyUV, AcrHardwareKeyUV}
+
+// AcrLevel returns the assurance level of an acr value or -1 if it is unknown.
+func AcrLevel(acr string) int {
+	for i, v := range acrLevels {
+		if v == acr {
+			return i
+		}
+	}
+	return -1
+}
+
+type AuthnContext struct {
+	Acr      string   `gorm:"type:VARCHAR(255);not null;default:''"`
+	Amr      []string `gorm:"serializer:json"`
+	AuthTime *time.Time
+}
+
+func (ac AuthnContext) AcrLevel() int {
+	return AcrLevel(ac.Acr)
+}
+
+func AuthnContextFromCredential(cred webauthn.Credential) AuthnContext {
+	ac := AuthnContext{Acr: AcrPasskey}
+
+	if cred.Flags.BackupEligible {
+		ac.Amr = append(ac.Amr, AmrSoftwareKey)
+	} else {
+		ac.Amr = append(ac.Amr, AmrHardwareKey)
+	}
+
+	if cred.Flags.UserPresent {
+		ac.Amr = append(ac.Amr, AmrUserPresence)
+	}
+
+	if cred.Flags.UserVerified {
+		ac.Amr = append(ac.Amr, AmrMultiFactor)
+		ac.Acr = AcrPasskeyUV
+		if !cred.Flags.BackupEligible {
+			ac.Acr = AcrHardwareKeyUV
+		}
+	}
+
+	return ac
+}
+
+func RecoveryURLAuthnContext() AuthnContext {
+	return AuthnContext{Acr: AcrRecoveryURL, Amr: []string{AmrRecoveryURL}}
+}
+
+func RecoveryCodeAuthnContext() AuthnContext {
+	return AuthnContext{Acr: AcrRecoveryCode, Amr: []string{AmrOneTimePassword}}
+}
+
+func CurrentAuthnContext(ctx context.Context) (*AuthnContext, error) {
+	s, err := FirstSession(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &s.AuthnContext, nil
+}
@@ -0,0 +1,37 @@
+package model
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/go-webauthn/webauthn/webauthn"
+)
+
+func TestAuthnContextFromCredential(t *testing.T) {
+	for _, tc := range []struct {
+		flags       webauthn.CredentialFlags
+		expectedAcr string
+		expectedAmr []string
+	}{
+		{webauthn.CredentialFlags{UserPresent: true}, AcrPasskey, []string{AmrHardwareKey, AmrUserPresence}},
+		{webauthn.CredentialFlags{UserPresent: true, UserVerified: true}, AcrHardwareKeyUV, []string{AmrHardwareKey, AmrUserPresence, AmrMultiFactor}},
+		{webauthn.CredentialFlags{UserPresent: true, UserVerified: true, BackupEligible: true}, AcrPasskeyUV, []string{AmrSoftwareKey, AmrUserPresence, AmrMultiFactor}},
+		{webauthn.CredentialFlags{BackupEligible: true}, AcrPasskey, []string{AmrSoftwareKey}},
+	} {
+		ac := AuthnContextFromCredential(webauthn.Credential{Flags: tc.flags})
+		if ac.Acr != tc.expectedAcr {
+			t.Errorf("expected acr %#v but got %#v", tc.expectedAcr, ac.Acr)
+		}
+		if !slices.Equal(ac.Amr, tc.expectedAmr) {
+			t.Errorf("expected amr %#v but got %#v", tc.expectedAmr, ac.Amr)
+		}
+	}
+
+	if AcrLevel(AcrRecoveryURL) >= AcrLevel(AcrPasskey) {
+		t.Errorf("recovery URL must be weaker than any passkey")
+	}
+
+	if AcrLevel(AcrRecoveryCode) >= AcrLevel(AcrRecoveryURL) {
+		t.Errorf("recovery code must be weaker than recovery URL")
+	}
+}
@@ -33,3 +33,8 @@ func CountCredentialByUserID(db *gorm.DB, userID uint) int {
 	return int(c)
 
 }
+
+// DeleteCredentialsByUserID removes all credentials of the user permanently.
+func DeleteCredentialsByUserID(tx *gorm.DB, userID uint) error {
+	return tx.Unscoped().Where("user_id = ?", userID).Delete(&Credential{}).Error
+}
@@ -0,0 +1,170 @@
+package model
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// EmailVerificationExpiresIn limits how long verification links are valid
+const EmailVerificationExpiresIn = 24 * time.Hour
+
+// EmailAddress belongs to a user and must be verified before it is exposed
+// to clients. Only the primary address is used for claims.
+type EmailAddress struct {
+	gorm.Model
+	UserID  uint   `gorm:"not null;uniqueIndex:idx_email_addresses_user_address"`
+	Address string `gorm:"type:VARCHAR(191);not null;uniqueIndex:idx_email_addresses_user_address"`
+	Primary bool   `gorm:"column:is_primary;not null;default:false"`
+	// VerifiedAt is set when the user opened the verification link
+	VerifiedAt *time.Time
+	// VerificationHash is the SHA-256 hash of the pending verification token
+	VerificationHash      []byte `gorm:"type:BINARY(32);index"`
+	VerificationExpiresAt *time.Time
+}
+
+func (e *EmailAddress) IsVerified() bool {
+	return e.VerifiedAt != nil
+}
+
+func hashVerificationToken(token string) []byte {
+	h := sha256.Sum256([]byte(token))
+	return h[:]
+}
+
+// AddEmailAddress adds an unverified address to the user. The first address
+// of a user becomes the primary address.
+func AddEmailAddress(tx *gorm.DB, userID uint, address string) (*EmailAddress, error) {
+	if err := ValidateEmail(address); err != nil {
+		return nil, err
+	}
+
+	var count int64
+	if r := tx.Model(&EmailAddress{}).Where("user_id = ?", userID).Count(&count); r.Error != nil {
+		return nil, r.Error
+	}
+
+	e := EmailAddress{UserID: userID, Address: address, Primary: count == 0}
+	if r := tx.Create(&e); r.Error != nil {
+		return nil, fmt.Errorf("cannot add email address: %v", r.Error)
+	}
+	return &e, nil
+}
+
+// NewVerificationToken replaces any pending verification of the address by
+// a new one and returns its token.
+func (e *EmailAddress) NewVerificationToken(tx *gorm.DB) (string, error) {
+	if e.IsVerified() {
+		return "", fmt.Errorf("email address already verified")
+	}
+
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	token := base64.RawURLEncoding.EncodeToString(b)
+
+	expiresAt := time.Now().Add(EmailVerificationExpiresIn)
+	e.VerificationHash, e.VerificationExpiresAt = hashVerificationToken(token), &expiresAt
+	if r := tx.Model(e).Select("VerificationHash", "VerificationExpiresAt").Updates(e); r.Error != nil {
+		return "", r.Error
+	}
+	return token, nil
+}
+
+// VerifyEmailAddress marks the address of a pending verification as
+// verified. Tokens can be used once.
+func VerifyEmailAddress(tx *gorm.DB, token string) (*EmailAddress, error) {
+	e := EmailAddress{}
+	r := tx.Where("verification_hash = ? AND verification_expires_at > ?", hashVerificationToken(token), time.Now()).First(&e)
+	if errors.Is(r.Error, gorm.ErrRecordNotFound) {
+		return nil, fmt.Errorf("invalid or expired verification token")
+	} else if r.Error != nil {
+		return nil, r.Error
+	}
+
+	now := time.Now()
+	e.VerifiedAt, e.VerificationHash, e.VerificationExpiresAt = &now, nil, nil
+	if r := tx.Model(&e).Select("VerifiedAt", "VerificationHash", "VerificationExpiresAt").Updates(&e); r.Error != nil {
+		return nil, r.Error
+	}
+	return &e, nil
+}
+
+func EmailAddressesByUserID(tx *gorm.DB, userID uint) ([]EmailAddress, error) {
+	addrs := []EmailAddress{}
+	if r := tx.Where("user_id = ?", userID).Order("id").Find(&addrs); r.Error != nil {
+		return nil, r.Error
+	}
+	return addrs, nil
+}
+
+func EmailAddressByUserID(tx *gorm.DB, userID uint, id string) (*EmailAddress, error) {
+	e := EmailAddress{}
+	if r := tx.Where("user_id = ? AND id = ?", userID, id).First(&e); r.Error != nil {
+		return nil, fmt.Errorf("email address not found")
+	}
+	return &e, nil
+}
+
+// VerifiedPrimaryEmail returns the primary address of the user or an empty
+// string when it is not verified yet.
+func VerifiedPrimaryEmail(tx *gorm.DB, userID uint) (string, error) {
+	addrs := []string{}
+	if r := tx.Model(&EmailAddress{}).Where("user_id = ? AND is_primary AND verified_at IS NOT NULL", userID).Limit(1).Pluck("address", &addrs); r.Error != nil {
+		return "", r.Error
+	}
+
+	if len(addrs) == 0 {
+		return "", nil
+	}
+	return addrs[0], nil
+}
+
+// MakePrimary replaces the primary address of the user. Only verified
+// addresses can become primary.
+func (e *EmailAddress) MakePrimary(tx *gorm.DB) error {
+	if !e.IsVerified() {
+		return fmt.Errorf("email address not verified")
+	}
+
+	return tx.Transaction(func(tx *gorm.DB) error {
+		if r := tx.Model(&EmailAddress{}).Where("user_id = ? AND id <> ?", e.UserID, e.ID).Update("is_primary", false); r.Error != nil {
+			return r.Error
+		}
+		if r := tx.Model(e).Update("is_primary", true); r.Error != nil {
+			return r.Error
+		}
+		e.Primary = true
+		return nil
+	})
+}
+
+// DeleteEmailAddress deletes the address permanently so that it can be added
+// again. The oldest remaining verified address becomes primary when the
+// primary address is deleted.
+func DeleteEmailAddress(tx *gorm.DB, e *EmailAddress) error {
+	return tx.Transaction(func(tx *gorm.DB) error {
+		if r := tx.Unscoped().Delete(e); r.Error != nil {
+			return r.Error
+		}
+
+		if !e.Primary {
+			return nil
+		}
+
+		next := EmailAddress{}
+		r := tx.Where("user_id = ? AND verified_at IS NOT NULL", e.UserID).Order("id").First(&next)
+		if errors.Is(r.Error, gorm.ErrRecordNotFound) {
+			return nil
+		} else if r.Error != nil {
+			return r.Error
+		}
+		return tx.Model(&next).Update("is_primary", true).Error
+	})
+}
@@ -0,0 +1,73 @@
+package model
+
+import (
+	"testing"
+)
+
+func TestEmailAddressVerification(t *testing.T) {
+	tx := openDb().Begin()
+	defer tx.Rollback()
+
+	user := User{Name: "jdoe"}
+	if r := tx.Create(&user); r.Error != nil {
+		t.Fatal(r.Error)
+	}
+
+	if _, err := AddEmailAddress(tx, user.ID, "John <jdoe@example.com>"); err == nil {
+		t.Errorf("expected error because of invalid address")
+	}
+
+	first, err := AddEmailAddress(tx, user.ID, "jdoe@example.com")
+	if err != nil {
+		t.Fatalf("cannot add email address: %v", err)
+	}
+	second, err := AddEmailAddress(tx, user.ID, "john@example.com")
+	if err != nil {
+		t.Fatalf("cannot add email address: %v", err)
+	}
+	if !first.Primary || second.Primary {
+		t.Errorf("expected first address to be primary")
+	}
+
+	if email, _ := VerifiedPrimaryEmail(tx, user.ID); email != "" {
+		t.Errorf("expected no email before verification but got %v", email)
+	}
+
+	if err := second.MakePrimary(tx); err == nil {
+		t.Errorf("expected error because of unverified address")
+	}
+
+	token, err := second.NewVerificationToken(tx)
+	if err != nil {
+		t.Fatalf("cannot create verification token: %v", err)
+	}
+
+	if _, err := VerifyEmailAddress(tx, token+"x"); err == nil {
+		t.Errorf("expected error because of invalid token")
+	}
+
+	verified, err := VerifyEmailAddress(tx, token)
+	if err != nil || verified.ID != second.ID || !verified.IsVerified() {
+		t.Fatalf("cannot verify email address: %v", err)
+	}
+
+	if _, err := VerifyEmailAddress(tx, token); err == nil {
+		t.Errorf("expected error because token was used before")
+	}
+
+	if err := verified.MakePrimary(tx); err != nil {
+		t.Fatalf("cannot make address primary: %v", err)
+	}
+
+	if email, _ := VerifiedPrimaryEmail(tx, user.ID); email != "john@example.com" {
+		t.Errorf("expected verified primary email but got %#v", email)
+	}
+
+	if err := DeleteEmailAddress(tx, verified); err != nil {
+		t.Fatalf("cannot delete email address: %v", err)
+	}
+
+	if email, _ := VerifiedPrimaryEmail(tx, user.ID); email != "" {
+		t.Errorf("expected no email after deleting primary address but got %v", email)
+	}
+}
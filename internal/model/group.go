@@ -0,0 +1,115 @@
+package model
+
+import (
+	"fmt"
+
+	"gorm.io/gorm"
+)
+
+type Group struct {
+	gorm.Model
+	Name        string `gorm:"type:VARCHAR(191);uniqueIndex;not null"`
+	Description string `gorm:"type:VARCHAR(255);not null;default:''"`
+	// ExternalID identifies the group at the provisioning client
+	ExternalID string `gorm:"type:VARCHAR(255);not null;default:''"`
+	Members    []User `gorm:"many2many:group_members"`
+}
+
+// Role is granted to users like a group but describes what users may do
+// instead of who they are.
+type Role struct {
+	gorm.Model
+	Name        string `gorm:"type:VARCHAR(191);uniqueIndex;not null"`
+	Description string `gorm:"type:VARCHAR(255);not null;default:''"`
+	Members     []User `gorm:"many2many:role_members"`
+}
+
+func CreateGroup(tx *gorm.DB, name, desc string) (*Group, error) {
+	if name == "" {
+		return nil, fmt.Errorf("name of group must not be empty")
+	}
+
+	group := Group{Name: name, Description: desc}
+	if r := tx.Create(&group); r.Error != nil {
+		return nil, r.Error
+	}
+	return &group, nil
+}
+
+func GroupByName(tx *gorm.DB, name string) (*Group, error) {
+	group := Group{}
+	if r := tx.Preload("Members").Where("name = ?", name).First(&group); r.Error != nil {
+		return nil, r.Error
+	}
+	return &group, nil
+}
+
+func (g *Group) AddMember(tx *gorm.DB, user *User) error {
+	return tx.Model(g).Association("Members").Append(user)
+}
+
+func (g *Group) RemoveMember(tx *gorm.DB, user *User) error {
+	return tx.Model(g).Association("Members").Delete(user)
+}
+
+// DeleteGroup deletes the group permanently so that its name can be reused.
+func DeleteGroup(tx *gorm.DB, g *Group) error {
+	return tx.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(g).Association("Members").Clear(); err != nil {
+			return err
+		}
+		return tx.Unscoped().Delete(g).Error
+	})
+}
+
+func CreateRole(tx *gorm.DB, name, desc string) (*Role, error) {
+	if name == "" {
+		return nil, fmt.Errorf("name of role must not be empty")
+	}
+
+	role := Role{Name: name, Description: desc}
+	if r := tx.Create(&role); r.Error != nil {
+		return nil, r.Error
+	}
+	return &role, nil
+}
+
+func RoleByName(tx *gorm.DB, name string) (*Role, error) {
+	role := Role{}
+	if r := tx.Preload("Members").Where("name = ?", name).First(&role); r.Error != nil {
+		return nil, r.Error
+	}
+	return &role, nil
+}
+
+func (r *Role) AddMember(tx *gorm.DB, user *User) error {
+	return tx.Model(r).Association("Members").Append(user)
+}
+
+func (r *Role) RemoveMember(tx *gorm.DB, user *User) error {
+	return tx.Model(r).Association("Members").Delete(user)
+}
+
+// DeleteRole deletes the role permanently so that its name can be reused.
+func DeleteRole(tx *gorm.DB, r *Role) error {
+	return tx.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(r).Association("Members").Clear(); err != nil {
+			return err
+		}
+		return tx.Unscoped().Delete(r).Error
+	})
+}
+
+// GroupNamesByUserID returns the names of all groups the user is member of.
+func GroupNamesByUserID(tx *gorm.DB, userID uint) ([]string, error) {
+	names := []string{}
+	r := tx.Model(&Group{}).Joins("JOIN group_members ON group_members.group_id = groups.id").Where("group_members.user_id = ?", userID).Order("groups.name").Pluck("groups.name", &names)
+	return names, r.Error
+}
+
+// RoleNamesByUserID returns the names of all roles granted to the user.
+func RoleNamesByUserID(tx *gorm.DB, userID uint) ([]string, error) {
+	names := []string{}
+	r := tx.Model(&Role{}).Joins("JOIN role_members ON role_members.role_id = roles.id").Where("role_members.user_id = ?", userID).Order("roles.name").Pluck("roles.name", &names)
+	return names, r.Error
+}
@@ -0,0 +1,38 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/seb-schulz/onegate/internal/database"
+	"gorm.io/gorm"
+)
+
+// UsedLoginToken prevents that a login URL is used more than once. Tokens are
+// kept until they expire.
+type UsedLoginToken struct {
+	ID        string    `gorm:"type:VARCHAR(36);primaryKey"`
+	ExpiresAt time.Time `gorm:"index;not null"`
+}
+
+// ConsumeLoginToken marks the token ID as used and fails if it was used
+// before.
+func ConsumeLoginToken(tx *gorm.DB, id string, expiresAt time.Time) error {
+	if id == "" {
+		return fmt.Errorf("missing token ID")
+	}
+
+	return tx.Transaction(func(tx *gorm.DB) error {
+		if r := tx.Where("expires_at < ?", time.Now()).Delete(&UsedLoginToken{}); r.Error != nil {
+			return r.Error
+		}
+
+		if err := database.CreateOnce(tx, &UsedLoginToken{ID: id, ExpiresAt: expiresAt}); errors.Is(err, database.ErrAlreadyUsed) {
+			return fmt.Errorf("login token already used")
+		} else if err != nil {
+			return fmt.Errorf("cannot use login token: %v", err)
+		}
+		return nil
+	})
+}
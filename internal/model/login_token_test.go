@@ -0,0 +1,41 @@
+package model
+
+import (
+	"testing"
+	"time"
+)
+
+func TestConsumeLoginToken(t *testing.T) {
+	tx := openDb().Begin()
+	defer tx.Rollback()
+
+	expiresAt := time.Now().Add(time.Minute)
+	if err := ConsumeLoginToken(tx, "", expiresAt); err == nil {
+		t.Errorf("expected error because of missing ID")
+	}
+
+	if err := ConsumeLoginToken(tx, "abcd", expiresAt); err != nil {
+		t.Fatalf("cannot consume token: %v", err)
+	}
+
+	if err := ConsumeLoginToken(tx, "abcd", expiresAt); err == nil {
+		t.Errorf("expected error because token was used")
+	}
+
+	// Expired tokens are removed and fail the signature check anyway
+	if err := ConsumeLoginToken(tx, "efgh", time.Now().Add(-time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	var count int64
+	tx.Model(&UsedLoginToken{}).Where("id = ?", "efgh").Count(&count)
+	if count != 1 {
+		t.Errorf("expected stored token but got %v", count)
+	}
+	if err := ConsumeLoginToken(tx, "ijkl", expiresAt); err != nil {
+		t.Fatal(err)
+	}
+	tx.Model(&UsedLoginToken{}).Where("id = ?", "efgh").Count(&count)
+	if count != 0 {
+		t.Errorf("expected expired token to be removed")
+	}
+}
@@ -0,0 +1,124 @@
+package model
+
+import (
+	"fmt"
+	"net/mail"
+	"net/url"
+	"regexp"
+	"slices"
+	"time"
+
+	"golang.org/x/text/language"
+)
+
+const defaultAttributeMaxLength = 255
+
+// Profile holds standard claims of users (see OpenID Connect Core 1.0,
+// section 5.1) and custom attributes defined by admins. Email addresses are
+// stored separately because they must be verified.
+type Profile struct {
+	Picture  string `gorm:"type:VARCHAR(1024);not null;default:''"`
+	Locale   string `gorm:"type:VARCHAR(35);not null;default:''"`
+	Zoneinfo string `gorm:"type:VARCHAR(64);not null;default:''"`
+	// Attributes map names of attribute definitions to values
+	Attributes map[string]string `gorm:"serializer:json"`
+}
+
+// AttributeDefinition allows users to set a custom attribute. Values must
+// match the pattern entirely if it is set.
+type AttributeDefinition struct {
+	Name        string
+	Description string
+	Pattern     string
+	MaxLength   int
+}
+
+func (def AttributeDefinition) validate(value string) error {
+	maxLength := def.MaxLength
+	if maxLength <= 0 {
+		maxLength = defaultAttributeMaxLength
+	}
+
+	if len(value) > maxLength {
+		return fmt.Errorf("%v must not be longer than %d letters", def.Name, maxLength)
+	}
+
+	if def.Pattern == "" {
+		return nil
+	}
+
+	re, err := regexp.Compile(fmt.Sprintf("^(?:%s)$", def.Pattern))
+	if err != nil {
+		return fmt.Errorf("invalid pattern of %v: %v", def.Name, err)
+	}
+
+	if !re.MatchString(value) {
+		return fmt.Errorf("%v has invalid format", def.Name)
+	}
+	return nil
+}
+
+func ValidateEmail(s string) error {
+	addr, err := mail.ParseAddress(s)
+	if err != nil || addr.Name != "" || addr.Address != s {
+		return fmt.Errorf("invalid email address %#v", s)
+	}
+	return nil
+}
+
+func validatePicture(s string) error {
+	u, err := url.Parse(s)
+	if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+		return fmt.Errorf("picture must be an absolute HTTP URL")
+	}
+	return nil
+}
+
+func validateLocale(s string) error {
+	if _, err := language.Parse(s); err != nil {
+		return fmt.Errorf("invalid locale %#v", s)
+	}
+	return nil
+}
+
+func validateZoneinfo(s string) error {
+	if s == "Local" {
+		return fmt.Errorf("invalid time zone %#v", s)
+	}
+	if _, err := time.LoadLocation(s); err != nil {
+		return fmt.Errorf("invalid time zone %#v", s)
+	}
+	return nil
+}
+
+// Validate checks the format of all attributes. Empty values are valid and
+// custom attributes must be defined.
+func (p Profile) Validate(defs []AttributeDefinition) error {
+	for _, attr := range []struct {
+		value    string
+		validate func(string) error
+	}{
+		{p.Picture, validatePicture},
+		{p.Locale, validateLocale},
+		{p.Zoneinfo, validateZoneinfo},
+	} {
+		if attr.value == "" {
+			continue
+		}
+		if err := attr.validate(attr.value); err != nil {
+			return err
+		}
+	}
+
+	for name, value := range p.Attributes {
+		i := slices.IndexFunc(defs, func(def AttributeDefinition) bool { return def.Name == name })
+		if i < 0 {
+			return fmt.Errorf("unknown attribute %#v", name)
+		}
+
+		if err := defs[i].validate(value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,41 @@
+package model
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestProfileValidate(t *testing.T) {
+	defs := []AttributeDefinition{
+		{Name: "department", Pattern: "[A-Z]{2,4}"},
+		{Name: "nickname", MaxLength: 5},
+		{Name: "bio"},
+	}
+
+	for _, tc := range []struct {
+		name    string
+		profile Profile
+		valid   bool
+	}{
+		{"empty", Profile{}, true},
+		{"complete", Profile{
+			Picture:    "https://example.com/jdoe.png",
+			Locale:     "de-DE",
+			Zoneinfo:   "Europe/Berlin",
+			Attributes: map[string]string{"department": "IT", "nickname": "jd"},
+		}, true},
+		{"relative picture", Profile{Picture: "/jdoe.png"}, false},
+		{"picture with other scheme", Profile{Picture: "javascript:alert(1)"}, false},
+		{"invalid locale", Profile{Locale: "not a locale"}, false},
+		{"invalid zoneinfo", Profile{Zoneinfo: "Nowhere/Town"}, false},
+		{"local zoneinfo", Profile{Zoneinfo: "Local"}, false},
+		{"unknown attribute", Profile{Attributes: map[string]string{"shoe size": "42"}}, false},
+		{"partial pattern match", Profile{Attributes: map[string]string{"department": "IT-1"}}, false},
+		{"custom max length", Profile{Attributes: map[string]string{"nickname": "johnny"}}, false},
+		{"default max length", Profile{Attributes: map[string]string{"bio": strings.Repeat("x", 256)}}, false},
+	} {
+		if err := tc.profile.Validate(defs); (err == nil) != tc.valid {
+			t.Errorf("%v: expected valid=%v but got: %v", tc.name, tc.valid, err)
+		}
+	}
+}
@@ -7,17 +7,22 @@ import (
 
 	"github.com/google/uuid"
 	"github.com/seb-schulz/onegate/internal/config"
+	"github.com/seb-schulz/onegate/internal/database"
 	"github.com/seb-schulz/onegate/internal/sessionmgr"
 	"gorm.io/gorm"
 )
 
 type Session struct {
-	ID        uuid.UUID `gorm:"primarykey"`
-	CreatedAt time.Time
-	UpdatedAt time.Time
-	DeletedAt gorm.DeletedAt `gorm:"index"`
-	UserID    uint
-	User      User
+	ID           uuid.UUID `gorm:"primarykey"`
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+	DeletedAt    gorm.DeletedAt `gorm:"index"`
+	UserID       uint
+	User         User
+	AuthnContext `gorm:"embedded"`
+	// Restricted sessions of users recovering their account may only
+	// register a new credential
+	Restricted bool `gorm:"not null;default:false"`
 }
 
 func (s *Session) String() string {
@@ -32,6 +37,21 @@ func (s *Session) IsCurrent(ctx context.Context) bool {
 	return s.ID == sessionmgr.FromContext(ctx).UUID
 }
 
+func FirstSession(ctx context.Context) (*Session, error) {
+	s := Session{ID: sessionmgr.FromContext(ctx).UUID}
+	if r := database.FromContext(ctx).First(&s); r.Error != nil {
+		return nil, r.Error
+	}
+	return &s, nil
+}
+
+// EndRestrictedSession deletes the restricted session of the context so that
+// the user logs in with the new credential.
+func EndRestrictedSession(ctx context.Context, tx *gorm.DB) error {
+	// Unscoped because the ID of the session is reused by the next login
+	return tx.Unscoped().Where("restricted = ?", true).Delete(&Session{ID: sessionmgr.FromContext(ctx).UUID}).Error
+}
+
 func DeleteSessionByUserID(userID uint, id uuid.UUID) func(tx *gorm.DB) error {
 	return func(tx *gorm.DB) error {
 		s := Session{ID: id}
@@ -43,6 +63,10 @@ func DeleteSessionByUserID(userID uint, id uuid.UUID) func(tx *gorm.DB) error {
 	}
 }
 
+func DeleteAllSessionsByUserID(tx *gorm.DB, userID uint) error {
+	return tx.Where("user_id = ?", userID).Delete(&Session{}).Error
+}
+
 func AllSessionByUserID(tx *gorm.DB, userID uint) ([]*Session, error) {
 	sessions := []*Session{}
 	r := tx.Where("user_id = ?", userID).Find(&sessions)
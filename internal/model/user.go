@@ -5,6 +5,7 @@ import (
 	"crypto/rand"
 	"errors"
 	"fmt"
+	"time"
 
 	"github.com/go-webauthn/webauthn/webauthn"
 	"github.com/seb-schulz/onegate/internal/database"
@@ -12,6 +13,15 @@ import (
 	"gorm.io/gorm"
 )
 
+const (
+	ApprovalPending  = "pending"
+	ApprovalApproved = "approved"
+	ApprovalRejected = "rejected"
+)
+
+// ErrNameTaken is returned if another user has the name already.
+var ErrNameTaken = errors.New("name is already taken")
+
 type User struct {
 	gorm.Model
 	// `RANDOM_BYTES` was added with MariaDB 10.10.0
@@ -20,6 +30,16 @@ type User struct {
 	Name        string `gorm:"type:VARCHAR(255);not null"`
 	DisplayName string `gorm:"type:VARCHAR(255);not null"`
 	Credentials []Credential
+	// ExternalID identifies the user at the provisioning client
+	ExternalID string `gorm:"type:VARCHAR(255);not null;default:''"`
+	// DeactivatedAt is set when users must not log in anymore
+	DeactivatedAt *time.Time
+	// ApprovalStatus of self-registered users is pending until an admin
+	// approved them. Only approved users can log in to clients.
+	ApprovalStatus string `gorm:"type:VARCHAR(16);not null;default:'approved'"`
+	Profile        `gorm:"embedded"`
+	Groups         []Group `gorm:"many2many:group_members"`
+	Roles          []Role  `gorm:"many2many:role_members"`
 }
 
 func (u User) WebAuthnID() []byte {
@@ -51,20 +71,29 @@ func (u *User) IDStr() string {
 }
 
 func FirstUser(ctx context.Context) (*User, error) {
+	return firstUser(ctx, false)
+}
+
+// FirstRecoveringUser returns the user of a restricted session.
+func FirstRecoveringUser(ctx context.Context) (*User, error) {
+	return firstUser(ctx, true)
+}
+
+func firstUser(ctx context.Context, restricted bool) (*User, error) {
 	s := Session{ID: sessionmgr.FromContext(ctx).UUID}
-	r := database.FromContext(ctx).Preload("User").First(&s)
+	r := database.FromContext(ctx).Preload("User").Where("restricted = ?", restricted).First(&s)
 	if errors.Is(r.Error, gorm.ErrRecordNotFound) {
 		return nil, r.Error
 	}
 	return &s.User, nil
 }
 
-func CreateUser(ctx context.Context, name string) (*User, error) {
+func CreateUser(ctx context.Context, name string, opts ...database.TransactionOptFunc) (*User, error) {
 	return database.Transaction(ctx, func(tx *gorm.DB) (*User, error) {
 		user := User{Name: name}
 
 		if r := tx.Create(&user); r.Error != nil {
-			return nil, r.Error
+			return nil, uniqueName(r.Error)
 		}
 
 		token := sessionmgr.FromContext(ctx)
@@ -75,31 +104,86 @@ func CreateUser(ctx context.Context, name string) (*User, error) {
 			return nil, r.Error
 		}
 		return &user, nil
-	})
+	}, opts...)
+}
+
+// Save updates the user and fails with ErrNameTaken if another user has the
+// name already.
+func (u *User) Save(tx *gorm.DB) error {
+	return uniqueName(tx.Save(u).Error)
+}
+
+func uniqueName(err error) error {
+	if errors.Is(err, gorm.ErrDuplicatedKey) {
+		return ErrNameTaken
+	}
+	return err
+}
+
+// MigrateUniqueNames adds a unique index on the names of users which are not
+// deleted. Deleted users keep their names but do not block them. Migration
+// fails while users share a name because logins by name must not be
+// ambiguous.
+func MigrateUniqueNames(db *gorm.DB) error {
+	duplicates := []string{}
+	if r := db.Model(&User{}).Group("name").Having("COUNT(*) > 1").Pluck("name", &duplicates); r.Error != nil {
+		return r.Error
+	}
+	if len(duplicates) > 0 {
+		return fmt.Errorf("users must be renamed or deleted because their names are not unique: %q", duplicates)
+	}
+
+	// MariaDB has no partial indexes but allows multiple NULL values
+	if !db.Migrator().HasColumn(&User{}, "active_name") {
+		if r := db.Exec("ALTER TABLE users ADD COLUMN active_name VARCHAR(255) AS (IF(deleted_at IS NULL, name, NULL)) VIRTUAL"); r.Error != nil {
+			return r.Error
+		}
+	}
+
+	if !db.Migrator().HasIndex(&User{}, "idx_user_active_name_uniq") {
+		return db.Exec("CREATE UNIQUE INDEX idx_user_active_name_uniq ON users(active_name)").Error
+	}
+	return nil
 }
 
 type LoginOpt struct {
 	UserID     *uint
 	Credential *Credential
 	Tx         *gorm.DB
+	// AuthnContext describes how the user was authenticated. It is derived
+	// from Credential when left empty.
+	AuthnContext AuthnContext
+	// Restricted sessions may only register a new credential
+	Restricted bool
 }
 
 func (opt *LoginOpt) setUserID(userID *uint) error {
 	if opt.UserID != nil {
-		return opt.verifyUserID(userID)
+		return opt.verifyUserID(*opt.UserID, userID)
 	}
-	*userID = opt.Credential.UserID
-	return nil
+	return opt.verifyUserID(opt.Credential.UserID, userID)
 }
 
-func (opt *LoginOpt) verifyUserID(userID *uint) error {
-	r := opt.Tx.Model(&User{}).Where("id = ?", *opt.UserID).Limit(1).Pluck("id", userID)
+// verifyUserID fails for deleted and deactivated users.
+func (opt *LoginOpt) verifyUserID(id uint, userID *uint) error {
+	r := opt.Tx.Model(&User{}).Where("id = ? AND deactivated_at IS NULL", id).Limit(1).Pluck("id", userID)
 	if r.RowsAffected != 1 {
 		return gorm.ErrRecordNotFound
 	}
 	return nil
 }
 
+func (opt *LoginOpt) authnContext() AuthnContext {
+	ac := opt.AuthnContext
+	if ac.Acr == "" && opt.Credential != nil {
+		ac = AuthnContextFromCredential(opt.Credential.Data)
+	}
+
+	now := time.Now()
+	ac.AuthTime = &now
+	return ac
+}
+
 func LoginUser(ctx context.Context, opt LoginOpt) error {
 	if opt.UserID == nil && opt.Credential == nil {
 		return fmt.Errorf("either UserID or Credetial must be defined")
@@ -119,13 +203,18 @@ func LoginUser(ctx context.Context, opt LoginOpt) error {
 			return err
 		}
 
-		if r := tx.FirstOrCreate(&Session{
-			ID:     sessionmgr.FromContext(ctx).UUID,
-			UserID: userID,
-		}); r.Error != nil {
+		// Assign updates an existing session so that a step-up login
+		// replaces the authentication context of a weaker login
+		s := Session{ID: sessionmgr.FromContext(ctx).UUID}
+		if r := tx.Assign(Session{
+			UserID:       userID,
+			AuthnContext: opt.authnContext(),
+		}).FirstOrCreate(&s); r.Error != nil {
 			return r.Error
 		}
-		return nil
+
+		// Assign skips zero values which would keep a restriction
+		return tx.Model(&s).Update("restricted", opt.Restricted).Error
 	}); err != nil {
 		return err
 	}
@@ -133,7 +222,126 @@ func LoginUser(ctx context.Context, opt LoginOpt) error {
 	return nil
 }
 
+func (u *User) IsActive() bool {
+	return u.DeactivatedAt == nil
+}
+
+// Deactivate prevents further logins and ends all sessions of the user.
+func (u *User) Deactivate(tx *gorm.DB) error {
+	return tx.Transaction(func(tx *gorm.DB) error {
+		if u.DeactivatedAt == nil {
+			now := time.Now()
+			if r := tx.Model(u).Update("deactivated_at", &now); r.Error != nil {
+				return r.Error
+			}
+			u.DeactivatedAt = &now
+		}
+		return DeleteAllSessionsByUserID(tx, u.ID)
+	})
+}
+
+// IsApproved is false for users awaiting approval or rejected users. Users
+// without approval status were created before approvals existed.
+func (u *User) IsApproved() bool {
+	return u.ApprovalStatus != ApprovalPending && u.ApprovalStatus != ApprovalRejected
+}
+
+// RequireApproval keeps the user from logging in to clients until an admin
+// approves the user.
+func (u *User) RequireApproval(tx *gorm.DB) error {
+	return u.setApprovalStatus(tx, ApprovalPending)
+}
+
+func (u *User) Approve(tx *gorm.DB) error {
+	if u.ApprovalStatus == ApprovalRejected {
+		return fmt.Errorf("user was rejected")
+	}
+	return u.setApprovalStatus(tx, ApprovalApproved)
+}
+
+// Reject deactivates a pending user.
+func (u *User) Reject(tx *gorm.DB) error {
+	if u.ApprovalStatus != ApprovalPending {
+		return fmt.Errorf("user is not awaiting approval")
+	}
+
+	return tx.Transaction(func(tx *gorm.DB) error {
+		if err := u.setApprovalStatus(tx, ApprovalRejected); err != nil {
+			return err
+		}
+		return u.Deactivate(tx)
+	})
+}
+
+func (u *User) setApprovalStatus(tx *gorm.DB, status string) error {
+	if r := tx.Model(u).Update("approval_status", status); r.Error != nil {
+		return r.Error
+	}
+	u.ApprovalStatus = status
+	return nil
+}
+
+func PendingUsers(tx *gorm.DB) ([]User, error) {
+	users := []User{}
+	if r := tx.Where("approval_status = ?", ApprovalPending).Order("id").Find(&users); r.Error != nil {
+		return nil, r.Error
+	}
+	return users, nil
+}
+
+func (u *User) Activate(tx *gorm.DB) error {
+	if r := tx.Model(u).Update("deactivated_at", nil); r.Error != nil {
+		return r.Error
+	}
+	u.DeactivatedAt = nil
+	return nil
+}
+
+// DeleteUser soft-deletes the user and ends all sessions of the user.
+func DeleteUser(tx *gorm.DB, u *User) error {
+	return tx.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(u).Association("Groups").Clear(); err != nil {
+			return err
+		}
+		if err := tx.Model(u).Association("Roles").Clear(); err != nil {
+			return err
+		}
+		if r := tx.Unscoped().Where("user_id = ?", u.ID).Delete(&EmailAddress{}); r.Error != nil {
+			return r.Error
+		}
+		if err := DeleteAllSessionsByUserID(tx, u.ID); err != nil {
+			return err
+		}
+		return tx.Delete(u).Error
+	})
+}
+
+// EraseUser deletes the user like DeleteUser and erases the personal data
+// of the soft-deleted row so that only its ID remains for references.
+func EraseUser(tx *gorm.DB, u *User) error {
+	return tx.Transaction(func(tx *gorm.DB) error {
+		if err := DeleteUser(tx, u); err != nil {
+			return err
+		}
+
+		return tx.Unscoped().Model(&User{}).Where("id = ?", u.ID).Updates(map[string]any{
+			"authn_id":     nil,
+			"name":         "",
+			"display_name": "",
+			"external_id":  "",
+			"picture":      "",
+			"locale":       "",
+			"zoneinfo":     "",
+			"attributes":   nil,
+		}).Error
+	})
+}
+
 func (u *User) BeforeCreate(tx *gorm.DB) error {
+	if u.ApprovalStatus == "" {
+		u.ApprovalStatus = ApprovalApproved
+	}
+
 	if len(u.AuthnID) > 0 {
 		return nil
 	}
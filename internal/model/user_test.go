@@ -1,6 +1,9 @@
 package model
 
 import (
+	"fmt"
+	"testing"
+
 	"github.com/seb-schulz/onegate/internal/database"
 	"gorm.io/gorm"
 )
@@ -36,3 +39,37 @@ func openDb() *gorm.DB {
 // 	}
 
 // }
+
+func TestUserIsApproved(t *testing.T) {
+	for _, tc := range []struct {
+		status   string
+		expected bool
+	}{
+		{"", true},
+		{ApprovalApproved, true},
+		{ApprovalPending, false},
+		{ApprovalRejected, false},
+	} {
+		user := User{ApprovalStatus: tc.status}
+		if actual := user.IsApproved(); actual != tc.expected {
+			t.Errorf("%#v: expected %v but got %v", tc.status, tc.expected, actual)
+		}
+	}
+}
+
+func TestUniqueName(t *testing.T) {
+	other := fmt.Errorf("database down")
+	for _, tc := range []struct {
+		err      error
+		expected error
+	}{
+		{nil, nil},
+		{gorm.ErrDuplicatedKey, ErrNameTaken},
+		{fmt.Errorf("cannot save: %w", gorm.ErrDuplicatedKey), ErrNameTaken},
+		{other, other},
+	} {
+		if actual := uniqueName(tc.err); actual != tc.expected {
+			t.Errorf("%v: expected %v but got %v", tc.err, tc.expected, actual)
+		}
+	}
+}
@@ -0,0 +1,155 @@
+// Package pow issues proof-of-work challenges which clients must solve
+// before expensive or abusable operations like registrations begin.
+//
+// Challenges are signed instead of stored. Only solved challenges are stored
+// until they expire so that each challenge can be used once.
+package pow
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/bits"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/seb-schulz/onegate/internal/database"
+	"gorm.io/gorm"
+)
+
+// maxSolutionLength limits the input hashed per verification
+const maxSolutionLength = 64
+
+// domain separates signatures of challenges from other MACs of the same key
+const domain = "proof-of-work:"
+
+type (
+	Config struct {
+		Key []byte
+		// Difficulty is the number of leading zero bits of the SHA-256
+		// hash of a solution. Proofs are not required when zero.
+		Difficulty int
+		ExpiresIn  time.Duration
+	}
+
+	Challenge struct {
+		Challenge  string
+		Difficulty int
+	}
+
+	// SolvedChallenge prevents that a challenge is used more than once
+	SolvedChallenge struct {
+		ID        string    `gorm:"type:VARCHAR(32);primaryKey"`
+		ExpiresAt time.Time `gorm:"index;not null"`
+	}
+)
+
+// Enabled reports whether proofs are required.
+func (c Config) Enabled() bool {
+	return c.Difficulty > 0
+}
+
+// NewChallenge returns a signed challenge consisting of a random nonce, the
+// expiry and the difficulty.
+func (c Config) NewChallenge() (*Challenge, error) {
+	nonce := make([]byte, 16)
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+
+	payload := fmt.Sprintf("%s.%d.%d", hex.EncodeToString(nonce), time.Now().Add(c.ExpiresIn).Unix(), c.Difficulty)
+	return &Challenge{
+		Challenge:  payload + "." + c.sign(payload),
+		Difficulty: c.Difficulty,
+	}, nil
+}
+
+// Verify checks the solution of the challenge and marks the challenge as
+// used.
+func (c Config) Verify(tx *gorm.DB, challenge, solution string) error {
+	nonce, expiresAt, err := c.check(challenge, solution, time.Now())
+	if err != nil {
+		return err
+	}
+
+	return tx.Transaction(func(tx *gorm.DB) error {
+		if r := tx.Where("expires_at < ?", time.Now()).Delete(&SolvedChallenge{}); r.Error != nil {
+			return r.Error
+		}
+
+		if err := database.CreateOnce(tx, &SolvedChallenge{ID: nonce, ExpiresAt: expiresAt}); errors.Is(err, database.ErrAlreadyUsed) {
+			return fmt.Errorf("challenge already used")
+		} else if err != nil {
+			return fmt.Errorf("cannot use challenge: %v", err)
+		}
+		return nil
+	})
+}
+
+// check verifies signature, expiry and solution of the challenge without
+// touching the database. It returns the nonce and expiry of the challenge.
+func (c Config) check(challenge, solution string, now time.Time) (string, time.Time, error) {
+	parts := strings.Split(challenge, ".")
+	if len(parts) != 4 {
+		return "", time.Time{}, fmt.Errorf("malformed challenge")
+	}
+
+	payload := strings.Join(parts[:3], ".")
+	if !hmac.Equal([]byte(parts[3]), []byte(c.sign(payload))) {
+		return "", time.Time{}, fmt.Errorf("invalid challenge")
+	}
+
+	expires, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("malformed challenge")
+	}
+	expiresAt := time.Unix(expires, 0)
+	if now.After(expiresAt) {
+		return "", time.Time{}, fmt.Errorf("challenge expired")
+	}
+
+	// Challenges issued with a lower difficulty are rejected after the
+	// difficulty was raised
+	difficulty, err := strconv.Atoi(parts[2])
+	if err != nil || difficulty < c.Difficulty {
+		return "", time.Time{}, fmt.Errorf("invalid challenge")
+	}
+
+	if len(solution) == 0 || len(solution) > maxSolutionLength {
+		return "", time.Time{}, fmt.Errorf("invalid solution")
+	}
+
+	if LeadingZeroBits(Hash(challenge, solution)) < difficulty {
+		return "", time.Time{}, fmt.Errorf("invalid solution")
+	}
+	return parts[0], expiresAt, nil
+}
+
+func (c Config) sign(payload string) string {
+	mac := hmac.New(sha256.New, c.Key)
+	mac.Write([]byte(domain + payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// Hash returns the hash which must have enough leading zero bits.
+func Hash(challenge, solution string) []byte {
+	sum := sha256.Sum256([]byte(challenge + ":" + solution))
+	return sum[:]
+}
+
+// LeadingZeroBits counts the zero bits before the first set bit.
+func LeadingZeroBits(b []byte) int {
+	n := 0
+	for _, v := range b {
+		if v != 0 {
+			return n + bits.LeadingZeros8(v)
+		}
+		n += 8
+	}
+	return n
+}
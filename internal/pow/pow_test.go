@@ -0,0 +1,96 @@
+package pow
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func solve(challenge string, difficulty int) string {
+	for i := 0; ; i++ {
+		s := strconv.Itoa(i)
+		if LeadingZeroBits(Hash(challenge, s)) >= difficulty {
+			return s
+		}
+	}
+}
+
+func TestLeadingZeroBits(t *testing.T) {
+	for _, tc := range []struct {
+		in       []byte
+		expected int
+	}{
+		{[]byte{0x80}, 0},
+		{[]byte{0x01}, 7},
+		{[]byte{0x00, 0x40}, 9},
+		{[]byte{0x00, 0x00}, 16},
+	} {
+		if got := LeadingZeroBits(tc.in); got != tc.expected {
+			t.Errorf("expected %v for %x but got %v", tc.expected, tc.in, got)
+		}
+	}
+}
+
+func TestCheck(t *testing.T) {
+	c := Config{Key: []byte("secret"), Difficulty: 8, ExpiresIn: time.Minute}
+
+	challenge, err := c.NewChallenge()
+	if err != nil {
+		t.Fatalf("cannot create challenge: %v", err)
+	}
+	if challenge.Difficulty != c.Difficulty {
+		t.Errorf("expected difficulty %v but got %v", c.Difficulty, challenge.Difficulty)
+	}
+
+	solution := solve(challenge.Challenge, challenge.Difficulty)
+	nonce, expiresAt, err := c.check(challenge.Challenge, solution, time.Now())
+	if err != nil {
+		t.Fatalf("expected valid solution: %v", err)
+	}
+	if !strings.HasPrefix(challenge.Challenge, nonce+".") || time.Until(expiresAt) > time.Minute {
+		t.Errorf("unexpected nonce %#v or expiry %v", nonce, expiresAt)
+	}
+
+	for name, fn := range map[string]func() error{
+		"wrong solution": func() error {
+			for i := 0; ; i++ {
+				s := strconv.Itoa(i)
+				if LeadingZeroBits(Hash(challenge.Challenge, s)) < c.Difficulty {
+					_, _, err := c.check(challenge.Challenge, s, time.Now())
+					return err
+				}
+			}
+		},
+		"expired": func() error {
+			_, _, err := c.check(challenge.Challenge, solution, time.Now().Add(2*time.Minute))
+			return err
+		},
+		"other key": func() error {
+			_, _, err := Config{Key: []byte("other"), Difficulty: 8}.check(challenge.Challenge, solution, time.Now())
+			return err
+		},
+		"raised difficulty": func() error {
+			_, _, err := Config{Key: c.Key, Difficulty: 16}.check(challenge.Challenge, solution, time.Now())
+			return err
+		},
+		"lowered difficulty": func() error {
+			parts := strings.Split(challenge.Challenge, ".")
+			parts[2] = "0"
+			_, _, err := c.check(strings.Join(parts, "."), solution, time.Now())
+			return err
+		},
+		"malformed": func() error {
+			_, _, err := c.check("abc", solution, time.Now())
+			return err
+		},
+		"long solution": func() error {
+			_, _, err := c.check(challenge.Challenge, strings.Repeat("0", maxSolutionLength+1), time.Now())
+			return err
+		},
+	} {
+		if err := fn(); err == nil {
+			t.Errorf("expected error for %v", name)
+		}
+	}
+}
@@ -0,0 +1,92 @@
+package proxy
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// cookieCodec encrypts values of cookies with AES-GCM. The cookie name is
+// authenticated as well so that values cannot be moved between cookies.
+type cookieCodec struct {
+	aead   cipher.AEAD
+	domain string
+}
+
+func newCookieCodec(key []byte, domain string) (*cookieCodec, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cookie key: %v", err)
+	}
+
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	return &cookieCodec{aead, domain}, nil
+}
+
+func (cc *cookieCodec) set(w http.ResponseWriter, name string, v any, expiresAt time.Time) error {
+	plaintext, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	nonce := make([]byte, cc.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return err
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     name,
+		Value:    base64.RawURLEncoding.EncodeToString(cc.aead.Seal(nonce, nonce, plaintext, []byte(name))),
+		Domain:   cc.domain,
+		Path:     "/",
+		Expires:  expiresAt,
+		Secure:   true,
+		HttpOnly: true,
+		// Lax to receive the state cookie with the callback from onegate
+		SameSite: http.SameSiteLaxMode,
+	})
+	return nil
+}
+
+func (cc *cookieCodec) get(r *http.Request, name string, v any) error {
+	cookie, err := r.Cookie(name)
+	if err != nil {
+		return err
+	}
+
+	raw, err := base64.RawURLEncoding.DecodeString(cookie.Value)
+	if err != nil {
+		return err
+	}
+
+	if len(raw) < cc.aead.NonceSize() {
+		return fmt.Errorf("cookie too short")
+	}
+
+	plaintext, err := cc.aead.Open(nil, raw[:cc.aead.NonceSize()], raw[cc.aead.NonceSize():], []byte(name))
+	if err != nil {
+		return fmt.Errorf("cannot decrypt cookie: %v", err)
+	}
+
+	return json.Unmarshal(plaintext, v)
+}
+
+func (cc *cookieCodec) clear(w http.ResponseWriter, name string) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     name,
+		Domain:   cc.domain,
+		Path:     "/",
+		MaxAge:   -1,
+		Secure:   true,
+		HttpOnly: true,
+	})
+}
@@ -0,0 +1,307 @@
+package proxy
+
+import (
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"log"
+	"log/slog"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/seb-schulz/onegate/internal/auth"
+	"golang.org/x/oauth2"
+)
+
+const (
+	SignOutPath = "/_onegate/sign_out"
+
+	stateExpiresIn = 10 * time.Minute
+)
+
+type (
+	// Upstream serves requests of a host. Upstreams without host serve
+	// requests of any host.
+	Upstream struct {
+		Host string
+		URL  url.URL
+	}
+
+	Config struct {
+		HttpPort string
+		// IssuerUrl is the base URL of onegate
+		IssuerUrl    url.URL
+		PublicKey    *ecdsa.PublicKey
+		ClientID     string
+		ClientSecret string
+		// RedirectUrl is registered for the client and served by the proxy
+		RedirectUrl     url.URL
+		CookieName      string
+		CookieKey       []byte
+		CookieDomain    string
+		SessionLifetime time.Duration
+		Upstreams       []Upstream
+	}
+
+	// session of a user who logged in to onegate. Upstreams identify users by
+	// subject because names can be changed by users.
+	session struct {
+		Subject   string    `json:"sub"`
+		Name      string    `json:"name"`
+		ExpiresAt time.Time `json:"exp"`
+	}
+
+	// loginState is kept while the user logs in to onegate
+	loginState struct {
+		State    string `json:"state"`
+		Verifier string `json:"verifier"`
+		Target   string `json:"target"`
+	}
+
+	upstreamProxy struct {
+		Upstream
+		proxy *httputil.ReverseProxy
+	}
+
+	proxyHandler struct {
+		oauth2Config    oauth2.Config
+		issuer          string
+		publicKey       *ecdsa.PublicKey
+		cookies         *cookieCodec
+		cookieName      string
+		callbackPath    string
+		sessionLifetime time.Duration
+		upstreams       []upstreamProxy
+		exchange        func(r *http.Request, code, verifier string) (*oauth2.Token, error)
+	}
+)
+
+func New(c *Config) (http.Handler, error) {
+	if c.ClientID == "" || c.ClientSecret == "" {
+		return nil, fmt.Errorf("client ID and client secret are required")
+	}
+
+	if c.PublicKey == nil {
+		return nil, fmt.Errorf("public key of onegate is required")
+	}
+
+	if len(c.Upstreams) == 0 {
+		return nil, fmt.Errorf("at least one upstream is required")
+	}
+
+	cookies, err := newCookieCodec(c.CookieKey, c.CookieDomain)
+	if err != nil {
+		return nil, err
+	}
+
+	ph := &proxyHandler{
+		oauth2Config: oauth2.Config{
+			ClientID:     c.ClientID,
+			ClientSecret: c.ClientSecret,
+			Endpoint: oauth2.Endpoint{
+				AuthURL:   c.IssuerUrl.JoinPath("auth", "auth").String(),
+				TokenURL:  c.IssuerUrl.JoinPath("auth", "token").String(),
+				AuthStyle: oauth2.AuthStyleInHeader,
+			},
+			RedirectURL: c.RedirectUrl.String(),
+			Scopes:      []string{"openid", "profile"},
+		},
+		issuer:          c.IssuerUrl.String(),
+		publicKey:       c.PublicKey,
+		cookies:         cookies,
+		cookieName:      c.CookieName,
+		callbackPath:    c.RedirectUrl.Path,
+		sessionLifetime: c.SessionLifetime,
+	}
+	ph.exchange = func(r *http.Request, code, verifier string) (*oauth2.Token, error) {
+		return ph.oauth2Config.Exchange(r.Context(), code, oauth2.VerifierOption(verifier))
+	}
+
+	for _, u := range c.Upstreams {
+		target := u.URL
+		ph.upstreams = append(ph.upstreams, upstreamProxy{u, &httputil.ReverseProxy{
+			Rewrite: func(pr *httputil.ProxyRequest) {
+				pr.SetURL(&target)
+				pr.SetXForwarded()
+				pr.Out.Host = pr.In.Host
+			},
+		}})
+	}
+
+	return ph, nil
+}
+
+func (ph *proxyHandler) upstream(host string) *upstreamProxy {
+	host, _, _ = strings.Cut(host, ":")
+	for i, u := range ph.upstreams {
+		if u.Host == "" || u.Host == host {
+			return &ph.upstreams[i]
+		}
+	}
+	return nil
+}
+
+func (ph *proxyHandler) stateCookieName() string {
+	return ph.cookieName + "_state"
+}
+
+func (ph *proxyHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.URL.Path {
+	case ph.callbackPath:
+		ph.callback(w, r)
+		return
+	case SignOutPath:
+		ph.cookies.clear(w, ph.cookieName)
+		http.Redirect(w, r, ph.issuer, http.StatusFound)
+		return
+	}
+
+	upstream := ph.upstream(r.Host)
+	if upstream == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	s := session{}
+	if err := ph.cookies.get(r, ph.cookieName, &s); err != nil || time.Now().After(s.ExpiresAt) {
+		ph.login(w, r)
+		return
+	}
+
+	// Identity headers must only originate from the proxy
+	r.Header.Del(auth.ForwardAuthUserHeader)
+	r.Header.Del(auth.ForwardAuthNameHeader)
+	r.Header.Set(auth.ForwardAuthUserHeader, s.Subject)
+	r.Header.Set(auth.ForwardAuthNameHeader, s.Name)
+	upstream.proxy.ServeHTTP(w, r)
+}
+
+// login starts the authorization code flow with PKCE. Other requests than
+// navigations cannot follow the redirect and fail instead.
+func (ph *proxyHandler) login(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return
+	}
+
+	state := make([]byte, 16)
+	if _, err := rand.Read(state); err != nil {
+		panic("cannot generate state")
+	}
+
+	target := url.URL{Scheme: "https", Host: r.Host, Path: r.URL.Path, RawQuery: r.URL.RawQuery}
+	if r.TLS == nil && r.Header.Get("X-Forwarded-Proto") == "http" {
+		target.Scheme = "http"
+	}
+
+	ls := loginState{
+		State:    base64.RawURLEncoding.EncodeToString(state),
+		Verifier: oauth2.GenerateVerifier(),
+		Target:   target.String(),
+	}
+	if err := ph.cookies.set(w, ph.stateCookieName(), ls, time.Now().Add(stateExpiresIn)); err != nil {
+		slog.Error(fmt.Sprintf("cannot set state cookie: %v", err))
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	http.Redirect(w, r, ph.oauth2Config.AuthCodeURL(ls.State, oauth2.S256ChallengeOption(ls.Verifier)), http.StatusFound)
+}
+
+func (ph *proxyHandler) callback(w http.ResponseWriter, r *http.Request) {
+	ls := loginState{}
+	if err := ph.cookies.get(r, ph.stateCookieName(), &ls); err != nil {
+		log.Printf("missing state of login: %v", err)
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+	ph.cookies.clear(w, ph.stateCookieName())
+
+	if subtle.ConstantTimeCompare([]byte(ls.State), []byte(r.URL.Query().Get("state"))) != 1 {
+		log.Printf("state of login does not match")
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	if e := r.URL.Query().Get("error"); e != "" {
+		log.Printf("login failed with: %v", e)
+		http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+		return
+	}
+
+	token, err := ph.exchange(r, r.URL.Query().Get("code"), ls.Verifier)
+	if err != nil {
+		log.Printf("cannot exchange code: %v", err)
+		http.Error(w, http.StatusText(http.StatusBadGateway), http.StatusBadGateway)
+		return
+	}
+
+	rawIDToken, _ := token.Extra("id_token").(string)
+	claims, err := ph.parseIDToken(rawIDToken)
+	if err != nil {
+		log.Printf("invalid ID token: %v", err)
+		http.Error(w, http.StatusText(http.StatusBadGateway), http.StatusBadGateway)
+		return
+	}
+
+	s := session{
+		Subject:   claims.Subject,
+		Name:      claims.Name,
+		ExpiresAt: time.Now().Add(ph.sessionLifetime),
+	}
+	if err := ph.cookies.set(w, ph.cookieName, s, s.ExpiresAt); err != nil {
+		slog.Error(fmt.Sprintf("cannot set session cookie: %v", err))
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	http.Redirect(w, r, ph.redirectTarget(ls.Target), http.StatusFound)
+}
+
+// redirectTarget returns the URL requested before login if it belongs to an
+// upstream and the root of the callback host otherwise.
+func (ph *proxyHandler) redirectTarget(target string) string {
+	u, err := url.Parse(target)
+	if err != nil || ph.upstream(u.Host) == nil {
+		return "/"
+	}
+	return u.String()
+}
+
+func (ph *proxyHandler) parseIDToken(s string) (*auth.IdTokenClaims, error) {
+	if s == "" {
+		return nil, fmt.Errorf("missing ID token")
+	}
+
+	token, err := jwt.ParseWithClaims(s, &auth.IdTokenClaims{}, func(token *jwt.Token) (interface{}, error) {
+		return ph.publicKey, nil
+	}, jwt.WithValidMethods([]string{"ES256"}), jwt.WithIssuer(ph.issuer), jwt.WithAudience(ph.oauth2Config.ClientID), jwt.WithExpirationRequired(), jwt.WithLeeway(30*time.Second))
+	if err != nil {
+		return nil, err
+	}
+
+	return token.Claims.(*auth.IdTokenClaims), nil
+}
+
+func Serve(c *Config) error {
+	h, err := New(c)
+	if err != nil {
+		return err
+	}
+
+	if c.HttpPort == "" {
+		return fmt.Errorf("http port not defined")
+	}
+
+	log.Println("Proxy listening on port ", c.HttpPort)
+	if err := http.ListenAndServe(":"+c.HttpPort, h); err != nil {
+		return fmt.Errorf("cannot run proxy: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,211 @@
+package proxy
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/seb-schulz/onegate/internal/auth"
+	"golang.org/x/oauth2"
+)
+
+func TestCookieCodec(t *testing.T) {
+	cc, err := newCookieCodec(make([]byte, 32), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rr := httptest.NewRecorder()
+	if err := cc.set(rr, "session", session{Subject: "jdoe"}, time.Now().Add(time.Hour)); err != nil {
+		t.Fatalf("cannot set cookie: %v", err)
+	}
+	value := rr.Result().Cookies()[0].Value
+
+	for _, tc := range []struct {
+		name  string
+		value string
+		valid bool
+	}{
+		{"session", value, true},
+		{"other", value, false},
+		{"session", value[:len(value)-2] + "AA", false},
+		{"session", "", false},
+	} {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.AddCookie(&http.Cookie{Name: tc.name, Value: tc.value})
+
+		s := session{}
+		if err := cc.get(r, "session", &s); (err == nil) != tc.valid {
+			t.Errorf("%v=%#v: expected valid=%v but got: %v", tc.name, tc.value, tc.valid, err)
+		} else if tc.valid && s.Subject != "jdoe" {
+			t.Errorf("unexpected session %#v", s)
+		}
+	}
+
+	if _, err := newCookieCodec([]byte("short"), ""); err == nil {
+		t.Errorf("expected invalid key to be rejected")
+	}
+}
+
+func newTestProxy(t *testing.T, upstream *httptest.Server) (*proxyHandler, *ecdsa.PrivateKey) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	upstreamUrl, _ := url.Parse(upstream.URL)
+	h, err := New(&Config{
+		IssuerUrl:       url.URL{Scheme: "https", Host: "onegate.example.com"},
+		PublicKey:       &key.PublicKey,
+		ClientID:        "1",
+		ClientSecret:    "secret",
+		RedirectUrl:     url.URL{Scheme: "https", Host: "app.example.com", Path: "/_onegate/callback"},
+		CookieName:      "_onegate_proxy",
+		CookieKey:       make([]byte, 32),
+		SessionLifetime: time.Hour,
+		Upstreams:       []Upstream{{Host: "app.example.com", URL: *upstreamUrl}},
+	})
+	if err != nil {
+		t.Fatalf("cannot create proxy: %v", err)
+	}
+	return h.(*proxyHandler), key
+}
+
+func TestProxyHandler(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Header.Get(auth.ForwardAuthUserHeader) + "|" + r.Header.Get(auth.ForwardAuthNameHeader) + "|" + r.URL.RequestURI()))
+	}))
+	defer upstream.Close()
+
+	ph, key := newTestProxy(t, upstream)
+
+	issueIDToken := func(aud string) string {
+		s, err := jwt.NewWithClaims(jwt.SigningMethodES256, auth.IdTokenClaims{
+			RegisteredClaims: jwt.RegisteredClaims{
+				Issuer:    "https://onegate.example.com",
+				Subject:   "sub",
+				Audience:  jwt.ClaimStrings{aud},
+				ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Minute)),
+			},
+			Name:              "John Doe",
+			PreferredUsername: "jdoe",
+		}).SignedString(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return s
+	}
+
+	audience := "1"
+	ph.exchange = func(r *http.Request, code, verifier string) (*oauth2.Token, error) {
+		if code != "code" || verifier == "" {
+			t.Errorf("unexpected code %#v or verifier %#v", code, verifier)
+		}
+		return (&oauth2.Token{AccessToken: "token"}).WithExtra(map[string]any{"id_token": issueIDToken(audience)}), nil
+	}
+
+	serve := func(r *http.Request, cookies ...*http.Cookie) *httptest.ResponseRecorder {
+		for _, c := range cookies {
+			r.AddCookie(c)
+		}
+		rr := httptest.NewRecorder()
+		ph.ServeHTTP(rr, r)
+		return rr
+	}
+
+	login := func(t *testing.T) (*http.Cookie, string) {
+		rr := serve(httptest.NewRequest(http.MethodGet, "https://app.example.com/page?q=1", nil))
+		if rr.Code != http.StatusFound {
+			t.Fatalf("expected redirect to onegate but got %v", rr.Code)
+		}
+
+		loc, _ := url.Parse(rr.Header().Get("Location"))
+		if loc.Host != "onegate.example.com" || loc.Path != "/auth/auth" || loc.Query().Get("code_challenge_method") != "S256" || loc.Query().Get("scope") != "openid profile" {
+			t.Fatalf("unexpected authorization request %v", loc)
+		}
+		return rr.Result().Cookies()[0], loc.Query().Get("state")
+	}
+
+	t.Run("unknown host", func(t *testing.T) {
+		if rr := serve(httptest.NewRequest(http.MethodGet, "https://other.example.com/", nil)); rr.Code != http.StatusNotFound {
+			t.Errorf("expected status 404 but got %v", rr.Code)
+		}
+	})
+
+	t.Run("unauthenticated post", func(t *testing.T) {
+		if rr := serve(httptest.NewRequest(http.MethodPost, "https://app.example.com/", nil)); rr.Code != http.StatusUnauthorized {
+			t.Errorf("expected status 401 but got %v", rr.Code)
+		}
+	})
+
+	t.Run("state mismatch", func(t *testing.T) {
+		stateCookie, _ := login(t)
+		if rr := serve(httptest.NewRequest(http.MethodGet, "https://app.example.com/_onegate/callback?code=code&state=other", nil), stateCookie); rr.Code != http.StatusBadRequest {
+			t.Errorf("expected status 400 but got %v", rr.Code)
+		}
+	})
+
+	t.Run("wrong audience", func(t *testing.T) {
+		audience = "2"
+		defer func() { audience = "1" }()
+
+		stateCookie, state := login(t)
+		if rr := serve(httptest.NewRequest(http.MethodGet, "https://app.example.com/_onegate/callback?code=code&state="+state, nil), stateCookie); rr.Code != http.StatusBadGateway {
+			t.Errorf("expected status 502 but got %v", rr.Code)
+		}
+	})
+
+	t.Run("login", func(t *testing.T) {
+		stateCookie, state := login(t)
+
+		rr := serve(httptest.NewRequest(http.MethodGet, "https://app.example.com/_onegate/callback?code=code&state="+state, nil), stateCookie)
+		if rr.Code != http.StatusFound || rr.Header().Get("Location") != "https://app.example.com/page?q=1" {
+			t.Fatalf("expected redirect to requested page but got %v: %v", rr.Code, rr.Header().Get("Location"))
+		}
+
+		var sessionCookie *http.Cookie
+		for _, c := range rr.Result().Cookies() {
+			if c.Name == "_onegate_proxy" {
+				sessionCookie = c
+			}
+		}
+		if sessionCookie == nil {
+			t.Fatalf("missing session cookie")
+		}
+
+		r := httptest.NewRequest(http.MethodGet, "https://app.example.com/page?q=1", nil)
+		r.Header.Set(auth.ForwardAuthUserHeader, "admin")
+		rr = serve(r, sessionCookie)
+		if rr.Code != http.StatusOK || rr.Body.String() != "sub|John Doe|/page?q=1" {
+			t.Errorf("unexpected upstream response %v: %v", rr.Code, rr.Body.String())
+		}
+	})
+
+	t.Run("sign out", func(t *testing.T) {
+		rr := serve(httptest.NewRequest(http.MethodGet, "https://app.example.com"+SignOutPath, nil))
+		if rr.Code != http.StatusFound || !strings.Contains(rr.Header().Get("Set-Cookie"), "Max-Age=0") {
+			t.Errorf("expected session cookie to be cleared but got %v: %v", rr.Code, rr.Header())
+		}
+	})
+}
+
+func TestProxyHandler_redirectTarget(t *testing.T) {
+	ph := &proxyHandler{upstreams: []upstreamProxy{{Upstream: Upstream{Host: "app.example.com"}}}}
+
+	for target, expected := range map[string]string{
+		"https://app.example.com/page":  "https://app.example.com/page",
+		"https://evil.example.com/page": "/",
+		"%zz":                           "/",
+	} {
+		if got := ph.redirectTarget(target); got != expected {
+			t.Errorf("expected %#v for %#v but got %#v", expected, target, got)
+		}
+	}
+}
@@ -0,0 +1,136 @@
+package recovery
+
+import (
+	"crypto/rand"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/seb-schulz/onegate/internal/auth"
+	"gorm.io/gorm"
+)
+
+const (
+	// CodeCount is the number of codes generated at once
+	CodeCount = 10
+	// selectorLength is the length of the prefix of a code which finds its
+	// hash without checking all hashes
+	selectorLength = 5
+	codeLength     = 20
+	alphabet       = "ABCDEFGHIJKLMNOPQRSTUVWXYZ234567"
+)
+
+// Code lets a user log in once with a restricted session to register a new
+// credential.
+type Code struct {
+	ID        uint `gorm:"primarykey"`
+	CreatedAt time.Time
+	UserID    uint   `gorm:"not null;index"`
+	Selector  string `gorm:"type:VARCHAR(8);not null;index"`
+	// Hash of the code in PHC string format
+	Hash string `gorm:"type:VARCHAR(255);not null"`
+}
+
+func (Code) TableName() string {
+	return "recovery_codes"
+}
+
+// Generate replaces all codes of the user with new ones. The codes are
+// returned once and only their hashes are stored.
+func Generate(tx *gorm.DB, newHasher func() auth.ClientSecretHasher, userID uint) ([]string, error) {
+	codes := make([]string, CodeCount)
+	records := make([]Code, CodeCount)
+	for i := range codes {
+		code, err := randomCode()
+		if err != nil {
+			return nil, err
+		}
+		codes[i] = format(code)
+		records[i] = Code{UserID: userID, Selector: code[:selectorLength], Hash: auth.SecretHash(newHasher(), []byte(code))}
+	}
+
+	if err := tx.Transaction(func(tx *gorm.DB) error {
+		if r := tx.Where("user_id = ?", userID).Delete(&Code{}); r.Error != nil {
+			return r.Error
+		}
+		return tx.Create(&records).Error
+	}); err != nil {
+		return nil, fmt.Errorf("cannot store recovery codes: %v", err)
+	}
+	return codes, nil
+}
+
+// Redeem deletes the matching code and returns the ID of its user.
+func Redeem(tx *gorm.DB, code string) (uint, error) {
+	code = normalize(code)
+	if len(code) != codeLength {
+		return 0, fmt.Errorf("invalid recovery code")
+	}
+
+	var candidates []Code
+	if r := tx.Where("selector = ?", code[:selectorLength]).Find(&candidates); r.Error != nil {
+		return 0, r.Error
+	}
+
+	for _, c := range candidates {
+		if auth.VerifySecretHash(c.Hash, []byte(code)) != nil {
+			continue
+		}
+
+		// Concurrent redemptions delete the code only once
+		r := tx.Delete(&c)
+		if r.Error != nil {
+			return 0, r.Error
+		}
+		if r.RowsAffected != 1 {
+			break
+		}
+		return c.UserID, nil
+	}
+	return 0, fmt.Errorf("invalid recovery code")
+}
+
+// Count returns the number of unused codes of the user.
+func Count(tx *gorm.DB, userID uint) (int64, error) {
+	var count int64
+	r := tx.Model(&Code{}).Where("user_id = ?", userID).Count(&count)
+	return count, r.Error
+}
+
+// Delete removes all codes of the user.
+func Delete(tx *gorm.DB, userID uint) error {
+	return tx.Where("user_id = ?", userID).Delete(&Code{}).Error
+}
+
+func randomCode() (string, error) {
+	b := make([]byte, codeLength)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	// The alphabet has 32 letters so that the modulo is not biased
+	for i := range b {
+		b[i] = alphabet[int(b[i])%len(alphabet)]
+	}
+	return string(b), nil
+}
+
+// format groups the code for readability.
+func format(code string) string {
+	groups := []string{}
+	for i := 0; i < len(code); i += selectorLength {
+		groups = append(groups, code[i:i+selectorLength])
+	}
+	return strings.Join(groups, "-")
+}
+
+// normalize accepts codes typed in lower case and without or with other
+// separators.
+func normalize(code string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '-' || r == ' ' {
+			return -1
+		}
+		return r
+	}, strings.ToUpper(code))
+}
@@ -0,0 +1,78 @@
+package recovery
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/seb-schulz/onegate/internal/auth"
+	"github.com/seb-schulz/onegate/internal/database"
+	"github.com/seb-schulz/onegate/internal/model"
+)
+
+func TestFormatAndNormalize(t *testing.T) {
+	code, err := randomCode()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(code) != codeLength || strings.Trim(code, alphabet) != "" {
+		t.Errorf("unexpected code %#v", code)
+	}
+
+	formatted := format(code)
+	if len(formatted) != codeLength+codeLength/selectorLength-1 {
+		t.Errorf("unexpected formatted code %#v", formatted)
+	}
+
+	for _, input := range []string{formatted, code, strings.ToLower(formatted), strings.ReplaceAll(formatted, "-", " ")} {
+		if actual := normalize(input); actual != code {
+			t.Errorf("expected %#v for %#v but got %#v", code, input, actual)
+		}
+	}
+}
+
+func TestGenerateAndRedeem(t *testing.T) {
+	db, err := database.Open()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tx := db.Begin()
+	defer tx.Rollback()
+
+	user := model.User{Name: "jdoe"}
+	if r := tx.Create(&user); r.Error != nil {
+		t.Fatal(r.Error)
+	}
+
+	codes, err := Generate(tx, auth.NewClientSecretHasher, user.ID)
+	if err != nil {
+		t.Fatalf("cannot generate codes: %v", err)
+	}
+	if len(codes) != CodeCount {
+		t.Errorf("expected %v codes but got %v", CodeCount, len(codes))
+	}
+
+	if _, err := Redeem(tx, codes[0]+"A"); err == nil {
+		t.Errorf("expected error because of invalid code")
+	}
+
+	userID, err := Redeem(tx, strings.ToLower(codes[0]))
+	if err != nil || userID != user.ID {
+		t.Fatalf("expected user %v but got %v: %v", user.ID, userID, err)
+	}
+
+	if _, err := Redeem(tx, codes[0]); err == nil {
+		t.Errorf("expected error because code was used")
+	}
+
+	if count, _ := Count(tx, user.ID); count != CodeCount-1 {
+		t.Errorf("expected %v remaining codes but got %v", CodeCount-1, count)
+	}
+
+	if _, err := Generate(tx, auth.NewClientSecretHasher, user.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Redeem(tx, codes[1]); err == nil {
+		t.Errorf("expected error because codes were regenerated")
+	}
+}
@@ -0,0 +1,316 @@
+package scim
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// column of an attribute which can be filtered. Boolean attributes like
+// "active" are stored as nullable column and are true if it is null.
+type column struct {
+	name   string
+	isNull bool
+}
+
+// filterExpr is a parsed filter (see RFC 7644, section 3.4.2.2) which is
+// translated to a SQL condition.
+type filterExpr interface {
+	sql(columns map[string]column) (string, []any, error)
+}
+
+type logicalExpr struct {
+	op          string
+	left, right filterExpr
+}
+
+type notExpr struct {
+	expr filterExpr
+}
+
+type compareExpr struct {
+	attr  string
+	op    string
+	value any
+}
+
+func (e logicalExpr) sql(columns map[string]column) (string, []any, error) {
+	l, largs, err := e.left.sql(columns)
+	if err != nil {
+		return "", nil, err
+	}
+
+	r, rargs, err := e.right.sql(columns)
+	if err != nil {
+		return "", nil, err
+	}
+	return fmt.Sprintf("(%s %s %s)", l, strings.ToUpper(e.op), r), append(largs, rargs...), nil
+}
+
+func (e notExpr) sql(columns map[string]column) (string, []any, error) {
+	s, args, err := e.expr.sql(columns)
+	if err != nil {
+		return "", nil, err
+	}
+	return fmt.Sprintf("NOT %s", s), args, nil
+}
+
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
+
+func (e compareExpr) sql(columns map[string]column) (string, []any, error) {
+	col, ok := columns[e.attr]
+	if !ok {
+		return "", nil, errInvalidFilter("unsupported attribute %#v", e.attr)
+	}
+
+	if col.isNull {
+		b, ok := e.value.(bool)
+		switch {
+		case e.op == "pr":
+			return "1 = 1", nil, nil
+		case !ok || (e.op != "eq" && e.op != "ne"):
+			return "", nil, errInvalidFilter("attribute %#v only supports eq and ne with boolean", e.attr)
+		case b == (e.op == "eq"):
+			return fmt.Sprintf("%s IS NULL", col.name), nil, nil
+		default:
+			return fmt.Sprintf("%s IS NOT NULL", col.name), nil, nil
+		}
+	}
+
+	if e.op == "pr" {
+		return fmt.Sprintf("(%s IS NOT NULL AND %s <> '')", col.name, col.name), nil, nil
+	}
+
+	if e.value == nil {
+		switch e.op {
+		case "eq":
+			return fmt.Sprintf("%s IS NULL", col.name), nil, nil
+		case "ne":
+			return fmt.Sprintf("%s IS NOT NULL", col.name), nil, nil
+		}
+		return "", nil, errInvalidFilter("null only supports eq and ne")
+	}
+
+	switch e.op {
+	case "eq":
+		return fmt.Sprintf("%s = ?", col.name), []any{e.value}, nil
+	case "ne":
+		return fmt.Sprintf("%s <> ?", col.name), []any{e.value}, nil
+	case "gt":
+		return fmt.Sprintf("%s > ?", col.name), []any{e.value}, nil
+	case "ge":
+		return fmt.Sprintf("%s >= ?", col.name), []any{e.value}, nil
+	case "lt":
+		return fmt.Sprintf("%s < ?", col.name), []any{e.value}, nil
+	case "le":
+		return fmt.Sprintf("%s <= ?", col.name), []any{e.value}, nil
+	}
+
+	s, ok := e.value.(string)
+	if !ok {
+		return "", nil, errInvalidFilter("operator %v requires a string", e.op)
+	}
+
+	switch e.op {
+	case "co":
+		s = "%" + escapeLike(s) + "%"
+	case "sw":
+		s = escapeLike(s) + "%"
+	case "ew":
+		s = "%" + escapeLike(s)
+	}
+	return fmt.Sprintf("%s LIKE ?", col.name), []any{s}, nil
+}
+
+var compareOperators = []string{"eq", "ne", "co", "sw", "ew", "gt", "ge", "lt", "le"}
+
+type filterParser struct {
+	tokens []string
+	pos    int
+}
+
+// parseFilter supports attribute expressions combined by and, or, not and
+// parentheses. Value paths like emails[type eq "work"] are not supported.
+func parseFilter(s string) (filterExpr, error) {
+	tokens, err := tokenizeFilter(s)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &filterParser{tokens: tokens}
+	expr, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+
+	if p.pos != len(p.tokens) {
+		return nil, errInvalidFilter("unexpected %#v", p.tokens[p.pos])
+	}
+	return expr, nil
+}
+
+func tokenizeFilter(s string) ([]string, error) {
+	tokens := []string{}
+	for i := 0; i < len(s); {
+		switch c := s[i]; {
+		case c == ' ':
+			i++
+		case c == '(' || c == ')':
+			tokens = append(tokens, string(c))
+			i++
+		case c == '"':
+			j := i + 1
+			for ; j < len(s) && s[j] != '"'; j++ {
+				if s[j] == '\\' {
+					j++
+				}
+			}
+			if j >= len(s) {
+				return nil, errInvalidFilter("unterminated string")
+			}
+			tokens = append(tokens, s[i:j+1])
+			i = j + 1
+		default:
+			j := i
+			for ; j < len(s) && s[j] != ' ' && s[j] != '(' && s[j] != ')'; j++ {
+				if s[j] == '[' {
+					return nil, errInvalidFilter("value paths are not supported")
+				}
+			}
+			tokens = append(tokens, s[i:j])
+			i = j
+		}
+	}
+	return tokens, nil
+}
+
+func (p *filterParser) next() string {
+	if p.pos >= len(p.tokens) {
+		return ""
+	}
+	t := p.tokens[p.pos]
+	p.pos++
+	return t
+}
+
+func (p *filterParser) peekKeyword(keyword string) bool {
+	return p.pos < len(p.tokens) && strings.EqualFold(p.tokens[p.pos], keyword)
+}
+
+func (p *filterParser) parseOr() (filterExpr, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.peekKeyword("or") {
+		p.pos++
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = logicalExpr{"or", left, right}
+	}
+	return left, nil
+}
+
+func (p *filterParser) parseAnd() (filterExpr, error) {
+	left, err := p.parseTerm()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.peekKeyword("and") {
+		p.pos++
+		right, err := p.parseTerm()
+		if err != nil {
+			return nil, err
+		}
+		left = logicalExpr{"and", left, right}
+	}
+	return left, nil
+}
+
+func (p *filterParser) parseTerm() (filterExpr, error) {
+	if p.peekKeyword("not") {
+		p.pos++
+		if p.next() != "(" {
+			return nil, errInvalidFilter("not must be followed by parentheses")
+		}
+		return p.parseGroup(func(e filterExpr) filterExpr { return notExpr{e} })
+	}
+
+	if p.pos < len(p.tokens) && p.tokens[p.pos] == "(" {
+		p.pos++
+		return p.parseGroup(func(e filterExpr) filterExpr { return e })
+	}
+
+	attr, op := p.next(), strings.ToLower(p.next())
+	if attr == "" || op == "" {
+		return nil, errInvalidFilter("incomplete expression")
+	}
+
+	if op == "pr" {
+		return compareExpr{attr: normalizeAttr(attr), op: op}, nil
+	}
+
+	if !slices.Contains(compareOperators, op) {
+		return nil, errInvalidFilter("unknown operator %#v", op)
+	}
+
+	value, err := parseFilterValue(p.next())
+	if err != nil {
+		return nil, err
+	}
+	return compareExpr{normalizeAttr(attr), op, value}, nil
+}
+
+func (p *filterParser) parseGroup(wrap func(filterExpr) filterExpr) (filterExpr, error) {
+	expr, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+
+	if p.next() != ")" {
+		return nil, errInvalidFilter("missing closing parenthesis")
+	}
+	return wrap(expr), nil
+}
+
+func parseFilterValue(s string) (any, error) {
+	switch {
+	case s == "":
+		return nil, errInvalidFilter("missing value")
+	case s[0] == '"':
+		var v string
+		if err := json.Unmarshal([]byte(s), &v); err != nil {
+			return nil, errInvalidFilter("invalid string %v", s)
+		}
+		return v, nil
+	case s == "true" || s == "false":
+		return s == "true", nil
+	case s == "null":
+		return nil, nil
+	}
+
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return nil, errInvalidFilter("invalid value %v", s)
+	}
+	return f, nil
+}
+
+// normalizeAttr strips the schema URN and lowercases the attribute name
+// because attribute names are case-insensitive.
+func normalizeAttr(attr string) string {
+	for _, schema := range []string{SchemaUser, SchemaGroup} {
+		if rest, ok := strings.CutPrefix(attr, schema+":"); ok {
+			attr = rest
+		}
+	}
+	return strings.ToLower(attr)
+}
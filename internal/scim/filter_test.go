@@ -0,0 +1,72 @@
+package scim
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseFilter(t *testing.T) {
+	for _, tc := range []struct {
+		filter string
+		sql    string
+		args   []any
+	}{
+		{`userName eq "jdoe"`, "name = ?", []any{"jdoe"}},
+		{`USERNAME Eq "jdoe"`, "name = ?", []any{"jdoe"}},
+		{`urn:ietf:params:scim:schemas:core:2.0:User:userName eq "jdoe"`, "name = ?", []any{"jdoe"}},
+		{`userName eq "j \"doe\""`, "name = ?", []any{`j "doe"`}},
+		{`displayName co "50%"`, "display_name LIKE ?", []any{`%50\%%`}},
+		{`displayName sw "J"`, "display_name LIKE ?", []any{"J%"}},
+		{`externalId pr`, "(external_id IS NOT NULL AND external_id <> '')", nil},
+		{`active eq true`, "deactivated_at IS NULL", nil},
+		{`active eq false`, "deactivated_at IS NOT NULL", nil},
+		{`active ne true`, "deactivated_at IS NOT NULL", nil},
+		{`userName eq "a" or userName eq "b" and active eq true`, "(name = ? OR (name = ? AND deactivated_at IS NULL))", []any{"a", "b"}},
+		{`(userName eq "a" or userName eq "b") and active eq true`, "((name = ? OR name = ?) AND deactivated_at IS NULL)", []any{"a", "b"}},
+		{`not (userName eq "a")`, "NOT name = ?", []any{"a"}},
+		{`meta.lastModified gt "2024-01-01T00:00:00Z"`, "updated_at > ?", []any{"2024-01-01T00:00:00Z"}},
+		{`id eq 5`, "id = ?", []any{float64(5)}},
+	} {
+		expr, err := parseFilter(tc.filter)
+		if err != nil {
+			t.Errorf("%v: cannot parse filter: %v", tc.filter, err)
+			continue
+		}
+
+		sql, args, err := expr.sql(userColumns)
+		if err != nil {
+			t.Errorf("%v: cannot translate filter: %v", tc.filter, err)
+			continue
+		}
+
+		if sql != tc.sql || !reflect.DeepEqual(args, tc.args) {
+			t.Errorf("%v: expected %v %v but got %v %v", tc.filter, tc.sql, tc.args, sql, args)
+		}
+	}
+}
+
+func TestParseFilter_invalid(t *testing.T) {
+	for _, filter := range []string{
+		`userName`,
+		`userName eq`,
+		`userName xx "a"`,
+		`userName eq "a`,
+		`userName eq a`,
+		`(userName eq "a"`,
+		`userName eq "a" and`,
+		`emails[type eq "work"]`,
+		`password eq "secret"`,
+		`active eq "yes"`,
+		`active gt true`,
+		`userName co 1`,
+	} {
+		expr, err := parseFilter(filter)
+		if err == nil {
+			_, _, err = expr.sql(userColumns)
+		}
+
+		if err == nil {
+			t.Errorf("expected %#v to be rejected", filter)
+		}
+	}
+}
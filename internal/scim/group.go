@@ -0,0 +1,353 @@
+package scim
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/seb-schulz/onegate/internal/database"
+	"github.com/seb-schulz/onegate/internal/model"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var groupColumns = map[string]column{
+	"id":                {name: "id"},
+	"displayname":       {name: "name"},
+	"externalid":        {name: "external_id"},
+	"meta.created":      {name: "created_at"},
+	"meta.lastmodified": {name: "updated_at"},
+}
+
+// groupResource is the representation of model.Group. Members are users only.
+type groupResource struct {
+	Schemas     []string    `json:"schemas"`
+	ID          string      `json:"id,omitempty"`
+	ExternalID  string      `json:"externalId,omitempty"`
+	DisplayName string      `json:"displayName"`
+	Members     []memberRef `json:"members"`
+	Meta        *meta       `json:"meta,omitempty"`
+}
+
+func (g *groupResource) validate() error {
+	if g.DisplayName == "" {
+		return errInvalidValue("displayName is required")
+	}
+	return nil
+}
+
+func (g *groupResource) memberIDs() []string {
+	ids := []string{}
+	for _, m := range g.Members {
+		if !slices.Contains(ids, m.Value) {
+			ids = append(ids, m.Value)
+		}
+	}
+	return ids
+}
+
+func (g *groupResource) addMembers(members []memberRef) {
+	for _, m := range members {
+		if !slices.ContainsFunc(g.Members, func(o memberRef) bool { return o.Value == m.Value }) {
+			g.Members = append(g.Members, m)
+		}
+	}
+}
+
+func (g *groupResource) removeMembers(ids ...string) {
+	g.Members = slices.DeleteFunc(g.Members, func(m memberRef) bool { return slices.Contains(ids, m.Value) })
+}
+
+func decodeMembers(raw json.RawMessage) ([]memberRef, error) {
+	members := []memberRef{}
+	if err := json.Unmarshal(raw, &members); err != nil {
+		return nil, errInvalidValue("expected list of members but got %s", raw)
+	}
+	return members, nil
+}
+
+func (g *groupResource) setAttribute(op, attr string, raw json.RawMessage) error {
+	var err error
+	switch attr {
+	case "displayname":
+		g.DisplayName, err = decodeString(raw)
+	case "externalid":
+		g.ExternalID, err = decodeString(raw)
+	case "members":
+		var members []memberRef
+		if members, err = decodeMembers(raw); err != nil {
+			return err
+		}
+
+		if op == "replace" {
+			g.Members = nil
+		}
+		g.addMembers(members)
+	}
+	return err
+}
+
+// removeAttribute removes all or the listed members if the path is
+// "members" and a single member with a path like members[value eq "1"].
+func (g *groupResource) removeAttribute(path string, raw json.RawMessage) error {
+	if id, ok := memberOfPath(path); ok {
+		g.removeMembers(id)
+		return nil
+	}
+
+	switch normalizeAttr(path) {
+	case "displayname":
+		return newError(http.StatusBadRequest, "mutability", "displayName cannot be removed")
+	case "externalid":
+		g.ExternalID = ""
+	case "members":
+		if len(raw) == 0 {
+			g.Members = nil
+			return nil
+		}
+
+		members, err := decodeMembers(raw)
+		if err != nil {
+			return err
+		}
+
+		ids := []string{}
+		for _, m := range members {
+			ids = append(ids, m.Value)
+		}
+		g.removeMembers(ids...)
+	default:
+		return errInvalidPath("unsupported path %#v", path)
+	}
+	return nil
+}
+
+func (g *groupResource) patch(ops []patchOp) error {
+	for _, op := range ops {
+		if op.Op == "remove" {
+			if err := g.removeAttribute(op.Path, op.Value); err != nil {
+				return err
+			}
+			continue
+		}
+
+		attrs, err := op.attributes()
+		if err != nil {
+			return err
+		}
+
+		for attr, value := range attrs {
+			if err := g.setAttribute(op.Op, attr, value); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (h *handler) groupResource(group *model.Group) groupResource {
+	g := groupResource{
+		Schemas:     []string{SchemaGroup},
+		ID:          fmt.Sprint(group.ID),
+		ExternalID:  group.ExternalID,
+		DisplayName: group.Name,
+		Members:     []memberRef{},
+		Meta: &meta{
+			ResourceType: "Group",
+			Created:      group.CreatedAt,
+			LastModified: group.UpdatedAt,
+			Location:     h.location("Groups", group.ID),
+		},
+	}
+
+	for _, u := range group.Members {
+		g.Members = append(g.Members, memberRef{fmt.Sprint(u.ID), h.location("Users", u.ID), u.DisplayName})
+	}
+	return g
+}
+
+func groupByID(ctx context.Context, id string) (*model.Group, error) {
+	groupID, err := strconv.ParseUint(id, 10, 0)
+	if err != nil {
+		return nil, errNotFound("group", id)
+	}
+
+	group := model.Group{}
+	if r := database.FromContext(ctx).Preload("Members").First(&group, groupID); errors.Is(r.Error, gorm.ErrRecordNotFound) {
+		return nil, errNotFound("group", id)
+	} else if r.Error != nil {
+		return nil, r.Error
+	}
+	return &group, nil
+}
+
+// saveGroup creates or updates the group and replaces its members.
+func saveGroup(ctx context.Context, group *model.Group, memberIDs []string) error {
+	return database.FromContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var count int64
+		if r := tx.Model(&model.Group{}).Where("name = ? AND id <> ?", group.Name, group.ID).Count(&count); r.Error != nil {
+			return r.Error
+		} else if count > 0 {
+			return errUniqueness("displayName %#v is already taken", group.Name)
+		}
+
+		members := []model.User{}
+		if len(memberIDs) > 0 {
+			if r := tx.Where("id IN ?", memberIDs).Find(&members); r.Error != nil {
+				return r.Error
+			}
+		}
+
+		if len(members) != len(memberIDs) {
+			return errInvalidValue("members must be existing users")
+		}
+
+		if r := tx.Omit(clause.Associations).Save(group); r.Error != nil {
+			return r.Error
+		}
+
+		if err := tx.Model(group).Association("Members").Replace(members); err != nil {
+			return err
+		}
+		group.Members = members
+		return nil
+	})
+}
+
+func (h *handler) listGroups(w http.ResponseWriter, r *http.Request) {
+	lq, err := parseListQuery(r.URL.Query())
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	groups := []model.Group{}
+	total, err := find(database.FromContext(r.Context()).Preload("Members"), lq, groupColumns, &groups)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	resources := []any{}
+	for i := range groups {
+		resources = append(resources, h.groupResource(&groups[i]))
+	}
+	writeJSON(w, http.StatusOK, listResponse{[]string{SchemaListResponse}, total, lq.startIndex, len(resources), resources})
+}
+
+func (h *handler) getGroup(w http.ResponseWriter, r *http.Request) {
+	group, err := groupByID(r.Context(), chi.URLParam(r, "id"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, h.groupResource(group))
+}
+
+func (h *handler) createGroup(w http.ResponseWriter, r *http.Request) {
+	g := groupResource{}
+	if err := decodeJSON(r, &g); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	if err := g.validate(); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	group := model.Group{Name: g.DisplayName, ExternalID: g.ExternalID}
+	if err := saveGroup(r.Context(), &group, g.memberIDs()); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Location", h.location("Groups", group.ID))
+	writeJSON(w, http.StatusCreated, h.groupResource(&group))
+}
+
+func (h *handler) replaceGroup(w http.ResponseWriter, r *http.Request) {
+	group, err := groupByID(r.Context(), chi.URLParam(r, "id"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	g := groupResource{}
+	if err := decodeJSON(r, &g); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	if err := g.validate(); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	group.Name, group.ExternalID = g.DisplayName, g.ExternalID
+	if err := saveGroup(r.Context(), group, g.memberIDs()); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, h.groupResource(group))
+}
+
+func (h *handler) patchGroup(w http.ResponseWriter, r *http.Request) {
+	group, err := groupByID(r.Context(), chi.URLParam(r, "id"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	pr := patchRequest{}
+	if err := decodeJSON(r, &pr); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	if err := pr.validate(); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	g := h.groupResource(group)
+	if err := g.patch(pr.Operations); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	if err := g.validate(); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	group.Name, group.ExternalID = g.DisplayName, g.ExternalID
+	if err := saveGroup(r.Context(), group, g.memberIDs()); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, h.groupResource(group))
+}
+
+func (h *handler) deleteGroup(w http.ResponseWriter, r *http.Request) {
+	group, err := groupByID(r.Context(), chi.URLParam(r, "id"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	if err := model.DeleteGroup(database.FromContext(r.Context()), group); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
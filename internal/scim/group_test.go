@@ -0,0 +1,52 @@
+package scim
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestGroupResource_patch(t *testing.T) {
+	g := groupResource{DisplayName: "Staff", Members: []memberRef{{Value: "1"}, {Value: "2"}}}
+
+	for _, tc := range []struct {
+		request  string
+		expected []string
+	}{
+		{`{"op": "add", "path": "members", "value": [{"value": "2"}, {"value": "3"}]}`, []string{"1", "2", "3"}},
+		{`{"op": "remove", "path": "members[value eq \"1\"]"}`, []string{"2", "3"}},
+		{`{"op": "remove", "path": "members", "value": [{"value": "3"}]}`, []string{"2"}},
+		{`{"op": "replace", "path": "members", "value": [{"value": "4"}, {"value": "5"}]}`, []string{"4", "5"}},
+		{`{"op": "remove", "path": "members"}`, []string{}},
+		{`{"op": "add", "value": {"members": [{"value": "6"}], "displayName": "Team"}}`, []string{"6"}},
+	} {
+		pr := decodePatchRequest(t, `{"schemas": ["urn:ietf:params:scim:api:messages:2.0:PatchOp"], "Operations": [`+tc.request+`]}`)
+		if err := g.patch(pr.Operations); err != nil {
+			t.Fatalf("%v: cannot patch group: %v", tc.request, err)
+		}
+
+		if ids := g.memberIDs(); !reflect.DeepEqual(ids, tc.expected) {
+			t.Errorf("%v: expected members %v but got %v", tc.request, tc.expected, ids)
+		}
+	}
+
+	if g.DisplayName != "Team" {
+		t.Errorf("expected displayName to be replaced but got %#v", g.DisplayName)
+	}
+
+	if err := g.patch([]patchOp{{Op: "remove", Path: "displayName"}}); err == nil {
+		t.Errorf("expected removal of displayName to fail")
+	}
+}
+
+func TestMemberOfPath(t *testing.T) {
+	for path, expected := range map[string]string{
+		`members[value eq "42"]`:  "42",
+		`Members[Value Eq "42"]`:  "42",
+		`members`:                 "",
+		`members[display eq "a"]`: "",
+	} {
+		if id, _ := memberOfPath(path); id != expected {
+			t.Errorf("%v: expected %#v but got %#v", path, expected, id)
+		}
+	}
+}
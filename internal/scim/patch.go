@@ -0,0 +1,103 @@
+package scim
+
+import (
+	"encoding/json"
+	"net/http"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+type patchOp struct {
+	Op    string          `json:"op"`
+	Path  string          `json:"path"`
+	Value json.RawMessage `json:"value"`
+}
+
+type patchRequest struct {
+	Schemas    []string  `json:"schemas"`
+	Operations []patchOp `json:"Operations"`
+}
+
+func (pr *patchRequest) validate() error {
+	if !slices.Contains(pr.Schemas, SchemaPatchOp) {
+		return errInvalidValue("missing schema %v", SchemaPatchOp)
+	}
+
+	for i, op := range pr.Operations {
+		// Some clients capitalize operations
+		pr.Operations[i].Op = strings.ToLower(op.Op)
+		switch pr.Operations[i].Op {
+		case "add", "replace":
+			if len(op.Value) == 0 {
+				return errInvalidValue("operation %v requires a value", op.Op)
+			}
+		case "remove":
+			if op.Path == "" {
+				return newError(http.StatusBadRequest, "noTarget", "operation remove requires a path")
+			}
+		default:
+			return errInvalidValue("unknown operation %#v", op.Op)
+		}
+	}
+	return nil
+}
+
+// attributes returns the attributes modified by an operation. Operations
+// without path contain the attributes as object.
+func (op *patchOp) attributes() (map[string]json.RawMessage, error) {
+	if op.Path != "" {
+		return map[string]json.RawMessage{normalizeAttr(op.Path): op.Value}, nil
+	}
+
+	raw := map[string]json.RawMessage{}
+	if err := json.Unmarshal(op.Value, &raw); err != nil {
+		return nil, errInvalidValue("value of operation without path must be an object")
+	}
+
+	attrs := map[string]json.RawMessage{}
+	for k, v := range raw {
+		attrs[normalizeAttr(k)] = v
+	}
+	return attrs, nil
+}
+
+func decodeString(raw json.RawMessage) (string, error) {
+	var s string
+	if err := json.Unmarshal(raw, &s); err != nil {
+		return "", errInvalidValue("expected string but got %s", raw)
+	}
+	return s, nil
+}
+
+// decodeBool also accepts booleans encoded as string, e.g. "False", which are
+// sent by some clients.
+func decodeBool(raw json.RawMessage) (bool, error) {
+	var b bool
+	if err := json.Unmarshal(raw, &b); err == nil {
+		return b, nil
+	}
+
+	s, err := decodeString(raw)
+	if err != nil {
+		return false, errInvalidValue("expected boolean but got %s", raw)
+	}
+
+	b, err = strconv.ParseBool(s)
+	if err != nil {
+		return false, errInvalidValue("expected boolean but got %s", raw)
+	}
+	return b, nil
+}
+
+var memberFilterPattern = regexp.MustCompile(`^(?i)members\[value eq "([^"]*)"\]$`)
+
+// memberOfPath returns the member ID of paths like members[value eq "1"].
+func memberOfPath(path string) (string, bool) {
+	m := memberFilterPattern.FindStringSubmatch(strings.TrimSpace(path))
+	if m == nil {
+		return "", false
+	}
+	return m[1], true
+}
@@ -0,0 +1,273 @@
+// Package scim implements a SCIM 2.0 server (RFC 7643 and RFC 7644) to
+// provision users and groups.
+package scim
+
+import (
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"gorm.io/gorm"
+)
+
+const (
+	SchemaUser                  = "urn:ietf:params:scim:schemas:core:2.0:User"
+	SchemaGroup                 = "urn:ietf:params:scim:schemas:core:2.0:Group"
+	SchemaServiceProviderConfig = "urn:ietf:params:scim:schemas:core:2.0:ServiceProviderConfig"
+	SchemaListResponse          = "urn:ietf:params:scim:api:messages:2.0:ListResponse"
+	SchemaPatchOp               = "urn:ietf:params:scim:api:messages:2.0:PatchOp"
+	SchemaError                 = "urn:ietf:params:scim:api:messages:2.0:Error"
+
+	contentType  = "application/scim+json"
+	defaultCount = 100
+	maxCount     = 1000
+)
+
+type Config struct {
+	// BaseUrl is the absolute URL the SCIM endpoints are mounted at
+	BaseUrl url.URL
+	// BearerToken authenticates provisioning clients. SCIM is disabled
+	// unless it is set.
+	BearerToken []byte
+}
+
+func warnf(format string, opts ...any) {
+	slog.Warn(fmt.Sprintf(format, opts...))
+}
+
+type scimError struct {
+	Schemas  []string `json:"schemas"`
+	Status   string   `json:"status"`
+	ScimType string   `json:"scimType,omitempty"`
+	Detail   string   `json:"detail,omitempty"`
+}
+
+func (e *scimError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Status, e.Detail)
+}
+
+func newError(status int, scimType, format string, a ...any) *scimError {
+	return &scimError{[]string{SchemaError}, strconv.Itoa(status), scimType, fmt.Sprintf(format, a...)}
+}
+
+func errInvalidFilter(format string, a ...any) error {
+	return newError(http.StatusBadRequest, "invalidFilter", format, a...)
+}
+
+func errInvalidValue(format string, a ...any) error {
+	return newError(http.StatusBadRequest, "invalidValue", format, a...)
+}
+
+func errInvalidPath(format string, a ...any) error {
+	return newError(http.StatusBadRequest, "invalidPath", format, a...)
+}
+
+func errUniqueness(format string, a ...any) error {
+	return newError(http.StatusConflict, "uniqueness", format, a...)
+}
+
+func errNotFound(resourceType, id string) error {
+	return newError(http.StatusNotFound, "", "%s %v not found", resourceType, id)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		warnf("cannot encode response: %v", err)
+		http.Error(w, "cannot encode response", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.WriteHeader(status)
+	w.Write(b)
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	var sErr *scimError
+	if !errors.As(err, &sErr) {
+		warnf("SCIM request failed: %v", err)
+		sErr = newError(http.StatusInternalServerError, "", "internal error")
+	}
+
+	status, _ := strconv.Atoi(sErr.Status)
+	writeJSON(w, status, sErr)
+}
+
+func decodeJSON(r *http.Request, v any) error {
+	if err := json.NewDecoder(r.Body).Decode(v); err != nil {
+		return newError(http.StatusBadRequest, "invalidSyntax", "cannot decode request: %v", err)
+	}
+	return nil
+}
+
+type meta struct {
+	ResourceType string    `json:"resourceType"`
+	Created      time.Time `json:"created"`
+	LastModified time.Time `json:"lastModified"`
+	Location     string    `json:"location"`
+}
+
+type listResponse struct {
+	Schemas      []string `json:"schemas"`
+	TotalResults int64    `json:"totalResults"`
+	StartIndex   int      `json:"startIndex"`
+	ItemsPerPage int      `json:"itemsPerPage"`
+	Resources    []any    `json:"Resources"`
+}
+
+// listQuery contains filter and pagination of a list request. The start
+// index is 1-based.
+type listQuery struct {
+	filter     filterExpr
+	startIndex int
+	count      int
+}
+
+func parseListQuery(q url.Values) (listQuery, error) {
+	lq := listQuery{startIndex: 1, count: defaultCount}
+
+	if s := q.Get("startIndex"); s != "" {
+		i, err := strconv.Atoi(s)
+		if err != nil {
+			return lq, errInvalidValue("invalid startIndex %#v", s)
+		}
+		lq.startIndex = max(i, 1)
+	}
+
+	if s := q.Get("count"); s != "" {
+		i, err := strconv.Atoi(s)
+		if err != nil {
+			return lq, errInvalidValue("invalid count %#v", s)
+		}
+		lq.count = min(max(i, 0), maxCount)
+	}
+
+	if s := q.Get("filter"); s != "" {
+		f, err := parseFilter(s)
+		if err != nil {
+			return lq, err
+		}
+		lq.filter = f
+	}
+
+	return lq, nil
+}
+
+// find loads a page of resources matching the filter and counts all of them.
+func find[T any](tx *gorm.DB, lq listQuery, columns map[string]column, dest *[]T) (int64, error) {
+	if lq.filter != nil {
+		cond, args, err := lq.filter.sql(columns)
+		if err != nil {
+			return 0, err
+		}
+		tx = tx.Where(cond, args...)
+	}
+	// New session so that counting does not affect the query of the page
+	tx = tx.Session(&gorm.Session{})
+
+	var total int64
+	if r := tx.Model(dest).Count(&total); r.Error != nil {
+		return 0, r.Error
+	}
+
+	if r := tx.Order("id").Offset(lq.startIndex - 1).Limit(lq.count).Find(dest); r.Error != nil {
+		return 0, r.Error
+	}
+	return total, nil
+}
+
+func bearerToken(r *http.Request) string {
+	scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return ""
+	}
+	return strings.TrimSpace(token)
+}
+
+type handler struct {
+	baseUrl     url.URL
+	bearerToken []byte
+}
+
+// authenticate compares the token like it is read from the configuration,
+// i.e. base64 encoded values are decoded first.
+func (h *handler) authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token := bearerToken(r)
+		b, err := base64.StdEncoding.DecodeString(token)
+		if err != nil {
+			b = []byte(token)
+		}
+
+		if token == "" || subtle.ConstantTimeCompare(b, h.bearerToken) != 1 {
+			w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+			writeError(w, newError(http.StatusUnauthorized, "", "invalid bearer token"))
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (h *handler) location(resourceType string, id uint) string {
+	return h.baseUrl.JoinPath(resourceType, fmt.Sprint(id)).String()
+}
+
+func (h *handler) serviceProviderConfig(w http.ResponseWriter, r *http.Request) {
+	type supported struct {
+		Supported bool `json:"supported"`
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"schemas":        []string{SchemaServiceProviderConfig},
+		"patch":          supported{true},
+		"bulk":           map[string]any{"supported": false, "maxOperations": 0, "maxPayloadSize": 0},
+		"filter":         map[string]any{"supported": true, "maxResults": maxCount},
+		"changePassword": supported{false},
+		"sort":           supported{false},
+		"etag":           supported{false},
+		"authenticationSchemes": []map[string]any{{
+			"type":        "oauthbearertoken",
+			"name":        "Bearer Token",
+			"description": "Authentication with the configured bearer token",
+		}},
+		"meta": map[string]any{"resourceType": "ServiceProviderConfig", "location": h.baseUrl.JoinPath("ServiceProviderConfig").String()},
+	})
+}
+
+func NewHandler(c *Config) http.Handler {
+	h := &handler{c.BaseUrl, c.BearerToken}
+
+	route := chi.NewRouter()
+	route.Use(h.authenticate)
+	route.Get("/ServiceProviderConfig", h.serviceProviderConfig)
+
+	route.Route("/Users", func(route chi.Router) {
+		route.Get("/", h.listUsers)
+		route.Post("/", h.createUser)
+		route.Get("/{id}", h.getUser)
+		route.Put("/{id}", h.replaceUser)
+		route.Patch("/{id}", h.patchUser)
+		route.Delete("/{id}", h.deleteUser)
+	})
+
+	route.Route("/Groups", func(route chi.Router) {
+		route.Get("/", h.listGroups)
+		route.Post("/", h.createGroup)
+		route.Get("/{id}", h.getGroup)
+		route.Put("/{id}", h.replaceGroup)
+		route.Patch("/{id}", h.patchGroup)
+		route.Delete("/{id}", h.deleteGroup)
+	})
+
+	return route
+}
@@ -0,0 +1,78 @@
+package scim
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+func TestHandler_authenticate(t *testing.T) {
+	h := NewHandler(&Config{
+		BaseUrl:     url.URL{Scheme: "https", Host: "example.com", Path: "/scim/v2"},
+		BearerToken: []byte("token"),
+	})
+
+	for _, tc := range []struct {
+		authorization string
+		status        int
+	}{
+		{"", http.StatusUnauthorized},
+		{"Bearer other", http.StatusUnauthorized},
+		{"Basic dG9rZW4=", http.StatusUnauthorized},
+		{"Bearer token", http.StatusOK},
+		{"Bearer " + base64.StdEncoding.EncodeToString([]byte("token")), http.StatusOK},
+	} {
+		r := httptest.NewRequest(http.MethodGet, "/ServiceProviderConfig", nil)
+		if tc.authorization != "" {
+			r.Header.Set("Authorization", tc.authorization)
+		}
+
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, r)
+		if rr.Code != tc.status {
+			t.Errorf("%#v: expected status %v but got %v", tc.authorization, tc.status, rr.Code)
+		}
+
+		if rr.Header().Get("Content-Type") != contentType {
+			t.Errorf("%#v: unexpected content type %v", tc.authorization, rr.Header().Get("Content-Type"))
+		}
+
+		if tc.status == http.StatusUnauthorized {
+			e := scimError{}
+			if err := json.Unmarshal(rr.Body.Bytes(), &e); err != nil || e.Status != "401" || e.Schemas[0] != SchemaError {
+				t.Errorf("%#v: expected SCIM error but got %v", tc.authorization, rr.Body.String())
+			}
+		}
+	}
+}
+
+func TestParseListQuery(t *testing.T) {
+	for _, tc := range []struct {
+		query      string
+		startIndex int
+		count      int
+		valid      bool
+	}{
+		{"", 1, defaultCount, true},
+		{"startIndex=11&count=10", 11, 10, true},
+		{"startIndex=0&count=-1", 1, 0, true},
+		{"count=100000", 1, maxCount, true},
+		{"startIndex=a", 0, 0, false},
+		{"count=a", 0, 0, false},
+		{"filter=userName", 0, 0, false},
+	} {
+		q, _ := url.ParseQuery(tc.query)
+		lq, err := parseListQuery(q)
+		if (err == nil) != tc.valid {
+			t.Errorf("%v: expected valid=%v but got: %v", tc.query, tc.valid, err)
+			continue
+		}
+
+		if tc.valid && (lq.startIndex != tc.startIndex || lq.count != tc.count) {
+			t.Errorf("%v: unexpected startIndex=%v and count=%v", tc.query, lq.startIndex, lq.count)
+		}
+	}
+}
@@ -0,0 +1,314 @@
+package scim
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/seb-schulz/onegate/internal/database"
+	"github.com/seb-schulz/onegate/internal/model"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var userColumns = map[string]column{
+	"id":                {name: "id"},
+	"username":          {name: "name"},
+	"displayname":       {name: "display_name"},
+	"externalid":        {name: "external_id"},
+	"active":            {name: "deactivated_at", isNull: true},
+	"meta.created":      {name: "created_at"},
+	"meta.lastmodified": {name: "updated_at"},
+}
+
+type memberRef struct {
+	Value   string `json:"value"`
+	Ref     string `json:"$ref,omitempty"`
+	Display string `json:"display,omitempty"`
+}
+
+// userResource is the representation of model.User. Attributes which are not
+// listed are ignored.
+type userResource struct {
+	Schemas     []string    `json:"schemas"`
+	ID          string      `json:"id,omitempty"`
+	ExternalID  string      `json:"externalId,omitempty"`
+	UserName    string      `json:"userName"`
+	DisplayName string      `json:"displayName,omitempty"`
+	Active      *bool       `json:"active,omitempty"`
+	Groups      []memberRef `json:"groups,omitempty"`
+	Meta        *meta       `json:"meta,omitempty"`
+}
+
+func (u *userResource) validate() error {
+	if u.UserName == "" {
+		return errInvalidValue("userName is required")
+	}
+	return nil
+}
+
+func (u *userResource) isActive() bool {
+	return u.Active == nil || *u.Active
+}
+
+func (u *userResource) setAttribute(attr string, raw json.RawMessage) error {
+	var err error
+	switch attr {
+	case "username":
+		u.UserName, err = decodeString(raw)
+	case "displayname":
+		u.DisplayName, err = decodeString(raw)
+	case "externalid":
+		u.ExternalID, err = decodeString(raw)
+	case "active":
+		var active bool
+		active, err = decodeBool(raw)
+		u.Active = &active
+	}
+	return err
+}
+
+func (u *userResource) removeAttribute(attr string) error {
+	switch attr {
+	case "username", "active":
+		return newError(http.StatusBadRequest, "mutability", "%v cannot be removed", attr)
+	case "displayname":
+		u.DisplayName = ""
+	case "externalid":
+		u.ExternalID = ""
+	}
+	return nil
+}
+
+func (u *userResource) patch(ops []patchOp) error {
+	for _, op := range ops {
+		if op.Op == "remove" {
+			if err := u.removeAttribute(normalizeAttr(op.Path)); err != nil {
+				return err
+			}
+			continue
+		}
+
+		attrs, err := op.attributes()
+		if err != nil {
+			return err
+		}
+
+		for attr, value := range attrs {
+			if err := u.setAttribute(attr, value); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (u *userResource) applyTo(user *model.User) {
+	user.Name = u.UserName
+	user.DisplayName = u.DisplayName
+	user.ExternalID = u.ExternalID
+}
+
+func (h *handler) userResource(user *model.User) userResource {
+	active := user.IsActive()
+	u := userResource{
+		Schemas:     []string{SchemaUser},
+		ID:          fmt.Sprint(user.ID),
+		ExternalID:  user.ExternalID,
+		UserName:    user.Name,
+		DisplayName: user.DisplayName,
+		Active:      &active,
+		Meta: &meta{
+			ResourceType: "User",
+			Created:      user.CreatedAt,
+			LastModified: user.UpdatedAt,
+			Location:     h.location("Users", user.ID),
+		},
+	}
+
+	for _, g := range user.Groups {
+		u.Groups = append(u.Groups, memberRef{fmt.Sprint(g.ID), h.location("Groups", g.ID), g.Name})
+	}
+	return u
+}
+
+func userByID(ctx context.Context, id string) (*model.User, error) {
+	userID, err := strconv.ParseUint(id, 10, 0)
+	if err != nil {
+		return nil, errNotFound("user", id)
+	}
+
+	user := model.User{}
+	if r := database.FromContext(ctx).Preload("Groups").First(&user, userID); errors.Is(r.Error, gorm.ErrRecordNotFound) {
+		return nil, errNotFound("user", id)
+	} else if r.Error != nil {
+		return nil, r.Error
+	}
+	return &user, nil
+}
+
+// saveUser creates or updates the user. Deactivated users cannot log in and
+// lose their sessions.
+func saveUser(ctx context.Context, user *model.User, active bool) error {
+	return database.FromContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var count int64
+		if r := tx.Model(&model.User{}).Where("name = ? AND id <> ?", user.Name, user.ID).Count(&count); r.Error != nil {
+			return r.Error
+		} else if count > 0 {
+			return errUniqueness("userName %#v is already taken", user.Name)
+		}
+
+		if r := tx.Omit(clause.Associations).Save(user); errors.Is(r.Error, gorm.ErrDuplicatedKey) {
+			return errUniqueness("userName %#v is already taken", user.Name)
+		} else if r.Error != nil {
+			return r.Error
+		}
+
+		if !active {
+			return user.Deactivate(tx)
+		}
+
+		if !user.IsActive() {
+			return user.Activate(tx)
+		}
+		return nil
+	})
+}
+
+func (h *handler) listUsers(w http.ResponseWriter, r *http.Request) {
+	lq, err := parseListQuery(r.URL.Query())
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	users := []model.User{}
+	total, err := find(database.FromContext(r.Context()).Preload("Groups"), lq, userColumns, &users)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	resources := []any{}
+	for i := range users {
+		resources = append(resources, h.userResource(&users[i]))
+	}
+	writeJSON(w, http.StatusOK, listResponse{[]string{SchemaListResponse}, total, lq.startIndex, len(resources), resources})
+}
+
+func (h *handler) getUser(w http.ResponseWriter, r *http.Request) {
+	user, err := userByID(r.Context(), chi.URLParam(r, "id"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, h.userResource(user))
+}
+
+func (h *handler) createUser(w http.ResponseWriter, r *http.Request) {
+	u := userResource{}
+	if err := decodeJSON(r, &u); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	if err := u.validate(); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	user := model.User{}
+	u.applyTo(&user)
+	if err := saveUser(r.Context(), &user, u.isActive()); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Location", h.location("Users", user.ID))
+	writeJSON(w, http.StatusCreated, h.userResource(&user))
+}
+
+func (h *handler) replaceUser(w http.ResponseWriter, r *http.Request) {
+	user, err := userByID(r.Context(), chi.URLParam(r, "id"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	u := userResource{}
+	if err := decodeJSON(r, &u); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	if err := u.validate(); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	u.applyTo(user)
+	if err := saveUser(r.Context(), user, u.isActive()); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, h.userResource(user))
+}
+
+func (h *handler) patchUser(w http.ResponseWriter, r *http.Request) {
+	user, err := userByID(r.Context(), chi.URLParam(r, "id"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	pr := patchRequest{}
+	if err := decodeJSON(r, &pr); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	if err := pr.validate(); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	u := h.userResource(user)
+	if err := u.patch(pr.Operations); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	if err := u.validate(); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	u.applyTo(user)
+	if err := saveUser(r.Context(), user, u.isActive()); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, h.userResource(user))
+}
+
+func (h *handler) deleteUser(w http.ResponseWriter, r *http.Request) {
+	user, err := userByID(r.Context(), chi.URLParam(r, "id"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	if err := model.DeleteUser(database.FromContext(r.Context()), user); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,109 @@
+package scim
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/seb-schulz/onegate/internal/database"
+	"github.com/seb-schulz/onegate/internal/model"
+)
+
+func decodePatchRequest(t *testing.T, s string) patchRequest {
+	pr := patchRequest{}
+	if err := json.Unmarshal([]byte(s), &pr); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := pr.validate(); err != nil {
+		t.Fatalf("invalid patch request: %v", err)
+	}
+	return pr
+}
+
+func TestUserResource_patch(t *testing.T) {
+	active := true
+	u := userResource{UserName: "jdoe", DisplayName: "John Doe", ExternalID: "ext", Active: &active}
+
+	pr := decodePatchRequest(t, `{
+		"schemas": ["urn:ietf:params:scim:api:messages:2.0:PatchOp"],
+		"Operations": [
+			{"op": "Replace", "path": "active", "value": "False"},
+			{"op": "replace", "value": {"displayName": "Jane Doe", "emails": [{"value": "jane@example.com"}]}},
+			{"op": "remove", "path": "externalId"}
+		]
+	}`)
+
+	if err := u.patch(pr.Operations); err != nil {
+		t.Fatalf("cannot patch user: %v", err)
+	}
+
+	if u.isActive() || u.DisplayName != "Jane Doe" || u.ExternalID != "" || u.UserName != "jdoe" {
+		t.Errorf("unexpected user %#v", u)
+	}
+
+	if err := u.patch([]patchOp{{Op: "remove", Path: "userName"}}); err == nil {
+		t.Errorf("expected removal of userName to fail")
+	}
+}
+
+func TestPatchRequest_validate(t *testing.T) {
+	for _, s := range []string{
+		`{"Operations": [{"op": "add", "path": "displayName", "value": "a"}]}`,
+		`{"schemas": ["urn:ietf:params:scim:api:messages:2.0:PatchOp"], "Operations": [{"op": "move", "path": "displayName"}]}`,
+		`{"schemas": ["urn:ietf:params:scim:api:messages:2.0:PatchOp"], "Operations": [{"op": "add", "path": "displayName"}]}`,
+		`{"schemas": ["urn:ietf:params:scim:api:messages:2.0:PatchOp"], "Operations": [{"op": "remove"}]}`,
+	} {
+		pr := patchRequest{}
+		if err := json.Unmarshal([]byte(s), &pr); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := pr.validate(); err == nil {
+			t.Errorf("expected %v to be rejected", s)
+		}
+	}
+}
+
+func TestSaveUser(t *testing.T) {
+	db, err := database.Open()
+	if err != nil {
+		panic(err)
+	}
+
+	tx := db.Begin()
+	defer tx.Rollback()
+
+	ctx := database.WithContext(context.Background(), tx)
+
+	user := model.User{Name: "scim-user"}
+	if err := saveUser(ctx, &user, true); err != nil {
+		t.Fatalf("cannot create user: %v", err)
+	}
+
+	if err := saveUser(ctx, &model.User{Name: "scim-user"}, true); err == nil {
+		t.Errorf("expected duplicated userName to be rejected")
+	}
+
+	session := model.Session{ID: uuid.New(), UserID: user.ID}
+	if r := tx.Create(&session); r.Error != nil {
+		t.Fatal(r.Error)
+	}
+
+	if err := saveUser(ctx, &user, false); err != nil {
+		t.Fatalf("cannot deactivate user: %v", err)
+	}
+
+	if sessions, _ := model.AllSessionByUserID(tx, user.ID); len(sessions) != 0 {
+		t.Errorf("expected sessions of deactivated user to be deleted but got %v", sessions)
+	}
+
+	if err := model.LoginUser(ctx, model.LoginOpt{UserID: &user.ID}); err == nil {
+		t.Errorf("expected login of deactivated user to fail")
+	}
+
+	if err := saveUser(ctx, &user, true); err != nil || !user.IsActive() {
+		t.Errorf("cannot activate user: %v", err)
+	}
+}
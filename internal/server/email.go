@@ -0,0 +1,71 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"html/template"
+	"log/slog"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/seb-schulz/onegate/internal/database"
+	"github.com/seb-schulz/onegate/internal/model"
+)
+
+type emailVerificationHandler struct {
+	verifyEmailAddress func(ctx context.Context, token string) (*model.EmailAddress, error)
+}
+
+var emailVerificationPage = template.Must(template.New("email_verification").Parse(`<!DOCTYPE html>
+<html lang="en">
+
+<head>
+  <meta charset="utf-8" />
+  <link rel="icon" href="/favicon.ico" />
+  <meta name="viewport" content="width=device-width, initial-scale=1" />
+  <title>{{.Title}} - One Gate</title>
+</head>
+
+<body>
+  <h1>{{.Title}}</h1>
+  <p>{{.Message}}</p>
+  <p><a href="/">Continue to One Gate</a></p>
+</body>
+
+</html>
+`))
+
+func newEmailRoute() http.Handler {
+	route := chi.NewRouter()
+
+	h := &emailVerificationHandler{
+		verifyEmailAddress: func(ctx context.Context, token string) (*model.EmailAddress, error) {
+			return model.VerifyEmailAddress(database.FromContext(ctx), token)
+		},
+	}
+	route.Get("/verify/{token}", h.ServeHTTP)
+
+	return route
+}
+
+func (h *emailVerificationHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	status, data := http.StatusOK, struct {
+		Title   string
+		Message string
+	}{"Email address verified", ""}
+
+	addr, err := h.verifyEmailAddress(r.Context(), chi.URLParam(r, "token"))
+	if err != nil {
+		slog.Warn(fmt.Sprintf("cannot verify email address: %v", err))
+		status = http.StatusBadRequest
+		data.Title = "Verification failed"
+		data.Message = "The link is invalid or expired. Please request a new one."
+	} else {
+		data.Message = fmt.Sprintf("Thank you for verifying %s.", addr.Address)
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	emailVerificationPage.Execute(w, data)
+}
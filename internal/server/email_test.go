@@ -0,0 +1,46 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/seb-schulz/onegate/internal/model"
+)
+
+func TestEmailVerificationHandler(t *testing.T) {
+	h := &emailVerificationHandler{
+		verifyEmailAddress: func(ctx context.Context, token string) (*model.EmailAddress, error) {
+			if token != "valid" {
+				return nil, fmt.Errorf("invalid or expired verification token")
+			}
+			return &model.EmailAddress{Address: "jdoe@example.com"}, nil
+		},
+	}
+
+	route := chi.NewRouter()
+	route.Get("/verify/{token}", h.ServeHTTP)
+
+	for _, tc := range []struct {
+		token    string
+		status   int
+		expected string
+	}{
+		{"valid", http.StatusOK, "Thank you for verifying jdoe@example.com."},
+		{"invalid", http.StatusBadRequest, "The link is invalid or expired."},
+	} {
+		rr := httptest.NewRecorder()
+		route.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/verify/"+tc.token, nil))
+
+		if rr.Code != tc.status {
+			t.Errorf("%v: expected status %v but got %v", tc.token, tc.status, rr.Code)
+		}
+		if !strings.Contains(rr.Body.String(), tc.expected) {
+			t.Errorf("%v: expected %#v in %v", tc.token, tc.expected, rr.Body.String())
+		}
+	}
+}
@@ -4,7 +4,6 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"math/rand"
 	"net/http"
 	"net/url"
 	"time"
@@ -12,14 +11,33 @@ import (
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/httplog/v2"
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/seb-schulz/onegate/graph"
+	"github.com/seb-schulz/onegate/internal/auth"
+	"github.com/seb-schulz/onegate/internal/database"
 	"github.com/seb-schulz/onegate/internal/model"
 	"github.com/seb-schulz/onegate/internal/ui"
 	"github.com/seb-schulz/onegate/internal/usermgr"
 )
 
+// Purposes of login URLs
+const (
+	// PurposeLogin logs the user in with full privileges
+	PurposeLogin = "login"
+	// PurposeRegisterCredential logs the user in with a restricted session
+	// which may only register a new credential
+	PurposeRegisterCredential = "register-credential"
+)
+
+// loginTokenLeeway accepts login tokens shortly after they expired so they
+// must be remembered as used for as long.
+const loginTokenLeeway = 30 * time.Second
+
 type (
 	loginClaims struct {
 		jwt.RegisteredClaims
+		// Purpose is empty for URLs issued before purposes were introduced
+		Purpose string `json:"purpose,omitempty"`
 	}
 
 	tokenBasedLoginService struct {
@@ -27,20 +45,32 @@ type (
 		validMethods []string
 		baseUrl      url.URL
 		loginUser    func(context.Context, model.LoginOpt) error
+		// consumeToken fails if the token ID was used before
+		consumeToken func(ctx context.Context, id string, expiresAt time.Time) error
 		targetUrl    url.URL
+		// recoveryUrl is the target of restricted sessions
+		recoveryUrl url.URL
 	}
 
 	LoginConfig struct {
 		Key          []byte
 		ValidMethods []string
 		BaseUrl      url.URL
+		// Mode selects the ceremonies offered by the login page
+		Mode graph.LoginMode
+		// ConditionalExpiresIn is the lifetime of passkey autofill
+		// ceremonies started by the login page
+		ConditionalExpiresIn time.Duration
 	}
 )
 
 var (
 	errJwtInvalidSubject   = errors.New("must be an int greater than zero")
+	errJwtInvalidPurpose   = errors.New("unknown purpose")
 	defaultTargetUrl       = url.URL{Path: "/"}
+	defaultRecoveryUrl     = url.URL{Path: "/login/recovery"}
 	defaultUserFromContext = usermgr.FromContext
+	defaultStepUpRequired  = auth.StepUpRequired
 )
 
 func (m loginClaims) Validate() error {
@@ -53,9 +83,19 @@ func (m loginClaims) Validate() error {
 		return errJwtInvalidSubject
 	}
 
+	if m.Purpose != "" && m.Purpose != PurposeLogin && m.Purpose != PurposeRegisterCredential {
+		return errJwtInvalidPurpose
+	}
+
 	return nil
 }
 
+// Restricted reports whether the session of the login may only register a
+// new credential.
+func (m loginClaims) Restricted() bool {
+	return m.Purpose == PurposeRegisterCredential
+}
+
 func (m loginClaims) UserID() (uint, error) {
 	sub, err := m.GetSubject()
 	if err != nil {
@@ -72,7 +112,7 @@ func (m loginClaims) UserID() (uint, error) {
 func redirectWhenLoggedIn(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		user := defaultUserFromContext(r.Context())
-		if user != nil {
+		if user != nil && !defaultStepUpRequired(r.Context(), r.URL.Query().Get(auth.TransactionParam)) {
 			http.Redirect(w, r, fmt.Sprint(&defaultTargetUrl), http.StatusSeeOther)
 			return
 		}
@@ -88,39 +128,62 @@ func newLoginRoute(lc LoginConfig) http.Handler {
 	route.Use(func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			ui.AddTemplateValue(r.Context(), "startLogin", false)
+			ui.AddTemplateValue(r.Context(), "loginMode", string(lc.Mode))
 			next.ServeHTTP(w, r)
 		})
 	})
 
-	tokenSrv := &tokenBasedLoginService{lc.Key, lc.ValidMethods, lc.BaseUrl, model.LoginUser, defaultTargetUrl}
+	tokenSrv := newTokenBasedLoginService(lc)
 	route.Get("/{token}", tokenSrv.handler)
+	route.Get("/recovery", func(w http.ResponseWriter, r *http.Request) {
+		ui.AddTemplateValue(r.Context(), "recovery", true)
+		ui.AddTemplateValue(r.Context(), "recovering", usermgr.RecoveringFromContext(r.Context()) != nil)
+		ui.Template("login.html.tmpl")(w, r)
+	})
 	route.Get("/", ui.Template("login.html.tmpl"))
 
 	return route
 }
 
-func TokenBasedLoginUrl(lc LoginConfig, userID uint, expiresIn time.Duration) (*url.URL, error) {
-	tokenSrv := &tokenBasedLoginService{lc.Key, lc.ValidMethods, lc.BaseUrl, model.LoginUser, defaultTargetUrl}
-	return tokenSrv.getLoginUrl(userID, expiresIn)
+func newTokenBasedLoginService(lc LoginConfig) *tokenBasedLoginService {
+	return &tokenBasedLoginService{
+		key:          lc.Key,
+		validMethods: lc.ValidMethods,
+		baseUrl:      lc.BaseUrl,
+		loginUser:    model.LoginUser,
+		consumeToken: func(ctx context.Context, id string, expiresAt time.Time) error {
+			return model.ConsumeLoginToken(database.FromContext(ctx), id, expiresAt)
+		},
+		targetUrl:   defaultTargetUrl,
+		recoveryUrl: defaultRecoveryUrl,
+	}
+}
+
+// TokenBasedLoginUrl returns a single-use login URL. The purpose is either
+// PurposeLogin or PurposeRegisterCredential.
+func TokenBasedLoginUrl(lc LoginConfig, userID uint, expiresIn time.Duration, purpose string) (*url.URL, error) {
+	return newTokenBasedLoginService(lc).getLoginUrl(userID, expiresIn, purpose)
 }
 
 func (ls *tokenBasedLoginService) parseToken(signedToken string) (*jwt.Token, error) {
 	return jwt.ParseWithClaims(signedToken, &loginClaims{}, func(token *jwt.Token) (interface{}, error) {
 		return ls.key, nil
-	}, jwt.WithValidMethods(ls.validMethods), jwt.WithExpirationRequired(), jwt.WithLeeway(30*time.Second))
+	}, jwt.WithValidMethods(ls.validMethods), jwt.WithExpirationRequired(), jwt.WithLeeway(loginTokenLeeway))
 }
 
-func (ls *tokenBasedLoginService) getLoginUrl(userID uint, expiresIn time.Duration) (*url.URL, error) {
-	characters := "ABCDEFGHIJKLMOPQRSTUVWXYZabcdefghijklmopqrstuvwxyz0123456789"
-	id_runes := make([]byte, 4)
-	for i := range id_runes {
-		id_runes[i] = characters[rand.Intn(len(characters))]
+func (ls *tokenBasedLoginService) getLoginUrl(userID uint, expiresIn time.Duration, purpose string) (*url.URL, error) {
+	if purpose != PurposeLogin && purpose != PurposeRegisterCredential {
+		return nil, errJwtInvalidPurpose
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, &jwt.RegisteredClaims{
-		ExpiresAt: jwt.NewNumericDate(time.Now().Add(expiresIn)),
-		ID:        string(id_runes),
-		Subject:   fmt.Sprintf("%x", userID),
+	// Random UUIDs are generated with crypto/rand
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, &loginClaims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(expiresIn)),
+			ID:        uuid.NewString(),
+			Subject:   fmt.Sprintf("%x", userID),
+		},
+		Purpose: purpose,
 	})
 
 	sigendToken, err := token.SignedString(ls.key)
@@ -133,7 +196,10 @@ func (ls *tokenBasedLoginService) getLoginUrl(userID uint, expiresIn time.Durati
 }
 
 func (ls *tokenBasedLoginService) handler(w http.ResponseWriter, r *http.Request) {
-	defer http.Redirect(w, r, fmt.Sprint(&ls.targetUrl), http.StatusSeeOther)
+	targetUrl := ls.targetUrl
+	defer func() {
+		http.Redirect(w, r, fmt.Sprint(&targetUrl), http.StatusSeeOther)
+	}()
 
 	logger := httplog.LogEntry(r.Context())
 	signedToken := chi.URLParam(r, "token")
@@ -143,17 +209,27 @@ func (ls *tokenBasedLoginService) handler(w http.ResponseWriter, r *http.Request
 		return
 	}
 
-	uID, err := token.Claims.(*loginClaims).UserID()
+	claims := token.Claims.(*loginClaims)
+	uID, err := claims.UserID()
 	if err != nil {
 		logger.Warn(fmt.Sprintf("cannot get user ID: %v", err))
 		return
 	}
 
-	if err := ls.loginUser(r.Context(), model.LoginOpt{UserID: &uID}); err != nil {
+	if err := ls.consumeToken(r.Context(), claims.ID, claims.ExpiresAt.Time.Add(loginTokenLeeway)); err != nil {
+		logger.Warn(fmt.Sprintf("cannot consume token: %v", err))
+		return
+	}
+
+	if err := ls.loginUser(r.Context(), model.LoginOpt{UserID: &uID, AuthnContext: model.RecoveryURLAuthnContext(), Restricted: claims.Restricted()}); err != nil {
 		logger.Warn(fmt.Sprintf("cannot login usere: %v", err))
 		return
 	}
 
+	if claims.Restricted() {
+		targetUrl = ls.recoveryUrl
+	}
+
 	// w.Write([]byte(`<!DOCTYPE html>
 	// <html>
 	// <head><meta http-equiv="refresh" content="0; url='/'"></head>
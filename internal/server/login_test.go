@@ -22,22 +22,27 @@ func TestRedirectWhenLoggedOut(t *testing.T) {
 	type testCase struct {
 		expectedStatus int
 		fromCtx        func(context.Context) *model.User
+		stepUp         bool
 	}
 
-	_orig := defaultUserFromContext
+	_orig, _origStepUp := defaultUserFromContext, defaultStepUpRequired
 	defer func() {
-		defaultUserFromContext = _orig
+		defaultUserFromContext, defaultStepUpRequired = _orig, _origStepUp
 	}()
 
 	for _, tc := range []testCase{
 		{http.StatusOK, func(context.Context) *model.User {
 			return nil
-		}},
+		}, false},
 		{http.StatusSeeOther, func(context.Context) *model.User {
 			return &model.User{Model: gorm.Model{ID: 1}}
-		}},
+		}, false},
+		{http.StatusOK, func(context.Context) *model.User {
+			return &model.User{Model: gorm.Model{ID: 1}}
+		}, true},
 	} {
 		defaultUserFromContext = tc.fromCtx
+		defaultStepUpRequired = func(context.Context, string) bool { return tc.stepUp }
 		handler := redirectWhenLoggedIn(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			fmt.Fprintln(w, "Ok")
 		}))
@@ -115,7 +120,7 @@ func FuzzTokenBasedLoginServiceGetLoginUrl(f *testing.F) {
 		f.Add(uint(rand.Int()))
 	}
 	f.Fuzz(func(t *testing.T, id uint) {
-		out, err := ls.getLoginUrl(id, time.Second)
+		out, err := ls.getLoginUrl(id, time.Second, PurposeLogin)
 		if err != nil {
 			t.Fatalf("URL for %v: failed: %v", id, err)
 		}
@@ -127,8 +132,8 @@ func FuzzTokenBasedLoginServiceGetLoginUrl(f *testing.F) {
 }
 
 func TestTokenBasedLoginServiceHandler(t *testing.T) {
-	newUrl := func(ls *tokenBasedLoginService, userID uint, expiresIn time.Duration) string {
-		url, _ := ls.getLoginUrl(userID, expiresIn)
+	newUrl := func(ls *tokenBasedLoginService, userID uint, expiresIn time.Duration, purpose string) string {
+		url, _ := ls.getLoginUrl(userID, expiresIn, purpose)
 		return fmt.Sprint(url)
 	}
 	newRequest := func(url string) *http.Request {
@@ -138,23 +143,53 @@ func TestTokenBasedLoginServiceHandler(t *testing.T) {
 		key:          []byte("abcd"),
 		validMethods: []string{"HS256"},
 		baseUrl:      url.URL{Scheme: "https", Host: "example.com", Path: "/login"},
+		targetUrl:    defaultTargetUrl,
+		recoveryUrl:  defaultRecoveryUrl,
+	}
+
+	usedTokens := map[string]bool{}
+	ls.consumeToken = func(ctx context.Context, id string, expiresAt time.Time) error {
+		if usedTokens[id] {
+			return fmt.Errorf("login token already used")
+		}
+		if expiresAt.Before(time.Now().Add(loginTokenLeeway)) {
+			t.Errorf("expected token to be kept beyond the leeway but got %v", expiresAt)
+		}
+		usedTokens[id] = true
+		return nil
 	}
 
 	handler := chi.NewRouter()
 	handler.Get("/login/{token}", ls.handler)
 
+	loginUrl := newUrl(&ls, 1, 10*time.Second, PurposeLogin)
+	noLogin := func(opt model.LoginOpt) {
+		t.Error("func should not be called because of an invalid or used token")
+	}
+
 	for _, tc := range []struct {
-		expectLogin func(model.LoginOpt)
-		req         *http.Request
+		expectLogin      func(model.LoginOpt)
+		req              *http.Request
+		expectedLocation string
 	}{
-		{func(opt model.LoginOpt) {
-			t.Error("func should not be called because of an invalid token")
-		}, newRequest("http://example.com/login/invalid")},
+		{noLogin, newRequest("http://example.com/login/invalid"), "/"},
 		{func(lo model.LoginOpt) {
 			if lo.UserID == nil || *lo.UserID != 1 {
 				t.Errorf("cannot login with valid user ID")
 			}
-		}, newRequest(newUrl(&ls, 1, 10*time.Second))},
+			if lo.AuthnContext.Acr != model.AcrRecoveryURL {
+				t.Errorf("expected recovery acr but got %#v", lo.AuthnContext.Acr)
+			}
+			if lo.Restricted {
+				t.Errorf("expected unrestricted session")
+			}
+		}, newRequest(loginUrl), "/"},
+		{noLogin, newRequest(loginUrl), "/"},
+		{func(lo model.LoginOpt) {
+			if lo.UserID == nil || *lo.UserID != 2 || !lo.Restricted {
+				t.Errorf("expected restricted session of user 2 but got %#v", lo)
+			}
+		}, newRequest(newUrl(&ls, 2, 10*time.Second, PurposeRegisterCredential)), "/login/recovery"},
 	} {
 		ls.loginUser = func(ctx context.Context, opt model.LoginOpt) error {
 			tc.expectLogin(opt)
@@ -169,6 +204,12 @@ func TestTokenBasedLoginServiceHandler(t *testing.T) {
 		if resp.StatusCode != http.StatusSeeOther {
 			t.Errorf("expected status code %v but got %v", http.StatusSeeOther, resp.StatusCode)
 		}
+		if location := resp.Header.Get("Location"); location != tc.expectedLocation {
+			t.Errorf("expected location %#v but got %#v", tc.expectedLocation, location)
+		}
 	}
 
+	if _, err := ls.getLoginUrl(1, time.Second, "admin"); err == nil {
+		t.Errorf("expected error because of unknown purpose")
+	}
 }
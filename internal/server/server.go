@@ -1,10 +1,12 @@
 package server
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"net/http"
 	"net/http/fcgi"
+	"net/url"
 	"time"
 
 	"github.com/99designs/gqlgen/graphql/handler"
@@ -14,6 +16,11 @@ import (
 	"github.com/seb-schulz/onegate/graph"
 	"github.com/seb-schulz/onegate/internal/auth"
 	"github.com/seb-schulz/onegate/internal/database"
+	"github.com/seb-schulz/onegate/internal/invitation"
+	"github.com/seb-schulz/onegate/internal/mail"
+	"github.com/seb-schulz/onegate/internal/model"
+	"github.com/seb-schulz/onegate/internal/pow"
+	"github.com/seb-schulz/onegate/internal/scim"
 	"github.com/seb-schulz/onegate/internal/sessionmgr"
 	"github.com/seb-schulz/onegate/internal/ui"
 	"github.com/seb-schulz/onegate/internal/usermgr"
@@ -32,9 +39,22 @@ type (
 		Webauthn                webauthn.Config
 		Limit                   RouterLimitConfig
 		SessionKey              []byte
+		SessionCookieDomain     string
+		ReauthenticateWithin    time.Duration
 		UserRegistrationEnabled bool
-		Login                   LoginConfig
-		Auth                    auth.Config
+		ProofOfWork             pow.Config
+		RegistrationApproval    bool
+		ApprovalWebhook         string
+		Invitation              invitation.Config
+		AdminGroup              string
+		ProfileAttributes       []model.AttributeDefinition
+		// Mail configures the delivery of verification links to
+		// EmailVerificationUrl. Emails are disabled without transport.
+		Mail                 mail.Config
+		EmailVerificationUrl url.URL
+		Login                LoginConfig
+		Auth                 auth.Config
+		SCIM                 scim.Config
 	}
 
 	ServerConfig struct {
@@ -55,11 +75,22 @@ func newRouter(config *RouterConfig) (http.Handler, error) {
 		return nil, err
 	}
 
+	if err := auth.CheckSubjectSalt(database.WithContext(context.Background(), db), config.Auth.SubjectSalt); err != nil {
+		return nil, err
+	}
+
 	webAuthn, err := webauthn.New(&config.Webauthn)
 	if err != nil {
 		return nil, fmt.Errorf("cannot configure WebAuth: %v", err)
 	}
 
+	var mailer mail.Mailer
+	if config.Mail.Transport != "" {
+		if mailer, err = mail.New(config.Mail); err != nil {
+			return nil, fmt.Errorf("cannot configure mailer: %v", err)
+		}
+	}
+
 	r := chi.NewRouter()
 	r.Use(contentSecurityPolicyMiddleware)
 	r.Use(ui.InitTemplateContext)
@@ -67,20 +98,37 @@ func newRouter(config *RouterConfig) (http.Handler, error) {
 		r.Use(loggerMiddleware)
 		r.Use(httprate.LimitByRealIP(config.Limit.RequestLimit, config.Limit.WindowLength))
 		r.Use(database.Middleware(db))
-		r.Use(sessionmgr.DefaultMiddleware(config.SessionKey))
+		r.Use(sessionmgr.DefaultMiddleware(config.SessionKey, sessionmgr.WithCookieDomain(config.SessionCookieDomain)))
 
 		r.Mount("/auth", auth.NewHandler(&config.Auth))
+		r.Mount("/email", newEmailRoute())
 
 		r.Group(func(r chi.Router) {
 			r.Use(usermgr.Middleware)
 			r.Use(csrfMitigationMiddleware)
 
-			r.With(auth.RedirectWhenLoggedInAndAssigned("/auth/callback")).Mount("/login", newLoginRoute(config.Login))
+			r.With(
+				auth.RedirectWhenLoggedInAndAssigned(url.URL{Path: "/auth/callback"}, url.URL{Path: "/auth/saml/sso"}),
+				auth.RedirectWhenLoggedInToForwardTarget(config.Auth.ForwardAuthRules),
+			).Mount("/login", newLoginRoute(config.Login))
 
 			srv := handler.NewDefaultServer(graph.NewExecutableSchema(graph.Config{Resolvers: &graph.Resolver{
-				DB:                      db,
-				WebAuthn:                webAuthn,
-				UserRegistrationEnabled: config.UserRegistrationEnabled,
+				DB:                        db,
+				WebAuthn:                  webAuthn,
+				UserRegistrationEnabled:   config.UserRegistrationEnabled,
+				LoginMode:                 config.Login.Mode,
+				DecoyKey:                  config.SessionKey,
+				ConditionalLoginExpiresIn: config.Login.ConditionalExpiresIn,
+				ProofOfWork:               config.ProofOfWork,
+				ReauthenticateWithin:      config.ReauthenticateWithin,
+				RegistrationApproval:      config.RegistrationApproval,
+				ApprovalWebhook:           config.ApprovalWebhook,
+				Invitation:                config.Invitation,
+				AdminGroup:                config.AdminGroup,
+				ProfileAttributes:         config.ProfileAttributes,
+				Mailer:                    mailer,
+				EmailVerificationUrl:      config.EmailVerificationUrl,
+				Auth:                      config.Auth,
 			}}))
 
 			r.Handle("/query", srv)
@@ -90,6 +138,17 @@ func newRouter(config *RouterConfig) (http.Handler, error) {
 		r.Get("/*", ui.Template("index.html.tmpl"))
 	})
 
+	// Provisioning clients authenticate with bearer tokens instead of sessions
+	if len(config.SCIM.BearerToken) > 0 {
+		r.Group(func(r chi.Router) {
+			r.Use(loggerMiddleware)
+			r.Use(httprate.LimitByRealIP(config.Limit.RequestLimit, config.Limit.WindowLength))
+			r.Use(database.Middleware(db))
+
+			r.Mount("/scim/v2", scim.NewHandler(&config.SCIM))
+		})
+	}
+
 	r.Group(func(r chi.Router) {
 		r.Handle("/favicon.ico", ui.PublicFile())
 		r.Handle("/robots.txt", ui.PublicFile())
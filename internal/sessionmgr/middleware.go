@@ -12,11 +12,22 @@ import (
 
 type (
 	middleware struct {
-		key      []byte
-		newToken func() tokenizer
+		key          []byte
+		newToken     func() tokenizer
+		cookieDomain string
 	}
+
+	MiddlewareOptFunc func(*middleware)
 )
 
+// WithCookieDomain shares the session cookie with subdomains of domain, e.g.
+// for applications protected by forward authentication.
+func WithCookieDomain(domain string) MiddlewareOptFunc {
+	return func(m *middleware) {
+		m.cookieDomain = domain
+	}
+}
+
 func (s *middleware) setCookie(w http.ResponseWriter, token tokenSigner) {
 	sToken, err := token.sign(s.key)
 	if err != nil {
@@ -30,6 +41,7 @@ func (s *middleware) setCookie(w http.ResponseWriter, token tokenSigner) {
 		HttpOnly: true,
 		SameSite: http.SameSiteStrictMode,
 		Path:     "/",
+		Domain:   s.cookieDomain,
 	})
 }
 
@@ -68,7 +80,10 @@ func (s *middleware) Handler(next http.Handler) http.Handler {
 
 }
 
-func DefaultMiddleware(key []byte) func(next http.Handler) http.Handler {
-	s := middleware{key, newToken}
+func DefaultMiddleware(key []byte, opts ...MiddlewareOptFunc) func(next http.Handler) http.Handler {
+	s := middleware{key: key, newToken: newToken}
+	for _, o := range opts {
+		o(&s)
+	}
 	return s.Handler
 }
@@ -441,3 +441,38 @@ func FuzzSessionMiddleware_context(f *testing.F) {
 		})
 	})
 }
+
+func TestSessionMiddleware_cookieDomain(t *testing.T) {
+	m := middleware{
+		key: []byte("key"),
+		newToken: func() tokenizer {
+			return &mockToken{
+				signFn: func(s *mockToken, k []byte) ([]byte, error) {
+					return []byte("token"), nil
+				},
+				parseFn: func(s *mockToken, key []byte, token []byte) error {
+					return fmt.Errorf("invalid token")
+				},
+				initFn: func(s *mockToken) {},
+			}
+		},
+	}
+	WithCookieDomain("example.com")(&m)
+
+	handler := m.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, newCustomRequest())
+
+	counter := 0
+	foreachCookie(w.Result(), "session", func(cookie *http.Cookie) {
+		counter++
+		if cookie.Domain != "example.com" {
+			t.Errorf("expected cookie domain example.com but got %#v", cookie.Domain)
+		}
+	})
+
+	if counter != 1 {
+		t.Errorf("countend %d cookies instead of 1", counter)
+	}
+}
@@ -13,13 +13,21 @@ import { TypedDocumentNode as DocumentNode } from '@graphql-typed-document-node/
  * Therefore it is highly recommended to use the babel or swc plugin for production.
  */
 const documents = {
-    "\nmutation createUser($name: String!) {\n  createUser(name: $name)\n}\n": types.CreateUserDocument,
+    "\nquery exportMyData {\n  exportMyData\n}\n": types.ExportMyDataDocument,
+    "\nmutation deleteAccount {\n  deleteAccount\n}\n": types.DeleteAccountDocument,
+    "\nmutation createUser($name: String, $invite: String, $proof: ProofOfWorkInput) {\n  createUser(name: $name, invite: $invite, proof: $proof)\n}\n": types.CreateUserDocument,
     "\nmutation addCredential($body: CredentialCreationResponse!) {\n    addCredential(body: $body)\n}\n": types.AddCredentialDocument,
-    "\nmutation beginLogin {\n  beginLogin\n}\n": types.BeginLoginDocument,
-    "\nmutation validateLogin($body: CredentialRequestResponse!) {\n    validateLogin(body: $body) {\n        redirectURL\n    }\n}\n": types.ValidateLoginDocument,
+    "\nmutation beginLogin($transaction: String, $proof: ProofOfWorkInput, $conditional: Boolean) {\n  beginLogin(transaction: $transaction, proof: $proof, conditional: $conditional)\n}\n": types.BeginLoginDocument,
+    "\nmutation beginUsernameLogin($name: String!, $transaction: String, $proof: ProofOfWorkInput) {\n  beginUsernameLogin(name: $name, transaction: $transaction, proof: $proof)\n}\n": types.BeginUsernameLoginDocument,
+    "\nmutation validateLogin($body: CredentialRequestResponse!, $transaction: String) {\n    validateLogin(body: $body, transaction: $transaction) {\n        redirectURL\n    }\n}\n": types.ValidateLoginDocument,
+    "\nmutation recoverAccount($code: String!, $proof: ProofOfWorkInput) {\n  recoverAccount(code: $code, proof: $proof)\n}\n": types.RecoverAccountDocument,
+    "\nmutation generateRecoveryCodes {\n  generateRecoveryCodes\n}\n": types.GenerateRecoveryCodesDocument,
+    "\nmutation beginReauthentication {\n  beginReauthentication\n}\n": types.BeginReauthenticationDocument,
+    "\nmutation completeReauthentication($body: CredentialRequestResponse!) {\n  completeReauthentication(body: $body)\n}\n": types.CompleteReauthenticationDocument,
+    "\nquery proofOfWork {\n  proofOfWork {\n    challenge\n    difficulty\n  }\n}\n": types.ProofOfWorkDocument,
     "\nquery credentials {\n  credentials {\n    id\n    description\n    createdAt\n    updatedAt\n    lastLogin\n  }\n}": types.CredentialsDocument,
     "\nmutation updateCredential($id: ID!, $description: String) {\n  updateCredential(id: $id, description: $description) {\n    id\n  }\n}": types.UpdateCredentialDocument,
-    "\nmutation initCredential {\n    initCredential\n}\n": types.InitCredentialDocument,
+    "\nmutation initCredential($proof: ProofOfWorkInput) {\n    initCredential(proof: $proof)\n}\n": types.InitCredentialDocument,
     "\nmutation removeCredential($id: ID!) {\n    removeCredential(id: $id)\n}\n": types.RemoveCredentialDocument,
     "\nmutation updateMe($name: String, $displayName: String) {\n  updateMe(name: $name, displayName: $displayName) {\n    name\n  }\n}\n": types.UpdateMeDocument,
     "\nquery me {\n  me {\n    displayName\n    name\n  }\n}": types.MeDocument,
@@ -44,7 +52,15 @@ export function gql(source: string): unknown;
 /**
  * The gql function is used to parse GraphQL queries into a document that can be used by GraphQL clients.
  */
-export function gql(source: "\nmutation createUser($name: String!) {\n  createUser(name: $name)\n}\n"): (typeof documents)["\nmutation createUser($name: String!) {\n  createUser(name: $name)\n}\n"];
+export function gql(source: "\nquery exportMyData {\n  exportMyData\n}\n"): (typeof documents)["\nquery exportMyData {\n  exportMyData\n}\n"];
+/**
+ * The gql function is used to parse GraphQL queries into a document that can be used by GraphQL clients.
+ */
+export function gql(source: "\nmutation deleteAccount {\n  deleteAccount\n}\n"): (typeof documents)["\nmutation deleteAccount {\n  deleteAccount\n}\n"];
+/**
+ * The gql function is used to parse GraphQL queries into a document that can be used by GraphQL clients.
+ */
+export function gql(source: "\nmutation createUser($name: String, $invite: String, $proof: ProofOfWorkInput) {\n  createUser(name: $name, invite: $invite, proof: $proof)\n}\n"): (typeof documents)["\nmutation createUser($name: String, $invite: String, $proof: ProofOfWorkInput) {\n  createUser(name: $name, invite: $invite, proof: $proof)\n}\n"];
 /**
  * The gql function is used to parse GraphQL queries into a document that can be used by GraphQL clients.
  */
@@ -52,11 +68,35 @@ export function gql(source: "\nmutation addCredential($body: CredentialCreationR
 /**
  * The gql function is used to parse GraphQL queries into a document that can be used by GraphQL clients.
  */
-export function gql(source: "\nmutation beginLogin {\n  beginLogin\n}\n"): (typeof documents)["\nmutation beginLogin {\n  beginLogin\n}\n"];
+export function gql(source: "\nmutation beginLogin($transaction: String, $proof: ProofOfWorkInput, $conditional: Boolean) {\n  beginLogin(transaction: $transaction, proof: $proof, conditional: $conditional)\n}\n"): (typeof documents)["\nmutation beginLogin($transaction: String, $proof: ProofOfWorkInput, $conditional: Boolean) {\n  beginLogin(transaction: $transaction, proof: $proof, conditional: $conditional)\n}\n"];
+/**
+ * The gql function is used to parse GraphQL queries into a document that can be used by GraphQL clients.
+ */
+export function gql(source: "\nmutation beginUsernameLogin($name: String!, $transaction: String, $proof: ProofOfWorkInput) {\n  beginUsernameLogin(name: $name, transaction: $transaction, proof: $proof)\n}\n"): (typeof documents)["\nmutation beginUsernameLogin($name: String!, $transaction: String, $proof: ProofOfWorkInput) {\n  beginUsernameLogin(name: $name, transaction: $transaction, proof: $proof)\n}\n"];
+/**
+ * The gql function is used to parse GraphQL queries into a document that can be used by GraphQL clients.
+ */
+export function gql(source: "\nmutation validateLogin($body: CredentialRequestResponse!, $transaction: String) {\n    validateLogin(body: $body, transaction: $transaction) {\n        redirectURL\n    }\n}\n"): (typeof documents)["\nmutation validateLogin($body: CredentialRequestResponse!, $transaction: String) {\n    validateLogin(body: $body, transaction: $transaction) {\n        redirectURL\n    }\n}\n"];
+/**
+ * The gql function is used to parse GraphQL queries into a document that can be used by GraphQL clients.
+ */
+export function gql(source: "\nmutation recoverAccount($code: String!, $proof: ProofOfWorkInput) {\n  recoverAccount(code: $code, proof: $proof)\n}\n"): (typeof documents)["\nmutation recoverAccount($code: String!, $proof: ProofOfWorkInput) {\n  recoverAccount(code: $code, proof: $proof)\n}\n"];
+/**
+ * The gql function is used to parse GraphQL queries into a document that can be used by GraphQL clients.
+ */
+export function gql(source: "\nmutation generateRecoveryCodes {\n  generateRecoveryCodes\n}\n"): (typeof documents)["\nmutation generateRecoveryCodes {\n  generateRecoveryCodes\n}\n"];
+/**
+ * The gql function is used to parse GraphQL queries into a document that can be used by GraphQL clients.
+ */
+export function gql(source: "\nmutation beginReauthentication {\n  beginReauthentication\n}\n"): (typeof documents)["\nmutation beginReauthentication {\n  beginReauthentication\n}\n"];
+/**
+ * The gql function is used to parse GraphQL queries into a document that can be used by GraphQL clients.
+ */
+export function gql(source: "\nmutation completeReauthentication($body: CredentialRequestResponse!) {\n  completeReauthentication(body: $body)\n}\n"): (typeof documents)["\nmutation completeReauthentication($body: CredentialRequestResponse!) {\n  completeReauthentication(body: $body)\n}\n"];
 /**
  * The gql function is used to parse GraphQL queries into a document that can be used by GraphQL clients.
  */
-export function gql(source: "\nmutation validateLogin($body: CredentialRequestResponse!) {\n    validateLogin(body: $body) {\n        redirectURL\n    }\n}\n"): (typeof documents)["\nmutation validateLogin($body: CredentialRequestResponse!) {\n    validateLogin(body: $body) {\n        redirectURL\n    }\n}\n"];
+export function gql(source: "\nquery proofOfWork {\n  proofOfWork {\n    challenge\n    difficulty\n  }\n}\n"): (typeof documents)["\nquery proofOfWork {\n  proofOfWork {\n    challenge\n    difficulty\n  }\n}\n"];
 /**
  * The gql function is used to parse GraphQL queries into a document that can be used by GraphQL clients.
  */
@@ -68,7 +108,7 @@ export function gql(source: "\nmutation updateCredential($id: ID!, $description:
 /**
  * The gql function is used to parse GraphQL queries into a document that can be used by GraphQL clients.
  */
-export function gql(source: "\nmutation initCredential {\n    initCredential\n}\n"): (typeof documents)["\nmutation initCredential {\n    initCredential\n}\n"];
+export function gql(source: "\nmutation initCredential($proof: ProofOfWorkInput) {\n    initCredential(proof: $proof)\n}\n"): (typeof documents)["\nmutation initCredential($proof: ProofOfWorkInput) {\n    initCredential(proof: $proof)\n}\n"];
 /**
  * The gql function is used to parse GraphQL queries into a document that can be used by GraphQL clients.
  */
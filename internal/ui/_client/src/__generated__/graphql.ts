@@ -21,6 +21,25 @@ export type Scalars = {
   Time: { input: any; output: any; }
 };
 
+export type Attribute = {
+  __typename?: 'Attribute';
+  name: Scalars['String']['output'];
+  value: Scalars['String']['output'];
+};
+
+export type AttributeDefinition = {
+  __typename?: 'AttributeDefinition';
+  description: Scalars['String']['output'];
+  maxLength: Scalars['Int']['output'];
+  name: Scalars['String']['output'];
+  pattern: Scalars['String']['output'];
+};
+
+export type AttributeInput = {
+  name: Scalars['String']['input'];
+  value: Scalars['String']['input'];
+};
+
 export type Credential = {
   __typename?: 'Credential';
   createdAt: Scalars['Time']['output'];
@@ -30,14 +49,50 @@ export type Credential = {
   updatedAt: Scalars['Time']['output'];
 };
 
+export type EmailAddress = {
+  __typename?: 'EmailAddress';
+  address: Scalars['String']['output'];
+  createdAt: Scalars['Time']['output'];
+  id: Scalars['ID']['output'];
+  primary: Scalars['Boolean']['output'];
+  verified: Scalars['Boolean']['output'];
+};
+
+export type Group = {
+  __typename?: 'Group';
+  description: Scalars['String']['output'];
+  id: Scalars['ID']['output'];
+  name: Scalars['String']['output'];
+};
+
 export type Mutation = {
   __typename?: 'Mutation';
   addCredential: Scalars['Boolean']['output'];
+  addEmailAddress: EmailAddress;
+  addGroupMember: Group;
+  addRoleMember: Role;
+  approveUser: User;
   beginLogin: Scalars['CredentialAssertion']['output'];
+  beginReauthentication: Scalars['CredentialAssertion']['output'];
+  beginUsernameLogin: Scalars['CredentialAssertion']['output'];
+  completeReauthentication: Scalars['Boolean']['output'];
+  createGroup: Group;
+  createRole: Role;
   createUser: Scalars['CredentialCreation']['output'];
+  deleteAccount: Scalars['Boolean']['output'];
+  deleteGroup: Scalars['Boolean']['output'];
+  deleteRole: Scalars['Boolean']['output'];
+  generateRecoveryCodes: Array<Scalars['String']['output']>;
   initCredential: Scalars['CredentialCreation']['output'];
+  makePrimaryEmailAddress: EmailAddress;
+  recoverAccount: Scalars['Boolean']['output'];
   removeCredential: Scalars['Boolean']['output'];
+  removeEmailAddress: Scalars['Boolean']['output'];
+  removeGroupMember: Group;
+  removeRoleMember: Role;
+  rejectUser: User;
   removeSession: Scalars['Boolean']['output'];
+  resendEmailVerification: Scalars['Boolean']['output'];
   updateCredential: Credential;
   updateMe: User;
   validateLogin?: Maybe<SuccessfulLogin>;
@@ -49,21 +104,129 @@ export type MutationAddCredentialArgs = {
 };
 
 
+export type MutationAddEmailAddressArgs = {
+  address: Scalars['String']['input'];
+};
+
+
+export type MutationAddGroupMemberArgs = {
+  name: Scalars['String']['input'];
+  userID: Scalars['ID']['input'];
+};
+
+
+export type MutationAddRoleMemberArgs = {
+  name: Scalars['String']['input'];
+  userID: Scalars['ID']['input'];
+};
+
+
+export type MutationApproveUserArgs = {
+  id: Scalars['ID']['input'];
+};
+
+
+export type MutationBeginLoginArgs = {
+  conditional?: InputMaybe<Scalars['Boolean']['input']>;
+  proof?: InputMaybe<ProofOfWorkInput>;
+  transaction?: InputMaybe<Scalars['String']['input']>;
+};
+
+
+export type MutationBeginUsernameLoginArgs = {
+  name: Scalars['String']['input'];
+  proof?: InputMaybe<ProofOfWorkInput>;
+  transaction?: InputMaybe<Scalars['String']['input']>;
+};
+
+
+export type MutationCompleteReauthenticationArgs = {
+  body: Scalars['CredentialRequestResponse']['input'];
+};
+
+
+export type MutationCreateGroupArgs = {
+  description?: InputMaybe<Scalars['String']['input']>;
+  name: Scalars['String']['input'];
+};
+
+
+export type MutationCreateRoleArgs = {
+  description?: InputMaybe<Scalars['String']['input']>;
+  name: Scalars['String']['input'];
+};
+
+
 export type MutationCreateUserArgs = {
+  invite?: InputMaybe<Scalars['String']['input']>;
+  name?: InputMaybe<Scalars['String']['input']>;
+  proof?: InputMaybe<ProofOfWorkInput>;
+};
+
+
+export type MutationDeleteGroupArgs = {
   name: Scalars['String']['input'];
 };
 
 
+export type MutationDeleteRoleArgs = {
+  name: Scalars['String']['input'];
+};
+
+
+export type MutationInitCredentialArgs = {
+  proof?: InputMaybe<ProofOfWorkInput>;
+};
+
+
+export type MutationMakePrimaryEmailAddressArgs = {
+  id: Scalars['ID']['input'];
+};
+
+
+export type MutationRecoverAccountArgs = {
+  code: Scalars['String']['input'];
+  proof?: InputMaybe<ProofOfWorkInput>;
+};
+
+
+export type MutationRejectUserArgs = {
+  id: Scalars['ID']['input'];
+};
+
+
 export type MutationRemoveCredentialArgs = {
   id: Scalars['ID']['input'];
 };
 
 
+export type MutationRemoveEmailAddressArgs = {
+  id: Scalars['ID']['input'];
+};
+
+
+export type MutationRemoveGroupMemberArgs = {
+  name: Scalars['String']['input'];
+  userID: Scalars['ID']['input'];
+};
+
+
+export type MutationRemoveRoleMemberArgs = {
+  name: Scalars['String']['input'];
+  userID: Scalars['ID']['input'];
+};
+
+
 export type MutationRemoveSessionArgs = {
   id: Scalars['ID']['input'];
 };
 
 
+export type MutationResendEmailVerificationArgs = {
+  id: Scalars['ID']['input'];
+};
+
+
 export type MutationUpdateCredentialArgs = {
   description?: InputMaybe<Scalars['String']['input']>;
   id: Scalars['ID']['input'];
@@ -71,13 +234,29 @@ export type MutationUpdateCredentialArgs = {
 
 
 export type MutationUpdateMeArgs = {
+  attributes?: InputMaybe<Array<AttributeInput>>;
   displayName?: InputMaybe<Scalars['String']['input']>;
+  locale?: InputMaybe<Scalars['String']['input']>;
   name?: InputMaybe<Scalars['String']['input']>;
+  picture?: InputMaybe<Scalars['String']['input']>;
+  zoneinfo?: InputMaybe<Scalars['String']['input']>;
 };
 
 
 export type MutationValidateLoginArgs = {
   body: Scalars['CredentialRequestResponse']['input'];
+  transaction?: InputMaybe<Scalars['String']['input']>;
+};
+
+export type ProofOfWorkChallenge = {
+  __typename?: 'ProofOfWorkChallenge';
+  challenge: Scalars['String']['output'];
+  difficulty: Scalars['Int']['output'];
+};
+
+export type ProofOfWorkInput = {
+  challenge: Scalars['String']['input'];
+  solution: Scalars['String']['input'];
 };
 
 export type PubKeyCredParam = {
@@ -89,7 +268,13 @@ export type PubKeyCredParam = {
 export type Query = {
   __typename?: 'Query';
   credentials?: Maybe<Array<Maybe<Credential>>>;
+  exportMyData: Scalars['String']['output'];
+  groups: Array<Group>;
   me?: Maybe<User>;
+  pendingUsers: Array<User>;
+  profileAttributes: Array<AttributeDefinition>;
+  proofOfWork: ProofOfWorkChallenge;
+  roles: Array<Role>;
   sessions?: Maybe<Array<Maybe<Session>>>;
 };
 
@@ -99,6 +284,13 @@ export type RelyingParty = {
   name: Scalars['String']['output'];
 };
 
+export type Role = {
+  __typename?: 'Role';
+  description: Scalars['String']['output'];
+  id: Scalars['ID']['output'];
+  name: Scalars['String']['output'];
+};
+
 export type Session = {
   __typename?: 'Session';
   createdAt: Scalars['Time']['output'];
@@ -115,12 +307,34 @@ export type SuccessfulLogin = {
 
 export type User = {
   __typename?: 'User';
+  approvalStatus: Scalars['String']['output'];
+  attributes: Array<Attribute>;
   displayName: Scalars['String']['output'];
+  emails: Array<EmailAddress>;
+  groups: Array<Group>;
+  id: Scalars['ID']['output'];
+  locale: Scalars['String']['output'];
   name: Scalars['String']['output'];
+  picture: Scalars['String']['output'];
+  recoveryCodes: Scalars['Int']['output'];
+  roles: Array<Role>;
+  zoneinfo: Scalars['String']['output'];
 };
 
+export type ExportMyDataQueryVariables = Exact<{ [key: string]: never; }>;
+
+
+export type ExportMyDataQuery = { __typename?: 'Query', exportMyData: string };
+
+export type DeleteAccountMutationVariables = Exact<{ [key: string]: never; }>;
+
+
+export type DeleteAccountMutation = { __typename?: 'Mutation', deleteAccount: boolean };
+
 export type CreateUserMutationVariables = Exact<{
-  name: Scalars['String']['input'];
+  name?: InputMaybe<Scalars['String']['input']>;
+  invite?: InputMaybe<Scalars['String']['input']>;
+  proof?: InputMaybe<ProofOfWorkInput>;
 }>;
 
 
@@ -133,18 +347,62 @@ export type AddCredentialMutationVariables = Exact<{
 
 export type AddCredentialMutation = { __typename?: 'Mutation', addCredential: boolean };
 
-export type BeginLoginMutationVariables = Exact<{ [key: string]: never; }>;
+export type BeginLoginMutationVariables = Exact<{
+  transaction?: InputMaybe<Scalars['String']['input']>;
+  proof?: InputMaybe<ProofOfWorkInput>;
+  conditional?: InputMaybe<Scalars['Boolean']['input']>;
+}>;
 
 
 export type BeginLoginMutation = { __typename?: 'Mutation', beginLogin: any };
 
+export type BeginUsernameLoginMutationVariables = Exact<{
+  name: Scalars['String']['input'];
+  transaction?: InputMaybe<Scalars['String']['input']>;
+  proof?: InputMaybe<ProofOfWorkInput>;
+}>;
+
+
+export type BeginUsernameLoginMutation = { __typename?: 'Mutation', beginUsernameLogin: any };
+
 export type ValidateLoginMutationVariables = Exact<{
   body: Scalars['CredentialRequestResponse']['input'];
+  transaction?: InputMaybe<Scalars['String']['input']>;
 }>;
 
 
 export type ValidateLoginMutation = { __typename?: 'Mutation', validateLogin?: { __typename?: 'SuccessfulLogin', redirectURL: string } | null };
 
+export type RecoverAccountMutationVariables = Exact<{
+  code: Scalars['String']['input'];
+  proof?: InputMaybe<ProofOfWorkInput>;
+}>;
+
+
+export type RecoverAccountMutation = { __typename?: 'Mutation', recoverAccount: boolean };
+
+export type GenerateRecoveryCodesMutationVariables = Exact<{ [key: string]: never; }>;
+
+
+export type GenerateRecoveryCodesMutation = { __typename?: 'Mutation', generateRecoveryCodes: Array<string> };
+
+export type BeginReauthenticationMutationVariables = Exact<{ [key: string]: never; }>;
+
+
+export type BeginReauthenticationMutation = { __typename?: 'Mutation', beginReauthentication: any };
+
+export type CompleteReauthenticationMutationVariables = Exact<{
+  body: Scalars['CredentialRequestResponse']['input'];
+}>;
+
+
+export type CompleteReauthenticationMutation = { __typename?: 'Mutation', completeReauthentication: boolean };
+
+export type ProofOfWorkQueryVariables = Exact<{ [key: string]: never; }>;
+
+
+export type ProofOfWorkQuery = { __typename?: 'Query', proofOfWork: { __typename?: 'ProofOfWorkChallenge', challenge: string, difficulty: number } };
+
 export type CredentialsQueryVariables = Exact<{ [key: string]: never; }>;
 
 
@@ -158,7 +416,9 @@ export type UpdateCredentialMutationVariables = Exact<{
 
 export type UpdateCredentialMutation = { __typename?: 'Mutation', updateCredential: { __typename?: 'Credential', id: string } };
 
-export type InitCredentialMutationVariables = Exact<{ [key: string]: never; }>;
+export type InitCredentialMutationVariables = Exact<{
+  proof?: InputMaybe<ProofOfWorkInput>;
+}>;
 
 
 export type InitCredentialMutation = { __typename?: 'Mutation', initCredential: any };
@@ -196,13 +456,21 @@ export type RemoveSessionMutationVariables = Exact<{
 export type RemoveSessionMutation = { __typename?: 'Mutation', removeSession: boolean };
 
 
-export const CreateUserDocument = {"kind":"Document","definitions":[{"kind":"OperationDefinition","operation":"mutation","name":{"kind":"Name","value":"createUser"},"variableDefinitions":[{"kind":"VariableDefinition","variable":{"kind":"Variable","name":{"kind":"Name","value":"name"}},"type":{"kind":"NonNullType","type":{"kind":"NamedType","name":{"kind":"Name","value":"String"}}}}],"selectionSet":{"kind":"SelectionSet","selections":[{"kind":"Field","name":{"kind":"Name","value":"createUser"},"arguments":[{"kind":"Argument","name":{"kind":"Name","value":"name"},"value":{"kind":"Variable","name":{"kind":"Name","value":"name"}}}]}]}}]} as unknown as DocumentNode<CreateUserMutation, CreateUserMutationVariables>;
+export const ExportMyDataDocument = {"kind":"Document","definitions":[{"kind":"OperationDefinition","operation":"query","name":{"kind":"Name","value":"exportMyData"},"selectionSet":{"kind":"SelectionSet","selections":[{"kind":"Field","name":{"kind":"Name","value":"exportMyData"}}]}}]} as unknown as DocumentNode<ExportMyDataQuery, ExportMyDataQueryVariables>;
+export const DeleteAccountDocument = {"kind":"Document","definitions":[{"kind":"OperationDefinition","operation":"mutation","name":{"kind":"Name","value":"deleteAccount"},"selectionSet":{"kind":"SelectionSet","selections":[{"kind":"Field","name":{"kind":"Name","value":"deleteAccount"}}]}}]} as unknown as DocumentNode<DeleteAccountMutation, DeleteAccountMutationVariables>;
+export const CreateUserDocument = {"kind":"Document","definitions":[{"kind":"OperationDefinition","operation":"mutation","name":{"kind":"Name","value":"createUser"},"variableDefinitions":[{"kind":"VariableDefinition","variable":{"kind":"Variable","name":{"kind":"Name","value":"name"}},"type":{"kind":"NamedType","name":{"kind":"Name","value":"String"}}},{"kind":"VariableDefinition","variable":{"kind":"Variable","name":{"kind":"Name","value":"invite"}},"type":{"kind":"NamedType","name":{"kind":"Name","value":"String"}}},{"kind":"VariableDefinition","variable":{"kind":"Variable","name":{"kind":"Name","value":"proof"}},"type":{"kind":"NamedType","name":{"kind":"Name","value":"ProofOfWorkInput"}}}],"selectionSet":{"kind":"SelectionSet","selections":[{"kind":"Field","name":{"kind":"Name","value":"createUser"},"arguments":[{"kind":"Argument","name":{"kind":"Name","value":"name"},"value":{"kind":"Variable","name":{"kind":"Name","value":"name"}}},{"kind":"Argument","name":{"kind":"Name","value":"invite"},"value":{"kind":"Variable","name":{"kind":"Name","value":"invite"}}},{"kind":"Argument","name":{"kind":"Name","value":"proof"},"value":{"kind":"Variable","name":{"kind":"Name","value":"proof"}}}]}]}}]} as unknown as DocumentNode<CreateUserMutation, CreateUserMutationVariables>;
 export const AddCredentialDocument = {"kind":"Document","definitions":[{"kind":"OperationDefinition","operation":"mutation","name":{"kind":"Name","value":"addCredential"},"variableDefinitions":[{"kind":"VariableDefinition","variable":{"kind":"Variable","name":{"kind":"Name","value":"body"}},"type":{"kind":"NonNullType","type":{"kind":"NamedType","name":{"kind":"Name","value":"CredentialCreationResponse"}}}}],"selectionSet":{"kind":"SelectionSet","selections":[{"kind":"Field","name":{"kind":"Name","value":"addCredential"},"arguments":[{"kind":"Argument","name":{"kind":"Name","value":"body"},"value":{"kind":"Variable","name":{"kind":"Name","value":"body"}}}]}]}}]} as unknown as DocumentNode<AddCredentialMutation, AddCredentialMutationVariables>;
-export const BeginLoginDocument = {"kind":"Document","definitions":[{"kind":"OperationDefinition","operation":"mutation","name":{"kind":"Name","value":"beginLogin"},"selectionSet":{"kind":"SelectionSet","selections":[{"kind":"Field","name":{"kind":"Name","value":"beginLogin"}}]}}]} as unknown as DocumentNode<BeginLoginMutation, BeginLoginMutationVariables>;
-export const ValidateLoginDocument = {"kind":"Document","definitions":[{"kind":"OperationDefinition","operation":"mutation","name":{"kind":"Name","value":"validateLogin"},"variableDefinitions":[{"kind":"VariableDefinition","variable":{"kind":"Variable","name":{"kind":"Name","value":"body"}},"type":{"kind":"NonNullType","type":{"kind":"NamedType","name":{"kind":"Name","value":"CredentialRequestResponse"}}}}],"selectionSet":{"kind":"SelectionSet","selections":[{"kind":"Field","name":{"kind":"Name","value":"validateLogin"},"arguments":[{"kind":"Argument","name":{"kind":"Name","value":"body"},"value":{"kind":"Variable","name":{"kind":"Name","value":"body"}}}],"selectionSet":{"kind":"SelectionSet","selections":[{"kind":"Field","name":{"kind":"Name","value":"redirectURL"}}]}}]}}]} as unknown as DocumentNode<ValidateLoginMutation, ValidateLoginMutationVariables>;
+export const BeginLoginDocument = {"kind":"Document","definitions":[{"kind":"OperationDefinition","operation":"mutation","name":{"kind":"Name","value":"beginLogin"},"variableDefinitions":[{"kind":"VariableDefinition","variable":{"kind":"Variable","name":{"kind":"Name","value":"transaction"}},"type":{"kind":"NamedType","name":{"kind":"Name","value":"String"}}},{"kind":"VariableDefinition","variable":{"kind":"Variable","name":{"kind":"Name","value":"proof"}},"type":{"kind":"NamedType","name":{"kind":"Name","value":"ProofOfWorkInput"}}},{"kind":"VariableDefinition","variable":{"kind":"Variable","name":{"kind":"Name","value":"conditional"}},"type":{"kind":"NamedType","name":{"kind":"Name","value":"Boolean"}}}],"selectionSet":{"kind":"SelectionSet","selections":[{"kind":"Field","name":{"kind":"Name","value":"beginLogin"},"arguments":[{"kind":"Argument","name":{"kind":"Name","value":"transaction"},"value":{"kind":"Variable","name":{"kind":"Name","value":"transaction"}}},{"kind":"Argument","name":{"kind":"Name","value":"proof"},"value":{"kind":"Variable","name":{"kind":"Name","value":"proof"}}},{"kind":"Argument","name":{"kind":"Name","value":"conditional"},"value":{"kind":"Variable","name":{"kind":"Name","value":"conditional"}}}]}]}}]} as unknown as DocumentNode<BeginLoginMutation, BeginLoginMutationVariables>;
+export const BeginUsernameLoginDocument = {"kind":"Document","definitions":[{"kind":"OperationDefinition","operation":"mutation","name":{"kind":"Name","value":"beginUsernameLogin"},"variableDefinitions":[{"kind":"VariableDefinition","variable":{"kind":"Variable","name":{"kind":"Name","value":"name"}},"type":{"kind":"NonNullType","type":{"kind":"NamedType","name":{"kind":"Name","value":"String"}}}},{"kind":"VariableDefinition","variable":{"kind":"Variable","name":{"kind":"Name","value":"transaction"}},"type":{"kind":"NamedType","name":{"kind":"Name","value":"String"}}},{"kind":"VariableDefinition","variable":{"kind":"Variable","name":{"kind":"Name","value":"proof"}},"type":{"kind":"NamedType","name":{"kind":"Name","value":"ProofOfWorkInput"}}}],"selectionSet":{"kind":"SelectionSet","selections":[{"kind":"Field","name":{"kind":"Name","value":"beginUsernameLogin"},"arguments":[{"kind":"Argument","name":{"kind":"Name","value":"name"},"value":{"kind":"Variable","name":{"kind":"Name","value":"name"}}},{"kind":"Argument","name":{"kind":"Name","value":"transaction"},"value":{"kind":"Variable","name":{"kind":"Name","value":"transaction"}}},{"kind":"Argument","name":{"kind":"Name","value":"proof"},"value":{"kind":"Variable","name":{"kind":"Name","value":"proof"}}}]}]}}]} as unknown as DocumentNode<BeginUsernameLoginMutation, BeginUsernameLoginMutationVariables>;
+export const ValidateLoginDocument = {"kind":"Document","definitions":[{"kind":"OperationDefinition","operation":"mutation","name":{"kind":"Name","value":"validateLogin"},"variableDefinitions":[{"kind":"VariableDefinition","variable":{"kind":"Variable","name":{"kind":"Name","value":"body"}},"type":{"kind":"NonNullType","type":{"kind":"NamedType","name":{"kind":"Name","value":"CredentialRequestResponse"}}}},{"kind":"VariableDefinition","variable":{"kind":"Variable","name":{"kind":"Name","value":"transaction"}},"type":{"kind":"NamedType","name":{"kind":"Name","value":"String"}}}],"selectionSet":{"kind":"SelectionSet","selections":[{"kind":"Field","name":{"kind":"Name","value":"validateLogin"},"arguments":[{"kind":"Argument","name":{"kind":"Name","value":"body"},"value":{"kind":"Variable","name":{"kind":"Name","value":"body"}}},{"kind":"Argument","name":{"kind":"Name","value":"transaction"},"value":{"kind":"Variable","name":{"kind":"Name","value":"transaction"}}}],"selectionSet":{"kind":"SelectionSet","selections":[{"kind":"Field","name":{"kind":"Name","value":"redirectURL"}}]}}]}}]} as unknown as DocumentNode<ValidateLoginMutation, ValidateLoginMutationVariables>;
+export const RecoverAccountDocument = {"kind":"Document","definitions":[{"kind":"OperationDefinition","operation":"mutation","name":{"kind":"Name","value":"recoverAccount"},"variableDefinitions":[{"kind":"VariableDefinition","variable":{"kind":"Variable","name":{"kind":"Name","value":"code"}},"type":{"kind":"NonNullType","type":{"kind":"NamedType","name":{"kind":"Name","value":"String"}}}},{"kind":"VariableDefinition","variable":{"kind":"Variable","name":{"kind":"Name","value":"proof"}},"type":{"kind":"NamedType","name":{"kind":"Name","value":"ProofOfWorkInput"}}}],"selectionSet":{"kind":"SelectionSet","selections":[{"kind":"Field","name":{"kind":"Name","value":"recoverAccount"},"arguments":[{"kind":"Argument","name":{"kind":"Name","value":"code"},"value":{"kind":"Variable","name":{"kind":"Name","value":"code"}}},{"kind":"Argument","name":{"kind":"Name","value":"proof"},"value":{"kind":"Variable","name":{"kind":"Name","value":"proof"}}}]}]}}]} as unknown as DocumentNode<RecoverAccountMutation, RecoverAccountMutationVariables>;
+export const GenerateRecoveryCodesDocument = {"kind":"Document","definitions":[{"kind":"OperationDefinition","operation":"mutation","name":{"kind":"Name","value":"generateRecoveryCodes"},"selectionSet":{"kind":"SelectionSet","selections":[{"kind":"Field","name":{"kind":"Name","value":"generateRecoveryCodes"}}]}}]} as unknown as DocumentNode<GenerateRecoveryCodesMutation, GenerateRecoveryCodesMutationVariables>;
+export const BeginReauthenticationDocument = {"kind":"Document","definitions":[{"kind":"OperationDefinition","operation":"mutation","name":{"kind":"Name","value":"beginReauthentication"},"selectionSet":{"kind":"SelectionSet","selections":[{"kind":"Field","name":{"kind":"Name","value":"beginReauthentication"}}]}}]} as unknown as DocumentNode<BeginReauthenticationMutation, BeginReauthenticationMutationVariables>;
+export const CompleteReauthenticationDocument = {"kind":"Document","definitions":[{"kind":"OperationDefinition","operation":"mutation","name":{"kind":"Name","value":"completeReauthentication"},"variableDefinitions":[{"kind":"VariableDefinition","variable":{"kind":"Variable","name":{"kind":"Name","value":"body"}},"type":{"kind":"NonNullType","type":{"kind":"NamedType","name":{"kind":"Name","value":"CredentialRequestResponse"}}}}],"selectionSet":{"kind":"SelectionSet","selections":[{"kind":"Field","name":{"kind":"Name","value":"completeReauthentication"},"arguments":[{"kind":"Argument","name":{"kind":"Name","value":"body"},"value":{"kind":"Variable","name":{"kind":"Name","value":"body"}}}]}]}}]} as unknown as DocumentNode<CompleteReauthenticationMutation, CompleteReauthenticationMutationVariables>;
+export const ProofOfWorkDocument = {"kind":"Document","definitions":[{"kind":"OperationDefinition","operation":"query","name":{"kind":"Name","value":"proofOfWork"},"selectionSet":{"kind":"SelectionSet","selections":[{"kind":"Field","name":{"kind":"Name","value":"proofOfWork"},"selectionSet":{"kind":"SelectionSet","selections":[{"kind":"Field","name":{"kind":"Name","value":"challenge"}},{"kind":"Field","name":{"kind":"Name","value":"difficulty"}}]}}]}}]} as unknown as DocumentNode<ProofOfWorkQuery, ProofOfWorkQueryVariables>;
 export const CredentialsDocument = {"kind":"Document","definitions":[{"kind":"OperationDefinition","operation":"query","name":{"kind":"Name","value":"credentials"},"selectionSet":{"kind":"SelectionSet","selections":[{"kind":"Field","name":{"kind":"Name","value":"credentials"},"selectionSet":{"kind":"SelectionSet","selections":[{"kind":"Field","name":{"kind":"Name","value":"id"}},{"kind":"Field","name":{"kind":"Name","value":"description"}},{"kind":"Field","name":{"kind":"Name","value":"createdAt"}},{"kind":"Field","name":{"kind":"Name","value":"updatedAt"}},{"kind":"Field","name":{"kind":"Name","value":"lastLogin"}}]}}]}}]} as unknown as DocumentNode<CredentialsQuery, CredentialsQueryVariables>;
 export const UpdateCredentialDocument = {"kind":"Document","definitions":[{"kind":"OperationDefinition","operation":"mutation","name":{"kind":"Name","value":"updateCredential"},"variableDefinitions":[{"kind":"VariableDefinition","variable":{"kind":"Variable","name":{"kind":"Name","value":"id"}},"type":{"kind":"NonNullType","type":{"kind":"NamedType","name":{"kind":"Name","value":"ID"}}}},{"kind":"VariableDefinition","variable":{"kind":"Variable","name":{"kind":"Name","value":"description"}},"type":{"kind":"NamedType","name":{"kind":"Name","value":"String"}}}],"selectionSet":{"kind":"SelectionSet","selections":[{"kind":"Field","name":{"kind":"Name","value":"updateCredential"},"arguments":[{"kind":"Argument","name":{"kind":"Name","value":"id"},"value":{"kind":"Variable","name":{"kind":"Name","value":"id"}}},{"kind":"Argument","name":{"kind":"Name","value":"description"},"value":{"kind":"Variable","name":{"kind":"Name","value":"description"}}}],"selectionSet":{"kind":"SelectionSet","selections":[{"kind":"Field","name":{"kind":"Name","value":"id"}}]}}]}}]} as unknown as DocumentNode<UpdateCredentialMutation, UpdateCredentialMutationVariables>;
-export const InitCredentialDocument = {"kind":"Document","definitions":[{"kind":"OperationDefinition","operation":"mutation","name":{"kind":"Name","value":"initCredential"},"selectionSet":{"kind":"SelectionSet","selections":[{"kind":"Field","name":{"kind":"Name","value":"initCredential"}}]}}]} as unknown as DocumentNode<InitCredentialMutation, InitCredentialMutationVariables>;
+export const InitCredentialDocument = {"kind":"Document","definitions":[{"kind":"OperationDefinition","operation":"mutation","name":{"kind":"Name","value":"initCredential"},"variableDefinitions":[{"kind":"VariableDefinition","variable":{"kind":"Variable","name":{"kind":"Name","value":"proof"}},"type":{"kind":"NamedType","name":{"kind":"Name","value":"ProofOfWorkInput"}}}],"selectionSet":{"kind":"SelectionSet","selections":[{"kind":"Field","name":{"kind":"Name","value":"initCredential"},"arguments":[{"kind":"Argument","name":{"kind":"Name","value":"proof"},"value":{"kind":"Variable","name":{"kind":"Name","value":"proof"}}}]}]}}]} as unknown as DocumentNode<InitCredentialMutation, InitCredentialMutationVariables>;
 export const RemoveCredentialDocument = {"kind":"Document","definitions":[{"kind":"OperationDefinition","operation":"mutation","name":{"kind":"Name","value":"removeCredential"},"variableDefinitions":[{"kind":"VariableDefinition","variable":{"kind":"Variable","name":{"kind":"Name","value":"id"}},"type":{"kind":"NonNullType","type":{"kind":"NamedType","name":{"kind":"Name","value":"ID"}}}}],"selectionSet":{"kind":"SelectionSet","selections":[{"kind":"Field","name":{"kind":"Name","value":"removeCredential"},"arguments":[{"kind":"Argument","name":{"kind":"Name","value":"id"},"value":{"kind":"Variable","name":{"kind":"Name","value":"id"}}}]}]}}]} as unknown as DocumentNode<RemoveCredentialMutation, RemoveCredentialMutationVariables>;
 export const UpdateMeDocument = {"kind":"Document","definitions":[{"kind":"OperationDefinition","operation":"mutation","name":{"kind":"Name","value":"updateMe"},"variableDefinitions":[{"kind":"VariableDefinition","variable":{"kind":"Variable","name":{"kind":"Name","value":"name"}},"type":{"kind":"NamedType","name":{"kind":"Name","value":"String"}}},{"kind":"VariableDefinition","variable":{"kind":"Variable","name":{"kind":"Name","value":"displayName"}},"type":{"kind":"NamedType","name":{"kind":"Name","value":"String"}}}],"selectionSet":{"kind":"SelectionSet","selections":[{"kind":"Field","name":{"kind":"Name","value":"updateMe"},"arguments":[{"kind":"Argument","name":{"kind":"Name","value":"name"},"value":{"kind":"Variable","name":{"kind":"Name","value":"name"}}},{"kind":"Argument","name":{"kind":"Name","value":"displayName"},"value":{"kind":"Variable","name":{"kind":"Name","value":"displayName"}}}],"selectionSet":{"kind":"SelectionSet","selections":[{"kind":"Field","name":{"kind":"Name","value":"name"}}]}}]}}]} as unknown as DocumentNode<UpdateMeMutation, UpdateMeMutationVariables>;
 export const MeDocument = {"kind":"Document","definitions":[{"kind":"OperationDefinition","operation":"query","name":{"kind":"Name","value":"me"},"selectionSet":{"kind":"SelectionSet","selections":[{"kind":"Field","name":{"kind":"Name","value":"me"},"selectionSet":{"kind":"SelectionSet","selections":[{"kind":"Field","name":{"kind":"Name","value":"displayName"}},{"kind":"Field","name":{"kind":"Name","value":"name"}}]}}]}}]} as unknown as DocumentNode<MeQuery, MeQueryVariables>;
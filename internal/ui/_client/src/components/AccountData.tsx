@@ -0,0 +1,73 @@
+import { Button, Spinner, Stack } from "react-bootstrap";
+import { useTranslation } from "react-i18next";
+import * as urql from 'urql';
+import { gql } from "../__generated__/gql";
+import { useReauthenticated } from "../reauth";
+
+const EXPORT_MY_DATA_GQL = gql(`
+query exportMyData {
+  exportMyData
+}
+`)
+
+const DELETE_ACCOUNT_GQL = gql(`
+mutation deleteAccount {
+  deleteAccount
+}
+`)
+
+// AccountData downloads everything stored about the user and deletes the
+// account.
+export default function AccountData({ onError }: {
+    onError: (errMsg: string) => void
+}) {
+    const { t } = useTranslation();
+    const client = urql.useClient();
+    const [{ fetching }, deleteAccount] = urql.useMutation(DELETE_ACCOUNT_GQL);
+    const reauthenticated = useReauthenticated();
+
+    if (fetching) return <Spinner animation="border" />;
+
+    const handleExport = async (e: React.SyntheticEvent) => {
+        e.preventDefault();
+        e.stopPropagation();
+
+        const result = await client.query(EXPORT_MY_DATA_GQL, {}, { requestPolicy: 'network-only' }).toPromise();
+        if (result.error) {
+            onError(result.error.message);
+            return;
+        }
+
+        const url = URL.createObjectURL(new Blob([result.data?.exportMyData || ""], { type: "application/json" }));
+        const a = document.createElement("a");
+        a.href = url;
+        a.download = "onegate-data.json";
+        a.click();
+        URL.revokeObjectURL(url);
+    };
+
+    const handleDelete = async (e: React.SyntheticEvent) => {
+        e.preventDefault();
+        e.stopPropagation();
+
+        if (!window.confirm(t("Deleting your account removes all passkeys and logs you out of all applications. This cannot be undone. Continue?"))) return;
+
+        try {
+            const result = await reauthenticated(() => deleteAccount({}));
+            if (result.error) {
+                onError(result.error.message);
+                return;
+            }
+            window.location.href = "/";
+        } catch (error) {
+            onError((error as urql.CombinedError).message);
+        }
+    };
+
+    return (
+        <Stack direction="horizontal" gap={2}>
+            <Button variant="secondary" onClick={handleExport}>{t("Export my data")}</Button>
+            <Button variant="danger" onClick={handleDelete}>{t("Delete account")}</Button>
+        </Stack>
+    );
+}
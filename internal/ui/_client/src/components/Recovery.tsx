@@ -0,0 +1,113 @@
+import React, { useRef, useState } from "react";
+import { Button, Form, Spinner } from "react-bootstrap";
+import { useTranslation } from "react-i18next";
+import * as urql from 'urql';
+import { startRegistration } from '@simplewebauthn/browser';
+import { gql } from "../__generated__/gql";
+import { useProofOfWork } from "../proofOfWork";
+
+const RECOVER_ACCOUNT_GQL = gql(`
+mutation recoverAccount($code: String!, $proof: ProofOfWorkInput) {
+  recoverAccount(code: $code, proof: $proof)
+}
+`)
+
+const INIT_CREDENTIAL_QGL = gql(`
+mutation initCredential($proof: ProofOfWorkInput) {
+    initCredential(proof: $proof)
+}
+`)
+
+const ADD_CREDENTIAL_QGL = gql(`
+mutation addCredential($body: CredentialCreationResponse!) {
+    addCredential(body: $body)
+}
+`)
+
+// RecoveryForm redeems a recovery code and registers a new passkey with the
+// restricted session of the code or of a recovery URL.
+export default function RecoveryForm({ recovering, onError, onPasskeyAdded }: {
+    recovering?: boolean
+    onError: (errMsg: string) => void
+    onPasskeyAdded: () => void
+}) {
+    const { t } = useTranslation();
+    const codeRef = useRef<HTMLInputElement | null>(null);
+    const [recovered, setRecovered] = useState(!!recovering);
+    const [working, setWorking] = useState(false);
+    const [, recoverAccount] = urql.useMutation(RECOVER_ACCOUNT_GQL);
+    const [, initCredential] = urql.useMutation(INIT_CREDENTIAL_QGL);
+    const [, addCredential] = urql.useMutation(ADD_CREDENTIAL_QGL);
+    const proofOfWork = useProofOfWork();
+
+    if (working) return <Spinner animation="border" />;
+
+    const handleRecover = async (e: React.SyntheticEvent) => {
+        e.preventDefault();
+        e.stopPropagation();
+
+        const code = codeRef.current?.value;
+        if (!code) return;
+
+        setWorking(true);
+        try {
+            const proof = await proofOfWork();
+            const result = await recoverAccount({ code, proof });
+            if (result.error) {
+                onError(result.error.message);
+                return;
+            }
+            setRecovered(!!result.data?.recoverAccount);
+        } catch (error) {
+            onError((error as urql.CombinedError).message);
+        } finally {
+            setWorking(false);
+        }
+    };
+
+    const handleAddPasskey = async (e: React.SyntheticEvent) => {
+        e.preventDefault();
+        e.stopPropagation();
+
+        setWorking(true);
+        try {
+            const proof = await proofOfWork();
+            const result = await initCredential({ proof });
+            if (!result.data || !result.data.initCredential) {
+                onError(result.error?.message || t("cannot load data"));
+                return;
+            }
+
+            const attResp = await startRegistration(result.data.initCredential.publicKey);
+            const added = await addCredential({ body: JSON.stringify(attResp) });
+            if (!added?.data?.addCredential) {
+                onError(added.error?.message || t("cannot load data"));
+                return;
+            }
+            onPasskeyAdded();
+        } catch (error) {
+            onError((error as urql.CombinedError).message);
+        } finally {
+            setWorking(false);
+        }
+    };
+
+    if (recovered) {
+        return (
+            <>
+                <p>{t("Register a new passkey to regain access to your account.")}</p>
+                <Button disabled={!window.PublicKeyCredential} onClick={handleAddPasskey}>{t("Register new passkey")}</Button>
+            </>
+        );
+    }
+
+    return (
+        <Form onSubmit={handleRecover}>
+            <Form.Group className="mb-3" controlId="recoveryCode">
+                <Form.Label>{t("Recovery code")}</Form.Label>
+                <Form.Control required type="text" ref={codeRef} autoComplete="one-time-code" placeholder="XXXXX-XXXXX-XXXXX-XXXXX" />
+            </Form.Group>
+            <Button type="submit">{t("Recover account")}</Button>
+        </Form>
+    );
+}
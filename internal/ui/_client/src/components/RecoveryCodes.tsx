@@ -0,0 +1,54 @@
+import { useState } from "react";
+import { Alert, Button, Spinner } from "react-bootstrap";
+import { useTranslation } from "react-i18next";
+import * as urql from 'urql';
+import { gql } from "../__generated__/gql";
+import { useReauthenticated } from "../reauth";
+
+const GENERATE_RECOVERY_CODES_GQL = gql(`
+mutation generateRecoveryCodes {
+  generateRecoveryCodes
+}
+`)
+
+// RecoveryCodes generates recovery codes which are shown once.
+export default function RecoveryCodes({ onError }: {
+    onError: (errMsg: string) => void
+}) {
+    const { t } = useTranslation();
+    const [codes, setCodes] = useState<string[]>([]);
+    const [{ fetching }, generateRecoveryCodes] = urql.useMutation(GENERATE_RECOVERY_CODES_GQL);
+    const reauthenticated = useReauthenticated();
+
+    if (fetching) return <Spinner animation="border" />;
+
+    const handleGenerate = async (e: React.SyntheticEvent) => {
+        e.preventDefault();
+        e.stopPropagation();
+
+        if (!window.confirm(t("Generating new recovery codes invalidates all previous codes. Continue?"))) return;
+
+        try {
+            const result = await reauthenticated(() => generateRecoveryCodes({}));
+            if (result.error) {
+                onError(result.error.message);
+                return;
+            }
+            setCodes(result.data?.generateRecoveryCodes || []);
+        } catch (error) {
+            onError((error as urql.CombinedError).message);
+        }
+    };
+
+    return (
+        <>
+            {codes.length > 0 ? (
+                <Alert variant="warning">
+                    <p>{t("Store these codes in a safe place. Each code can be used once to register a new passkey if you lose all passkeys. They are not shown again.")}</p>
+                    <pre className="mb-0">{codes.join("\n")}</pre>
+                </Alert>
+            ) : ""}
+            <Button variant="secondary" onClick={handleGenerate}>{t("Generate recovery codes")}</Button>
+        </>
+    );
+}
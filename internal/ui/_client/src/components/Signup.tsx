@@ -4,13 +4,26 @@ import { useTranslation } from "react-i18next";
 import * as urql from 'urql';
 import { startRegistration } from '@simplewebauthn/browser';
 import { gql } from "../__generated__/gql";
+import { useProofOfWork } from "../proofOfWork";
 
 const CREATE_USER_GQL = gql(`
-mutation createUser($name: String!) {
-  createUser(name: $name)
+mutation createUser($name: String, $invite: String, $proof: ProofOfWorkInput) {
+  createUser(name: $name, invite: $invite, proof: $proof)
 }
 `)
 
+// invitedName returns the name preset by the invite token. The token is
+// verified by the server.
+function invitedName(invite: string | null): string {
+    if (!invite) return "";
+    try {
+        const payload = invite.split(".")[1].replace(/-/g, "+").replace(/_/g, "/");
+        return JSON.parse(atob(payload)).name || "";
+    } catch {
+        return "";
+    }
+}
+
 const ADD_PASSKEY_QGL = gql(`
 mutation addCredential($body: CredentialCreationResponse!) {
     addCredential(body: $body)
@@ -25,8 +38,11 @@ function SignupCard({ onUserCreated, onError, onPasskeyAdded }: {
     const { t } = useTranslation();
     const [validated, setValidated] = useState(false);
     const userNameRef = useRef<HTMLInputElement | null>(null);
+    const invite = new URLSearchParams(window.location.search).get("invite");
+    const presetName = invitedName(invite);
     const [{ fetching: loadingCreateUser }, createUser] = urql.useMutation(CREATE_USER_GQL);
     const [{ fetching: loadingAddPasskey }, addCredential] = urql.useMutation(ADD_PASSKEY_QGL);
+    const proofOfWork = useProofOfWork();
 
     if (loadingCreateUser || loadingAddPasskey) return <p>Loading...</p>;
 
@@ -40,7 +56,8 @@ function SignupCard({ onUserCreated, onError, onPasskeyAdded }: {
 
         const userName = userNameRef.current.value;
         try {
-            const result = await createUser({ name: userName });
+            const proof = await proofOfWork();
+            const result = await createUser({ name: userName, invite: invite, proof });
 
             if (!result.data || !result.data.createUser) {
                 onError("cannot load data");
@@ -68,7 +85,7 @@ function SignupCard({ onUserCreated, onError, onPasskeyAdded }: {
                 <Form noValidate validated={validated} onSubmit={handleSubmit}>
                     <Card.Text>
                         <Form.Label htmlFor="inputUserName">{t('Username')}</Form.Label>
-                        <Form.Control required type="text" id="inputUserName" ref={userNameRef} autoComplete="username webauthn" />
+                        <Form.Control required type="text" id="inputUserName" ref={userNameRef} autoComplete="username webauthn" defaultValue={presetName} readOnly={presetName !== ""} />
                     </Card.Text>
                     <Button type="submit" disabled={!window.PublicKeyCredential || loadingCreateUser || loadingAddPasskey}>{t('Register')}</Button>{' '}
                 </Form>
@@ -1,31 +1,46 @@
-import { Button, Spinner } from 'react-bootstrap';
+import { Button, Form, Spinner } from 'react-bootstrap';
 import * as urql from 'urql';
 import { gql } from '../__generated__/gql';
 import * as graphql from '../__generated__/graphql';
-import { startAuthentication } from '@simplewebauthn/browser';
-import { useEffect, useRef, useState } from 'react';
+import { browserSupportsWebAuthnAutofill, startAuthentication } from '@simplewebauthn/browser';
+import { useCallback, useEffect, useRef, useState } from 'react';
+import { useProofOfWork } from '../proofOfWork';
+import { useTranslation } from 'react-i18next';
 
 const BEGIN_LOGIN_QGL = gql(`
-mutation beginLogin {
-  beginLogin
+mutation beginLogin($transaction: String, $proof: ProofOfWorkInput, $conditional: Boolean) {
+  beginLogin(transaction: $transaction, proof: $proof, conditional: $conditional)
+}
+`);
+
+const BEGIN_USERNAME_LOGIN_QGL = gql(`
+mutation beginUsernameLogin($name: String!, $transaction: String, $proof: ProofOfWorkInput) {
+  beginUsernameLogin(name: $name, transaction: $transaction, proof: $proof)
 }
 `);
 
 const VALIDATE_LOGIN_QGL = gql(`
-mutation validateLogin($body: CredentialRequestResponse!) {
-    validateLogin(body: $body) {
+mutation validateLogin($body: CredentialRequestResponse!, $transaction: String) {
+    validateLogin(body: $body, transaction: $transaction) {
         redirectURL
     }
 }
 `);
 
-async function handleLogin({ beginLogin, validateLogin, onSuccess, onError }: {
-    beginLogin: urql.UseMutationExecute<graphql.BeginLoginMutation, graphql.Exact<{
-        [key: string]: never;
-    }>>,
-    validateLogin: urql.UseMutationExecute<graphql.ValidateLoginMutation, graphql.Exact<{
-        body: any;
-    }>>
+type BeginCeremony = (variables: { transaction: string | null, proof: graphql.ProofOfWorkInput }) => Promise<any>;
+
+async function handleLogin({ proofOfWork, beginLogin, validateLogin, onSuccess, onError, autofill, onCancel }: {
+    proofOfWork: () => Promise<graphql.ProofOfWorkInput>,
+    // beginLogin returns the options of the discoverable or username-first
+    // ceremony
+    beginLogin: BeginCeremony,
+    // autofill waits until the user picks a passkey from the autofill of
+    // inputs with autocomplete "username webauthn"
+    autofill?: boolean,
+    // onCancel is called instead of onError when an autofill ceremony was
+    // aborted by a modal ceremony or expired
+    onCancel?: (aborted: boolean) => void,
+    validateLogin: urql.UseMutationExecute<graphql.ValidateLoginMutation, graphql.ValidateLoginMutationVariables>
     onSuccess?: (redirectURL?: string) => void
     onError?: (errMsg: string) => void
 }) {
@@ -37,15 +52,19 @@ async function handleLogin({ beginLogin, validateLogin, onSuccess, onError }: {
         onError = (_: string) => { };
     }
 
+    // Pending authorization request of an application, if any
+    const transaction = new URLSearchParams(window.location.search).get("tx");
+
     try {
-        const result = await beginLogin({});
-        if (!result || !result.data) {
+        const proof = await proofOfWork();
+        const options = await beginLogin({ transaction, proof });
+        if (!options) {
             onError("cannot request data")
             return;
         }
 
-        const asseResp = await startAuthentication(result.data.beginLogin.publicKey);
-        const verificationResp = await validateLogin({ body: JSON.stringify(asseResp) });
+        const asseResp = await startAuthentication(options.publicKey, autofill);
+        const verificationResp = await validateLogin({ body: JSON.stringify(asseResp), transaction });
 
         if (!verificationResp || !verificationResp.data) {
             onError("cannot request data")
@@ -56,17 +75,68 @@ async function handleLogin({ beginLogin, validateLogin, onSuccess, onError }: {
             onSuccess(verificationResp.data.validateLogin?.redirectURL);
         }
     } catch (error) {
+        const name = (error as Error).name;
+        if (autofill && onCancel && (name === "AbortError" || name === "NotAllowedError")) {
+            onCancel(name === "AbortError");
+            return;
+        }
         onError((error as urql.CombinedError).message);
     }
 }
 
+// useConditionalLogin offers passkeys by autofill while the login page is
+// shown. Modal ceremonies abort it so the returned function restarts it
+// afterwards.
+export function useConditionalLogin({ enabled, onError, onSuccess }: {
+    enabled: boolean
+    onSuccess?: (redirectURL?: string) => void
+    onError?: (errMsg: string) => void
+}): () => void {
+    const [, beginDiscoverableLogin] = urql.useMutation(BEGIN_LOGIN_QGL);
+    const [, validateLogin] = urql.useMutation(VALIDATE_LOGIN_QGL);
+    const proofOfWork = useProofOfWork();
+    const [attempt, setAttempt] = useState(0);
+    const restart = useCallback(() => setAttempt((n) => n + 1), []);
+
+    useEffect(() => {
+        if (!enabled || !window.PublicKeyCredential) return;
+
+        let cancelled = false;
+        const beginLogin: BeginCeremony = async (variables) => (await beginDiscoverableLogin({ ...variables, conditional: true })).data?.beginLogin;
+
+        browserSupportsWebAuthnAutofill().then((supported) => {
+            if (!supported || cancelled) return;
+
+            handleLogin({
+                proofOfWork, beginLogin, validateLogin, onSuccess, autofill: true,
+                onError: (errMsg: string) => {
+                    if (cancelled) return;
+                    if (!!onError) onError(errMsg);
+                    restart();
+                },
+                // Modal ceremonies restart autofill when done while expired
+                // ceremonies are restarted right away
+                onCancel: (aborted: boolean) => {
+                    if (!cancelled && !aborted) restart();
+                },
+            });
+        });
+        return () => {
+            cancelled = true;
+        };
+    }, [enabled, attempt]);
+
+    return restart;
+}
+
 export function LoginSpinner({ onError, onSuccess }: {
     onSuccess?: (redirectURL?: string) => void
     onError?: (errMsg: string) => void
 }) {
     const executed = useRef(false)
-    const [, beginLogin] = urql.useMutation(BEGIN_LOGIN_QGL);
+    const [, beginDiscoverableLogin] = urql.useMutation(BEGIN_LOGIN_QGL);
     const [, validateLogin] = urql.useMutation(VALIDATE_LOGIN_QGL);
+    const proofOfWork = useProofOfWork();
 
     if (!window.PublicKeyCredential) {
         return (
@@ -74,9 +144,11 @@ export function LoginSpinner({ onError, onSuccess }: {
         )
     }
 
+    const beginLogin: BeginCeremony = async (variables) => (await beginDiscoverableLogin(variables)).data?.beginLogin;
+
     useEffect(() => {
         if (!executed.current) {
-            handleLogin({ beginLogin, validateLogin, onSuccess, onError })
+            handleLogin({ proofOfWork, beginLogin, validateLogin, onSuccess, onError })
         }
         return () => {
             executed.current = true;
@@ -90,8 +162,9 @@ export function LoginButton({ onError, onSuccess, children }: {
     onError?: (errMsg: string) => void
     children: string | JSX.Element | JSX.Element[]
 }) {
-    const [{ fetching: fetchingBeginLogin }, beginLogin] = urql.useMutation(BEGIN_LOGIN_QGL);
+    const [{ fetching: fetchingBeginLogin }, beginDiscoverableLogin] = urql.useMutation(BEGIN_LOGIN_QGL);
     const [{ fetching: fetchingValidateLogin }, validateLogin] = urql.useMutation(VALIDATE_LOGIN_QGL);
+    const proofOfWork = useProofOfWork();
     const [spinner, setSpinner] = useState(false);
 
     const onHookedSuccess = (redirectURL?: string) => {
@@ -108,13 +181,58 @@ export function LoginButton({ onError, onSuccess, children }: {
 
     if (spinner) return (<Spinner animation="border" />);
 
+    const beginLogin: BeginCeremony = async (variables) => (await beginDiscoverableLogin(variables)).data?.beginLogin;
+
     const handleSubmit = async (e: React.SyntheticEvent) => {
         e.preventDefault();
         e.stopPropagation();
-        await handleLogin({ beginLogin, validateLogin, onSuccess: onHookedSuccess, onError: onHookedError })
+        setSpinner(true);
+        await handleLogin({ proofOfWork, beginLogin, validateLogin, onSuccess: onHookedSuccess, onError: onHookedError })
     };
 
     return (
         <Button disabled={!window.PublicKeyCredential} onClick={handleSubmit}>{children}</Button>
     )
 }
+
+// UsernameLoginForm asks for the username first so that security keys
+// without resident credentials can be used. Its input offers passkeys by
+// autofill, too.
+export function UsernameLoginForm({ onError, onSuccess }: {
+    onSuccess?: (redirectURL?: string) => void
+    onError?: (errMsg: string) => void
+}) {
+    const { t } = useTranslation();
+    const nameRef = useRef<HTMLInputElement | null>(null)
+    const [, beginUsernameLogin] = urql.useMutation(BEGIN_USERNAME_LOGIN_QGL);
+    const [, validateLogin] = urql.useMutation(VALIDATE_LOGIN_QGL);
+    const proofOfWork = useProofOfWork();
+    const [spinner, setSpinner] = useState(false);
+
+    if (spinner) return (<Spinner animation="border" />);
+
+    const handleSubmit = async (e: React.SyntheticEvent) => {
+        e.preventDefault();
+        e.stopPropagation();
+
+        const name = nameRef.current?.value || "";
+        if (!name) return;
+
+        const beginLogin: BeginCeremony = async (variables) => (await beginUsernameLogin({ name, ...variables })).data?.beginUsernameLogin;
+        setSpinner(true);
+        await handleLogin({
+            proofOfWork, beginLogin, validateLogin,
+            onSuccess: (redirectURL?: string) => { if (!!onSuccess) onSuccess(redirectURL); setSpinner(false); },
+            onError: (errMsg: string) => { if (!!onError) onError(errMsg); setSpinner(false); },
+        })
+    };
+
+    return (
+        <Form onSubmit={handleSubmit}>
+            <Form.Group className="mb-3" controlId="loginName">
+                <Form.Control placeholder={t('Username')} autoComplete="username webauthn" ref={nameRef} />
+            </Form.Group>
+            <Button type="submit" disabled={!window.PublicKeyCredential}>{t('Continue with security key')}</Button>
+        </Form>
+    )
+}
@@ -2,42 +2,81 @@ import * as React from 'react'
 import ReactDOM from 'react-dom/client';
 import 'bootstrap/dist/css/bootstrap.min.css';
 import './login.css'
-import { Alert, Card, Spinner } from 'react-bootstrap';
+import { Alert, Card, Form, Spinner } from 'react-bootstrap';
 import Provider from './client';
 import { useTranslation } from 'react-i18next';
-import { LoginButton, LoginSpinner } from './components/login';
+import { LoginButton, LoginSpinner, UsernameLoginForm, useConditionalLogin } from './components/login';
+import RecoveryForm from './components/Recovery';
 
 const rootDom = document.getElementById('root') as HTMLElement;
 const root = ReactDOM.createRoot(rootDom);
 const startLogin = rootDom.dataset['startLogin'] == "1"
+const recovery = rootDom.dataset['recovery'] == "1"
+// A recovery URL already started a restricted session
+const recovering = rootDom.dataset['recovering'] == "1"
+// Either discoverable, username or both
+const loginMode = rootDom.dataset['loginMode'] || "discoverable"
 
 function CentralCard() {
     const { t } = useTranslation();
     const [error, setError] = React.useState("")
+    const [passkeyAdded, setPasskeyAdded] = React.useState(false)
 
     const onSuccess = (redirectURL?: string) => {
         console.log(redirectURL)
-        if (!redirectURL) {
-            window.location.href = "/";
+        if (!redirectURL || redirectURL == "/") {
+            // The login page verifies and follows targets of forward authentication
+            if (new URLSearchParams(window.location.search).has("rd")) {
+                window.location.reload();
+            } else {
+                window.location.href = "/";
+            }
         } else {
             window.location.href = redirectURL;
         }
     }
 
+    // Passkeys are offered by autofill of the username input unless logins
+    // require the username first
+    const restartAutofill = useConditionalLogin({ enabled: loginMode != "username" && !recovery && !startLogin, onError: setError, onSuccess });
+    const onModalError = (errMsg: string) => {
+        setError(errMsg);
+        restartAutofill();
+    }
+
     const login = (
-        <LoginButton onError={setError} onSuccess={onSuccess}>{t('Login with passkey')}</LoginButton>
+        <>
+            {loginMode == "discoverable" ? <Form.Control className="mb-3" placeholder={t('Username')} autoComplete="username webauthn" /> : ""}
+            {loginMode != "username" ? <LoginButton onError={onModalError} onSuccess={onSuccess}>{t('Login with passkey')}</LoginButton> : ""}
+            {loginMode == "both" ? <p className="mt-3 mb-3">{t('or')}</p> : ""}
+            {loginMode != "discoverable" ? <UsernameLoginForm onError={onModalError} onSuccess={onSuccess} /> : ""}
+        </>
     )
 
     const spinner = (
         <LoginSpinner onError={setError} onSuccess={onSuccess} />
     );
 
+    if (recovery && !passkeyAdded) {
+        return (
+            <Card className="shadow text-center mt-5 login-card m-auto">
+                <Card.Body>
+                    <Card.Title>{t('Account recovery')}</Card.Title>
+                    {error ? <Alert variant="danger">{error}</Alert> : ""}
+                    <RecoveryForm recovering={recovering} onError={setError} onPasskeyAdded={() => { setError(""); setPasskeyAdded(true); }} />
+                </Card.Body>
+            </Card>
+        );
+    }
+
     return (
         <Card className="shadow text-center mt-5 login-card m-auto">
             <Card.Body>
                 <Card.Title>{t('One Gate')}</Card.Title>
                 {error ? <Alert variant="danger">{error}</Alert> : ""}
-                {startLogin && !error ? spinner : login}
+                {passkeyAdded ? <p>{t('Passkey registered. Log in with your new passkey.')}</p> : ""}
+                {startLogin && loginMode != "username" && !error ? spinner : login}
+                {recovery ? "" : <p className="mt-3 mb-0"><a href="/login/recovery">{t('Lost your passkeys?')}</a></p>}
             </Card.Body>
         </Card>
     );
@@ -0,0 +1,46 @@
+import * as urql from 'urql';
+import { gql } from './__generated__/gql';
+import { ProofOfWorkInput } from './__generated__/graphql';
+
+const PROOF_OF_WORK_GQL = gql(`
+query proofOfWork {
+  proofOfWork {
+    challenge
+    difficulty
+  }
+}
+`);
+
+function leadingZeroBits(digest: Uint8Array): number {
+    let n = 0;
+    for (const b of digest) {
+        if (b !== 0) return n + Math.clz32(b) - 24;
+        n += 8;
+    }
+    return n;
+}
+
+// solve searches a solution whose SHA-256 hash of challenge and solution has
+// at least difficulty leading zero bits.
+async function solve(challenge: string, difficulty: number): Promise<string> {
+    const encoder = new TextEncoder();
+    for (let i = 0; ; i++) {
+        const solution = i.toString();
+        const digest = await crypto.subtle.digest("SHA-256", encoder.encode(`${challenge}:${solution}`));
+        if (leadingZeroBits(new Uint8Array(digest)) >= difficulty) return solution;
+    }
+}
+
+// useProofOfWork returns a function fetching and solving a new challenge
+// which must be passed to registrations and logins.
+export function useProofOfWork(): () => Promise<ProofOfWorkInput> {
+    const client = urql.useClient();
+    return async () => {
+        const result = await client.query(PROOF_OF_WORK_GQL, {}, { requestPolicy: 'network-only' }).toPromise();
+        if (result.error) throw result.error;
+        if (!result.data) throw new Error("cannot load challenge");
+
+        const { challenge, difficulty } = result.data.proofOfWork;
+        return { challenge, solution: await solve(challenge, difficulty) };
+    };
+}
@@ -0,0 +1,48 @@
+import * as urql from 'urql';
+import { startAuthentication } from '@simplewebauthn/browser';
+import { gql } from './__generated__/gql';
+
+const BEGIN_REAUTHENTICATION_GQL = gql(`
+mutation beginReauthentication {
+  beginReauthentication
+}
+`);
+
+const COMPLETE_REAUTHENTICATION_GQL = gql(`
+mutation completeReauthentication($body: CredentialRequestResponse!) {
+  completeReauthentication(body: $body)
+}
+`);
+
+// The server rejects sensitive mutations with this code unless the session
+// was authenticated recently
+const REAUTHENTICATION_REQUIRED = "REAUTHENTICATION_REQUIRED";
+
+function isReauthenticationRequired(error?: urql.CombinedError): boolean {
+    return !!error?.graphQLErrors.some((e) => e.extensions?.code === REAUTHENTICATION_REQUIRED);
+}
+
+// useReauthenticated returns a function running a mutation which is retried
+// once after the user re-authenticated with a passkey if the server demands
+// it.
+export function useReauthenticated(): <T extends { error?: urql.CombinedError }>(mutate: () => Promise<T>) => Promise<T> {
+    const client = urql.useClient();
+
+    const reauthenticate = async () => {
+        const begin = await client.mutation(BEGIN_REAUTHENTICATION_GQL, {}).toPromise();
+        if (begin.error) throw begin.error;
+        if (!begin.data) throw new Error("cannot load data");
+
+        const asseResp = await startAuthentication(begin.data.beginReauthentication.publicKey);
+        const complete = await client.mutation(COMPLETE_REAUTHENTICATION_GQL, { body: JSON.stringify(asseResp) }).toPromise();
+        if (complete.error) throw complete.error;
+    };
+
+    return async (mutate) => {
+        const result = await mutate();
+        if (!isReauthenticationRequired(result.error)) return result;
+
+        await reauthenticate();
+        return mutate();
+    };
+}
@@ -8,6 +8,9 @@ import { useOutletContext } from "react-router-dom";
 import { gql } from '../__generated__/gql';
 import * as graphql from '../__generated__/graphql';
 import { ContextType } from "./root";
+import { useProofOfWork } from "../proofOfWork";
+import RecoveryCodes from "../components/RecoveryCodes";
+import { useReauthenticated } from "../reauth";
 
 const CREDENTIALS_GQL = gql(`
 query credentials {
@@ -28,8 +31,8 @@ mutation updateCredential($id: ID!, $description: String) {
 }`);
 
 const INIT_CREDENTIAL_QGL = gql(`
-mutation initCredential {
-    initCredential
+mutation initCredential($proof: ProofOfWorkInput) {
+    initCredential(proof: $proof)
 }
 `)
 
@@ -178,6 +181,8 @@ export default function Credentials() {
     const [{ fetching, data, error }, refetch] = urql.useQuery({ query: CREDENTIALS_GQL });
     const [{ fetching: fetchingInitPasskey }, initCredential] = urql.useMutation(INIT_CREDENTIAL_QGL);
     const [{ fetching: fetchingAddPasskey }, addCredential] = urql.useMutation(ADD_CREDENTIAL_QGL);
+    const proofOfWork = useProofOfWork();
+    const reauthenticated = useReauthenticated();
     const [{ fetching: fetchingRemoveCredential }, removeCredential] = urql.useMutation(REMOVE_CREDENTIAL_QGL);
 
 
@@ -191,7 +196,7 @@ export default function Credentials() {
         e.stopPropagation();
 
         try {
-            const result = await removeCredential({ id: id })
+            const result = await reauthenticated(() => removeCredential({ id: id }))
             if (!!result?.data?.removeCredential) {
                 refetch();
             }
@@ -212,7 +217,7 @@ export default function Credentials() {
         e.stopPropagation();
 
         try {
-            const result = await initCredential({});
+            const result = await reauthenticated(async () => initCredential({ proof: await proofOfWork() }));
 
             if (!result.data || !result.data.initCredential) {
                 setFlashMessage({ msg: t("cannot load data"), type: "danger" });
@@ -255,6 +260,10 @@ export default function Credentials() {
                     ? <Spinner animation="border" />
                     : <Button onClick={handleAddCredential}>Add</Button>}
             </Col></Row>
+            <Row className="mt-4"><Col>
+                <h5>{t("Recovery codes")}</h5>
+                <RecoveryCodes onError={(msg) => setFlashMessage({ msg, type: "danger" })} />
+            </Col></Row>
         </>
     )
 }
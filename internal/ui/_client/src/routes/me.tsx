@@ -5,6 +5,8 @@ import { ContextType } from "./root";
 import { useRef } from "react";
 import { gql } from '../__generated__/gql';
 import * as urql from 'urql';
+import { useReauthenticated } from "../reauth";
+import AccountData from "../components/AccountData";
 
 const UPDATE_GQL = gql(`
 mutation updateMe($name: String, $displayName: String) {
@@ -20,6 +22,7 @@ export default function Me() {
     const nameRef = useRef<HTMLInputElement | null>(null)
     const displayNameRef = useRef<HTMLInputElement | null>(null)
     const [{ fetching }, updateMe] = urql.useMutation(UPDATE_GQL);
+    const reauthenticated = useReauthenticated();
 
 
     if (!me) return (
@@ -33,11 +36,18 @@ export default function Me() {
         e.preventDefault();
         e.stopPropagation();
 
+        // The form is hidden while the mutation is running
+        const variables = {
+            name: nameRef.current?.value,
+            displayName: displayNameRef.current?.value,
+        };
+
         try {
-            await updateMe({
-                name: nameRef.current?.value,
-                displayName: displayNameRef.current?.value,
-            });
+            const result = await reauthenticated(() => updateMe(variables));
+            if (result.error) {
+                setFlashMessage({ msg: result.error.message, type: "danger" });
+                return;
+            }
             await refetchMe();
         } catch (error) {
             setFlashMessage({ msg: (error as urql.CombinedError).message, type: "danger" });
@@ -65,6 +75,10 @@ export default function Me() {
                 </Form.Group>
                 <Button type="submit">{t('Save')}</Button>
             </Form>
+            <Row className="mt-4"><Col>
+                <h5>{t("Your data")}</h5>
+                <AccountData onError={(msg) => setFlashMessage({ msg, type: "danger" })} />
+            </Col></Row>
         </>
     );
 }
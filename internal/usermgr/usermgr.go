@@ -9,17 +9,35 @@ import (
 )
 
 var (
-	defaultMgr *sessionmgr.StorageManager[*model.User]
+	defaultMgr    *sessionmgr.StorageManager[*model.User]
+	recoveringMgr *sessionmgr.StorageManager[*model.User]
 )
 
 func init() {
 	defaultMgr = sessionmgr.NewStorage("user", model.FirstUser)
+	recoveringMgr = sessionmgr.NewStorage("recoveringUser", model.FirstRecoveringUser)
 }
 
 func Middleware(next http.Handler) http.Handler {
-	return defaultMgr.Handler(next)
+	recovering := recoveringMgr.Handler(next)
+	return defaultMgr.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Logged-in users cannot have a restricted session at the same time
+		if FromContext(r.Context()) != nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		recovering.ServeHTTP(w, r)
+	}))
 }
 
+// FromContext returns the logged-in user. Users of restricted sessions are
+// not logged in.
 func FromContext(ctx context.Context) *model.User {
 	return defaultMgr.FromContext(ctx)
 }
+
+// RecoveringFromContext returns the user of a restricted session which may
+// only register a new credential.
+func RecoveringFromContext(ctx context.Context) *model.User {
+	return recoveringMgr.FromContext(ctx)
+}
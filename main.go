@@ -4,7 +4,11 @@ import (
 	"github.com/seb-schulz/onegate/cmd"
 	_ "github.com/seb-schulz/onegate/cmd/client"
 	_ "github.com/seb-schulz/onegate/cmd/generate"
+	_ "github.com/seb-schulz/onegate/cmd/group"
+	_ "github.com/seb-schulz/onegate/cmd/resource"
+	_ "github.com/seb-schulz/onegate/cmd/role"
 	_ "github.com/seb-schulz/onegate/cmd/session"
+	_ "github.com/seb-schulz/onegate/cmd/sp"
 	_ "github.com/seb-schulz/onegate/cmd/user"
 )
 